@@ -0,0 +1,199 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+var (
+	ErrExportNotFound = errors.New("requested account export is not found")
+	exportBucket      = []byte("export")
+)
+
+// AccountExport is an archive of the data of a user, built in the
+// background. Only the latest export of a user is kept.
+type AccountExport struct {
+	ID         uuid.UUID  `json:"id"`
+	User       string     `json:"user"`
+	Status     string     `json:"status"`
+	Size       int        `json:"size,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+func exportArchiveKey(username string) []byte {
+	return []byte(username + "\x00archive")
+}
+
+// PutAccountExport stores the export of the user, replacing the previous
+// one. The archive is stored along with it when it is not nil.
+func (db *DB) PutAccountExport(export *AccountExport, archive []byte) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(exportBucket)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(export)
+		if err != nil {
+			return err
+		}
+		if err := bucket.Delete(exportArchiveKey(export.User)); err != nil {
+			return err
+		}
+		if archive != nil {
+			if err := bucket.Put(exportArchiveKey(export.User), archive); err != nil {
+				return err
+			}
+		}
+		return bucket.Put([]byte(export.User), data)
+	})
+}
+
+func (db *DB) GetAccountExport(username string) (*AccountExport, error) {
+	export := &AccountExport{}
+	if err := db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(exportBucket)
+		if bucket == nil {
+			return ErrExportNotFound
+		}
+		b := bucket.Get([]byte(username))
+		if b == nil {
+			return ErrExportNotFound
+		}
+		return json.Unmarshal(b, export)
+	}); err != nil {
+		return nil, err
+	}
+	return export, nil
+}
+
+// GetAccountExportArchive returns the archive of the latest export of the
+// user.
+func (db *DB) GetAccountExportArchive(username string) ([]byte, error) {
+	var archive []byte
+	err := db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(exportBucket)
+		if bucket == nil {
+			return ErrExportNotFound
+		}
+		b := bucket.Get(exportArchiveKey(username))
+		if b == nil {
+			return ErrExportNotFound
+		}
+		archive = append([]byte(nil), b...)
+		return nil
+	})
+	return archive, err
+}
+
+// deleteKeysTx deletes the keys of the bucket for which owned returns true.
+// Keys are collected first, as deleting while iterating skips entries.
+func deleteKeysTx(tx *bolt.Tx, name []byte, owned func(k []byte, v []byte) (bool, error)) error {
+	bucket := tx.Bucket(name)
+	if bucket == nil {
+		return nil
+	}
+	var keys [][]byte
+	err := bucket.ForEach(func(k, v []byte) error {
+		ok, err := owned(k, v)
+		if ok {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := bucket.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ownedBy matches the JSON records whose User is the user.
+func ownedBy(username string) func(k []byte, v []byte) (bool, error) {
+	return func(k, v []byte) (bool, error) {
+		var record struct {
+			User string `json:"user"`
+		}
+		if v == nil {
+			return false, nil
+		}
+		if err := json.Unmarshal(v, &record); err != nil {
+			return false, err
+		}
+		return record.User == username, nil
+	}
+}
+
+func hasPrefix(prefix []byte) func(k []byte, v []byte) (bool, error) {
+	return func(k, v []byte) (bool, error) {
+		return bytes.HasPrefix(k, prefix), nil
+	}
+}
+
+func isKey(key []byte) func(k []byte, v []byte) (bool, error) {
+	return func(k, v []byte) (bool, error) {
+		return bytes.Equal(k, key), nil
+	}
+}
+
+// deleteNestedBucketTx deletes the bucket of the user within the root
+// bucket.
+func deleteNestedBucketTx(tx *bolt.Tx, root []byte, username string) error {
+	bucket := tx.Bucket(root)
+	if bucket == nil || bucket.Bucket([]byte(username)) == nil {
+		return nil
+	}
+	return bucket.DeleteBucket([]byte(username))
+}
+
+// DeleteUser removes the user and every record owned by them, in all
+// buckets, within a single transaction.
+func (db *DB) DeleteUser(username string) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		users := tx.Bucket(userBucket)
+		if users == nil || users.Get([]byte(username)) == nil {
+			return ErrUserNotFound
+		}
+		owner := []byte(username + "\x00")
+		for _, step := range []struct {
+			bucket []byte
+			owned  func(k []byte, v []byte) (bool, error)
+		}{
+			{taskBucket, ownedBy(username)},
+			{timeEntryBucket, ownedBy(username)},
+			{runningTimerBucket, isKey([]byte(username))},
+			{templateBucket, ownedBy(username)},
+			{filterBucket, ownedBy(username)},
+			{fieldBucket, hasPrefix(owner)},
+			{importBucket, hasPrefix(owner)},
+			{feedBucket, ownedBy(username)},
+			{exportBucket, isKey([]byte(username))},
+			{exportBucket, isKey(exportArchiveKey(username))},
+			{userBucket, isKey([]byte(username))},
+		} {
+			if err := deleteKeysTx(tx, step.bucket, step.owned); err != nil {
+				return err
+			}
+		}
+		for _, root := range [][]byte{userTaskBucket, statsBucket, syncBucket, auditBucket} {
+			if err := deleteNestedBucketTx(tx, root, username); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
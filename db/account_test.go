@@ -0,0 +1,66 @@
+package db
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestDeleteUser(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+
+	for _, username := range []string{"alice", "bobby"} {
+		require.NoError(t, db.CreateUser(&User{Username: username, Password: "secret", Email: username + "@example.com"}))
+		task := &Task{ID: uuid.New(), Title: "Pack bag", User: username, Project: "trip", Tags: []string{"travel"}, CreatedAt: now}
+		require.NoError(t, db.CreateTasks([]*Task{task}))
+		_, _, err := db.GetSyncEntries(username, "trip", 0)
+		require.NoError(t, err)
+		require.NoError(t, db.StartTimer(&TimeEntry{ID: uuid.New(), TaskID: task.ID, User: username, Start: now}))
+		require.NoError(t, db.PutTemplate(&Template{ID: uuid.New(), User: username, Name: "Trip"}))
+		require.NoError(t, db.PutFilter(&SavedFilter{ID: uuid.New(), User: username, Name: "Travel"}))
+		require.NoError(t, db.CreateFieldDefinition(&FieldDefinition{User: username, Project: "trip", Key: "km", Name: "Distance", Type: FieldNumber}))
+		require.NoError(t, db.InTransaction(username, func(tx *TaskTx) error {
+			return tx.SetImportedTaskID("trello", "c1", task.ID)
+		}))
+		require.NoError(t, db.AppendAuditEvent(&AuditEvent{ID: uuid.New(), Time: now, Actor: username, Action: "task.create"}))
+		require.NoError(t, db.PutFeedToken(&FeedToken{User: username, Hash: username + "-hash", CreatedAt: now}, nil))
+		require.NoError(t, db.PutAccountExport(&AccountExport{ID: uuid.New(), User: username, Status: ExportReady}, []byte("PK")))
+	}
+
+	require.NoError(t, db.DeleteUser("alice"))
+	require.ErrorIs(t, db.DeleteUser("alice"), ErrUserNotFound)
+
+	// Nothing stored may mention the deleted user, while every bucket
+	// still holds the records of the other one.
+	found := map[string]bool{}
+	var walk func(path string, b *bolt.Bucket) error
+	walk = func(path string, b *bolt.Bucket) error {
+		return b.ForEach(func(k, v []byte) error {
+			require.NotContains(t, string(k), "alice", path)
+			require.NotContains(t, string(v), "alice", path)
+			if bytes.Contains(k, []byte("bobby")) || bytes.Contains(v, []byte("bobby")) {
+				found[path] = true
+			}
+			if v == nil {
+				return walk(path+"/"+string(k), b.Bucket(k))
+			}
+			return nil
+		})
+	}
+	require.NoError(t, db.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return walk(string(name), b)
+		})
+	}))
+	for _, name := range []string{"task", "time_entry", "running_timer", "template", "filter", "field", "import", "feed", "export", "user", "stats", "sync", "audit"} {
+		require.True(t, found[name], name)
+	}
+
+	_, err := db.GetUser("bobby")
+	require.NoError(t, err)
+}
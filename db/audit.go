@@ -0,0 +1,150 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	auditBucket       = []byte("audit")
+	errAuditMalformed = errors.New("audit event is malformed")
+)
+
+// auditKeyLayout makes keys sort in chronological order within a bucket.
+const auditKeyLayout = "20060102T150405.000000000"
+
+// AuditEvent records a single action performed by a user. Events are only
+// ever appended, never changed or removed.
+type AuditEvent struct {
+	ID        uuid.UUID    `json:"id"`
+	Time      time.Time    `json:"time"`
+	Actor     string       `json:"actor"`
+	Action    string       `json:"action"`
+	Target    string       `json:"target,omitempty"`
+	Before    *TaskSummary `json:"before,omitempty"`
+	After     *TaskSummary `json:"after,omitempty"`
+	IP        string       `json:"ip,omitempty"`
+	UserAgent string       `json:"userAgent,omitempty"`
+	RequestID string       `json:"requestId,omitempty"`
+}
+
+// TaskSummary is the part of a task kept in the audit trail.
+type TaskSummary struct {
+	Title      string     `json:"title"`
+	User       string     `json:"user"`
+	Project    string     `json:"project,omitempty"`
+	Status     string     `json:"status,omitempty"`
+	Priority   string     `json:"priority,omitempty"`
+	Tags       []string   `json:"tags,omitempty"`
+	Due        *time.Time `json:"due,omitempty"`
+	DeferUntil *time.Time `json:"deferUntil,omitempty"`
+	Someday    bool       `json:"someday,omitempty"`
+	Checklist  string     `json:"checklist,omitempty"`
+}
+
+// Summary returns the audit summary of the task, or nil for a nil task.
+func (t *Task) Summary() *TaskSummary {
+	if t == nil {
+		return nil
+	}
+	s := &TaskSummary{
+		Title:      t.Title,
+		User:       t.User,
+		Project:    t.Project,
+		Status:     t.Status,
+		Priority:   t.Priority,
+		Tags:       append([]string(nil), t.Tags...),
+		Due:        t.Due,
+		DeferUntil: t.DeferUntil,
+		Someday:    t.Someday,
+	}
+	if len(t.Checklist) > 0 {
+		s.Checklist = t.ChecklistProgressString()
+	}
+	return s
+}
+
+func auditKey(e *AuditEvent) []byte {
+	return []byte(e.Time.UTC().Format(auditKeyLayout) + "/" + e.ID.String())
+}
+
+// AppendAuditEvent stores the event in the bucket of its actor.
+func (db *DB) AppendAuditEvent(e *AuditEvent) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return appendAuditEventTx(tx, e)
+	})
+}
+
+func appendAuditEventTx(tx *bolt.Tx, e *AuditEvent) error {
+	if e.Actor == "" || e.Action == "" {
+		return errAuditMalformed
+	}
+	root, err := tx.CreateBucketIfNotExists(auditBucket)
+	if err != nil {
+		return err
+	}
+	bucket, err := root.CreateBucketIfNotExists([]byte(e.Actor))
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(auditKey(e), data)
+}
+
+// ForEachAuditEvent calls fn in chronological order for the events of the
+// user within [from, to). A zero from or to leaves that side unbounded.
+func (db *DB) ForEachAuditEvent(username string, from time.Time, to time.Time, fn func(*AuditEvent) error) error {
+	return db.db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(auditBucket)
+		if root == nil {
+			return nil
+		}
+		bucket := root.Bucket([]byte(username))
+		if bucket == nil {
+			return nil
+		}
+
+		var end []byte
+		if !to.IsZero() {
+			end = []byte(to.UTC().Format(auditKeyLayout))
+		}
+		c := bucket.Cursor()
+		k, v := c.First()
+		if !from.IsZero() {
+			k, v = c.Seek([]byte(from.UTC().Format(auditKeyLayout)))
+		}
+		for ; k != nil; k, v = c.Next() {
+			if end != nil && bytes.Compare(k, end) >= 0 {
+				break
+			}
+			var e AuditEvent
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			if err := fn(&e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetAuditEvents returns the events of the user within [from, to).
+func (db *DB) GetAuditEvents(username string, from time.Time, to time.Time) ([]AuditEvent, error) {
+	events := []AuditEvent{}
+	err := db.ForEachAuditEvent(username, from, to, func(e *AuditEvent) error {
+		events = append(events, *e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditEvents(t *testing.T) {
+	db := newTestDB(t)
+	base := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Appended out of order to check that events come back chronologically.
+	for _, offset := range []int{2, 0, 1} {
+		require.NoError(t, db.AppendAuditEvent(&AuditEvent{
+			ID:     uuid.New(),
+			Time:   base.Add(time.Duration(offset) * time.Hour),
+			Actor:  "alice",
+			Action: "task.update",
+		}))
+	}
+	require.NoError(t, db.AppendAuditEvent(&AuditEvent{ID: uuid.New(), Time: base, Actor: "bob", Action: "user.login"}))
+	require.ErrorIs(t, db.AppendAuditEvent(&AuditEvent{ID: uuid.New(), Time: base, Action: "user.login"}), errAuditMalformed)
+
+	times := func(events []AuditEvent) []time.Time {
+		result := []time.Time{}
+		for _, e := range events {
+			result = append(result, e.Time.UTC())
+		}
+		return result
+	}
+
+	t.Run("per user in order", func(t *testing.T) {
+		events, err := db.GetAuditEvents("alice", time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Equal(t, []time.Time{base, base.Add(time.Hour), base.Add(2 * time.Hour)}, times(events))
+
+		events, err = db.GetAuditEvents("carol", time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Empty(t, events)
+	})
+	t.Run("time range is half open", func(t *testing.T) {
+		events, err := db.GetAuditEvents("alice", base.Add(time.Hour), base.Add(2*time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, []time.Time{base.Add(time.Hour)}, times(events))
+	})
+}
+
+func TestAuditEventInTransaction(t *testing.T) {
+	db := newTestDB(t)
+	task := &Task{ID: uuid.New(), Title: "report", User: "alice"}
+	require.NoError(t, db.CreateTasks([]*Task{task}))
+	update := func(e *AuditEvent) error {
+		return db.InTaskTransaction(task.ID, func(tx *TaskTx) error {
+			if _, err := tx.UpdateTask(task.ID, func(t *Task) error {
+				t.Title = "report, final"
+				return nil
+			}); err != nil {
+				return err
+			}
+			return tx.AppendAuditEvent(e)
+		})
+	}
+
+	// The change is not committed without its event.
+	require.ErrorIs(t, update(&AuditEvent{ID: uuid.New(), Time: time.Now(), Action: "task.update"}), errAuditMalformed)
+	stored, err := db.GetTask(task.ID.String())
+	require.NoError(t, err)
+	require.Equal(t, "report", stored.Title)
+
+	require.NoError(t, update(&AuditEvent{ID: uuid.New(), Time: time.Now(), Actor: "alice", Action: "task.update"}))
+	stored, err = db.GetTask(task.ID.String())
+	require.NoError(t, err)
+	require.Equal(t, "report, final", stored.Title)
+	events, err := db.GetAuditEvents("alice", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	require.ErrorIs(t, db.InTaskTransaction(uuid.New(), func(tx *TaskTx) error { return nil }), ErrNoRows)
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+// TaskTx gives access to the tasks of a single user within one bolt
+// transaction, so several changes can be committed or rolled back together.
+type TaskTx struct {
+	tx       *bolt.Tx
+	username string
+}
+
+// InTransaction runs fn in a read-write transaction scoped to the tasks of
+// the user. If fn returns an error every change made through the TaskTx is
+// rolled back.
+func (db *DB) InTransaction(username string, fn func(tx *TaskTx) error) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		return fn(&TaskTx{tx: tx, username: username})
+	})
+}
+
+// InTaskTransaction runs fn like InTransaction, scoped to the owner of the
+// task with the id. It returns ErrNoRows if there is no such task.
+func (db *DB) InTaskTransaction(id uuid.UUID, fn func(tx *TaskTx) error) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		task, err := getTaskTx(tx, id)
+		if err != nil {
+			return err
+		}
+		return fn(&TaskTx{tx: tx, username: task.User})
+	})
+}
+
+func (t *TaskTx) GetTask(id uuid.UUID) (*Task, error) {
+	task, err := getTaskTx(t.tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if task.User != t.username {
+		return nil, ErrNoRows
+	}
+	return task, nil
+}
+
+func (t *TaskTx) UpdateTask(id uuid.UUID, fn func(task *Task) error) (*Task, error) {
+	return updateTaskTx(t.tx, id, func(task *Task) error {
+		if task.User != t.username {
+			return ErrNoRows
+		}
+		return fn(task)
+	})
+}
+
+func (t *TaskTx) MoveTask(id uuid.UUID, after uuid.UUID, before uuid.UUID, update func(*Task) error) (*Task, error) {
+	return moveTaskTx(t.tx, id, t.username, after, before, update)
+}
+
+func (t *TaskTx) DeleteTask(id uuid.UUID) error {
+	if _, err := t.GetTask(id); err != nil {
+		return err
+	}
+	return deleteTaskTx(t.tx, id)
+}
+
+func (t *TaskTx) CreateTask(task *Task) error {
+	if task.User != t.username {
+		return ErrNoRows
+	}
+	return putNewTask(t.tx, task)
+}
+
+// AppendAuditEvent stores the event along with the changes of the
+// transaction, so that neither is committed without the other.
+func (t *TaskTx) AppendAuditEvent(e *AuditEvent) error {
+	return appendAuditEventTx(t.tx, e)
+}
+
+// GetFieldDefinitions returns the custom field definitions of a project of
+// the user.
+func (t *TaskTx) GetFieldDefinitions(project string) ([]FieldDefinition, error) {
+	return fieldDefinitionsTx(t.tx, t.username, project)
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrChecklistItemNotFound = errors.New("requested checklist item is not found")
+	ErrInvalidPosition       = errors.New("checklist position is out of range")
+)
+
+type ChecklistItem struct {
+	ID        uuid.UUID `json:"id"`
+	Text      string    `json:"text" validate:"required"`
+	Done      bool      `json:"done"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ChecklistProgress returns the number of checked items and the total
+// number of items in the checklist of the task.
+func (t *Task) ChecklistProgress() (done int, total int) {
+	for _, item := range t.Checklist {
+		if item.Done {
+			done++
+		}
+	}
+	return done, len(t.Checklist)
+}
+
+// ChecklistProgressString formats the checklist progress as "done/total", or
+// returns an empty string if the task has no checklist.
+func (t *Task) ChecklistProgressString() string {
+	done, total := t.ChecklistProgress()
+	if total == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d/%d", done, total)
+}
+
+func (t *Task) checklistIndex(itemID uuid.UUID) int {
+	for i, item := range t.Checklist {
+		if item.ID == itemID {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddChecklistItem inserts the item at the given position. A negative
+// position appends the item to the end of the checklist.
+func (t *Task) AddChecklistItem(item ChecklistItem, position int) error {
+	if position < 0 {
+		position = len(t.Checklist)
+	}
+	if position > len(t.Checklist) {
+		return ErrInvalidPosition
+	}
+	t.Checklist = append(t.Checklist, ChecklistItem{})
+	copy(t.Checklist[position+1:], t.Checklist[position:])
+	t.Checklist[position] = item
+	return nil
+}
+
+func (t *Task) MoveChecklistItem(itemID uuid.UUID, position int) error {
+	i := t.checklistIndex(itemID)
+	if i < 0 {
+		return ErrChecklistItemNotFound
+	}
+	if position < 0 || position >= len(t.Checklist) {
+		return ErrInvalidPosition
+	}
+	item := t.Checklist[i]
+	t.Checklist = append(t.Checklist[:i], t.Checklist[i+1:]...)
+	return t.AddChecklistItem(item, position)
+}
+
+func (t *Task) SetChecklistItemDone(itemID uuid.UUID, done bool) error {
+	i := t.checklistIndex(itemID)
+	if i < 0 {
+		return ErrChecklistItemNotFound
+	}
+	t.Checklist[i].Done = done
+	return nil
+}
+
+func (t *Task) RemoveChecklistItem(itemID uuid.UUID) (ChecklistItem, error) {
+	i := t.checklistIndex(itemID)
+	if i < 0 {
+		return ChecklistItem{}, ErrChecklistItemNotFound
+	}
+	item := t.Checklist[i]
+	t.Checklist = append(t.Checklist[:i], t.Checklist[i+1:]...)
+	if len(t.Checklist) == 0 {
+		t.Checklist = nil
+	}
+	return item, nil
+}
+
+// ConvertChecklistItem removes the item from the checklist of the task and
+// stores the task built from it. build receives the task as it was before
+// and may refuse the conversion. It returns the changed task and the new
+// one.
+func (t *TaskTx) ConvertChecklistItem(taskID uuid.UUID, itemID uuid.UUID, build func(parent *Task, item ChecklistItem) (*Task, error)) (*Task, *Task, error) {
+	var newTask *Task
+	parent, err := t.UpdateTask(taskID, func(task *Task) error {
+		i := task.checklistIndex(itemID)
+		if i < 0 {
+			return ErrChecklistItemNotFound
+		}
+		var err error
+		newTask, err = build(task, task.Checklist[i])
+		if err != nil {
+			return err
+		}
+		if _, err := task.RemoveChecklistItem(itemID); err != nil {
+			return err
+		}
+		task.UpdatedAt = newTask.CreatedAt
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := t.CreateTask(newTask); err != nil {
+		return nil, nil, err
+	}
+	return parent, newTask, nil
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func newChecklistTask(texts ...string) *Task {
+	t := &Task{}
+	for _, text := range texts {
+		t.Checklist = append(t.Checklist, ChecklistItem{ID: uuid.New(), Text: text})
+	}
+	return t
+}
+
+func checklistTexts(t *Task) []string {
+	texts := []string{}
+	for _, item := range t.Checklist {
+		texts = append(texts, item.Text)
+	}
+	return texts
+}
+
+func TestChecklist(t *testing.T) {
+	t.Run("add appends and inserts", func(t *testing.T) {
+		task := newChecklistTask("a", "c")
+		require.NoError(t, task.AddChecklistItem(ChecklistItem{ID: uuid.New(), Text: "d"}, -1))
+		require.NoError(t, task.AddChecklistItem(ChecklistItem{ID: uuid.New(), Text: "b"}, 1))
+		require.Equal(t, []string{"a", "b", "c", "d"}, checklistTexts(task))
+		require.ErrorIs(t, task.AddChecklistItem(ChecklistItem{ID: uuid.New()}, 10), ErrInvalidPosition)
+	})
+	t.Run("move reorders", func(t *testing.T) {
+		task := newChecklistTask("a", "b", "c")
+		require.NoError(t, task.MoveChecklistItem(task.Checklist[0].ID, 2))
+		require.Equal(t, []string{"b", "c", "a"}, checklistTexts(task))
+		require.NoError(t, task.MoveChecklistItem(task.Checklist[2].ID, 0))
+		require.Equal(t, []string{"a", "b", "c"}, checklistTexts(task))
+		require.ErrorIs(t, task.MoveChecklistItem(task.Checklist[0].ID, 3), ErrInvalidPosition)
+		require.ErrorIs(t, task.MoveChecklistItem(uuid.New(), 0), ErrChecklistItemNotFound)
+	})
+	t.Run("progress", func(t *testing.T) {
+		task := newChecklistTask("a", "b", "c")
+		require.Equal(t, "", (&Task{}).ChecklistProgressString())
+		require.NoError(t, task.SetChecklistItemDone(task.Checklist[1].ID, true))
+		require.Equal(t, "1/3", task.ChecklistProgressString())
+		_, err := task.RemoveChecklistItem(task.Checklist[0].ID)
+		require.NoError(t, err)
+		require.Equal(t, "1/2", task.ChecklistProgressString())
+	})
+}
@@ -28,21 +28,41 @@ func NewSQL(filepath string, l *zerolog.Logger) (*DB, error) {
 		return nil, err
 	}
 	sqlDB.db = db
+	if err := db.Update(initTx); err != nil {
+		db.Close()
+		return nil, err
+	}
 	l.Info().Msg("db connection is successful.")
 	return sqlDB, nil
 
 }
 
+// initTx builds the indexes kept alongside the tasks for users whose tasks
+// were stored by a version without them, so that reads never write.
+func initTx(tx *bolt.Tx) error {
+	if err := initTaskIndexTx(tx); err != nil {
+		return err
+	}
+	users, err := taskUsersTx(tx)
+	if err != nil {
+		return err
+	}
+	for _, username := range users {
+		if _, err := userStatsTx(tx, username); err != nil {
+			return err
+		}
+		if _, err := userSyncTx(tx, username); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (sql *DB) Close() {
 	sql.db.Close()
 }
 
 func checkDuplicate(c *bolt.Cursor, id []byte) bool {
-	for k, v := c.First(); k != nil; k, v = c.Next() {
-		if bytes.Equal(id, v) {
-			return true
-		}
-	}
-
-	return false
+	k, _ := c.Seek(id)
+	return k != nil && bytes.Equal(id, k)
 }
@@ -0,0 +1,17 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDB(t *testing.T) *DB {
+	l := zerolog.Nop()
+	db, err := NewSQL(filepath.Join(t.TempDir(), "test.db"), &l)
+	require.NoError(t, err)
+	t.Cleanup(db.Close)
+	return db
+}
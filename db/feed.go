@@ -0,0 +1,118 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	ErrFeedNotFound = errors.New("requested feed is not found")
+	feedBucket      = []byte("feed")
+)
+
+// FeedToken grants access to the calendar feed of a user. Only the hash of
+// the token is stored. It is kept under the hash, to find the user of a
+// token, and under the user, to replace or revoke it.
+type FeedToken struct {
+	User      string    `json:"user"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func feedUserKey(username string) []byte {
+	return []byte("user\x00" + username)
+}
+
+func feedHashKey(hash string) []byte {
+	return []byte("hash\x00" + hash)
+}
+
+func deleteFeedTokenTx(bucket *bolt.Bucket, username string) error {
+	b := bucket.Get(feedUserKey(username))
+	if b == nil {
+		return ErrFeedNotFound
+	}
+	var old FeedToken
+	if err := json.Unmarshal(b, &old); err != nil {
+		return err
+	}
+	if err := bucket.Delete(feedHashKey(old.Hash)); err != nil {
+		return err
+	}
+	return bucket.Delete(feedUserKey(username))
+}
+
+// PutFeedToken stores the token of the user in place of the previous one,
+// and the audit event e unless it is nil, in one transaction.
+func (db *DB) PutFeedToken(token *FeedToken, e *AuditEvent) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(feedBucket)
+		if err != nil {
+			return err
+		}
+		if err := deleteFeedTokenTx(bucket, token.User); err != nil && !errors.Is(err, ErrFeedNotFound) {
+			return err
+		}
+		data, err := json.Marshal(token)
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(feedHashKey(token.Hash), data); err != nil {
+			return err
+		}
+		if err := bucket.Put(feedUserKey(token.User), data); err != nil {
+			return err
+		}
+		if e == nil {
+			return nil
+		}
+		return appendAuditEventTx(tx, e)
+	})
+}
+
+func (db *DB) getFeedToken(key []byte) (*FeedToken, error) {
+	token := &FeedToken{}
+	if err := db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(feedBucket)
+		if bucket == nil {
+			return ErrFeedNotFound
+		}
+		b := bucket.Get(key)
+		if b == nil {
+			return ErrFeedNotFound
+		}
+		return json.Unmarshal(b, token)
+	}); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (db *DB) GetFeedToken(username string) (*FeedToken, error) {
+	return db.getFeedToken(feedUserKey(username))
+}
+
+// GetFeedTokenByHash returns the token stored under the hash.
+func (db *DB) GetFeedTokenByHash(hash string) (*FeedToken, error) {
+	return db.getFeedToken(feedHashKey(hash))
+}
+
+// DeleteFeedToken revokes the token of the user, and stores the audit event
+// e unless it is nil, in one transaction.
+func (db *DB) DeleteFeedToken(username string, e *AuditEvent) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(feedBucket)
+		if bucket == nil {
+			return ErrFeedNotFound
+		}
+		if err := deleteFeedTokenTx(bucket, username); err != nil {
+			return err
+		}
+		if e == nil {
+			return nil
+		}
+		return appendAuditEventTx(tx, e)
+	})
+}
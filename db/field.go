@@ -0,0 +1,176 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	FieldText     = "text"
+	FieldNumber   = "number"
+	FieldDate     = "date"
+	FieldSelect   = "select"
+	FieldCheckbox = "checkbox"
+)
+
+var (
+	ErrFieldNotFound   = errors.New("requested custom field is not found")
+	ErrFieldExists     = errors.New("custom field already exists")
+	ErrFieldValuesLost = errors.New("custom field values would be lost")
+	fieldBucket        = []byte("field")
+)
+
+// FieldDefinition describes a typed custom field of the tasks in a project.
+// Values are stored on the tasks under Key, which never changes.
+type FieldDefinition struct {
+	User      string    `json:"user"`
+	Project   string    `json:"project"`
+	Key       string    `json:"key" validate:"required,max=40"`
+	Name      string    `json:"name" validate:"required"`
+	Type      string    `json:"type" validate:"required,oneof=text number date select checkbox"`
+	Options   []string  `json:"options,omitempty" validate:"required_if=Type select,dive,required"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func fieldPrefix(username string, project string) []byte {
+	return []byte(username + "\x00" + project + "\x00")
+}
+
+func fieldKey(username string, project string, key string) []byte {
+	return append(fieldPrefix(username, project), key...)
+}
+
+func (db *DB) CreateFieldDefinition(def *FieldDefinition) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(fieldBucket)
+		if err != nil {
+			return err
+		}
+		key := fieldKey(def.User, def.Project, def.Key)
+		if bucket.Get(key) != nil {
+			return ErrFieldExists
+		}
+		data, err := json.Marshal(def)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key, data)
+	})
+}
+
+// GetFieldDefinitions returns the definitions of the project ordered by key.
+func (db *DB) GetFieldDefinitions(username string, project string) ([]FieldDefinition, error) {
+	var defs []FieldDefinition
+	err := db.db.View(func(tx *bolt.Tx) error {
+		var err error
+		defs, err = fieldDefinitionsTx(tx, username, project)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return defs, nil
+}
+
+// GetAllFieldDefinitions returns the definitions of every project of the
+// user, ordered by project and key.
+func (db *DB) GetAllFieldDefinitions(username string) ([]FieldDefinition, error) {
+	var defs []FieldDefinition
+	err := db.db.View(func(tx *bolt.Tx) error {
+		var err error
+		defs, err = fieldDefinitionsWithPrefixTx(tx, []byte(username+"\x00"))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return defs, nil
+}
+
+func fieldDefinitionsTx(tx *bolt.Tx, username string, project string) ([]FieldDefinition, error) {
+	return fieldDefinitionsWithPrefixTx(tx, fieldPrefix(username, project))
+}
+
+func fieldDefinitionsWithPrefixTx(tx *bolt.Tx, prefix []byte) ([]FieldDefinition, error) {
+	defs := []FieldDefinition{}
+	bucket := tx.Bucket(fieldBucket)
+	if bucket == nil {
+		return defs, nil
+	}
+	c := bucket.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		var def FieldDefinition
+		if err := json.Unmarshal(v, &def); err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+	return defs, nil
+}
+
+// ReplaceFieldDefinition stores def under the key, or deletes the definition
+// when def is nil, and applies migrate to every task of the project with a
+// value for the field, all in one transaction. migrate is given the
+// transaction of the user to record the change along with it, and the task
+// is stored after it returns. It returns the number of
+// tasks whose value migrate removed; unless force is set, removing any fails
+// with ErrFieldValuesLost and changes nothing.
+func (db *DB) ReplaceFieldDefinition(username string, project string, key string, def *FieldDefinition, force bool, migrate func(tx *TaskTx, t *Task) error) (int, error) {
+	lost := 0
+	err := db.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(fieldBucket)
+		if bucket == nil {
+			return ErrFieldNotFound
+		}
+		k := fieldKey(username, project, key)
+		if bucket.Get(k) == nil {
+			return ErrFieldNotFound
+		}
+		if def == nil {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		} else {
+			data, err := json.Marshal(def)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(k, data); err != nil {
+				return err
+			}
+		}
+
+		tasks, err := userTasksTx(tx, username)
+		if err != nil {
+			return err
+		}
+		taskTx := &TaskTx{tx: tx, username: username}
+		for i := range tasks {
+			t := &tasks[i]
+			if t.Project != project {
+				continue
+			}
+			if _, ok := t.Fields[key]; !ok {
+				continue
+			}
+			if err := migrate(taskTx, t); err != nil {
+				return err
+			}
+			if _, ok := t.Fields[key]; !ok {
+				lost++
+			}
+			if err := putTaskTx(tx, t); err != nil {
+				return err
+			}
+		}
+		if lost > 0 && !force {
+			return ErrFieldValuesLost
+		}
+		return nil
+	})
+	return lost, err
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplaceFieldDefinition(t *testing.T) {
+	db := newTestDB(t)
+	require.NoError(t, db.CreateFieldDefinition(&FieldDefinition{User: "alice", Project: "home", Key: "room", Name: "Room", Type: FieldText}))
+	a := &Task{ID: uuid.New(), Title: "paint", User: "alice", Project: "home", Fields: map[string]interface{}{"room": "kitchen"}}
+	b := &Task{ID: uuid.New(), Title: "clean", User: "alice", Project: "home"}
+	require.NoError(t, db.CreateTasks([]*Task{a, b}))
+	drop := func(tx *TaskTx, t *Task) error {
+		delete(t.Fields, "room")
+		return nil
+	}
+
+	// A value stored before the change is counted in its transaction.
+	_, err := db.UpdateTaskFunc(b.ID, func(t *Task) error {
+		t.Fields = map[string]interface{}{"room": "hall"}
+		return nil
+	})
+	require.NoError(t, err)
+	lost, err := db.ReplaceFieldDefinition("alice", "home", "room", nil, false, drop)
+	require.ErrorIs(t, err, ErrFieldValuesLost)
+	require.Equal(t, 2, lost)
+	defs, err := db.GetFieldDefinitions("alice", "home")
+	require.NoError(t, err)
+	require.Len(t, defs, 1)
+	task, err := db.GetTask(a.ID.String())
+	require.NoError(t, err)
+	require.Equal(t, "kitchen", task.Fields["room"])
+
+	lost, err = db.ReplaceFieldDefinition("alice", "home", "room", nil, true, drop)
+	require.NoError(t, err)
+	require.Equal(t, 2, lost)
+	defs, err = db.GetFieldDefinitions("alice", "home")
+	require.NoError(t, err)
+	require.Empty(t, defs)
+	task, err = db.GetTask(b.ID.String())
+	require.NoError(t, err)
+	require.NotContains(t, task.Fields, "room")
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	ErrFilterNotFound = errors.New("requested filter is not found")
+	filterBucket      = []byte("filter")
+)
+
+// SavedFilter is a named filter expression of a user, with an optional sort
+// specification for its results.
+type SavedFilter struct {
+	ID        uuid.UUID `json:"id"`
+	User      string    `json:"user"`
+	Name      string    `json:"name" validate:"required"`
+	Query     string    `json:"query"`
+	Sort      string    `json:"sort,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func (db *DB) PutFilter(filter *SavedFilter) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(filterBucket)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(filter)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(filter.ID.String()), data)
+	})
+}
+
+func (db *DB) GetFilter(username string, id uuid.UUID) (*SavedFilter, error) {
+	filter := &SavedFilter{}
+	if err := db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(filterBucket)
+		if bucket == nil {
+			return ErrFilterNotFound
+		}
+		b := bucket.Get([]byte(id.String()))
+		if b == nil {
+			return ErrFilterNotFound
+		}
+		return json.Unmarshal(b, filter)
+	}); err != nil {
+		return nil, err
+	}
+	if filter.User != username {
+		return nil, ErrFilterNotFound
+	}
+	return filter, nil
+}
+
+func (db *DB) GetFilters(username string) ([]SavedFilter, error) {
+	filters := []SavedFilter{}
+	err := db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(filterBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var filter SavedFilter
+			if err := json.Unmarshal(v, &filter); err != nil {
+				return err
+			}
+			if filter.User == username {
+				filters = append(filters, filter)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return filters, nil
+}
+
+func (db *DB) DeleteFilter(username string, id uuid.UUID) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(filterBucket)
+		if bucket == nil {
+			return ErrFilterNotFound
+		}
+		key := []byte(id.String())
+		b := bucket.Get(key)
+		if b == nil {
+			return ErrFilterNotFound
+		}
+		var filter SavedFilter
+		if err := json.Unmarshal(b, &filter); err != nil {
+			return err
+		}
+		if filter.User != username {
+			return ErrFilterNotFound
+		}
+		return bucket.Delete(key)
+	})
+}
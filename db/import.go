@@ -0,0 +1,35 @@
+package db
+
+import (
+	"github.com/google/uuid"
+)
+
+var importBucket = []byte("import")
+
+func importKey(username string, source string, sourceID string) []byte {
+	return []byte(username + "\x00" + source + "\x00" + sourceID)
+}
+
+// ImportedTaskID returns the task an item of an import source was imported
+// as, or uuid.Nil if it was not.
+func (t *TaskTx) ImportedTaskID(source string, sourceID string) uuid.UUID {
+	bucket := t.tx.Bucket(importBucket)
+	if bucket == nil {
+		return uuid.Nil
+	}
+	id, err := uuid.FromBytes(bucket.Get(importKey(t.username, source, sourceID)))
+	if err != nil {
+		return uuid.Nil
+	}
+	return id
+}
+
+// SetImportedTaskID records the task an item of an import source was
+// imported as, so importing the item again updates the task.
+func (t *TaskTx) SetImportedTaskID(source string, sourceID string, id uuid.UUID) error {
+	bucket, err := t.tx.CreateBucketIfNotExists(importBucket)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(importKey(t.username, source, sourceID), id[:])
+}
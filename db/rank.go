@@ -0,0 +1,309 @@
+package db
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Fractional indexing keys are strings over an ordered base-62 alphabet that
+// compare lexicographically. Between any two distinct keys another key can
+// always be generated, so moving an item only requires rewriting its own key.
+// Keys never end with the smallest digit, which keeps room below every key.
+//
+// There is one rank sequence per user rather than one per column. The board
+// groups cards by status or by project, and a single order serves both
+// groupings, so a card keeps its place relative to the others when it moves
+// to another column and a column is read by filtering the user's ordered
+// tasks. The cost is in the rare writes that look at the whole sequence:
+// appending reads the tasks of the user through the per-user index to find
+// the last rank, and a rebalance, needed only once a key outgrows
+// maxRankLength or a legacy task has no rank, rewrites all of them.
+const rankDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var (
+	ErrInvalidRank = errors.New("fractional index keys are invalid or out of order")
+)
+
+func validRank(key string) bool {
+	if key == "" || key[len(key)-1] == rankDigits[0] {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(rankDigits, key[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// rankBetween returns a key that sorts strictly between a and b. An empty a
+// means "before everything" and an empty b means "after everything".
+func rankBetween(a, b string) (string, error) {
+	if a != "" && !validRank(a) || b != "" && !validRank(b) {
+		return "", ErrInvalidRank
+	}
+	if a != "" && b != "" && a >= b {
+		return "", ErrInvalidRank
+	}
+	return rankMidpoint(a, b), nil
+}
+
+func rankMidpoint(a, b string) string {
+	if b != "" {
+		// Skip the common prefix; a is padded with the zero digit.
+		n := 0
+		for n < len(b) {
+			digitA := rankDigits[0]
+			if n < len(a) {
+				digitA = a[n]
+			}
+			if digitA != b[n] {
+				break
+			}
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(a) {
+				rest = a[n:]
+			}
+			return b[:n] + rankMidpoint(rest, b[n:])
+		}
+	}
+
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(rankDigits, a[0])
+	}
+	digitB := len(rankDigits)
+	if b != "" {
+		digitB = strings.IndexByte(rankDigits, b[0])
+	}
+
+	if digitB-digitA > 1 {
+		return string(rankDigits[(digitA+digitB+1)/2])
+	}
+	// The first digits are consecutive.
+	if len(b) > 1 {
+		return b[:1]
+	}
+	rest := ""
+	if a != "" {
+		rest = a[1:]
+	}
+	return string(rankDigits[digitA]) + rankMidpoint(rest, "")
+}
+
+// evenRanks returns n ascending keys spread evenly over the key space, using
+// the shortest width that fits them. It is used to rebalance keys that have
+// grown long.
+func evenRanks(n int) []string {
+	if n <= 0 {
+		return nil
+	}
+	base := big.NewInt(int64(len(rankDigits)))
+	space := new(big.Int).Set(base)
+	width := 1
+	for space.Cmp(big.NewInt(int64(n+1))) <= 0 {
+		space.Mul(space, base)
+		width++
+	}
+
+	keys := make([]string, n)
+	step := new(big.Int).Div(space, big.NewInt(int64(n+1)))
+	v := new(big.Int)
+	digit := new(big.Int)
+	buf := make([]byte, width)
+	for i := range keys {
+		v.Mul(step, big.NewInt(int64(i+1)))
+		for j := width - 1; j >= 0; j-- {
+			v.DivMod(v, base, digit)
+			buf[j] = rankDigits[digit.Int64()]
+		}
+		keys[i] = strings.TrimRight(string(buf), rankDigits[:1])
+	}
+	return keys
+}
+
+// maxRankLength is the key length above which the ranks of all tasks of a
+// user are spread out again.
+const maxRankLength = 12
+
+// SortByRank orders tasks by their manual rank. Tasks that were never ranked
+// go last, oldest first.
+func SortByRank(tasks []Task) {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		a, b := tasks[i], tasks[j]
+		switch {
+		case a.Rank == "" && b.Rank == "":
+			return a.CreatedAt.Before(b.CreatedAt)
+		case a.Rank == "" || b.Rank == "":
+			return b.Rank == ""
+		default:
+			return a.Rank < b.Rank
+		}
+	})
+}
+
+// appendRankTx ranks a new task after every other task of its user and
+// stores it.
+func appendRankTx(tx *bolt.Tx, task *Task) error {
+	tasks, err := userTasksTx(tx, task.User)
+	if err != nil {
+		return err
+	}
+	last := ""
+	if n := len(tasks); n > 0 {
+		last = tasks[n-1].Rank
+	}
+	if last != "" || len(tasks) == 0 {
+		task.Rank, err = rankBetween(last, "")
+		if err != nil {
+			return err
+		}
+		if len(task.Rank) <= maxRankLength {
+			return putTaskTx(tx, task)
+		}
+	}
+	// The new task sorts last either way, so it picks up the last rank.
+	all := append(tasks, *task)
+	if err := rebalanceRanksTx(tx, all); err != nil {
+		return err
+	}
+	task.Rank = all[len(all)-1].Rank
+	return nil
+}
+
+// rebalanceRanksTx assigns evenly spaced ranks to the tasks, keeping their
+// current order, and stores all of them.
+func rebalanceRanksTx(tx *bolt.Tx, tasks []Task) error {
+	spreadRanks(tasks)
+	for i := range tasks {
+		if err := putTaskTx(tx, &tasks[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// spreadRanks assigns evenly spaced ranks to the tasks, keeping their
+// current order, without storing them.
+func spreadRanks(tasks []Task) {
+	SortByRank(tasks)
+	for i, rank := range evenRanks(len(tasks)) {
+		tasks[i].Rank = rank
+	}
+}
+
+// MoveTask places the task between the after and before neighbours, either of
+// which may be uuid.Nil to move it to the start or end. Only the moved task is
+// written unless the ranks of the user need rebalancing. The update callback
+// can change the column of the task in the same transaction.
+func (db *DB) MoveTask(id uuid.UUID, username string, after uuid.UUID, before uuid.UUID, update func(*Task) error) (*Task, error) {
+	var moved *Task
+	err := db.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		moved, err = moveTaskTx(tx, id, username, after, before, update)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return moved, nil
+}
+
+func moveTaskTx(tx *bolt.Tx, id uuid.UUID, username string, after uuid.UUID, before uuid.UUID, update func(*Task) error) (*Task, error) {
+	task, err := getTaskTx(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if task.User != username {
+		return nil, ErrNoRows
+	}
+
+	// Everything that can refuse the move is checked before the first write,
+	// so that a refused move leaves the transaction as it was.
+	neighbours := [2]*Task{}
+	unranked := false
+	for i, nid := range [2]uuid.UUID{after, before} {
+		if nid == uuid.Nil {
+			continue
+		}
+		if nid == id {
+			return nil, ErrInvalidRank
+		}
+		n, err := getTaskTx(tx, nid)
+		if err != nil {
+			return nil, err
+		}
+		if n.User != username {
+			return nil, ErrNoRows
+		}
+		neighbours[i] = n
+		unranked = unranked || n.Rank == ""
+	}
+	if update != nil {
+		if err := update(task); err != nil {
+			return nil, err
+		}
+	}
+
+	var rebalanced []Task
+	if unranked {
+		rebalanced, err = userTasksTx(tx, username)
+		if err != nil {
+			return nil, err
+		}
+		spreadRanks(rebalanced)
+		for _, t := range rebalanced {
+			for _, n := range neighbours {
+				if n != nil && n.ID == t.ID {
+					n.Rank = t.Rank
+				}
+			}
+		}
+	}
+	ranks := [2]string{}
+	for i, n := range neighbours {
+		if n != nil {
+			ranks[i] = n.Rank
+		}
+	}
+	task.Rank, err = rankBetween(ranks[0], ranks[1])
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range rebalanced {
+		if rebalanced[i].ID == id {
+			continue
+		}
+		if err := putTaskTx(tx, &rebalanced[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := putTaskTx(tx, task); err != nil {
+		return nil, err
+	}
+
+	if len(task.Rank) > maxRankLength {
+		tasks, err := userTasksTx(tx, username)
+		if err != nil {
+			return nil, err
+		}
+		if err := rebalanceRanksTx(tx, tasks); err != nil {
+			return nil, err
+		}
+		for _, t := range tasks {
+			if t.ID == id {
+				task.Rank = t.Rank
+			}
+		}
+	}
+	return task, nil
+}
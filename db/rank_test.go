@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestRankBetween(t *testing.T) {
+	t.Run("first key", func(t *testing.T) {
+		k, err := rankBetween("", "")
+		require.NoError(t, err)
+		require.Equal(t, "V", k)
+	})
+	t.Run("between neighbours", func(t *testing.T) {
+		pairs := [][2]string{
+			{"", "V"}, {"V", ""}, {"V", "W"}, {"V", "V1"}, {"a", "b"},
+			{"az", "b"}, {"1", "2"}, {"", "1"}, {"", "01"}, {"zz", ""},
+		}
+		for _, p := range pairs {
+			k, err := rankBetween(p[0], p[1])
+			require.NoError(t, err)
+			require.True(t, validRank(k), "key %q", k)
+			if p[0] != "" {
+				require.Greater(t, k, p[0])
+			}
+			if p[1] != "" {
+				require.Less(t, k, p[1])
+			}
+		}
+	})
+	t.Run("rejects invalid input", func(t *testing.T) {
+		_, err := rankBetween("b", "a")
+		require.ErrorIs(t, err, ErrInvalidRank)
+		_, err = rankBetween("a", "a")
+		require.ErrorIs(t, err, ErrInvalidRank)
+		_, err = rankBetween("a0", "")
+		require.ErrorIs(t, err, ErrInvalidRank)
+		_, err = rankBetween("a-", "")
+		require.ErrorIs(t, err, ErrInvalidRank)
+	})
+	t.Run("repeated inserts stay ordered", func(t *testing.T) {
+		keys := []string{}
+		lo, hi := "", ""
+		for i := 0; i < 200; i++ {
+			k, err := rankBetween(lo, hi)
+			require.NoError(t, err)
+			keys = append(keys, k)
+			if i%2 == 0 {
+				lo = k
+			} else {
+				hi = k
+			}
+		}
+		sorted := append([]string(nil), keys...)
+		sort.Strings(sorted)
+		for i := 1; i < len(sorted); i++ {
+			require.NotEqual(t, sorted[i-1], sorted[i])
+		}
+	})
+}
+
+func TestEvenRanks(t *testing.T) {
+	for _, n := range []int{1, 10, 61, 62, 1000} {
+		keys := evenRanks(n)
+		require.Len(t, keys, n)
+		for i, k := range keys {
+			require.True(t, validRank(k), "key %q", k)
+			if i > 0 {
+				require.Less(t, keys[i-1], k)
+			}
+		}
+	}
+}
+
+func TestMoveTask(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+
+	ids := []uuid.UUID{}
+	for i := 0; i < 4; i++ {
+		task := &Task{ID: uuid.New(), Title: fmt.Sprintf("task %d", i), User: "alice"}
+		_, err := db.CreateTask(task)
+		require.NoError(t, err)
+		ids = append(ids, task.ID)
+	}
+	order := func() []uuid.UUID {
+		tasks, err := db.GetAllTasksFromUser(ctx, "alice")
+		require.NoError(t, err)
+		got := []uuid.UUID{}
+		for _, task := range tasks {
+			got = append(got, task.ID)
+		}
+		return got
+	}
+	require.Equal(t, ids, order())
+
+	t.Run("between neighbours", func(t *testing.T) {
+		_, err := db.MoveTask(ids[3], "alice", ids[0], ids[1], nil)
+		require.NoError(t, err)
+		require.Equal(t, []uuid.UUID{ids[0], ids[3], ids[1], ids[2]}, order())
+	})
+	t.Run("to the start", func(t *testing.T) {
+		_, err := db.MoveTask(ids[2], "alice", uuid.Nil, ids[0], nil)
+		require.NoError(t, err)
+		require.Equal(t, []uuid.UUID{ids[2], ids[0], ids[3], ids[1]}, order())
+	})
+	t.Run("rejects out of order neighbours", func(t *testing.T) {
+		_, err := db.MoveTask(ids[0], "alice", ids[1], ids[2], nil)
+		require.ErrorIs(t, err, ErrInvalidRank)
+	})
+	t.Run("rejects foreign tasks", func(t *testing.T) {
+		_, err := db.MoveTask(ids[0], "bob", uuid.Nil, uuid.Nil, nil)
+		require.ErrorIs(t, err, ErrNoRows)
+	})
+	t.Run("ranks legacy tasks", func(t *testing.T) {
+		legacy := &Task{ID: uuid.New(), Title: "legacy", User: "alice", CreatedAt: time.Now()}
+		require.NoError(t, db.db.Update(func(tx *bolt.Tx) error { return putTaskTx(tx, legacy) }))
+		_, err := db.MoveTask(legacy.ID, "alice", uuid.Nil, ids[2], nil)
+		require.NoError(t, err)
+		require.Equal(t, legacy.ID, order()[0])
+		_, err = db.MoveTask(legacy.ID, "alice", ids[1], uuid.Nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, legacy.ID, order()[4])
+
+		unranked := &Task{ID: uuid.New(), Title: "unranked", User: "alice", CreatedAt: time.Now()}
+		require.NoError(t, db.db.Update(func(tx *bolt.Tx) error { return putTaskTx(tx, unranked) }))
+		_, err = db.MoveTask(ids[0], "alice", unranked.ID, uuid.Nil, nil)
+		require.NoError(t, err)
+		got := order()
+		require.Equal(t, []uuid.UUID{unranked.ID, ids[0]}, got[len(got)-2:])
+	})
+	t.Run("rebalances long keys", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			moved, err := db.MoveTask(ids[3], "alice", ids[2], ids[0], nil)
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(moved.Rank), maxRankLength)
+			ids[2], ids[3] = ids[3], ids[2]
+		}
+		require.Equal(t, 6, len(order()))
+	})
+}
@@ -0,0 +1,331 @@
+package db
+
+import (
+	"encoding/binary"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	statsBucket          = []byte("stats")
+	statsCreatedBucket   = []byte("created")
+	statsCompletedBucket = []byte("completed")
+	statsDueBucket       = []byte("due")
+	statsTagBucket       = []byte("tag")
+	statsProjectBucket   = []byte("project")
+	statsTotalsKey       = []byte("totals")
+	statsModifiedKey     = []byte("modified")
+)
+
+// statsHourLayout keys the history counters by UTC hour, so that they can be
+// regrouped into days of any time zone with a whole-hour offset.
+const statsHourLayout = "2006-01-02T15"
+
+// StatusCounts counts the open and done tasks of a group.
+type StatusCounts struct {
+	Open int `json:"open"`
+	Done int `json:"done"`
+}
+
+// StatsTotals are the counters of a user that describe the current state.
+// The lead time sums are kept for every completion, including those of tasks
+// that were deleted later.
+type StatsTotals struct {
+	StatusCounts
+	LeadTimeSeconds int64 `json:"leadTimeSeconds"`
+	LeadTimeCount   int64 `json:"leadTimeCount"`
+}
+
+// StatsCounters are the incrementally maintained statistics of a user.
+// Created and Completed count tasks per UTC hour; deleting a task does not
+// change its history. Due holds the due dates of open tasks.
+type StatsCounters struct {
+	Totals    StatsTotals
+	Created   map[time.Time]int64
+	Completed map[time.Time]int64
+	Due       []time.Time
+	Tags      map[string]StatusCounts
+	Projects  map[string]StatusCounts
+}
+
+func statsHour(t time.Time) []byte {
+	return []byte(t.UTC().Format(statsHourLayout))
+}
+
+func isDone(t *Task) bool {
+	return t.Status == StatusDone && t.CompletedAt != nil
+}
+
+// userStatsTx returns the statistics bucket of the user, building it from
+// the stored tasks the first time. Only writes may call it.
+func userStatsTx(tx *bolt.Tx, username string) (*bolt.Bucket, error) {
+	root, err := tx.CreateBucketIfNotExists(statsBucket)
+	if err != nil {
+		return nil, err
+	}
+	if b := root.Bucket([]byte(username)); b != nil {
+		return b, nil
+	}
+	b, err := root.CreateBucket([]byte(username))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range [][]byte{statsCreatedBucket, statsCompletedBucket, statsDueBucket, statsTagBucket, statsProjectBucket} {
+		if _, err := b.CreateBucket(name); err != nil {
+			return nil, err
+		}
+	}
+	tasks, err := userTasksTx(tx, username)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tasks {
+		if err := applyStatsTx(b, nil, &tasks[i]); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func addCounter(b *bolt.Bucket, key []byte, delta int64) error {
+	var n int64
+	if v := b.Get(key); len(v) == 8 {
+		n = int64(binary.BigEndian.Uint64(v))
+	}
+	n += delta
+	if n <= 0 {
+		return b.Delete(key)
+	}
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, uint64(n))
+	return b.Put(key, v)
+}
+
+func addStatusCounts(b *bolt.Bucket, key string, done bool, delta int) error {
+	var c StatusCounts
+	if v := b.Get([]byte(key)); v != nil {
+		if err := json.Unmarshal(v, &c); err != nil {
+			return err
+		}
+	}
+	if done {
+		c.Done += delta
+	} else {
+		c.Open += delta
+	}
+	if c.Open <= 0 && c.Done <= 0 {
+		return b.Delete([]byte(key))
+	}
+	v, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(key), v)
+}
+
+// applyStatsTx updates the counters in the user bucket b for a task changing
+// from old to new. old is nil for a new task and new is nil for a deleted one.
+func applyStatsTx(b *bolt.Bucket, old *Task, new *Task) error {
+	var totals StatsTotals
+	if v := b.Get(statsTotalsKey); v != nil {
+		if err := json.Unmarshal(v, &totals); err != nil {
+			return err
+		}
+	}
+
+	if old == nil && new != nil {
+		if err := addCounter(b.Bucket(statsCreatedBucket), statsHour(new.CreatedAt), 1); err != nil {
+			return err
+		}
+	}
+	oldDone := old != nil && isDone(old)
+	newDone := new != nil && isDone(new)
+	if oldDone && (new == nil || newDone && old.CompletedAt.Equal(*new.CompletedAt)) {
+		// Unchanged completion, or a completed task being deleted: history stays.
+		oldDone, newDone = false, false
+	}
+	if oldDone {
+		if err := addCounter(b.Bucket(statsCompletedBucket), statsHour(*old.CompletedAt), -1); err != nil {
+			return err
+		}
+		totals.LeadTimeSeconds -= int64(old.CompletedAt.Sub(old.CreatedAt) / time.Second)
+		totals.LeadTimeCount--
+	}
+	if newDone {
+		if err := addCounter(b.Bucket(statsCompletedBucket), statsHour(*new.CompletedAt), 1); err != nil {
+			return err
+		}
+		totals.LeadTimeSeconds += int64(new.CompletedAt.Sub(new.CreatedAt) / time.Second)
+		totals.LeadTimeCount++
+	}
+
+	for _, change := range []struct {
+		task  *Task
+		delta int
+	}{{old, -1}, {new, 1}} {
+		t := change.task
+		if t == nil {
+			continue
+		}
+		done := isDone(t)
+		if done {
+			totals.Done += change.delta
+		} else {
+			totals.Open += change.delta
+		}
+		for _, tag := range t.Tags {
+			if err := addStatusCounts(b.Bucket(statsTagBucket), tag, done, change.delta); err != nil {
+				return err
+			}
+		}
+		if t.Project != "" {
+			if err := addStatusCounts(b.Bucket(statsProjectBucket), t.Project, done, change.delta); err != nil {
+				return err
+			}
+		}
+	}
+
+	due := b.Bucket(statsDueBucket)
+	if old != nil {
+		if err := due.Delete([]byte(old.ID.String())); err != nil {
+			return err
+		}
+	}
+	if new != nil && new.Due != nil && !isDone(new) {
+		v, err := new.Due.MarshalText()
+		if err != nil {
+			return err
+		}
+		if err := due.Put([]byte(new.ID.String()), v); err != nil {
+			return err
+		}
+	}
+
+	v, err := json.Marshal(totals)
+	if err != nil {
+		return err
+	}
+	return b.Put(statsTotalsKey, v)
+}
+
+// updateStatsTx keeps the statistics of the task owner in step with a write
+// of the task. It must be called before the task bucket is changed.
+func updateStatsTx(tx *bolt.Tx, old *Task, new *Task) error {
+	owner := new
+	if owner == nil {
+		owner = old
+	}
+	b, err := userStatsTx(tx, owner.User)
+	if err != nil {
+		return err
+	}
+	if err := applyStatsTx(b, old, new); err != nil {
+		return err
+	}
+	v, err := time.Now().UTC().MarshalText()
+	if err != nil {
+		return err
+	}
+	return b.Put(statsModifiedKey, v)
+}
+
+// GetTasksModified returns the time of the last task write of the user,
+// deletions included, or the zero time if the database has not recorded one.
+func (db *DB) GetTasksModified(username string) (time.Time, error) {
+	var modified time.Time
+	err := db.db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(statsBucket)
+		if root == nil {
+			return nil
+		}
+		b := root.Bucket([]byte(username))
+		if b == nil {
+			return nil
+		}
+		if v := b.Get(statsModifiedKey); v != nil {
+			return modified.UnmarshalText(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	return modified, nil
+}
+
+// GetStatsCounters returns the statistics counters of the user. A user
+// without a statistics bucket has no tasks yet, since NewSQL builds the
+// bucket of every user with tasks.
+func (db *DB) GetStatsCounters(username string) (*StatsCounters, error) {
+	counters := &StatsCounters{
+		Created:   map[time.Time]int64{},
+		Completed: map[time.Time]int64{},
+		Due:       []time.Time{},
+		Tags:      map[string]StatusCounts{},
+		Projects:  map[string]StatusCounts{},
+	}
+	err := db.db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(statsBucket)
+		if root == nil {
+			return nil
+		}
+		b := root.Bucket([]byte(username))
+		if b == nil {
+			return nil
+		}
+		if v := b.Get(statsTotalsKey); v != nil {
+			if err := json.Unmarshal(v, &counters.Totals); err != nil {
+				return err
+			}
+		}
+		for name, hours := range map[string]map[time.Time]int64{
+			string(statsCreatedBucket):   counters.Created,
+			string(statsCompletedBucket): counters.Completed,
+		} {
+			err := b.Bucket([]byte(name)).ForEach(func(k, v []byte) error {
+				hour, err := time.Parse(statsHourLayout, string(k))
+				if err != nil {
+					return err
+				}
+				hours[hour] = int64(binary.BigEndian.Uint64(v))
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		err := b.Bucket(statsDueBucket).ForEach(func(k, v []byte) error {
+			var due time.Time
+			if err := due.UnmarshalText(v); err != nil {
+				return err
+			}
+			counters.Due = append(counters.Due, due)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for name, groups := range map[string]map[string]StatusCounts{
+			string(statsTagBucket):     counters.Tags,
+			string(statsProjectBucket): counters.Projects,
+		} {
+			err := b.Bucket([]byte(name)).ForEach(func(k, v []byte) error {
+				var c StatusCounts
+				if err := json.Unmarshal(v, &c); err != nil {
+					return err
+				}
+				groups[string(k)] = c
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counters, nil
+}
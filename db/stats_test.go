@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestStatsCounters(t *testing.T) {
+	created := time.Date(2023, 4, 5, 9, 30, 0, 0, time.UTC)
+	due := created.Add(24 * time.Hour)
+
+	// Written without statistics, as by an older version.
+	legacy := &Task{ID: uuid.New(), Title: "legacy", User: "alice", Project: "home", CreatedAt: created}
+	db := newLegacyTestDB(t, legacy)
+
+	task := &Task{ID: uuid.New(), Title: "report", User: "alice", Tags: []string{"work"}, Due: &due, CreatedAt: created}
+	_, err := db.CreateTask(task)
+	require.NoError(t, err)
+
+	hour := func(h int) time.Time { return time.Date(2023, 4, 5, h, 0, 0, 0, time.UTC) }
+	counters, err := db.GetStatsCounters("alice")
+	require.NoError(t, err)
+	require.Equal(t, StatsTotals{StatusCounts: StatusCounts{Open: 2}}, counters.Totals)
+	require.Equal(t, map[time.Time]int64{hour(9): 2}, counters.Created)
+	require.Equal(t, []time.Time{due}, counters.Due)
+	require.Equal(t, map[string]StatusCounts{"work": {Open: 1}}, counters.Tags)
+	require.Equal(t, map[string]StatusCounts{"home": {Open: 1}}, counters.Projects)
+
+	done := created.Add(5 * time.Hour)
+	_, err = db.UpdateTaskFunc(task.ID, func(t *Task) error {
+		t.SetStatus(StatusDone, done)
+		return nil
+	})
+	require.NoError(t, err)
+	counters, err = db.GetStatsCounters("alice")
+	require.NoError(t, err)
+	require.Equal(t, StatsTotals{StatusCounts: StatusCounts{Open: 1, Done: 1}, LeadTimeSeconds: 5 * 3600, LeadTimeCount: 1}, counters.Totals)
+	require.Equal(t, map[time.Time]int64{hour(14): 1}, counters.Completed)
+	require.Empty(t, counters.Due)
+	require.Equal(t, map[string]StatusCounts{"work": {Done: 1}}, counters.Tags)
+
+	t.Run("deleting keeps the history", func(t *testing.T) {
+		_, err := db.DeleteTask(context.Background(), task.ID)
+		require.NoError(t, err)
+		counters, err := db.GetStatsCounters("alice")
+		require.NoError(t, err)
+		require.Equal(t, StatsTotals{StatusCounts: StatusCounts{Open: 1}, LeadTimeSeconds: 5 * 3600, LeadTimeCount: 1}, counters.Totals)
+		require.Equal(t, map[time.Time]int64{hour(9): 2}, counters.Created)
+		require.Equal(t, map[time.Time]int64{hour(14): 1}, counters.Completed)
+		require.Empty(t, counters.Tags)
+	})
+	t.Run("reopening undoes the completion", func(t *testing.T) {
+		_, err := db.UpdateTaskFunc(legacy.ID, func(t *Task) error {
+			t.SetStatus(StatusDone, done)
+			return nil
+		})
+		require.NoError(t, err)
+		_, err = db.UpdateTaskFunc(legacy.ID, func(t *Task) error {
+			t.SetStatus(StatusTodo, done)
+			return nil
+		})
+		require.NoError(t, err)
+		counters, err := db.GetStatsCounters("alice")
+		require.NoError(t, err)
+		require.Equal(t, map[time.Time]int64{hour(14): 1}, counters.Completed)
+		require.Equal(t, int64(1), counters.Totals.LeadTimeCount)
+		require.Equal(t, map[string]StatusCounts{"home": {Open: 1}}, counters.Projects)
+	})
+}
+
+// newLegacyTestDB opens a database holding the tasks as stored by a version
+// without the indexes kept alongside them.
+func newLegacyTestDB(t *testing.T, tasks ...*Task) *DB {
+	l := zerolog.Nop()
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := bolt.Open(path, 0600, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket(taskBucket)
+		require.NoError(t, err)
+		for _, task := range tasks {
+			data, err := json.Marshal(task)
+			require.NoError(t, err)
+			require.NoError(t, b.Put([]byte(task.ID.String()), data))
+		}
+		return nil
+	}))
+	require.NoError(t, db.Close())
+
+	legacy, err := NewSQL(path, &l)
+	require.NoError(t, err)
+	t.Cleanup(legacy.Close)
+	return legacy
+}
+
+func TestStatsCountersOnOpen(t *testing.T) {
+	db := newLegacyTestDB(t, &Task{ID: uuid.New(), Title: "legacy", User: "alice", Project: "home", CreatedAt: time.Now()})
+	counters, err := db.GetStatsCounters("alice")
+	require.NoError(t, err)
+	require.Equal(t, StatsTotals{StatusCounts: StatusCounts{Open: 1}}, counters.Totals)
+	require.Equal(t, map[string]StatusCounts{"home": {Open: 1}}, counters.Projects)
+
+	counters, err = db.GetStatsCounters("bob")
+	require.NoError(t, err)
+	require.Equal(t, StatsTotals{}, counters.Totals)
+	require.Empty(t, counters.Created)
+}
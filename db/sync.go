@@ -0,0 +1,172 @@
+package db
+
+import (
+	"bytes"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var syncBucket = []byte("sync")
+
+// SyncEntry records the last change of a task within a project. Revisions
+// count the task writes of a user. A task that was deleted or moved to
+// another project leaves an entry with Deleted set, so that clients holding
+// an older revision learn about the removal.
+type SyncEntry struct {
+	TaskID   uuid.UUID `json:"taskId"`
+	Project  string    `json:"project"`
+	Revision uint64    `json:"revision"`
+	Deleted  bool      `json:"deleted,omitempty"`
+	// Name is the last known iCalendar UID of a deleted task.
+	Name string `json:"name,omitempty"`
+}
+
+func syncKey(project string, id uuid.UUID) []byte {
+	return []byte(project + "\x00" + id.String())
+}
+
+func putSyncEntry(b *bolt.Bucket, e *SyncEntry) error {
+	v, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return b.Put(syncKey(e.Project, e.TaskID), v)
+}
+
+// userSyncTx returns the sync bucket of the user, recording every stored
+// task at the first revision the first time. The sequence of the bucket is
+// the current revision. Only writes may call it.
+func userSyncTx(tx *bolt.Tx, username string) (*bolt.Bucket, error) {
+	root, err := tx.CreateBucketIfNotExists(syncBucket)
+	if err != nil {
+		return nil, err
+	}
+	if b := root.Bucket([]byte(username)); b != nil {
+		return b, nil
+	}
+	b, err := root.CreateBucket([]byte(username))
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := userTasksTx(tx, username)
+	if err != nil {
+		return nil, err
+	}
+	if len(tasks) == 0 {
+		return b, nil
+	}
+	rev, err := b.NextSequence()
+	if err != nil {
+		return nil, err
+	}
+	for i := range tasks {
+		e := &SyncEntry{TaskID: tasks[i].ID, Project: tasks[i].Project, Revision: rev}
+		if err := putSyncEntry(b, e); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// syncBucketTx returns the sync bucket of the user for reading, or nil if
+// the user has none.
+func syncBucketTx(tx *bolt.Tx, username string) *bolt.Bucket {
+	root := tx.Bucket(syncBucket)
+	if root == nil {
+		return nil
+	}
+	return root.Bucket([]byte(username))
+}
+
+// updateSyncTx records a write of the task at the next revision of its
+// owner. It must be called before the task bucket is changed.
+func updateSyncTx(tx *bolt.Tx, old *Task, new *Task) error {
+	owner := new
+	if owner == nil {
+		owner = old
+	}
+	b, err := userSyncTx(tx, owner.User)
+	if err != nil {
+		return err
+	}
+	rev, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+	if old != nil && (new == nil || old.Project != new.Project) {
+		name := old.ICalUID
+		if name == "" {
+			name = old.ID.String()
+		}
+		e := &SyncEntry{TaskID: old.ID, Project: old.Project, Revision: rev, Deleted: true, Name: name}
+		if err := putSyncEntry(b, e); err != nil {
+			return err
+		}
+	}
+	if new != nil {
+		return putSyncEntry(b, &SyncEntry{TaskID: new.ID, Project: new.Project, Revision: rev})
+	}
+	return nil
+}
+
+// GetSyncEntries returns the current revision of the user and the entries
+// of the project changed after the since revision. A user without a sync
+// bucket has not stored a task yet and is at revision 0.
+func (db *DB) GetSyncEntries(username string, project string, since uint64) (uint64, []SyncEntry, error) {
+	var rev uint64
+	entries := []SyncEntry{}
+	err := db.db.View(func(tx *bolt.Tx) error {
+		b := syncBucketTx(tx, username)
+		if b == nil {
+			return nil
+		}
+		rev = b.Sequence()
+		prefix := []byte(project + "\x00")
+		c := b.Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var e SyncEntry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			if e.Revision > since {
+				entries = append(entries, e)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	return rev, entries, nil
+}
+
+// GetSyncProjects returns the projects of the user that have tasks, in
+// order.
+func (db *DB) GetSyncProjects(username string) ([]string, error) {
+	projects := []string{}
+	err := db.db.View(func(tx *bolt.Tx) error {
+		b := syncBucketTx(tx, username)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			project := string(k[:bytes.IndexByte(k, 0)])
+			if len(projects) > 0 && projects[len(projects)-1] == project {
+				return nil
+			}
+			var e SyncEntry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			if !e.Deleted {
+				projects = append(projects, project)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return projects, nil
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncEntries(t *testing.T) {
+	db := newTestDB(t)
+	a := &Task{ID: uuid.New(), Title: "first", User: "alice", Project: "home"}
+	b := &Task{ID: uuid.New(), Title: "second", User: "alice", Project: "home", ICalUID: "b@example.com"}
+	require.NoError(t, db.CreateTasks([]*Task{a, b}))
+
+	rev, entries, err := db.GetSyncEntries("alice", "home", 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), rev)
+	require.Len(t, entries, 2)
+
+	_, err = db.UpdateTaskFunc(a.ID, func(t *Task) error {
+		t.Title = "first, renamed"
+		return nil
+	})
+	require.NoError(t, err)
+	_, err = db.UpdateTaskFunc(b.ID, func(t *Task) error {
+		t.Project = "work"
+		return nil
+	})
+	require.NoError(t, err)
+
+	rev, entries, err = db.GetSyncEntries("alice", "home", 2)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), rev)
+	require.Len(t, entries, 2)
+	require.Equal(t, SyncEntry{TaskID: a.ID, Project: "home", Revision: 3}, findSyncEntry(entries, a.ID))
+	require.Equal(t, SyncEntry{TaskID: b.ID, Project: "home", Revision: 4, Deleted: true, Name: "b@example.com"}, findSyncEntry(entries, b.ID))
+
+	_, entries, err = db.GetSyncEntries("alice", "work", 2)
+	require.NoError(t, err)
+	require.Equal(t, []uuid.UUID{b.ID}, []uuid.UUID{entries[0].TaskID})
+
+	_, err = db.DeleteTask(context.Background(), b.ID)
+	require.NoError(t, err)
+	projects, err := db.GetSyncProjects("alice")
+	require.NoError(t, err)
+	require.Equal(t, []string{"home"}, projects)
+
+	_, entries, err = db.GetSyncEntries("alice", "work", 4)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.True(t, entries[0].Deleted)
+}
+
+func TestSyncEntriesOnOpen(t *testing.T) {
+	legacy := &Task{ID: uuid.New(), Title: "legacy", User: "alice", Project: "home"}
+	db := newLegacyTestDB(t, legacy)
+	rev, entries, err := db.GetSyncEntries("alice", "home", 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), rev)
+	require.Equal(t, []SyncEntry{{TaskID: legacy.ID, Project: "home", Revision: 1}}, entries)
+	projects, err := db.GetSyncProjects("alice")
+	require.NoError(t, err)
+	require.Equal(t, []string{"home"}, projects)
+
+	rev, entries, err = db.GetSyncEntries("bob", "home", 0)
+	require.NoError(t, err)
+	require.Zero(t, rev)
+	require.Empty(t, entries)
+	projects, err = db.GetSyncProjects("bob")
+	require.NoError(t, err)
+	require.Empty(t, projects)
+}
+
+func findSyncEntry(entries []SyncEntry, id uuid.UUID) SyncEntry {
+	for _, e := range entries {
+		if e.TaskID == id {
+			return e
+		}
+	}
+	return SyncEntry{}
+}
@@ -1,44 +1,178 @@
 package db
 
 import (
+	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	bolt "go.etcd.io/bbolt"
 )
 
+const (
+	StatusTodo       = "todo"
+	StatusInProgress = "in_progress"
+	StatusDone       = "done"
+
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+)
+
 var (
 	ErrTaskAlreadyExists = errors.New("task already exists")
 	ErrTaskNotFound      = errors.New("requested task is not found")
 	taskBucket           = []byte("task")
+	userTaskBucket       = []byte("usertask")
 )
 
 type Task struct {
-	ID        uuid.UUID `json:"id"`
-	Title     string    `json:"title" validate:"required,min=4"`
-	User      string    `json:"user" validate:"required"`
-	Text      string    `json:"text"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID          uuid.UUID              `json:"id"`
+	Title       string                 `json:"title" validate:"required,min=4"`
+	User        string                 `json:"user" validate:"required"`
+	Text        string                 `json:"text"`
+	Project     string                 `json:"project,omitempty"`
+	Status      string                 `json:"status,omitempty" validate:"omitempty,oneof=todo in_progress done"`
+	Tags        []string               `json:"tags,omitempty" validate:"dive,required,excludesall= "`
+	Priority    string                 `json:"priority,omitempty" validate:"omitempty,oneof=low medium high"`
+	Rank        string                 `json:"rank,omitempty"`
+	ParentID    *uuid.UUID             `json:"parentId,omitempty"`
+	Due         *time.Time             `json:"due,omitempty"`
+	Recurrence  string                 `json:"recurrence,omitempty"`
+	DeferUntil  *time.Time             `json:"deferUntil,omitempty"`
+	Someday     bool                   `json:"someday,omitempty"`
+	Checklist   []ChecklistItem        `json:"checklist,omitempty" validate:"dive"`
+	Fields      map[string]interface{} `json:"fields,omitempty"`
+	ICalUID     string                 `json:"icalUid,omitempty"`
+	Extensions  map[string]string      `json:"extensions,omitempty"`
+	CreatedAt   time.Time              `json:"createdAt"`
+	UpdatedAt   time.Time              `json:"updatedAt"`
+	CompletedAt *time.Time             `json:"completedAt,omitempty"`
+}
+
+// SetStatus changes the status of the task and keeps CompletedAt in sync
+// with it.
+func (t *Task) SetStatus(status string, now time.Time) {
+	if status == t.Status {
+		return
+	}
+	t.Status = status
+	if status == StatusDone {
+		t.CompletedAt = &now
+	} else {
+		t.CompletedAt = nil
+	}
+}
+
+// Deferred reports whether the task is snoozed past now or parked for
+// someday, and so hidden from the default listings.
+func (t *Task) Deferred(now time.Time) bool {
+	return t.Someday || t.DeferUntil != nil && t.DeferUntil.After(now)
+}
+
+func (t *Task) HasTag(tag string) bool {
+	for _, have := range t.Tags {
+		if strings.EqualFold(have, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddTags adds the tags the task does not have yet, ignoring case.
+func (t *Task) AddTags(tags ...string) {
+	for _, tag := range tags {
+		if tag != "" && !t.HasTag(tag) {
+			t.Tags = append(t.Tags, tag)
+		}
+	}
+}
+
+func (t *Task) RemoveTags(tags ...string) {
+	kept := t.Tags[:0]
+	for _, have := range t.Tags {
+		remove := false
+		for _, tag := range tags {
+			if strings.EqualFold(have, tag) {
+				remove = true
+				break
+			}
+		}
+		if !remove {
+			kept = append(kept, have)
+		}
+	}
+	t.Tags = kept
+	if len(t.Tags) == 0 {
+		t.Tags = nil
+	}
 }
 
 func (db *DB) CreateTask(task *Task) (uuid.UUID, error) {
 	err := db.db.Update(func(tx *bolt.Tx) error {
-		bucket, err := tx.CreateBucketIfNotExists(userBucket)
-		if err != nil {
-			return err
+		return putNewTask(tx, task)
+	})
+	return task.ID, err
+}
+
+// CreateTasks stores all tasks in a single transaction. Either every task is
+// created or none is.
+func (db *DB) CreateTasks(tasks []*Task) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		for _, task := range tasks {
+			if err := putNewTask(tx, task); err != nil {
+				return err
+			}
 		}
-		id := []byte(task.ID.String())
-		if !checkDuplicate(bucket.Cursor(), id) {
-			data, _ := json.Marshal(task)
-			err = bucket.Put(id, data)
-		} else {
-			return ErrUserAlreadyExists
+		return nil
+	})
+}
+
+func putNewTask(tx *bolt.Tx, task *Task) error {
+	bucket, err := tx.CreateBucketIfNotExists(taskBucket)
+	if err != nil {
+		return err
+	}
+	id := []byte(task.ID.String())
+	if checkDuplicate(bucket.Cursor(), id) {
+		return ErrTaskAlreadyExists
+	}
+	if task.Rank == "" {
+		return appendRankTx(tx, task)
+	}
+	return putTaskTx(tx, task)
+}
+
+// putTaskTx stores the task, updating the statistics and sync revisions of
+// its user. Every task write goes through here or deleteTaskTx.
+func putTaskTx(tx *bolt.Tx, task *Task) error {
+	bucket, err := tx.CreateBucketIfNotExists(taskBucket)
+	if err != nil {
+		return err
+	}
+	key := []byte(task.ID.String())
+	var old *Task
+	if b := bucket.Get(key); b != nil {
+		old = &Task{}
+		if err := json.Unmarshal(b, old); err != nil {
+			return err
 		}
+	}
+	if err := updateStatsTx(tx, old, task); err != nil {
 		return err
-	})
-	return task.ID, err
+	}
+	if err := updateSyncTx(tx, old, task); err != nil {
+		return err
+	}
+	if err := indexTaskTx(tx, old, task); err != nil {
+		return err
+	}
+	data, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, data)
 }
 
 func (db *DB) GetTask(id string) (*Task, error) {
@@ -46,11 +180,11 @@ func (db *DB) GetTask(id string) (*Task, error) {
 	if err := db.db.View(func(tx *bolt.Tx) error {
 		bucket := tx.Bucket(taskBucket)
 		if bucket == nil {
-			return ErrUserNotFound
+			return ErrTaskNotFound
 		}
 		b := bucket.Get([]byte(id))
 		if b == nil {
-			return ErrUserNotFound
+			return ErrTaskNotFound
 		}
 		err := json.Unmarshal(b, task)
 		return err
@@ -59,3 +193,368 @@ func (db *DB) GetTask(id string) (*Task, error) {
 	}
 	return task, nil
 }
+
+// GetTasks returns the tasks with the ids in a single transaction. Ids
+// without a task are left out.
+func (db *DB) GetTasks(ids []uuid.UUID) ([]Task, error) {
+	tasks := []Task{}
+	err := db.db.View(func(tx *bolt.Tx) error {
+		for _, id := range ids {
+			task, err := getTaskTx(tx, id)
+			if errors.Is(err, ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			tasks = append(tasks, *task)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func getTaskTx(tx *bolt.Tx, id uuid.UUID) (*Task, error) {
+	bucket := tx.Bucket(taskBucket)
+	if bucket == nil {
+		return nil, ErrNoRows
+	}
+	b := bucket.Get([]byte(id.String()))
+	if b == nil {
+		return nil, ErrNoRows
+	}
+	task := &Task{}
+	if err := json.Unmarshal(b, task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// GetAllTasksFromUser returns the tasks of the user in their manual order.
+func (db *DB) GetAllTasksFromUser(ctx context.Context, username string) ([]Task, error) {
+	var tasks []Task
+	err := db.db.View(func(tx *bolt.Tx) error {
+		var err error
+		tasks, err = userTasksTx(tx, username)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// userTasksTx returns the tasks of the user in their manual order, reading
+// only those listed in the index of the user.
+func userTasksTx(tx *bolt.Tx, username string) ([]Task, error) {
+	tasks := []Task{}
+	bucket := tx.Bucket(taskBucket)
+	index := tx.Bucket(userTaskBucket)
+	if bucket == nil || index == nil {
+		return tasks, nil
+	}
+	owned := index.Bucket([]byte(username))
+	if owned == nil {
+		return tasks, nil
+	}
+	err := owned.ForEach(func(k, _ []byte) error {
+		v := bucket.Get(k)
+		if v == nil {
+			return nil
+		}
+		var task Task
+		if err := json.Unmarshal(v, &task); err != nil {
+			return err
+		}
+		tasks = append(tasks, task)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	SortByRank(tasks)
+	return tasks, nil
+}
+
+// TaskKey is what the per-user indexes hold of a task: its status from the
+// task index, its project from the sync entries and its due date from the
+// statistics. The statistics drop the due dates of completed tasks, so
+// DueKnown is false for those.
+type TaskKey struct {
+	ID       uuid.UUID
+	Status   string
+	Project  string
+	Due      *time.Time
+	DueKnown bool
+}
+
+// GetTasksByKey returns the tasks of the user whose keys satisfy keep, in
+// their manual order. Only the tasks that are kept are decoded.
+func (db *DB) GetTasksByKey(username string, keep func(k *TaskKey) bool) ([]Task, error) {
+	tasks := []Task{}
+	err := db.db.View(func(tx *bolt.Tx) error {
+		keys, err := userTaskKeysTx(tx, username)
+		if err != nil {
+			return err
+		}
+		bucket := tx.Bucket(taskBucket)
+		for i := range keys {
+			if !keep(&keys[i]) {
+				continue
+			}
+			v := bucket.Get([]byte(keys[i].ID.String()))
+			if v == nil {
+				continue
+			}
+			var task Task
+			if err := json.Unmarshal(v, &task); err != nil {
+				return err
+			}
+			tasks = append(tasks, task)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	SortByRank(tasks)
+	return tasks, nil
+}
+
+// userTaskKeysTx joins the task index, the sync entries and the due dates
+// of the statistics of the user.
+func userTaskKeysTx(tx *bolt.Tx, username string) ([]TaskKey, error) {
+	keys := []TaskKey{}
+	index := tx.Bucket(userTaskBucket)
+	if index == nil {
+		return keys, nil
+	}
+	owned := index.Bucket([]byte(username))
+	if owned == nil {
+		return keys, nil
+	}
+
+	projects := map[uuid.UUID]string{}
+	if b := syncBucketTx(tx, username); b != nil {
+		err := b.ForEach(func(k, v []byte) error {
+			var e SyncEntry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			if !e.Deleted {
+				projects[e.TaskID] = e.Project
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dues := map[uuid.UUID]time.Time{}
+	if root := tx.Bucket(statsBucket); root != nil {
+		if b := root.Bucket([]byte(username)); b != nil {
+			err := b.Bucket(statsDueBucket).ForEach(func(k, v []byte) error {
+				id, err := uuid.ParseBytes(k)
+				if err != nil {
+					return err
+				}
+				var due time.Time
+				if err := due.UnmarshalText(v); err != nil {
+					return err
+				}
+				dues[id] = due
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err := owned.ForEach(func(k, v []byte) error {
+		id, err := uuid.ParseBytes(k)
+		if err != nil {
+			return err
+		}
+		key := TaskKey{ID: id, Status: string(v), Project: projects[id]}
+		due, ok := dues[id]
+		if ok {
+			key.Due = &due
+		}
+		key.DueKnown = ok || key.Status != StatusDone
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// indexTaskTx keeps the index of the task ids of every user, which maps them
+// to the task status, in step with a write of the task. old is nil for a new task and new is nil for a deleted
+// one.
+func indexTaskTx(tx *bolt.Tx, old *Task, new *Task) error {
+	root, err := tx.CreateBucketIfNotExists(userTaskBucket)
+	if err != nil {
+		return err
+	}
+	if old != nil && (new == nil || old.User != new.User) {
+		if b := root.Bucket([]byte(old.User)); b != nil {
+			if err := b.Delete([]byte(old.ID.String())); err != nil {
+				return err
+			}
+		}
+	}
+	if new == nil {
+		return nil
+	}
+	b, err := root.CreateBucketIfNotExists([]byte(new.User))
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(new.ID.String()), []byte(new.Status))
+}
+
+// initTaskIndexTx builds the index of the task ids of every user, if the
+// tasks were stored by a version without it.
+func initTaskIndexTx(tx *bolt.Tx) error {
+	if tx.Bucket(userTaskBucket) != nil {
+		return nil
+	}
+	if _, err := tx.CreateBucket(userTaskBucket); err != nil {
+		return err
+	}
+	bucket := tx.Bucket(taskBucket)
+	if bucket == nil {
+		return nil
+	}
+	return bucket.ForEach(func(k, v []byte) error {
+		var task Task
+		if err := json.Unmarshal(v, &task); err != nil {
+			return err
+		}
+		return indexTaskTx(tx, nil, &task)
+	})
+}
+
+// taskUsersTx returns the users that have stored tasks.
+func taskUsersTx(tx *bolt.Tx) ([]string, error) {
+	users := []string{}
+	index := tx.Bucket(userTaskBucket)
+	if index == nil {
+		return users, nil
+	}
+	err := index.ForEach(func(k, v []byte) error {
+		if v == nil {
+			users = append(users, string(k))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetResurfacingTasks returns the tasks of all users whose deferral ended at
+// or before now.
+func (db *DB) GetResurfacingTasks(now time.Time) ([]Task, error) {
+	tasks := []Task{}
+	err := db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(taskBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var task Task
+			if err := json.Unmarshal(v, &task); err != nil {
+				return err
+			}
+			if task.DeferUntil != nil && !task.DeferUntil.After(now) {
+				tasks = append(tasks, task)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+// UpdateTaskFunc loads the task with the given ID, passes it to fn and stores
+// the result, all within a single transaction. If fn returns an error nothing
+// is written.
+func (db *DB) UpdateTaskFunc(id uuid.UUID, fn func(task *Task) error) (*Task, error) {
+	var task *Task
+	err := db.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		task, err = updateTaskTx(tx, id, fn)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func updateTaskTx(tx *bolt.Tx, id uuid.UUID, fn func(task *Task) error) (*Task, error) {
+	bucket := tx.Bucket(taskBucket)
+	if bucket == nil {
+		return nil, ErrNoRows
+	}
+	key := []byte(id.String())
+	b := bucket.Get(key)
+	if b == nil {
+		return nil, ErrNoRows
+	}
+	task := &Task{}
+	if err := json.Unmarshal(b, task); err != nil {
+		return nil, err
+	}
+	if err := fn(task); err != nil {
+		return nil, err
+	}
+	return task, putTaskTx(tx, task)
+}
+
+func (db *DB) DeleteTask(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	err := db.db.Update(func(tx *bolt.Tx) error {
+		return deleteTaskTx(tx, id)
+	})
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return id, nil
+}
+
+func deleteTaskTx(tx *bolt.Tx, id uuid.UUID) error {
+	bucket := tx.Bucket(taskBucket)
+	if bucket == nil {
+		return ErrNoRows
+	}
+	key := []byte(id.String())
+	b := bucket.Get(key)
+	if b == nil {
+		return ErrNoRows
+	}
+	old := &Task{}
+	if err := json.Unmarshal(b, old); err != nil {
+		return err
+	}
+	if err := updateStatsTx(tx, old, nil); err != nil {
+		return err
+	}
+	if err := updateSyncTx(tx, old, nil); err != nil {
+		return err
+	}
+	if err := indexTaskTx(tx, old, nil); err != nil {
+		return err
+	}
+	return bucket.Delete(key)
+}
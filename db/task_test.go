@@ -0,0 +1,118 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestDeferredTasks(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Date(2023, 4, 5, 15, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+
+	tasks := []*Task{
+		{ID: uuid.New(), Title: "Past", User: "alice", DeferUntil: &past, CreatedAt: now},
+		{ID: uuid.New(), Title: "Now", User: "bob", DeferUntil: &now, CreatedAt: now},
+		{ID: uuid.New(), Title: "Future", User: "alice", DeferUntil: &future, CreatedAt: now},
+		{ID: uuid.New(), Title: "Someday", User: "alice", Someday: true, CreatedAt: now},
+		{ID: uuid.New(), Title: "Plain", User: "alice", CreatedAt: now},
+	}
+	require.NoError(t, db.CreateTasks(tasks))
+
+	deferred := []string{}
+	for _, task := range tasks {
+		if task.Deferred(now) {
+			deferred = append(deferred, task.Title)
+		}
+	}
+	require.Equal(t, []string{"Future", "Someday"}, deferred)
+
+	resurfacing, err := db.GetResurfacingTasks(now)
+	require.NoError(t, err)
+	titles := []string{}
+	for _, task := range resurfacing {
+		titles = append(titles, task.Title)
+	}
+	require.ElementsMatch(t, []string{"Past", "Now"}, titles)
+}
+
+func TestUserTasks(t *testing.T) {
+	db := newTestDB(t)
+	a := &Task{ID: uuid.New(), Title: "first", User: "alice"}
+	b := &Task{ID: uuid.New(), Title: "second", User: "alice"}
+	other := &Task{ID: uuid.New(), Title: "other", User: "bob"}
+	require.NoError(t, db.CreateTasks([]*Task{a, b, other}))
+	_, err := db.DeleteTask(context.Background(), b.ID)
+	require.NoError(t, err)
+
+	// The tasks of other users are not read at all.
+	require.NoError(t, db.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(taskBucket).Put([]byte(other.ID.String()), []byte("{"))
+	}))
+	tasks, err := db.GetAllTasksFromUser(context.Background(), "alice")
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	require.Equal(t, a.ID, tasks[0].ID)
+
+	require.NoError(t, db.db.Update(func(tx *bolt.Tx) error {
+		users, err := taskUsersTx(tx)
+		require.ElementsMatch(t, []string{"alice", "bob"}, users)
+		return err
+	}))
+}
+
+func TestTasksByKey(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Date(2023, 4, 5, 15, 0, 0, 0, time.UTC)
+	due := now.Add(time.Hour)
+	open := &Task{ID: uuid.New(), Title: "open", User: "alice", Project: "home", Status: StatusTodo, Due: &due, Rank: "a"}
+	done := &Task{ID: uuid.New(), Title: "done", User: "alice", Project: "home", Status: StatusTodo, Due: &due, Rank: "b"}
+	moved := &Task{ID: uuid.New(), Title: "moved", User: "alice", Project: "home", Rank: "c"}
+	require.NoError(t, db.CreateTasks([]*Task{open, done, moved}))
+	require.NoError(t, db.InTransaction("alice", func(tx *TaskTx) error {
+		if _, err := tx.UpdateTask(done.ID, func(task *Task) error {
+			task.Status, task.CompletedAt = StatusDone, &now
+			return nil
+		}); err != nil {
+			return err
+		}
+		_, err := tx.UpdateTask(moved.ID, func(task *Task) error {
+			task.Project = "work"
+			return nil
+		})
+		return err
+	}))
+
+	keys := map[uuid.UUID]TaskKey{}
+	tasks, err := db.GetTasksByKey("alice", func(k *TaskKey) bool {
+		keys[k.ID] = *k
+		return k.Project == "home"
+	})
+	require.NoError(t, err)
+	require.Len(t, tasks, 2)
+	require.Equal(t, []string{"open", "done"}, []string{tasks[0].Title, tasks[1].Title})
+
+	require.Equal(t, StatusTodo, keys[open.ID].Status)
+	require.True(t, keys[open.ID].DueKnown)
+	require.True(t, due.Equal(*keys[open.ID].Due))
+	// Completed tasks leave the due dates of the statistics.
+	require.Equal(t, StatusDone, keys[done.ID].Status)
+	require.False(t, keys[done.ID].DueKnown)
+	require.Equal(t, "work", keys[moved.ID].Project)
+	require.True(t, keys[moved.ID].DueKnown)
+	require.Nil(t, keys[moved.ID].Due)
+
+	// Tasks that are not kept are not decoded.
+	require.NoError(t, db.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(taskBucket).Put([]byte(moved.ID.String()), []byte("{"))
+	}))
+	tasks, err = db.GetTasksByKey("alice", func(k *TaskKey) bool { return k.Status == StatusTodo })
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	require.Equal(t, open.ID, tasks[0].ID)
+}
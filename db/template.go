@@ -0,0 +1,115 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("requested template is not found")
+	templateBucket      = []byte("template")
+)
+
+// Template describes a set of tasks that can be instantiated repeatedly.
+// Titles, texts and checklist items may contain {{placeholders}}.
+type Template struct {
+	ID        uuid.UUID      `json:"id"`
+	User      string         `json:"user"`
+	Name      string         `json:"name" validate:"required"`
+	Tasks     []TemplateTask `json:"tasks" validate:"required,min=1,dive"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+}
+
+type TemplateTask struct {
+	Title     string         `json:"title" validate:"required"`
+	Text      string         `json:"text,omitempty"`
+	Project   string         `json:"project,omitempty"`
+	DueIn     string         `json:"dueIn,omitempty"`
+	Checklist []string       `json:"checklist,omitempty" validate:"dive,required"`
+	Subtasks  []TemplateTask `json:"subtasks,omitempty" validate:"dive"`
+}
+
+func (db *DB) PutTemplate(template *Template) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(templateBucket)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(template)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(template.ID.String()), data)
+	})
+}
+
+func (db *DB) GetTemplate(username string, id uuid.UUID) (*Template, error) {
+	template := &Template{}
+	if err := db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(templateBucket)
+		if bucket == nil {
+			return ErrTemplateNotFound
+		}
+		b := bucket.Get([]byte(id.String()))
+		if b == nil {
+			return ErrTemplateNotFound
+		}
+		return json.Unmarshal(b, template)
+	}); err != nil {
+		return nil, err
+	}
+	if template.User != username {
+		return nil, ErrTemplateNotFound
+	}
+	return template, nil
+}
+
+func (db *DB) GetTemplates(username string) ([]Template, error) {
+	templates := []Template{}
+	err := db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(templateBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var template Template
+			if err := json.Unmarshal(v, &template); err != nil {
+				return err
+			}
+			if template.User == username {
+				templates = append(templates, template)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (db *DB) DeleteTemplate(username string, id uuid.UUID) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(templateBucket)
+		if bucket == nil {
+			return ErrTemplateNotFound
+		}
+		key := []byte(id.String())
+		b := bucket.Get(key)
+		if b == nil {
+			return ErrTemplateNotFound
+		}
+		var template Template
+		if err := json.Unmarshal(b, &template); err != nil {
+			return err
+		}
+		if template.User != username {
+			return ErrTemplateNotFound
+		}
+		return bucket.Delete(key)
+	})
+}
@@ -0,0 +1,238 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	ErrTimerRunning       = errors.New("a timer is already running for the user")
+	ErrTimerNotRunning    = errors.New("no timer is running for the user")
+	ErrTimeEntryNotFound  = errors.New("requested time entry is not found")
+	timeEntryBucket       = []byte("time_entry")
+	runningTimerBucket    = []byte("running_timer")
+	errTimeEntryMalformed = errors.New("time entry is malformed")
+)
+
+// TimeEntry is a span of time spent by a user on a task. A running timer is
+// an entry without an End.
+type TimeEntry struct {
+	ID        uuid.UUID  `json:"id"`
+	TaskID    uuid.UUID  `json:"taskId"`
+	User      string     `json:"user"`
+	Start     time.Time  `json:"start"`
+	End       *time.Time `json:"end,omitempty"`
+	Note      string     `json:"note,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+func (e *TimeEntry) Running() bool {
+	return e.End == nil
+}
+
+// Duration returns the tracked time of the entry. Running entries are
+// measured up to now.
+func (e *TimeEntry) Duration(now time.Time) time.Duration {
+	if e.End != nil {
+		return e.End.Sub(e.Start)
+	}
+	return now.Sub(e.Start)
+}
+
+func putTimeEntry(tx *bolt.Tx, entry *TimeEntry) error {
+	bucket, err := tx.CreateBucketIfNotExists(timeEntryBucket)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return bucket.Put([]byte(entry.ID.String()), data)
+}
+
+func getTimeEntry(tx *bolt.Tx, id []byte) (*TimeEntry, error) {
+	bucket := tx.Bucket(timeEntryBucket)
+	if bucket == nil {
+		return nil, ErrTimeEntryNotFound
+	}
+	b := bucket.Get(id)
+	if b == nil {
+		return nil, ErrTimeEntryNotFound
+	}
+	entry := &TimeEntry{}
+	if err := json.Unmarshal(b, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// checkTimeEntryTask verifies that the task of the entry exists and belongs
+// to the user of the entry.
+func checkTimeEntryTask(tx *bolt.Tx, entry *TimeEntry) error {
+	task, err := getTaskTx(tx, entry.TaskID)
+	if err != nil {
+		return err
+	}
+	if task.User != entry.User {
+		return ErrNoRows
+	}
+	return nil
+}
+
+// StartTimer stores a running entry for the user. Only one timer may run per
+// user at a time.
+func (db *DB) StartTimer(entry *TimeEntry) error {
+	if !entry.Running() {
+		return errTimeEntryMalformed
+	}
+	return db.db.Update(func(tx *bolt.Tx) error {
+		running, err := tx.CreateBucketIfNotExists(runningTimerBucket)
+		if err != nil {
+			return err
+		}
+		if running.Get([]byte(entry.User)) != nil {
+			return ErrTimerRunning
+		}
+		if err := checkTimeEntryTask(tx, entry); err != nil {
+			return err
+		}
+		if err := putTimeEntry(tx, entry); err != nil {
+			return err
+		}
+		return running.Put([]byte(entry.User), []byte(entry.ID.String()))
+	})
+}
+
+// StopTimer ends the running timer of the user at the given time.
+func (db *DB) StopTimer(username string, end time.Time) (*TimeEntry, error) {
+	var entry *TimeEntry
+	err := db.db.Update(func(tx *bolt.Tx) error {
+		running := tx.Bucket(runningTimerBucket)
+		if running == nil {
+			return ErrTimerNotRunning
+		}
+		id := running.Get([]byte(username))
+		if id == nil {
+			return ErrTimerNotRunning
+		}
+		var err error
+		entry, err = getTimeEntry(tx, id)
+		if err != nil {
+			return err
+		}
+		if end.Before(entry.Start) {
+			end = entry.Start
+		}
+		entry.End = &end
+		if err := putTimeEntry(tx, entry); err != nil {
+			return err
+		}
+		return running.Delete([]byte(username))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// GetRunningTimer returns the running entry of the user.
+func (db *DB) GetRunningTimer(username string) (*TimeEntry, error) {
+	var entry *TimeEntry
+	err := db.db.View(func(tx *bolt.Tx) error {
+		running := tx.Bucket(runningTimerBucket)
+		if running == nil {
+			return ErrTimerNotRunning
+		}
+		id := running.Get([]byte(username))
+		if id == nil {
+			return ErrTimerNotRunning
+		}
+		var err error
+		entry, err = getTimeEntry(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// CreateTimeEntry stores a manually entered, finished time entry.
+func (db *DB) CreateTimeEntry(entry *TimeEntry) error {
+	if entry.Running() {
+		return errTimeEntryMalformed
+	}
+	return db.db.Update(func(tx *bolt.Tx) error {
+		if err := checkTimeEntryTask(tx, entry); err != nil {
+			return err
+		}
+		return putTimeEntry(tx, entry)
+	})
+}
+
+func (db *DB) DeleteTimeEntry(username string, id uuid.UUID) error {
+	return db.db.Update(func(tx *bolt.Tx) error {
+		key := []byte(id.String())
+		entry, err := getTimeEntry(tx, key)
+		if err != nil {
+			return err
+		}
+		if entry.User != username {
+			return ErrTimeEntryNotFound
+		}
+		if entry.Running() {
+			if running := tx.Bucket(runningTimerBucket); running != nil {
+				if err := running.Delete([]byte(username)); err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Bucket(timeEntryBucket).Delete(key)
+	})
+}
+
+// GetTimeEntries returns the entries of the user which overlap the
+// [from, to) interval. A zero from or to leaves that side unbounded, and a
+// non-nil taskID restricts the result to a single task.
+func (db *DB) GetTimeEntries(username string, taskID uuid.UUID, from time.Time, to time.Time) ([]TimeEntry, error) {
+	entries := []TimeEntry{}
+	now := time.Now()
+	err := db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(timeEntryBucket)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var entry TimeEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				return err
+			}
+			if entry.User != username {
+				return nil
+			}
+			if taskID != uuid.Nil && entry.TaskID != taskID {
+				return nil
+			}
+			end := now
+			if entry.End != nil {
+				end = *entry.End
+			}
+			if !to.IsZero() && !entry.Start.Before(to) {
+				return nil
+			}
+			if !from.IsZero() && end.Before(from) {
+				return nil
+			}
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
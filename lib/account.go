@@ -0,0 +1,78 @@
+package lib
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"time"
+
+	"tasks/db"
+)
+
+// AccountProfile is the part of a user written to an account export. The
+// password hash is left out.
+type AccountProfile struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+// AccountData is everything stored for a user, as written to an account
+// export.
+type AccountData struct {
+	Profile     AccountProfile       `json:"profile"`
+	Tasks       []db.Task            `json:"tasks"`
+	History     []db.AuditEvent      `json:"history"`
+	TimeEntries []db.TimeEntry       `json:"timeEntries"`
+	Templates   []db.Template        `json:"templates"`
+	Filters     []db.SavedFilter     `json:"filters"`
+	Fields      []db.FieldDefinition `json:"fields"`
+	ExportedAt  time.Time            `json:"exportedAt"`
+}
+
+// WriteAccountArchive writes the data as a ZIP archive: a JSON file for
+// each kind of record, and a Markdown file for each task under tasks/.
+func WriteAccountArchive(w io.Writer, data *AccountData) error {
+	z := zip.NewWriter(w)
+	add := func(name string, content []byte) error {
+		f, err := z.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: data.ExportedAt})
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(content)
+		return err
+	}
+	addJSON := func(name string, v interface{}) error {
+		content, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("could not write %s! %v", name, err)
+		}
+		return add(name, append(content, '\n'))
+	}
+
+	for _, file := range []struct {
+		name string
+		v    interface{}
+	}{
+		{"profile.json", data.Profile},
+		{"tasks.json", data.Tasks},
+		{"history.json", data.History},
+		{"time_entries.json", data.TimeEntries},
+		{"templates.json", data.Templates},
+		{"filters.json", data.Filters},
+		{"fields.json", data.Fields},
+	} {
+		if err := addJSON(file.name, file.v); err != nil {
+			return err
+		}
+	}
+	for i := range data.Tasks {
+		content, err := TaskMarkdown(&data.Tasks[i])
+		if err != nil {
+			return err
+		}
+		if err := add("tasks/"+TaskMarkdownName(&data.Tasks[i]), content); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
@@ -14,6 +14,7 @@ type Config struct {
 	HTTPServerAddress   string        `mapstructure:"HTTP_SERVER_ADDRESS"`
 	PASETOSecret        string        `mapstructure:"PASETO_SYMMETRIC_KEY"`
 	AccessTokenDuration time.Duration `mapstructure:"ACCESS_TOKEN_DURATION"`
+	ResurfaceInterval   time.Duration `mapstructure:"RESURFACE_INTERVAL"`
 }
 
 // Load reads configuration from file or environment variables.
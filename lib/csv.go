@@ -0,0 +1,335 @@
+package lib
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"tasks/db"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidCSV = errors.New("invalid CSV")
+
+// CSVFieldPrefix addresses a custom field as a column, as in cf.points.
+const CSVFieldPrefix = "cf."
+
+// CSVIgnore maps a header to no column on import.
+const CSVIgnore = "-"
+
+// CSVColumns lists the columns of a task in CSV export and import, in their
+// default order.
+var CSVColumns = []string{
+	"id", "title", "text", "project", "status", "priority", "tags", "due", "recurrence",
+	"deferUntil", "someday", "createdAt", "updatedAt", "completedAt",
+}
+
+// csvDateLayouts are the layouts accepted for dates on import, tried in
+// order. Values without an offset are taken as UTC.
+var csvDateLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"}
+
+// csvColumn returns the canonical name of a column, matched
+// case-insensitively.
+func csvColumn(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if len(name) > len(CSVFieldPrefix) && strings.EqualFold(name[:len(CSVFieldPrefix)], CSVFieldPrefix) {
+		return CSVFieldPrefix + name[len(CSVFieldPrefix):], true
+	}
+	for _, column := range CSVColumns {
+		if strings.EqualFold(column, name) {
+			return column, true
+		}
+	}
+	return "", false
+}
+
+// ParseCSVColumns reads a comma-separated list of columns. An empty list
+// selects CSVColumns.
+func ParseCSVColumns(spec string) ([]string, error) {
+	if strings.TrimSpace(spec) == "" {
+		return CSVColumns, nil
+	}
+	columns := []string{}
+	seen := map[string]bool{}
+	for _, name := range strings.Split(spec, ",") {
+		column, ok := csvColumn(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown column %q", ErrInvalidCSV, strings.TrimSpace(name))
+		}
+		if seen[column] {
+			return nil, fmt.Errorf("%w: duplicate column %q", ErrInvalidCSV, column)
+		}
+		seen[column] = true
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// CSVWriter writes tasks as CSV rows with a fixed set of columns.
+type CSVWriter struct {
+	w       *csv.Writer
+	columns []string
+	row     []string
+}
+
+func NewCSVWriter(w io.Writer, columns []string) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w), columns: columns, row: make([]string, len(columns))}
+}
+
+func (w *CSVWriter) WriteHeader() error {
+	return w.w.Write(w.columns)
+}
+
+func (w *CSVWriter) Write(t *db.Task) error {
+	for i, column := range w.columns {
+		w.row[i] = csvValue(t, column)
+	}
+	return w.w.Write(w.row)
+}
+
+// Flush writes any buffered rows and reports the first write error.
+func (w *CSVWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+func csvTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func csvValue(t *db.Task, column string) string {
+	switch column {
+	case "id":
+		return t.ID.String()
+	case "title":
+		return t.Title
+	case "text":
+		return t.Text
+	case "project":
+		return t.Project
+	case "status":
+		return t.Status
+	case "priority":
+		return t.Priority
+	case "tags":
+		return strings.Join(t.Tags, " ")
+	case "due":
+		return csvTime(t.Due)
+	case "recurrence":
+		return t.Recurrence
+	case "deferUntil":
+		return csvTime(t.DeferUntil)
+	case "someday":
+		if t.Someday {
+			return "true"
+		}
+		return ""
+	case "createdAt":
+		return csvTime(&t.CreatedAt)
+	case "updatedAt":
+		return csvTime(&t.UpdatedAt)
+	case "completedAt":
+		return csvTime(t.CompletedAt)
+	}
+	switch v := t.Fields[strings.TrimPrefix(column, CSVFieldPrefix)].(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// CSVRowError describes a problem with a row of an imported CSV file. Line
+// is the line the row starts on, the header being line 1.
+type CSVRowError struct {
+	Line   int    `json:"line"`
+	Column string `json:"column,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// CSVRow is a task read from a CSV row. Only the columns of the reader are
+// set on Task. The raw values of custom field columns are kept in Fields by
+// key; Problems lists the values that could not be parsed.
+type CSVRow struct {
+	Line     int
+	Task     db.Task
+	Fields   map[string]string
+	Problems []CSVRowError
+}
+
+// CSVReader reads tasks from a CSV stream with a header row.
+type CSVReader struct {
+	r       *csv.Reader
+	index   []string
+	Columns []string
+	Ignored []string
+}
+
+// NewCSVReader reads the header of the CSV stream and maps it to task
+// columns. mapping maps header names to columns, or to CSVIgnore to skip
+// them; other headers map to the column of the same name and are ignored
+// when there is none. A title or id column is required.
+func NewCSVReader(r io.Reader, mapping map[string]string) (*CSVReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidCSV)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCSV, err)
+	}
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+
+	reader := &CSVReader{r: cr, index: make([]string, len(header)), Columns: []string{}, Ignored: []string{}}
+	used := map[string]bool{}
+	mapped := map[string]bool{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		target, explicit := mapping[name]
+		if !explicit {
+			target = name
+		}
+		mapped[name] = true
+		if target == CSVIgnore {
+			reader.Ignored = append(reader.Ignored, name)
+			continue
+		}
+		column, ok := csvColumn(target)
+		switch {
+		case !ok && explicit:
+			return nil, fmt.Errorf("%w: %q is mapped to unknown column %q", ErrInvalidCSV, name, target)
+		case !ok:
+			reader.Ignored = append(reader.Ignored, name)
+			continue
+		case used[column]:
+			return nil, fmt.Errorf("%w: more than one header maps to column %q", ErrInvalidCSV, column)
+		}
+		used[column] = true
+		reader.index[i] = column
+		reader.Columns = append(reader.Columns, column)
+	}
+	for name := range mapping {
+		if !mapped[name] {
+			return nil, fmt.Errorf("%w: mapped header %q is not in the file", ErrInvalidCSV, name)
+		}
+	}
+	if !used["title"] && !used["id"] {
+		return nil, fmt.Errorf("%w: no title or id column", ErrInvalidCSV)
+	}
+	return reader, nil
+}
+
+// Read returns the next row, or io.EOF after the last one. Blank rows are
+// skipped.
+func (r *CSVReader) Read() (*CSVRow, error) {
+	for {
+		record, err := r.r.Read()
+		if err == io.EOF {
+			return nil, err
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCSV, err)
+		}
+		line, _ := r.r.FieldPos(0)
+		if strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		row := &CSVRow{Line: line}
+		for i, value := range record {
+			if i >= len(r.index) || r.index[i] == "" {
+				continue
+			}
+			if err := row.set(r.index[i], value); err != nil {
+				row.Problems = append(row.Problems, CSVRowError{Line: line, Column: r.index[i], Value: value, Reason: err.Error()})
+			}
+		}
+		return row, nil
+	}
+}
+
+func parseCSVTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range csvDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, errors.New("not a date")
+}
+
+func (row *CSVRow) set(column string, value string) error {
+	value = strings.TrimSpace(value)
+	t := &row.Task
+	var err error
+	switch column {
+	case "id":
+		if value != "" {
+			if t.ID, err = uuid.Parse(value); err != nil {
+				err = errors.New("not an id")
+			}
+		}
+	case "title":
+		t.Title = value
+	case "text":
+		t.Text = value
+	case "project":
+		t.Project = value
+	case "status":
+		t.Status = strings.NewReplacer(" ", "_", "-", "_").Replace(strings.ToLower(value))
+	case "priority":
+		t.Priority = strings.ToLower(value)
+	case "tags":
+		t.Tags = nil
+		for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+			t.AddTags(strings.TrimPrefix(tag, "#"))
+		}
+	case "due":
+		t.Due, err = parseCSVTime(value)
+	case "recurrence":
+		t.Recurrence = value
+	case "deferUntil":
+		t.DeferUntil, err = parseCSVTime(value)
+	case "someday":
+		switch strings.ToLower(value) {
+		case "", "0", "f", "false", "n", "no":
+			t.Someday = false
+		case "1", "t", "true", "y", "yes", "x":
+			t.Someday = true
+		default:
+			err = errors.New("not a boolean")
+		}
+	case "createdAt", "updatedAt":
+		var at *time.Time
+		if at, err = parseCSVTime(value); at != nil {
+			if column == "createdAt" {
+				t.CreatedAt = *at
+			} else {
+				t.UpdatedAt = *at
+			}
+		}
+	case "completedAt":
+		t.CompletedAt, err = parseCSVTime(value)
+	default:
+		if row.Fields == nil {
+			row.Fields = map[string]string{}
+		}
+		row.Fields[strings.TrimPrefix(column, CSVFieldPrefix)] = value
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+package lib
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"tasks/db"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func readCSVRows(t *testing.T, r *CSVReader) []*CSVRow {
+	rows := []*CSVRow{}
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return rows
+		}
+		require.NoError(t, err)
+		rows = append(rows, row)
+	}
+}
+
+func TestCSV(t *testing.T) {
+	due := time.Date(2023, 4, 5, 15, 0, 0, 0, time.UTC)
+	task := db.Task{
+		ID: uuid.New(), Title: "Water plants", Text: "Kitchen, then \"balcony\"\nand hall", Project: "home",
+		Status: db.StatusTodo, Priority: db.PriorityHigh, Tags: []string{"home", "weekly"}, Due: &due,
+		Fields: map[string]interface{}{"points": 2.5, "room": "kitchen"}, CreatedAt: due,
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		columns, err := ParseCSVColumns("ID, title,text,project,status,priority,tags,due,cf.points,CF.room")
+		require.NoError(t, err)
+		require.Equal(t, "cf.room", columns[len(columns)-1])
+
+		var buf bytes.Buffer
+		w := NewCSVWriter(&buf, columns)
+		require.NoError(t, w.WriteHeader())
+		require.NoError(t, w.Write(&task))
+		require.NoError(t, w.Flush())
+		require.Contains(t, buf.String(), "home weekly,2023-04-05T15:00:00Z,2.5,kitchen\n")
+
+		r, err := NewCSVReader(&buf, nil)
+		require.NoError(t, err)
+		require.Equal(t, columns, r.Columns)
+		rows := readCSVRows(t, r)
+		require.Len(t, rows, 1)
+		require.Empty(t, rows[0].Problems)
+		require.Equal(t, 2, rows[0].Line)
+		require.Equal(t, map[string]string{"points": "2.5", "room": "kitchen"}, rows[0].Fields)
+
+		got := rows[0].Task
+		require.Equal(t, task.ID, got.ID)
+		require.Equal(t, task.Text, got.Text)
+		require.Equal(t, task.Tags, got.Tags)
+		require.Equal(t, task.Due, got.Due)
+		require.Equal(t, task.Priority, got.Priority)
+
+		_, err = ParseCSVColumns("title,colour")
+		require.ErrorIs(t, err, ErrInvalidCSV)
+		_, err = ParseCSVColumns("title,Title")
+		require.ErrorIs(t, err, ErrInvalidCSV)
+	})
+
+	t.Run("mapping", func(t *testing.T) {
+		data := "\ufeffName,Notes,Due Date,Done?,Owner\n" +
+			"Buy milk,,2023-04-06,no,me\n" +
+			",,,,\n" +
+			"\"Call\nmum\",x,tomorrow,maybe,me\n"
+		r, err := NewCSVReader(strings.NewReader(data), map[string]string{"Name": "title", "Due Date": "due", "Notes": "-", "Done?": "someday"})
+		require.NoError(t, err)
+		require.Equal(t, []string{"title", "due", "someday"}, r.Columns)
+		require.Equal(t, []string{"Notes", "Owner"}, r.Ignored)
+
+		rows := readCSVRows(t, r)
+		require.Len(t, rows, 2)
+		require.Equal(t, "Buy milk", rows[0].Task.Title)
+		require.Equal(t, time.Date(2023, 4, 6, 0, 0, 0, 0, time.UTC), *rows[0].Task.Due)
+		require.Empty(t, rows[0].Problems)
+
+		require.Equal(t, 4, rows[1].Line)
+		require.Equal(t, []CSVRowError{
+			{Line: 4, Column: "due", Value: "tomorrow", Reason: "not a date"},
+			{Line: 4, Column: "someday", Value: "maybe", Reason: "not a boolean"},
+		}, rows[1].Problems)
+	})
+
+	t.Run("invalid header", func(t *testing.T) {
+		for _, tt := range []struct {
+			data    string
+			mapping map[string]string
+		}{
+			{"", nil},
+			{"Name,Notes\n", nil},
+			{"Name\n", map[string]string{"Name": "colour"}},
+			{"Name\n", map[string]string{"Title": "title"}},
+			{"title,Name\n", map[string]string{"Name": "title"}},
+		} {
+			_, err := NewCSVReader(strings.NewReader(tt.data), tt.mapping)
+			require.ErrorIs(t, err, ErrInvalidCSV, tt.data)
+		}
+	})
+}
@@ -0,0 +1,772 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"tasks/db"
+)
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// Filter is a parsed filter expression. Terms separated by spaces must all
+// match, OR has a lower precedence than the implicit AND, and a term is
+// negated with a leading "-" or NOT. Parentheses group terms.
+//
+//	status:todo tag:work due<today priority>=high -tag:someday
+//	(project:home OR project:garden) is:overdue
+//
+// Words without a field, and quoted phrases, match the title or text; a word
+// starting with "#" is a tag. Custom fields are compared as cf.<key>, by the
+// type of the stored value.
+type Filter struct {
+	root filterNode
+}
+
+// filterNode is a node of the filter AST. now carries the time zone in which
+// dates such as "today" are resolved.
+type filterNode interface {
+	match(t *db.Task, now time.Time) bool
+	String() string
+}
+
+type filterAnd []filterNode
+
+func (n filterAnd) match(t *db.Task, now time.Time) bool {
+	for _, child := range n {
+		if !child.match(t, now) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n filterAnd) String() string {
+	parts := make([]string, len(n))
+	for i, child := range n {
+		parts[i] = child.String()
+	}
+	return "(" + strings.Join(parts, " AND ") + ")"
+}
+
+type filterOr []filterNode
+
+func (n filterOr) match(t *db.Task, now time.Time) bool {
+	for _, child := range n {
+		if child.match(t, now) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n filterOr) String() string {
+	parts := make([]string, len(n))
+	for i, child := range n {
+		parts[i] = child.String()
+	}
+	return "(" + strings.Join(parts, " OR ") + ")"
+}
+
+type filterNot struct {
+	node filterNode
+}
+
+func (n filterNot) match(t *db.Task, now time.Time) bool {
+	return !n.node.match(t, now)
+}
+
+func (n filterNot) String() string {
+	return "NOT " + n.node.String()
+}
+
+// filterTerm compares one field of the task with a value.
+type filterTerm struct {
+	field string
+	op    string
+	value string
+	pred  func(t *db.Task, now time.Time) bool
+}
+
+func (n *filterTerm) match(t *db.Task, now time.Time) bool {
+	return n.pred(t, now)
+}
+
+func (n *filterTerm) String() string {
+	if n.field == "" {
+		return strconv.Quote(n.value)
+	}
+	return n.field + n.op + strconv.Quote(n.value)
+}
+
+// ParseFilter parses a filter expression. An empty expression matches every
+// task.
+func ParseFilter(query string) (*Filter, error) {
+	p := &filterParser{text: []rune(query)}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	if len(p.tokens) == 0 {
+		return &Filter{root: filterAnd{}}, nil
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok != nil {
+		return nil, p.errorf(tok.pos, "unexpected %q", tok.text)
+	}
+	return &Filter{root: root}, nil
+}
+
+// Match reports whether the task satisfies the filter. Relative dates are
+// resolved against now, in its location.
+func (f *Filter) Match(t *db.Task, now time.Time) bool {
+	return f.root.match(t, now)
+}
+
+// MatchField reports whether the task satisfies the terms on field that
+// every match of the filter satisfies, the ones joined by the top-level AND.
+// Those terms read field alone, so t may carry just that value, as known
+// from an index.
+func (f *Filter) MatchField(field string, t *db.Task, now time.Time) bool {
+	nodes, ok := f.root.(filterAnd)
+	if !ok {
+		nodes = filterAnd{f.root}
+	}
+	for _, node := range nodes {
+		if term, ok := node.(*filterTerm); ok && term.field == field && !term.match(t, now) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns the tasks matching the filter, in their original order.
+func (f *Filter) Apply(tasks []db.Task, now time.Time) []db.Task {
+	result := []db.Task{}
+	for i := range tasks {
+		if f.Match(&tasks[i], now) {
+			result = append(result, tasks[i])
+		}
+	}
+	return result
+}
+
+// String returns the normalised expression with explicit operators.
+func (f *Filter) String() string {
+	return f.root.String()
+}
+
+const (
+	filterLParen = "("
+	filterRParen = ")"
+	filterOrOp   = "OR"
+	filterNotOp  = "NOT"
+	filterAndOp  = "AND"
+)
+
+type filterToken struct {
+	kind  string // one of the operators above, or "" for a term
+	text  string
+	pos   int
+	field string
+	op    string
+	value string
+}
+
+type filterParser struct {
+	text   []rune
+	tokens []filterToken
+	next   int
+}
+
+func (p *filterParser) errorf(pos int, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s at position %d", ErrInvalidFilter, fmt.Sprintf(format, args...), pos)
+}
+
+func isFilterDelim(r rune) bool {
+	return unicode.IsSpace(r) || r == '(' || r == ')'
+}
+
+// readValue reads a quoted string or a word starting at i.
+func (p *filterParser) readValue(i int) (string, int, error) {
+	if i < len(p.text) && p.text[i] == '"' {
+		var b strings.Builder
+		for j := i + 1; j < len(p.text); j++ {
+			switch {
+			case p.text[j] == '\\' && j+1 < len(p.text):
+				j++
+				b.WriteRune(p.text[j])
+			case p.text[j] == '"':
+				return b.String(), j + 1, nil
+			default:
+				b.WriteRune(p.text[j])
+			}
+		}
+		return "", 0, p.errorf(i, "unterminated quote")
+	}
+	j := i
+	for j < len(p.text) && !isFilterDelim(p.text[j]) {
+		j++
+	}
+	return string(p.text[i:j]), j, nil
+}
+
+func (p *filterParser) lex() error {
+	i := 0
+	for i < len(p.text) {
+		r := p.text[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(' || r == ')':
+			p.tokens = append(p.tokens, filterToken{kind: string(r), text: string(r), pos: i})
+			i++
+			continue
+		case r == '-' && i+1 < len(p.text) && (p.text[i+1] == '(' || !isFilterDelim(p.text[i+1])):
+			p.tokens = append(p.tokens, filterToken{kind: filterNotOp, text: "-", pos: i})
+			i++
+			continue
+		case r == '|':
+			p.tokens = append(p.tokens, filterToken{kind: filterOrOp, text: "|", pos: i})
+			i++
+			continue
+		}
+
+		start := i
+		j := i
+		for j < len(p.text) && (unicode.IsLetter(p.text[j]) || p.text[j] == '_' || j > i && (p.text[j] == '.' || unicode.IsDigit(p.text[j]))) {
+			j++
+		}
+		op := ""
+		if j > i {
+			for _, candidate := range []string{"<=", ">=", "!=", ":", "<", ">", "="} {
+				if strings.HasPrefix(string(p.text[j:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+		}
+		if op == "" {
+			value, end, err := p.readValue(i)
+			if err != nil {
+				return err
+			}
+			tok := filterToken{text: string(p.text[start:end]), pos: start, value: value}
+			switch value {
+			case filterOrOp, filterNotOp, filterAndOp:
+				if p.text[start] != '"' {
+					tok.kind = value
+				}
+			}
+			p.tokens = append(p.tokens, tok)
+			i = end
+			continue
+		}
+
+		value, end, err := p.readValue(j + len(op))
+		if err != nil {
+			return err
+		}
+		p.tokens = append(p.tokens, filterToken{
+			text:  string(p.text[start:end]),
+			pos:   start,
+			field: strings.ToLower(string(p.text[i:j])),
+			op:    op,
+			value: value,
+		})
+		i = end
+	}
+	return nil
+}
+
+func (p *filterParser) peek() *filterToken {
+	if p.next < len(p.tokens) {
+		return &p.tokens[p.next]
+	}
+	return nil
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	nodes := filterOr{first}
+	for tok := p.peek(); tok != nil && tok.kind == filterOrOp; tok = p.peek() {
+		p.next++
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 1 {
+		return first, nil
+	}
+	return nodes, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	nodes := filterAnd{}
+	for tok := p.peek(); tok != nil && tok.kind != filterOrOp && tok.kind != filterRParen; tok = p.peek() {
+		if tok.kind == filterAndOp {
+			p.next++
+			continue
+		}
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	switch len(nodes) {
+	case 0:
+		pos := len(p.text)
+		if tok := p.peek(); tok != nil {
+			pos = tok.pos
+		}
+		return nil, p.errorf(pos, "expected a term")
+	case 1:
+		return nodes[0], nil
+	default:
+		return nodes, nil
+	}
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	tok := p.peek()
+	if tok == nil {
+		return nil, p.errorf(len(p.text), "expected a term")
+	}
+	p.next++
+	switch tok.kind {
+	case filterNotOp:
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{node}, nil
+	case filterLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.peek(); closing == nil || closing.kind != filterRParen {
+			return nil, p.errorf(tok.pos, "unclosed parenthesis")
+		}
+		p.next++
+		return node, nil
+	case "":
+		return p.term(tok)
+	default:
+		return nil, p.errorf(tok.pos, "unexpected %q", tok.text)
+	}
+}
+
+var filterPriorities = map[string]int{"": 0, db.PriorityLow: 1, db.PriorityMedium: 2, db.PriorityHigh: 3}
+
+// compareOrdered turns a three-way comparison result into a match for op.
+func compareOrdered(cmp int, op string) bool {
+	switch op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "!=":
+		return cmp != 0
+	default:
+		return cmp == 0
+	}
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func (p *filterParser) term(tok *filterToken) (filterNode, error) {
+	if tok.field == "" && len(tok.value) > 1 && tok.text[0] == '#' {
+		tok.field, tok.op = "tag", ":"
+	}
+	if tok.field == "tag" {
+		tok.value = strings.TrimPrefix(tok.value, "#")
+	}
+	n := &filterTerm{field: tok.field, op: tok.op, value: tok.value}
+	equality := tok.op == ":" || tok.op == "=" || tok.op == "!="
+	negate := func(pred func(t *db.Task, now time.Time) bool) func(t *db.Task, now time.Time) bool {
+		if tok.op != "!=" {
+			return pred
+		}
+		return func(t *db.Task, now time.Time) bool { return !pred(t, now) }
+	}
+	value := tok.value
+
+	if key := strings.TrimPrefix(tok.field, customFieldPrefix); key != tok.field {
+		op := tok.op
+		n.pred = func(t *db.Task, now time.Time) bool {
+			v, ok := t.Fields[key]
+			if !ok {
+				return op == "!="
+			}
+			cmp, ok := compareFieldQuery(v, value, now)
+			return ok && compareOrdered(cmp, op)
+		}
+		return n, nil
+	}
+
+	switch tok.field {
+	case "":
+		n.pred = func(t *db.Task, now time.Time) bool {
+			return containsFold(t.Title, value) || containsFold(t.Text, value)
+		}
+		return n, nil
+	case "status":
+		switch value {
+		case db.StatusTodo, db.StatusInProgress, db.StatusDone:
+		default:
+			return nil, p.errorf(tok.pos, "unknown status %q", value)
+		}
+		n.pred = func(t *db.Task, now time.Time) bool {
+			status := t.Status
+			if status == "" {
+				status = db.StatusTodo
+			}
+			return status == value
+		}
+	case "tag":
+		n.pred = func(t *db.Task, now time.Time) bool { return t.HasTag(value) }
+	case "project":
+		n.pred = func(t *db.Task, now time.Time) bool { return strings.EqualFold(t.Project, value) }
+	case "title":
+		n.pred = func(t *db.Task, now time.Time) bool { return containsFold(t.Title, value) }
+	case "text":
+		n.pred = func(t *db.Task, now time.Time) bool { return containsFold(t.Text, value) }
+	case "has":
+		has, ok := map[string]func(t *db.Task) bool{
+			"due":        func(t *db.Task) bool { return t.Due != nil },
+			"tags":       func(t *db.Task) bool { return len(t.Tags) > 0 },
+			"checklist":  func(t *db.Task) bool { return len(t.Checklist) > 0 },
+			"text":       func(t *db.Task) bool { return t.Text != "" },
+			"project":    func(t *db.Task) bool { return t.Project != "" },
+			"priority":   func(t *db.Task) bool { return t.Priority != "" },
+			"parent":     func(t *db.Task) bool { return t.ParentID != nil },
+			"recurrence": func(t *db.Task) bool { return t.Recurrence != "" },
+		}[value]
+		if key := strings.TrimPrefix(value, customFieldPrefix); key != value {
+			has, ok = func(t *db.Task) bool {
+				_, ok := t.Fields[key]
+				return ok
+			}, true
+		}
+		if !ok {
+			return nil, p.errorf(tok.pos, "unknown property %q", value)
+		}
+		n.pred = func(t *db.Task, now time.Time) bool { return has(t) }
+	case "is":
+		is, ok := map[string]func(t *db.Task, now time.Time) bool{
+			"done": func(t *db.Task, now time.Time) bool { return t.Status == db.StatusDone },
+			"open": func(t *db.Task, now time.Time) bool { return t.Status != db.StatusDone },
+			"overdue": func(t *db.Task, now time.Time) bool {
+				return t.Status != db.StatusDone && t.Due != nil && t.Due.Before(now)
+			},
+			"recurring": func(t *db.Task, now time.Time) bool { return t.Recurrence != "" },
+			"subtask":   func(t *db.Task, now time.Time) bool { return t.ParentID != nil },
+			"deferred":  func(t *db.Task, now time.Time) bool { return t.Deferred(now) },
+			"someday":   func(t *db.Task, now time.Time) bool { return t.Someday },
+		}[value]
+		if !ok {
+			return nil, p.errorf(tok.pos, "unknown state %q", value)
+		}
+		n.pred = is
+	case "priority":
+		want, ok := filterPriorities[strings.ToLower(value)]
+		if !ok || value == "" {
+			return nil, p.errorf(tok.pos, "unknown priority %q", value)
+		}
+		n.pred = func(t *db.Task, now time.Time) bool {
+			have := filterPriorities[t.Priority]
+			return compareOrdered(have-want, tok.op)
+		}
+		return n, nil
+	case "due", "created", "updated", "completed":
+		resolve, err := parseFilterDate(value)
+		if err != nil {
+			return nil, p.errorf(tok.pos, "%v", err)
+		}
+		field := map[string]func(t *db.Task) *time.Time{
+			"due":       func(t *db.Task) *time.Time { return t.Due },
+			"created":   func(t *db.Task) *time.Time { return &t.CreatedAt },
+			"updated":   func(t *db.Task) *time.Time { return &t.UpdatedAt },
+			"completed": func(t *db.Task) *time.Time { return t.CompletedAt },
+		}[tok.field]
+		op := tok.op
+		n.pred = func(t *db.Task, now time.Time) bool {
+			have := field(t)
+			if have == nil {
+				return false
+			}
+			start, end := resolve(now)
+			within := !have.Before(start) && have.Before(end) || start.Equal(end) && have.Equal(start)
+			switch op {
+			case "<":
+				return have.Before(start)
+			case "<=":
+				return have.Before(start) || within
+			case ">":
+				return !have.Before(start) && !within
+			case ">=":
+				return !have.Before(start)
+			case "!=":
+				return !within
+			default:
+				return within
+			}
+		}
+		return n, nil
+	default:
+		return nil, p.errorf(tok.pos, "unknown field %q", tok.field)
+	}
+
+	if !equality {
+		return nil, p.errorf(tok.pos, "%s only supports \":\" and \"!=\"", tok.field)
+	}
+	n.pred = negate(n.pred)
+	return n, nil
+}
+
+// parseFilterDate parses today, tomorrow, yesterday, now, YYYY-MM-DD and
+// relative days such as +3d or -1w. The returned function resolves the value
+// to the [start, end) interval of its day; for now both are the instant.
+func parseFilterDate(value string) (func(now time.Time) (time.Time, time.Time), error) {
+	day := func(offset func(now time.Time) time.Time) func(now time.Time) (time.Time, time.Time) {
+		return func(now time.Time) (time.Time, time.Time) {
+			d := offset(now)
+			start := time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, now.Location())
+			return start, start.AddDate(0, 0, 1)
+		}
+	}
+	days := func(n int) func(now time.Time) (time.Time, time.Time) {
+		return day(func(now time.Time) time.Time { return now.AddDate(0, 0, n) })
+	}
+
+	switch strings.ToLower(value) {
+	case "now":
+		return func(now time.Time) (time.Time, time.Time) { return now, now }, nil
+	case "today":
+		return days(0), nil
+	case "tomorrow":
+		return days(1), nil
+	case "yesterday":
+		return days(-1), nil
+	}
+
+	if len(value) > 2 && (value[0] == '+' || value[0] == '-') {
+		n, err := strconv.Atoi(value[1 : len(value)-1])
+		unit := map[byte]int{'d': 1, 'w': 7}[value[len(value)-1]]
+		if err == nil && unit > 0 {
+			if value[0] == '-' {
+				n = -n
+			}
+			return days(n * unit), nil
+		}
+	}
+
+	if d, err := time.Parse("2006-01-02", value); err == nil {
+		return day(func(now time.Time) time.Time {
+			return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, now.Location())
+		}), nil
+	}
+	return nil, fmt.Errorf("invalid date %q", value)
+}
+
+// TaskOrder sorts tasks by a comma separated list of keys, each optionally
+// prefixed with "-" for descending order. Tasks without a value for a key
+// come last regardless of the direction.
+type TaskOrder []taskSortKey
+
+type taskSortKey struct {
+	name string
+	desc bool
+}
+
+var taskSortFields = map[string]func(a, b *db.Task) (cmp int, ok bool){
+	"rank": func(a, b *db.Task) (int, bool) { return 0, true },
+	"title": func(a, b *db.Task) (int, bool) {
+		return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title)), true
+	},
+	"project": func(a, b *db.Task) (int, bool) {
+		return strings.Compare(strings.ToLower(a.Project), strings.ToLower(b.Project)), true
+	},
+	"status": func(a, b *db.Task) (int, bool) {
+		order := map[string]int{"": 0, db.StatusTodo: 0, db.StatusInProgress: 1, db.StatusDone: 2}
+		return order[a.Status] - order[b.Status], true
+	},
+	"priority": func(a, b *db.Task) (int, bool) {
+		return filterPriorities[a.Priority] - filterPriorities[b.Priority], true
+	},
+	"due":       func(a, b *db.Task) (int, bool) { return compareTimes(a.Due, b.Due) },
+	"completed": func(a, b *db.Task) (int, bool) { return compareTimes(a.CompletedAt, b.CompletedAt) },
+	"created":   func(a, b *db.Task) (int, bool) { return compareTimes(&a.CreatedAt, &b.CreatedAt) },
+	"updated":   func(a, b *db.Task) (int, bool) { return compareTimes(&a.UpdatedAt, &b.UpdatedAt) },
+}
+
+// taskSortField returns the comparison for a sort key, which is a task
+// property or a custom field.
+func taskSortField(name string) (func(a, b *db.Task) (int, bool), bool) {
+	key := strings.TrimPrefix(name, customFieldPrefix)
+	if key == name {
+		compare, ok := taskSortFields[name]
+		return compare, ok
+	}
+	return func(a, b *db.Task) (int, bool) {
+		va, okA := a.Fields[key]
+		vb, okB := b.Fields[key]
+		switch {
+		case !okA && !okB:
+			return 0, true
+		case !okA:
+			return 1, false
+		case !okB:
+			return -1, false
+		}
+		return compareFieldValues(va, vb), true
+	}, key != ""
+}
+
+// compareTimes compares two optional times. ok is false when exactly one of
+// them is missing, in which case cmp orders the missing one last.
+func compareTimes(a, b *time.Time) (int, bool) {
+	switch {
+	case a == nil && b == nil:
+		return 0, true
+	case a == nil:
+		return 1, false
+	case b == nil:
+		return -1, false
+	case a.Before(*b):
+		return -1, true
+	case b.Before(*a):
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+// ParseTaskOrder parses a sort specification such as "due,-priority". An
+// empty specification keeps the manual (rank) order.
+func ParseTaskOrder(spec string) (TaskOrder, error) {
+	order := TaskOrder{}
+	if strings.TrimSpace(spec) == "" {
+		return order, nil
+	}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		key := taskSortKey{name: strings.TrimPrefix(part, "-"), desc: strings.HasPrefix(part, "-")}
+		if _, ok := taskSortField(key.name); !ok {
+			return nil, fmt.Errorf("%w: unknown sort key %q", ErrInvalidFilter, key.name)
+		}
+		order = append(order, key)
+	}
+	return order, nil
+}
+
+// Sort orders the tasks by the keys, falling back to the manual order.
+func (o TaskOrder) Sort(tasks []db.Task) {
+	db.SortByRank(tasks)
+	sort.SliceStable(tasks, func(i, j int) bool {
+		for _, key := range o {
+			compare, _ := taskSortField(key.name)
+			cmp, ok := compare(&tasks[i], &tasks[j])
+			if key.desc && ok {
+				cmp = -cmp
+			}
+			if cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+}
+
+// customFieldPrefix selects a custom field in filters and sort keys, as in
+// cf.points>=3 or -cf.points.
+const customFieldPrefix = "cf."
+
+// compareFieldValues compares two stored custom field values. Values of
+// different types are ordered by type.
+func compareFieldValues(a, b interface{}) int {
+	switch va := a.(type) {
+	case float64:
+		if vb, ok := b.(float64); ok {
+			switch {
+			case va < vb:
+				return -1
+			case va > vb:
+				return 1
+			}
+			return 0
+		}
+	case bool:
+		if vb, ok := b.(bool); ok {
+			switch {
+			case va == vb:
+				return 0
+			case vb:
+				return -1
+			}
+			return 1
+		}
+	case string:
+		if vb, ok := b.(string); ok {
+			return strings.Compare(strings.ToLower(va), strings.ToLower(vb))
+		}
+	}
+	return strings.Compare(fmt.Sprintf("%T", a), fmt.Sprintf("%T", b))
+}
+
+// compareFieldQuery compares a stored custom field value with a value from a
+// filter expression, interpreted according to the stored type. Dates can use
+// the same values as the due field. ok is false if the query value does not
+// fit the type.
+func compareFieldQuery(v interface{}, query string, now time.Time) (int, bool) {
+	switch value := v.(type) {
+	case float64:
+		n, err := strconv.ParseFloat(query, 64)
+		if err != nil {
+			return 0, false
+		}
+		return compareFieldValues(value, n), true
+	case bool:
+		b, err := strconv.ParseBool(query)
+		if err != nil {
+			return 0, false
+		}
+		return compareFieldValues(value, b), true
+	case string:
+		if d, err := time.ParseInLocation("2006-01-02", value, now.Location()); err == nil {
+			if resolve, err := parseFilterDate(query); err == nil {
+				start, end := resolve(now)
+				switch {
+				case d.Before(start):
+					return -1, true
+				case !d.Before(end) && !d.Equal(start):
+					return 1, true
+				}
+				return 0, true
+			}
+		}
+		return compareFieldValues(value, query), true
+	}
+	return 0, false
+}
@@ -0,0 +1,134 @@
+package lib
+
+import (
+	"testing"
+	"time"
+
+	"tasks/db"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter(t *testing.T) {
+	// Wednesday, 5 April 2023, 15:00.
+	now := time.Date(2023, 4, 5, 15, 0, 0, 0, time.UTC)
+	day := func(d int, h int) *time.Time {
+		t := time.Date(2023, 4, d, h, 0, 0, 0, time.UTC)
+		return &t
+	}
+
+	tasks := []db.Task{
+		{Title: "Overdue report", Project: "Work", Status: db.StatusTodo, Tags: []string{"work"}, Priority: db.PriorityHigh, Due: day(4, 18), Rank: "a",
+			Fields: map[string]interface{}{"points": 5.0, "customer": "Acme", "billable": true, "deadline": "2023-04-07"}},
+		{Title: "Review slides", Project: "work", Status: db.StatusInProgress, Tags: []string{"work", "someday"}, Priority: db.PriorityMedium, Due: day(5, 9), Rank: "b",
+			Fields: map[string]interface{}{"points": 2.0, "customer": "Globex", "deadline": "2023-04-05"}},
+		{Title: "Water plants", Project: "home", Status: db.StatusDone, Tags: []string{"home"}, Due: day(6, 8), DeferUntil: day(7, 9), Rank: "c"},
+		{Title: "Read a book", Text: "Something about gardens", Project: "home", Tags: []string{"someday"}, Priority: db.PriorityLow, Someday: true, Rank: "d"},
+	}
+	titles := func(tasks []db.Task) []string {
+		result := []string{}
+		for _, t := range tasks {
+			result = append(result, t.Title)
+		}
+		return result
+	}
+
+	t.Run("matches", func(t *testing.T) {
+		tests := []struct {
+			query string
+			want  []string
+		}{
+			{"", []string{"Overdue report", "Review slides", "Water plants", "Read a book"}},
+			{"status:todo tag:work due<today priority>=high -tag:someday", []string{"Overdue report"}},
+			{"project:WORK", []string{"Overdue report", "Review slides"}},
+			{"due:today", []string{"Review slides"}},
+			{"due<=today", []string{"Overdue report", "Review slides"}},
+			{"due>today", []string{"Water plants"}},
+			{"due>=+1d", []string{"Water plants"}},
+			{"due<now", []string{"Overdue report", "Review slides"}},
+			{"due:2023-04-04", []string{"Overdue report"}},
+			{"is:overdue", []string{"Overdue report", "Review slides"}},
+			{"priority<medium", []string{"Water plants", "Read a book"}},
+			{"priority!=medium has:priority", []string{"Overdue report", "Read a book"}},
+			{"#home OR garden", []string{"Water plants", "Read a book"}},
+			{"(project:work OR project:home) NOT is:done -#someday", []string{"Overdue report"}},
+			{"status!=todo", []string{"Review slides", "Water plants"}},
+			{`title:"a book"`, []string{"Read a book"}},
+			{"-has:due", []string{"Read a book"}},
+			{"-(tag:work OR is:done)", []string{"Read a book"}},
+			{"project:home -(tag:someday)", []string{"Water plants"}},
+			{"is:deferred", []string{"Water plants", "Read a book"}},
+			{"is:someday", []string{"Read a book"}},
+			{"cf.points>=3", []string{"Overdue report"}},
+			{"cf.customer:acme", []string{"Overdue report"}},
+			{"cf.customer!=acme", []string{"Review slides", "Water plants", "Read a book"}},
+			{"cf.billable:true", []string{"Overdue report"}},
+			{"cf.deadline<=today", []string{"Review slides"}},
+			{"cf.deadline>+1d", []string{"Overdue report"}},
+			{"has:cf.points -has:cf.billable", []string{"Review slides"}},
+			{"cf.points>lots", []string{}},
+		}
+		for _, tt := range tests {
+			f, err := ParseFilter(tt.query)
+			require.NoError(t, err, tt.query)
+			require.Equal(t, tt.want, titles(f.Apply(tasks, now)), tt.query)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, query := range []string{
+			"colour:red",
+			"status:open",
+			"priority:urgent",
+			"due<someday",
+			"tag>work",
+			"(tag:work",
+			"tag:work)",
+			"tag:work OR",
+			`title:"open`,
+			"has:wings",
+		} {
+			_, err := ParseFilter(query)
+			require.ErrorIs(t, err, ErrInvalidFilter, query)
+		}
+	})
+
+	t.Run("match field", func(t *testing.T) {
+		f, err := ParseFilter("status:todo project:home due<today (status:done OR tag:work) -project:work")
+		require.NoError(t, err)
+		require.True(t, f.MatchField("status", &db.Task{}, now))
+		require.False(t, f.MatchField("status", &db.Task{Status: db.StatusDone}, now))
+		require.True(t, f.MatchField("project", &db.Task{Project: "Home"}, now))
+		require.False(t, f.MatchField("project", &db.Task{Project: "work"}, now))
+		require.True(t, f.MatchField("due", &db.Task{Due: day(4, 9)}, now))
+		require.False(t, f.MatchField("due", &db.Task{}, now))
+		require.True(t, f.MatchField("tag", &db.Task{}, now))
+
+		// Terms under OR do not restrict every match.
+		f, err = ParseFilter("status:todo OR project:home")
+		require.NoError(t, err)
+		require.True(t, f.MatchField("status", &db.Task{Status: db.StatusDone}, now))
+		require.True(t, f.MatchField("project", &db.Task{}, now))
+	})
+
+	t.Run("sort", func(t *testing.T) {
+		order, err := ParseTaskOrder("-priority,due")
+		require.NoError(t, err)
+		sorted := append([]db.Task(nil), tasks...)
+		order.Sort(sorted)
+		require.Equal(t, []string{"Overdue report", "Review slides", "Read a book", "Water plants"}, titles(sorted))
+
+		order, err = ParseTaskOrder("-due")
+		require.NoError(t, err)
+		order.Sort(sorted)
+		require.Equal(t, []string{"Water plants", "Review slides", "Overdue report", "Read a book"}, titles(sorted))
+
+		order, err = ParseTaskOrder("cf.points")
+		require.NoError(t, err)
+		order.Sort(sorted)
+		require.Equal(t, []string{"Review slides", "Overdue report", "Water plants", "Read a book"}, titles(sorted))
+
+		_, err = ParseTaskOrder("colour")
+		require.ErrorIs(t, err, ErrInvalidFilter)
+	})
+}
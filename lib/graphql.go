@@ -0,0 +1,808 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidGraphQL is reported for documents that are not valid GraphQL
+// syntax.
+var ErrInvalidGraphQL = errors.New("invalid GraphQL document")
+
+// GraphQLLocation is a position in a GraphQL document, both counted from 1.
+type GraphQLLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// gqlDocument is a parsed executable document: operations and the fragments
+// they spread. Type system definitions are not accepted.
+type gqlDocument struct {
+	operations []*gqlOperation
+	fragments  map[string]*gqlFragment
+}
+
+type gqlOperation struct {
+	kind       string // query, mutation or subscription
+	name       string
+	variables  []*gqlVariableDefinition
+	directives []*gqlDirective
+	selections []gqlSelection
+	loc        GraphQLLocation
+}
+
+type gqlVariableDefinition struct {
+	name         string
+	typ          *gqlTypeRef
+	defaultValue *gqlValue
+	loc          GraphQLLocation
+}
+
+// gqlTypeRef is a type as written in a document: a named type, or a list of
+// elem when elem is set.
+type gqlTypeRef struct {
+	name    string
+	elem    *gqlTypeRef
+	nonNull bool
+}
+
+func (t *gqlTypeRef) String() string {
+	s := t.name
+	if t.elem != nil {
+		s = "[" + t.elem.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+// gqlSelection is a *gqlField, *gqlFragmentSpread or *gqlInlineFragment.
+type gqlSelection interface{}
+
+type gqlField struct {
+	alias      string
+	name       string
+	arguments  []*gqlArgument
+	directives []*gqlDirective
+	selections []gqlSelection
+	loc        GraphQLLocation
+}
+
+// responseKey is the name of the field in the response.
+func (f *gqlField) responseKey() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type gqlArgument struct {
+	name  string
+	value *gqlValue
+	loc   GraphQLLocation
+}
+
+type gqlDirective struct {
+	name      string
+	arguments []*gqlArgument
+	loc       GraphQLLocation
+}
+
+type gqlFragmentSpread struct {
+	name       string
+	directives []*gqlDirective
+	loc        GraphQLLocation
+}
+
+type gqlInlineFragment struct {
+	typeCondition string
+	directives    []*gqlDirective
+	selections    []gqlSelection
+	loc           GraphQLLocation
+}
+
+type gqlFragment struct {
+	name          string
+	typeCondition string
+	directives    []*gqlDirective
+	selections    []gqlSelection
+	loc           GraphQLLocation
+}
+
+type gqlValueKind int
+
+const (
+	gqlVariable gqlValueKind = iota
+	gqlInt
+	gqlFloat
+	gqlString
+	gqlBoolean
+	gqlNull
+	gqlEnum
+	gqlList
+	gqlObject
+)
+
+// gqlValue is a literal or a variable. text holds the name of a variable or
+// an enum value, the digits of a number and the decoded string.
+type gqlValue struct {
+	kind   gqlValueKind
+	text   string
+	list   []*gqlValue
+	fields []*gqlObjectField
+	loc    GraphQLLocation
+}
+
+type gqlObjectField struct {
+	name  string
+	value *gqlValue
+	loc   GraphQLLocation
+}
+
+type gqlTokenKind int
+
+const (
+	gqlEOF gqlTokenKind = iota
+	gqlPunctuator
+	gqlName
+	gqlIntValue
+	gqlFloatValue
+	gqlStringValue
+)
+
+type gqlToken struct {
+	kind gqlTokenKind
+	text string
+	loc  GraphQLLocation
+}
+
+// gqlLexer splits a document into tokens, skipping white space, commas and
+// comments.
+type gqlLexer struct {
+	src       string
+	pos       int
+	line      int
+	lineStart int
+}
+
+func (l *gqlLexer) errorf(loc GraphQLLocation, format string, args ...interface{}) error {
+	return &GraphQLError{Message: "syntax error: " + fmt.Sprintf(format, args...), Locations: []GraphQLLocation{loc}, Err: ErrInvalidGraphQL}
+}
+
+func (l *gqlLexer) location() GraphQLLocation {
+	return GraphQLLocation{Line: l.line, Column: utf8.RuneCountInString(l.src[l.lineStart:l.pos]) + 1}
+}
+
+func (l *gqlLexer) newline() {
+	l.line++
+	l.lineStart = l.pos
+}
+
+func (l *gqlLexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch c := l.src[l.pos]; c {
+		case ' ', '\t', ',':
+			l.pos++
+		case '\n':
+			l.pos++
+			l.newline()
+		case '\r':
+			l.pos++
+			if l.pos < len(l.src) && l.src[l.pos] == '\n' {
+				l.pos++
+			}
+			l.newline()
+		case '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' && l.src[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			if strings.HasPrefix(l.src[l.pos:], "\uFEFF") {
+				l.pos += len("\uFEFF")
+				continue
+			}
+			return
+		}
+	}
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+func isNameContinue(c byte) bool {
+	return isNameStart(c) || c >= '0' && c <= '9'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (l *gqlLexer) next() (gqlToken, error) {
+	l.skipIgnored()
+	loc := l.location()
+	if l.pos >= len(l.src) {
+		return gqlToken{kind: gqlEOF, loc: loc}, nil
+	}
+	start := l.pos
+	c := l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		return gqlToken{kind: gqlPunctuator, text: "...", loc: loc}, nil
+	case strings.IndexByte("!$&():=@[]{|}", c) >= 0:
+		l.pos++
+		return gqlToken{kind: gqlPunctuator, text: string(c), loc: loc}, nil
+	case isNameStart(c):
+		for l.pos < len(l.src) && isNameContinue(l.src[l.pos]) {
+			l.pos++
+		}
+		return gqlToken{kind: gqlName, text: l.src[start:l.pos], loc: loc}, nil
+	case c == '-' || isDigit(c):
+		return l.number(loc)
+	case strings.HasPrefix(l.src[l.pos:], `"""`):
+		return l.blockString(loc)
+	case c == '"':
+		return l.string(loc)
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	return gqlToken{}, l.errorf(loc, "unexpected character %q", r)
+}
+
+func (l *gqlLexer) digits(loc GraphQLLocation) error {
+	if l.pos >= len(l.src) || !isDigit(l.src[l.pos]) {
+		return l.errorf(loc, "invalid number, expected a digit")
+	}
+	for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+		l.pos++
+	}
+	return nil
+}
+
+func (l *gqlLexer) number(loc GraphQLLocation) (gqlToken, error) {
+	start := l.pos
+	kind := gqlIntValue
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '0' {
+		l.pos++
+		if l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			return gqlToken{}, l.errorf(loc, "invalid number, unexpected digit after 0")
+		}
+	} else if err := l.digits(loc); err != nil {
+		return gqlToken{}, err
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		kind = gqlFloatValue
+		l.pos++
+		if err := l.digits(loc); err != nil {
+			return gqlToken{}, err
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		kind = gqlFloatValue
+		l.pos++
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		if err := l.digits(loc); err != nil {
+			return gqlToken{}, err
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == '.' || isNameStart(l.src[l.pos])) {
+		return gqlToken{}, l.errorf(loc, "invalid number, unexpected %q", l.src[l.pos])
+	}
+	return gqlToken{kind: kind, text: l.src[start:l.pos], loc: loc}, nil
+}
+
+func (l *gqlLexer) string(loc GraphQLLocation) (gqlToken, error) {
+	l.pos++
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '"':
+			l.pos++
+			return gqlToken{kind: gqlStringValue, text: b.String(), loc: loc}, nil
+		case c == '\n' || c == '\r':
+			return gqlToken{}, l.errorf(loc, "unterminated string")
+		case c == '\\':
+			if l.pos+1 >= len(l.src) {
+				return gqlToken{}, l.errorf(loc, "unterminated string")
+			}
+			escape := l.src[l.pos+1]
+			l.pos += 2
+			switch escape {
+			case '"', '\\', '/':
+				b.WriteByte(escape)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if l.pos+4 > len(l.src) {
+					return gqlToken{}, l.errorf(loc, "invalid unicode escape")
+				}
+				code, err := strconv.ParseUint(l.src[l.pos:l.pos+4], 16, 32)
+				if err != nil {
+					return gqlToken{}, l.errorf(loc, "invalid unicode escape %q", l.src[l.pos:l.pos+4])
+				}
+				b.WriteRune(rune(code))
+				l.pos += 4
+			default:
+				return gqlToken{}, l.errorf(loc, "invalid escape \\%c", escape)
+			}
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+	return gqlToken{}, l.errorf(loc, "unterminated string")
+}
+
+// blockString reads a """ string. Its lines lose their common indentation
+// and the blank lines around them.
+func (l *gqlLexer) blockString(loc GraphQLLocation) (gqlToken, error) {
+	l.pos += 3
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		switch {
+		case strings.HasPrefix(l.src[l.pos:], `"""`):
+			l.pos += 3
+			return gqlToken{kind: gqlStringValue, text: blockStringValue(b.String()), loc: loc}, nil
+		case strings.HasPrefix(l.src[l.pos:], `\"""`):
+			b.WriteString(`"""`)
+			l.pos += 4
+		default:
+			c := l.src[l.pos]
+			b.WriteByte(c)
+			l.pos++
+			if c == '\n' || c == '\r' && (l.pos >= len(l.src) || l.src[l.pos] != '\n') {
+				l.newline()
+			}
+		}
+	}
+	return gqlToken{}, l.errorf(loc, "unterminated block string")
+}
+
+func blockStringValue(raw string) string {
+	lines := strings.Split(strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(raw), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = strings.TrimLeft(lines[i], " \t")
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[0], " \t") == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[len(lines)-1], " \t") == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// gqlParser parses a document with one token of lookahead.
+type gqlParser struct {
+	lexer *gqlLexer
+	tok   gqlToken
+}
+
+func parseGraphQL(src string) (*gqlDocument, error) {
+	p := &gqlParser{lexer: &gqlLexer{src: src, line: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	doc := &gqlDocument{fragments: map[string]*gqlFragment{}}
+	for p.tok.kind != gqlEOF {
+		switch {
+		case p.peek("{"), p.tok.kind == gqlName && (p.tok.text == "query" || p.tok.text == "mutation" || p.tok.text == "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case p.tok.kind == gqlName && p.tok.text == "fragment":
+			f, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.fragments[f.name]; ok {
+				return nil, p.lexer.errorf(f.loc, "there can be only one fragment named %q", f.name)
+			}
+			doc.fragments[f.name] = f
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, &GraphQLError{Message: "the document has no operation", Err: ErrInvalidGraphQL}
+	}
+	return doc, nil
+}
+
+func (p *gqlParser) advance() error {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+// found describes the current token for errors.
+func (p *gqlParser) found() string {
+	if p.tok.kind == gqlEOF {
+		return "the end of the document"
+	}
+	return strconv.Quote(p.tok.text)
+}
+
+func (p *gqlParser) unexpected() error {
+	return p.lexer.errorf(p.tok.loc, "unexpected %s", p.found())
+}
+
+// peek reports whether the current token is the punctuator.
+func (p *gqlParser) peek(punctuator string) bool {
+	return p.tok.kind == gqlPunctuator && p.tok.text == punctuator
+}
+
+// skip consumes the punctuator if it is the current token.
+func (p *gqlParser) skip(punctuator string) (bool, error) {
+	if !p.peek(punctuator) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+func (p *gqlParser) expect(punctuator string) error {
+	if !p.peek(punctuator) {
+		return p.lexer.errorf(p.tok.loc, "expected %q, found %s", punctuator, p.found())
+	}
+	return p.advance()
+}
+
+func (p *gqlParser) name() (string, error) {
+	if p.tok.kind != gqlName {
+		return "", p.lexer.errorf(p.tok.loc, "expected a name, found %s", p.found())
+	}
+	name := p.tok.text
+	return name, p.advance()
+}
+
+func (p *gqlParser) keyword(word string) error {
+	if p.tok.kind != gqlName || p.tok.text != word {
+		return p.lexer.errorf(p.tok.loc, "expected %q, found %s", word, p.found())
+	}
+	return p.advance()
+}
+
+func (p *gqlParser) operation() (*gqlOperation, error) {
+	op := &gqlOperation{kind: "query", loc: p.tok.loc}
+	if p.peek("{") {
+		var err error
+		op.selections, err = p.selectionSet()
+		return op, err
+	}
+	op.kind = p.tok.text
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var err error
+	if p.tok.kind == gqlName {
+		if op.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if op.variables, err = p.variableDefinitions(); err != nil {
+		return nil, err
+	}
+	if op.directives, err = p.directives(false); err != nil {
+		return nil, err
+	}
+	op.selections, err = p.selectionSet()
+	return op, err
+}
+
+func (p *gqlParser) variableDefinitions() ([]*gqlVariableDefinition, error) {
+	if ok, err := p.skip("("); !ok || err != nil {
+		return nil, err
+	}
+	var defs []*gqlVariableDefinition
+	for {
+		if ok, err := p.skip(")"); ok || err != nil {
+			return defs, err
+		}
+		def := &gqlVariableDefinition{loc: p.tok.loc}
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		var err error
+		if def.name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if def.typ, err = p.typeRef(); err != nil {
+			return nil, err
+		}
+		if ok, err := p.skip("="); err != nil {
+			return nil, err
+		} else if ok {
+			if def.defaultValue, err = p.value(true); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := p.directives(true); err != nil {
+			return nil, err
+		}
+		defs = append(defs, def)
+	}
+}
+
+func (p *gqlParser) typeRef() (*gqlTypeRef, error) {
+	t := &gqlTypeRef{}
+	if ok, err := p.skip("["); err != nil {
+		return nil, err
+	} else if ok {
+		if t.elem, err = p.typeRef(); err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	} else if t.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	var err error
+	t.nonNull, err = p.skip("!")
+	return t, err
+}
+
+func (p *gqlParser) directives(isConst bool) ([]*gqlDirective, error) {
+	var directives []*gqlDirective
+	for p.peek("@") {
+		d := &gqlDirective{loc: p.tok.loc}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		var err error
+		if d.name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if d.arguments, err = p.arguments(isConst); err != nil {
+			return nil, err
+		}
+		directives = append(directives, d)
+	}
+	return directives, nil
+}
+
+func (p *gqlParser) arguments(isConst bool) ([]*gqlArgument, error) {
+	if ok, err := p.skip("("); !ok || err != nil {
+		return nil, err
+	}
+	var args []*gqlArgument
+	for {
+		if ok, err := p.skip(")"); ok || err != nil {
+			if len(args) == 0 && err == nil {
+				return nil, p.lexer.errorf(p.tok.loc, "expected an argument")
+			}
+			return args, err
+		}
+		arg := &gqlArgument{loc: p.tok.loc}
+		var err error
+		if arg.name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if arg.value, err = p.value(isConst); err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+}
+
+func (p *gqlParser) selectionSet() ([]gqlSelection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var selections []gqlSelection
+	for {
+		if ok, err := p.skip("}"); ok || err != nil {
+			if len(selections) == 0 && err == nil {
+				return nil, p.lexer.errorf(p.tok.loc, "a selection set cannot be empty")
+			}
+			return selections, err
+		}
+		selection, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+}
+
+func (p *gqlParser) selection() (gqlSelection, error) {
+	loc := p.tok.loc
+	if ok, err := p.skip("..."); err != nil {
+		return nil, err
+	} else if ok {
+		if p.tok.kind == gqlName && p.tok.text != "on" {
+			spread := &gqlFragmentSpread{loc: loc}
+			if spread.name, err = p.name(); err != nil {
+				return nil, err
+			}
+			spread.directives, err = p.directives(false)
+			return spread, err
+		}
+		inline := &gqlInlineFragment{loc: loc}
+		if p.tok.kind == gqlName {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			if inline.typeCondition, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		if inline.directives, err = p.directives(false); err != nil {
+			return nil, err
+		}
+		inline.selections, err = p.selectionSet()
+		return inline, err
+	}
+
+	f := &gqlField{loc: loc}
+	var err error
+	if f.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if ok, err := p.skip(":"); err != nil {
+		return nil, err
+	} else if ok {
+		f.alias = f.name
+		if f.name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if f.arguments, err = p.arguments(false); err != nil {
+		return nil, err
+	}
+	if f.directives, err = p.directives(false); err != nil {
+		return nil, err
+	}
+	if p.peek("{") {
+		f.selections, err = p.selectionSet()
+	}
+	return f, err
+}
+
+func (p *gqlParser) fragment() (*gqlFragment, error) {
+	f := &gqlFragment{loc: p.tok.loc}
+	if err := p.keyword("fragment"); err != nil {
+		return nil, err
+	}
+	var err error
+	if f.name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if f.name == "on" {
+		return nil, p.lexer.errorf(f.loc, "a fragment cannot be named on")
+	}
+	if err := p.keyword("on"); err != nil {
+		return nil, err
+	}
+	if f.typeCondition, err = p.name(); err != nil {
+		return nil, err
+	}
+	if f.directives, err = p.directives(false); err != nil {
+		return nil, err
+	}
+	f.selections, err = p.selectionSet()
+	return f, err
+}
+
+// value parses a value. A constant value cannot hold variables.
+func (p *gqlParser) value(isConst bool) (*gqlValue, error) {
+	v := &gqlValue{loc: p.tok.loc, text: p.tok.text}
+	switch p.tok.kind {
+	case gqlIntValue:
+		v.kind = gqlInt
+	case gqlFloatValue:
+		v.kind = gqlFloat
+	case gqlStringValue:
+		v.kind = gqlString
+	case gqlName:
+		switch p.tok.text {
+		case "true", "false":
+			v.kind = gqlBoolean
+		case "null":
+			v.kind = gqlNull
+		default:
+			v.kind = gqlEnum
+		}
+	case gqlPunctuator:
+		switch p.tok.text {
+		case "$":
+			if isConst {
+				return nil, p.lexer.errorf(v.loc, "a variable is not allowed here")
+			}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			var err error
+			v.kind = gqlVariable
+			v.text, err = p.name()
+			return v, err
+		case "[":
+			v.kind = gqlList
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			for {
+				if ok, err := p.skip("]"); ok || err != nil {
+					return v, err
+				}
+				item, err := p.value(isConst)
+				if err != nil {
+					return nil, err
+				}
+				v.list = append(v.list, item)
+			}
+		case "{":
+			v.kind = gqlObject
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			for {
+				if ok, err := p.skip("}"); ok || err != nil {
+					return v, err
+				}
+				f := &gqlObjectField{loc: p.tok.loc}
+				var err error
+				if f.name, err = p.name(); err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				if f.value, err = p.value(isConst); err != nil {
+					return nil, err
+				}
+				v.fields = append(v.fields, f)
+			}
+		default:
+			return nil, p.unexpected()
+		}
+	default:
+		return nil, p.unexpected()
+	}
+	return v, p.advance()
+}
@@ -0,0 +1,239 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testGraphQLSchema is a schema of books whose authors are read through a
+// loader counting its batches.
+func testGraphQLSchema(t *testing.T, batches *int) *GraphQLSchema {
+	authors := map[interface{}]interface{}{
+		"ann": map[string]interface{}{"name": "Ann"},
+		"bob": map[string]interface{}{"name": "Bob"},
+	}
+	books := []map[string]interface{}{
+		{"title": "Tides", "author": "ann", "pages": 120},
+		{"title": "Stones", "author": "bob", "pages": 300},
+		{"title": "Rivers", "author": "ann", "pages": 210},
+		{"title": "Ghost", "author": "eve", "pages": 90},
+	}
+	loaders := map[context.Context]*Loader{}
+
+	author := &GraphQLObject{Name: "Author", Fields: []*GraphQLField{
+		{Name: "name", Type: GraphQLNonNullOf(GraphQLString)},
+	}}
+	genre := &GraphQLEnum{Name: "Genre", Values: []GraphQLEnumValue{{Name: "NOVEL", Value: "novel"}, {Name: "POETRY", Value: "poetry"}}}
+	book := &GraphQLObject{Name: "Book", Fields: []*GraphQLField{
+		{Name: "title", Type: GraphQLNonNullOf(GraphQLString)},
+		{Name: "pages", Type: GraphQLInt},
+		{Name: "genre", Type: genre, Resolve: func(p GraphQLParams) (interface{}, error) { return "novel", nil }},
+		{Name: "author", Type: GraphQLNonNullOf(author), Resolve: func(p GraphQLParams) (interface{}, error) {
+			l, ok := loaders[p.Context]
+			if !ok {
+				l = NewLoader(func(keys []interface{}) (map[interface{}]interface{}, error) {
+					*batches++
+					found := map[interface{}]interface{}{}
+					for _, key := range keys {
+						if a, ok := authors[key]; ok {
+							found[key] = a
+						}
+					}
+					return found, nil
+				})
+				loaders[p.Context] = l
+			}
+			return l.Load(p.Source.(map[string]interface{})["author"]), nil
+		}},
+	}}
+	book.Fields = append(book.Fields, &GraphQLField{Name: "sequel", Type: book, Resolve: func(p GraphQLParams) (interface{}, error) {
+		return p.Source, nil
+	}})
+	filter := &GraphQLInputObject{Name: "BookFilter", Fields: []*GraphQLArgument{
+		{Name: "minPages", Type: GraphQLInt, Default: 0},
+		{Name: "titles", Type: GraphQLListOf(GraphQLNonNullOf(GraphQLString))},
+	}}
+	query := &GraphQLObject{Name: "Query", Fields: []*GraphQLField{
+		{
+			Name: "books",
+			Args: []*GraphQLArgument{{Name: "first", Type: GraphQLInt, Default: 10}, {Name: "filter", Type: filter}},
+			Type: GraphQLNonNullOf(GraphQLListOf(GraphQLNonNullOf(book))),
+			Resolve: func(p GraphQLParams) (interface{}, error) {
+				var result []map[string]interface{}
+				filter, _ := p.Args["filter"].(map[string]interface{})
+				for _, b := range books {
+					if filter != nil && b["pages"].(int) < filter["minPages"].(int) {
+						continue
+					}
+					if titles, ok := filter["titles"].([]interface{}); ok && len(titles) > 0 && titles[0] != b["title"] {
+						continue
+					}
+					result = append(result, b)
+				}
+				if first := p.Args["first"].(int); len(result) > first {
+					result = result[:first]
+				}
+				return result, nil
+			},
+			Complexity: func(args map[string]interface{}, child int) int { return 1 + args["first"].(int)*child },
+		},
+		{Name: "fail", Type: GraphQLString, Resolve: func(p GraphQLParams) (interface{}, error) {
+			return nil, errors.New("out of ink")
+		}},
+	}}
+	var log []string
+	mutation := &GraphQLObject{Name: "Mutation", Fields: []*GraphQLField{
+		{Name: "append", Args: []*GraphQLArgument{{Name: "word", Type: GraphQLNonNullOf(GraphQLString)}}, Type: GraphQLNonNullOf(GraphQLListOf(GraphQLString)),
+			Resolve: func(p GraphQLParams) (interface{}, error) {
+				log = append(log, p.Args["word"].(string))
+				return append([]string(nil), log...), nil
+			}},
+	}}
+	schema, err := NewGraphQLSchema(query, mutation, 4, 200)
+	require.NoError(t, err)
+	return schema
+}
+
+func execGraphQL(t *testing.T, s *GraphQLSchema, query string, variables map[string]interface{}) string {
+	resp := s.Execute(context.Background(), GraphQLRequest{Query: query, Variables: variables})
+	b, err := json.Marshal(resp)
+	require.NoError(t, err)
+	return string(b)
+}
+
+func TestGraphQL(t *testing.T) {
+	var batches int
+	s := testGraphQLSchema(t, &batches)
+
+	t.Run("query", func(t *testing.T) {
+		got := execGraphQL(t, s, `
+			query Books($min: Int = 100, $withPages: Boolean!) {
+				# Authors are loaded in a single batch.
+				long: books(filter: {minPages: $min}) { ...info pages @include(if: $withPages) }
+				__typename
+			}
+			fragment info on Book { title author { name } genre }`, map[string]interface{}{"withPages": false})
+		require.JSONEq(t, `{"data": {
+			"long": [
+				{"title": "Tides", "author": {"name": "Ann"}, "genre": "NOVEL"},
+				{"title": "Stones", "author": {"name": "Bob"}, "genre": "NOVEL"},
+				{"title": "Rivers", "author": {"name": "Ann"}, "genre": "NOVEL"}
+			],
+			"__typename": "Query"
+		}}`, got)
+		require.Equal(t, 1, batches)
+		require.Regexp(t, `^\{"data":\{"long":\[\{"title":"Tides","author"`, got)
+
+		got = execGraphQL(t, s, `{ books(first: 1, filter: {titles: "Stones"}) { title } }`, nil)
+		require.JSONEq(t, `{"data": {"books": [{"title": "Stones"}]}}`, got)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		// A failed non-null field nulls the closest nullable parent.
+		got := execGraphQL(t, s, `{ fail books(filter: {minPages: 100}) { sequel { author { name } } } }`, nil)
+		require.JSONEq(t, `{
+			"data": {"fail": null, "books": [
+				{"sequel": {"author": {"name": "Ann"}}},
+				{"sequel": {"author": {"name": "Bob"}}},
+				{"sequel": {"author": {"name": "Ann"}}}
+			]},
+			"errors": [{"message": "out of ink", "locations": [{"line": 1, "column": 3}], "path": ["fail"]}]
+		}`, got)
+		got = execGraphQL(t, s, `{ books { sequel { author { name } } } }`, nil)
+		require.Contains(t, got, `{"sequel":null}]}`)
+		require.Contains(t, got, `"path":["books",3,"sequel","author"]`)
+		got = execGraphQL(t, s, `{ books { author { name } } }`, nil)
+		require.JSONEq(t, `{
+			"data": null,
+			"errors": [{"message": "cannot return null for a non-null field", "locations": [{"line": 1, "column": 11}], "path": ["books", 3, "author"]}]
+		}`, got)
+
+		tests := []struct {
+			query string
+			want  string
+		}{
+			{`{ books { title }`, "syntax error: expected a name, found the end of the document"},
+			{`{ books { titel } }`, `cannot query field "titel" on type Book`},
+			{`{ books }`, `field "books" of type [Book!]! must have a selection of subfields`},
+			{`{ books { title { x } } }`, `field "title" must not have a selection since type String! has no subfields`},
+			{`{ books(last: 2) { title } }`, `field "books": unknown argument "last"`},
+			{`{ books(first: "2") { title } }`, `field "books": argument "first" has an invalid value: Int cannot represent 2`},
+			{`query($n: Int) { books(first: $m) { title } }`, `field "books": argument "first" has an invalid value: variable $m is not defined`},
+			{`query($n: String) { books(first: $n) { title } }`, `field "books": argument "first" has an invalid value: variable $n of type String cannot be used as Int`},
+			{`query($t: Book) { books { title } }`, `variable $t cannot be of the non-input type Book`},
+			{`{ books { ...info } } fragment info on Book { sequel { ...info } }`, `cannot spread fragment "info" within itself via info`},
+			{`{ books { ...missing } }`, `unknown fragment "missing"`},
+			{`{ books { title @upper } }`, `unknown directive @upper`},
+			{`{ books { genre(style: LOUD) } }`, `field "genre": unknown argument "style"`},
+			{`{ books { sequel { sequel { sequel { title } } } } }`, `query is more than 4 levels deep`},
+			{`{ books(first: 100) { title author { name } } }`, `query has a complexity of 301, more than the limit of 200`},
+			{`mutation { append(word: null) }`, `field "append": argument "word" has an invalid value: expected a non-null String!`},
+			{`subscription { books { title } }`, `the schema does not support subscription operations`},
+		}
+		for _, tt := range tests {
+			resp := s.Execute(context.Background(), GraphQLRequest{Query: tt.query})
+			require.False(t, resp.Executed(), tt.query)
+			require.NotEmpty(t, resp.Errors, tt.query)
+			require.Equal(t, tt.want, resp.Errors[0].Message, tt.query)
+		}
+	})
+
+	t.Run("mutation", func(t *testing.T) {
+		got := execGraphQL(t, s, `mutation { a: append(word: "one") b: append(word: "two") }`, nil)
+		require.JSONEq(t, `{"data": {"a": ["one"], "b": ["one", "two"]}}`, got)
+	})
+
+	t.Run("operation name", func(t *testing.T) {
+		resp := s.Execute(context.Background(), GraphQLRequest{Query: `query A { __typename } query B { books { title } }`, OperationName: "A"})
+		require.Empty(t, resp.Errors)
+		b, err := json.Marshal(resp)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"data": {"__typename": "Query"}}`, string(b))
+
+		resp = s.Execute(context.Background(), GraphQLRequest{Query: `query A { __typename } query B { __typename }`})
+		require.ErrorIs(t, resp.Errors[0], ErrGraphQLRequest)
+	})
+
+	t.Run("schema", func(t *testing.T) {
+		sdl := s.SDL()
+		require.Contains(t, sdl, "type Query {\n  books(first: Int = 10, filter: BookFilter): [Book!]!\n  fail: String\n}\n")
+		require.Contains(t, sdl, "input BookFilter {\n  minPages: Int = 0\n  titles: [String!]\n}\n")
+		require.Contains(t, sdl, "enum Genre {\n  NOVEL\n  POETRY\n}\n")
+		require.NotContains(t, sdl, "scalar Int")
+	})
+}
+
+func TestLoader(t *testing.T) {
+	var fetched [][]interface{}
+	l := NewLoader(func(keys []interface{}) (map[interface{}]interface{}, error) {
+		fetched = append(fetched, keys)
+		found := map[interface{}]interface{}{}
+		for _, key := range keys {
+			if key != 3 {
+				found[key] = fmt.Sprint("value ", key)
+			}
+		}
+		return found, nil
+	})
+	one, two, three, again := l.Load(1), l.Load(2), l.Load(3), l.Load(1)
+	for _, load := range []func() (interface{}, error){one, two, again} {
+		_, err := load()
+		require.NoError(t, err)
+	}
+	v, err := three()
+	require.NoError(t, err)
+	require.Nil(t, v)
+	v, err = l.Load(2)()
+	require.NoError(t, err)
+	require.Equal(t, "value 2", v)
+	require.Equal(t, [][]interface{}{{1, 2, 3}}, fetched)
+
+	l.Clear()
+	_, err = l.Load(2)()
+	require.NoError(t, err)
+	require.Len(t, fetched, 2)
+}
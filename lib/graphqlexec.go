@@ -0,0 +1,986 @@
+package lib
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrGraphQLRequest     = errors.New("invalid GraphQL request")
+	ErrGraphQLTooDeep     = errors.New("GraphQL query is too deep")
+	ErrGraphQLTooComplex  = errors.New("GraphQL query is too complex")
+	errGraphQLNullNonNull = errors.New("cannot return null for a non-null field")
+)
+
+// GraphQLRequest is a GraphQL request as sent over HTTP.
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// GraphQLError is an error of a GraphQL response. Err is the error it was
+// made from: ErrInvalidGraphQL, ErrGraphQLRequest, ErrGraphQLTooDeep or
+// ErrGraphQLTooComplex for requests refused before they run, or the error
+// of a resolver.
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Locations  []GraphQLLocation      `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+	Err        error                  `json:"-"`
+}
+
+func (e *GraphQLError) Error() string {
+	return e.Message
+}
+
+func (e *GraphQLError) Unwrap() error {
+	return e.Err
+}
+
+// GraphQLResponse is the result of a request. Data is only sent once the
+// request was valid and started to run; it is then null if a non-null field
+// failed at the root.
+type GraphQLResponse struct {
+	Data     interface{}
+	Errors   []*GraphQLError
+	executed bool
+}
+
+func (r *GraphQLResponse) MarshalJSON() ([]byte, error) {
+	members := map[string]interface{}{}
+	if len(r.Errors) > 0 {
+		members["errors"] = r.Errors
+	}
+	if r.executed {
+		members["data"] = r.Data
+	}
+	return json.Marshal(members)
+}
+
+// Executed reports whether the request was valid and ran.
+func (r *GraphQLResponse) Executed() bool {
+	return r.executed
+}
+
+// gqlResultObject is an object of the response, whose members keep the
+// order of the query.
+type gqlResultObject struct {
+	keys   []string
+	values []interface{}
+}
+
+func (o *gqlResultObject) add(key string) int {
+	o.keys = append(o.keys, key)
+	o.values = append(o.values, nil)
+	return len(o.values) - 1
+}
+
+func (o *gqlResultObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.Quote(key))
+		b.WriteByte(':')
+		value, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// gqlSlot is the place of a value in the response. A failed non-null value
+// nulls the closest nullable slot above it.
+type gqlSlot struct {
+	parent  *gqlSlot
+	key     interface{}
+	nonNull bool
+	nulled  bool
+	set     func(v interface{})
+}
+
+func (s *gqlSlot) path() []interface{} {
+	var path []interface{}
+	for ; s.parent != nil; s = s.parent {
+		path = append([]interface{}{s.key}, path...)
+	}
+	return path
+}
+
+func (s *gqlSlot) null() {
+	for s.nonNull && s.parent != nil {
+		s = s.parent
+	}
+	s.set(nil)
+	s.nulled = true
+}
+
+// alive reports whether the slot is still part of the response.
+func (s *gqlSlot) alive() bool {
+	for ; s != nil; s = s.parent {
+		if s.nulled {
+			return false
+		}
+	}
+	return true
+}
+
+// gqlFieldGroup are the fields of a selection set with the same response
+// key, merged into one.
+type gqlFieldGroup struct {
+	key    string
+	fields []*gqlField
+}
+
+// subselections returns the selection sets of the fields of the group.
+func (g *gqlFieldGroup) subselections() []gqlSelection {
+	var selections []gqlSelection
+	for _, f := range g.fields {
+		selections = append(selections, f.selections...)
+	}
+	return selections
+}
+
+// gqlWork is an object whose fields are to be resolved.
+type gqlWork struct {
+	object *GraphQLObject
+	source interface{}
+	groups []*gqlFieldGroup
+	result *gqlResultObject
+	slot   *gqlSlot
+}
+
+// gqlPendingField is a resolved field whose value is not completed yet.
+type gqlPendingField struct {
+	def   *GraphQLField
+	group *gqlFieldGroup
+	slot  *gqlSlot
+	value interface{}
+	err   error
+}
+
+type gqlExecutor struct {
+	ctx       context.Context
+	schema    *GraphQLSchema
+	doc       *gqlDocument
+	variables map[string]interface{}
+	varDefs   map[string]GraphQLType
+	args      map[*gqlField]map[string]interface{}
+	errors    []*GraphQLError
+	visited   int
+	tooDeep   bool
+}
+
+// Execute runs the request against the schema. The fields of a query are
+// resolved breadth first: all fields at one depth are resolved before the
+// deferred values their resolvers returned are read, so that loads are
+// batched across the objects of a list. The fields at the root of a
+// mutation run one after the other.
+func (s *GraphQLSchema) Execute(ctx context.Context, req GraphQLRequest) *GraphQLResponse {
+	resp := &GraphQLResponse{}
+	doc, err := parseGraphQL(req.Query)
+	if err != nil {
+		var gerr *GraphQLError
+		if !errors.As(err, &gerr) {
+			gerr = &GraphQLError{Message: err.Error(), Err: err}
+		}
+		resp.Errors = []*GraphQLError{gerr}
+		return resp
+	}
+	e := &gqlExecutor{ctx: ctx, schema: s, doc: doc, args: map[*gqlField]map[string]interface{}{}}
+
+	op := e.operation(req.OperationName)
+	var root *GraphQLObject
+	if op != nil {
+		switch op.kind {
+		case "query":
+			root = s.Query
+		case "mutation":
+			root = s.Mutation
+		}
+		if root == nil {
+			e.requestError(op.loc, "the schema does not support %s operations", op.kind)
+		}
+	}
+	if len(e.errors) == 0 {
+		e.coerceVariables(op, req.Variables)
+	}
+	if len(e.errors) == 0 {
+		e.checkFragments()
+	}
+	if len(e.errors) == 0 {
+		complexity := e.validateSelectionSet(root, op.selections, 1)
+		if s.MaxComplexity > 0 && complexity > s.MaxComplexity && !e.tooDeep {
+			e.errors = append(e.errors, &GraphQLError{
+				Message: fmt.Sprintf("query has a complexity of %d, more than the limit of %d", complexity, s.MaxComplexity),
+				Err:     ErrGraphQLTooComplex,
+			})
+		}
+	}
+	if len(e.errors) > 0 {
+		resp.Errors = e.errors
+		return resp
+	}
+
+	resp.executed = true
+	result := &gqlResultObject{}
+	resp.Data = result
+	rootSlot := &gqlSlot{set: func(v interface{}) { resp.Data = v }}
+	groups := e.collectFields(root, op.selections, false)
+	if op.kind == "mutation" {
+		for _, g := range groups {
+			e.run([]*gqlWork{{object: root, groups: []*gqlFieldGroup{g}, result: result, slot: rootSlot}})
+		}
+	} else {
+		e.run([]*gqlWork{{object: root, groups: groups, result: result, slot: rootSlot}})
+	}
+	resp.Errors = e.errors
+	return resp
+}
+
+func (e *gqlExecutor) requestError(loc GraphQLLocation, format string, args ...interface{}) {
+	gerr := &GraphQLError{Message: fmt.Sprintf(format, args...), Err: ErrGraphQLRequest}
+	if loc.Line > 0 {
+		gerr.Locations = []GraphQLLocation{loc}
+	}
+	e.errors = append(e.errors, gerr)
+}
+
+// operation picks the operation to run: the one named, or the only one.
+func (e *gqlExecutor) operation(name string) *gqlOperation {
+	names := map[string]bool{}
+	for _, op := range e.doc.operations {
+		if op.name == "" && len(e.doc.operations) > 1 {
+			e.requestError(op.loc, "an anonymous operation must be the only operation of the document")
+			return nil
+		}
+		if names[op.name] {
+			e.requestError(op.loc, "there can be only one operation named %q", op.name)
+			return nil
+		}
+		names[op.name] = true
+	}
+	if name == "" {
+		if len(e.doc.operations) > 1 {
+			e.requestError(GraphQLLocation{}, "operationName is required when the document has several operations")
+			return nil
+		}
+		return e.doc.operations[0]
+	}
+	for _, op := range e.doc.operations {
+		if op.name == name {
+			return op
+		}
+	}
+	e.requestError(GraphQLLocation{}, "unknown operation %q", name)
+	return nil
+}
+
+// typeOf returns the schema type of a type written in the document.
+func (e *gqlExecutor) typeOf(ref *gqlTypeRef) GraphQLType {
+	var t GraphQLType
+	if ref.elem != nil {
+		elem := e.typeOf(ref.elem)
+		if elem == nil {
+			return nil
+		}
+		t = GraphQLListOf(elem)
+	} else if t = e.schema.types[ref.name]; t == nil {
+		return nil
+	}
+	if ref.nonNull {
+		t = GraphQLNonNullOf(t)
+	}
+	return t
+}
+
+func (e *gqlExecutor) coerceVariables(op *gqlOperation, values map[string]interface{}) {
+	e.variables = map[string]interface{}{}
+	e.varDefs = map[string]GraphQLType{}
+	for _, def := range op.variables {
+		if _, ok := e.varDefs[def.name]; ok {
+			e.requestError(def.loc, "there can be only one variable named $%s", def.name)
+			continue
+		}
+		t := e.typeOf(def.typ)
+		if t == nil || !isInputType(t) {
+			e.requestError(def.loc, "variable $%s cannot be of the non-input type %s", def.name, def.typ)
+			continue
+		}
+		e.varDefs[def.name] = t
+
+		value, ok := values[def.name]
+		switch {
+		case !ok && def.defaultValue != nil:
+			v, _, err := e.coerceLiteral(def.defaultValue, t)
+			if err != nil {
+				e.requestError(def.loc, "variable $%s has an invalid default value: %v", def.name, err)
+				continue
+			}
+			e.variables[def.name] = v
+		case isNonNull(t) && value == nil:
+			e.requestError(def.loc, "variable $%s of required type %s was not provided", def.name, t)
+		case ok:
+			v, err := coerceInput(value, t)
+			if err != nil {
+				e.requestError(def.loc, "variable $%s got an invalid value: %v", def.name, err)
+				continue
+			}
+			e.variables[def.name] = v
+		}
+	}
+}
+
+// coerceInput coerces a decoded JSON value to the input type t.
+func coerceInput(v interface{}, t GraphQLType) (interface{}, error) {
+	if nn, ok := t.(*graphQLNonNull); ok {
+		if v == nil {
+			return nil, fmt.Errorf("expected a non-null %s", t)
+		}
+		return coerceInput(v, nn.of)
+	}
+	if v == nil {
+		return nil, nil
+	}
+	switch t := t.(type) {
+	case *graphQLList:
+		items, ok := v.([]interface{})
+		if !ok {
+			item, err := coerceInput(v, t.of)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{item}, nil
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			var err error
+			if list[i], err = coerceInput(item, t.of); err != nil {
+				return nil, fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		return list, nil
+	case *GraphQLInputObject:
+		members, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected an object of type %s", t.Name)
+		}
+		for name := range members {
+			if inputField(t, name) == nil {
+				return nil, fmt.Errorf("field %q is not defined by type %s", name, t.Name)
+			}
+		}
+		object := map[string]interface{}{}
+		for _, f := range t.Fields {
+			member, ok := members[f.Name]
+			if !ok {
+				if err := defaultInput(object, f); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			value, err := coerceInput(member, f.Type)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.Name, err)
+			}
+			object[f.Name] = value
+		}
+		return object, nil
+	case *GraphQLEnum:
+		name, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("enum %s cannot represent %v", t.Name, v)
+		}
+		return enumValue(t, name)
+	case *GraphQLScalar:
+		return t.Parse(v)
+	}
+	return nil, fmt.Errorf("%s is not an input type", t)
+}
+
+func inputField(t *GraphQLInputObject, name string) *GraphQLArgument {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// defaultInput sets the default of an argument or input field that is not
+// given.
+func defaultInput(values map[string]interface{}, arg *GraphQLArgument) error {
+	switch {
+	case arg.Default != nil:
+		values[arg.Name] = arg.Default
+	case isNonNull(arg.Type):
+		return fmt.Errorf("%s of required type %s was not provided", arg.Name, arg.Type)
+	}
+	return nil
+}
+
+func enumValue(t *GraphQLEnum, name string) (interface{}, error) {
+	for _, v := range t.Values {
+		if v.Name == name {
+			return v.Value, nil
+		}
+	}
+	return nil, fmt.Errorf("value %q does not exist in enum %s", name, t.Name)
+}
+
+// coerceLiteral coerces a value of the document to the input type t. It
+// reports whether the value is present, which it is not for a variable
+// without a value.
+func (e *gqlExecutor) coerceLiteral(v *gqlValue, t GraphQLType) (interface{}, bool, error) {
+	if v.kind == gqlVariable {
+		varType, ok := e.varDefs[v.text]
+		if !ok {
+			return nil, false, fmt.Errorf("variable $%s is not defined", v.text)
+		}
+		if !typeFits(varType, t) {
+			return nil, false, fmt.Errorf("variable $%s of type %s cannot be used as %s", v.text, varType, t)
+		}
+		value, ok := e.variables[v.text]
+		if !ok && isNonNull(t) {
+			return nil, false, fmt.Errorf("variable $%s was not provided", v.text)
+		}
+		return value, ok, nil
+	}
+	if nn, ok := t.(*graphQLNonNull); ok {
+		if v.kind == gqlNull {
+			return nil, false, fmt.Errorf("expected a non-null %s", t)
+		}
+		value, present, err := e.coerceLiteral(v, nn.of)
+		if err == nil && value == nil {
+			err = fmt.Errorf("expected a non-null %s", t)
+		}
+		return value, present, err
+	}
+	if v.kind == gqlNull {
+		return nil, true, nil
+	}
+
+	switch t := t.(type) {
+	case *graphQLList:
+		if v.kind != gqlList {
+			item, present, err := e.coerceLiteral(v, t.of)
+			if err != nil || !present {
+				return nil, present, err
+			}
+			return []interface{}{item}, true, nil
+		}
+		list := make([]interface{}, len(v.list))
+		for i, item := range v.list {
+			var err error
+			if list[i], _, err = e.coerceLiteral(item, t.of); err != nil {
+				return nil, false, fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		return list, true, nil
+	case *GraphQLInputObject:
+		if v.kind != gqlObject {
+			return nil, false, fmt.Errorf("expected an object of type %s", t.Name)
+		}
+		object := map[string]interface{}{}
+		given := map[string]*gqlValue{}
+		for _, f := range v.fields {
+			if inputField(t, f.name) == nil {
+				return nil, false, fmt.Errorf("field %q is not defined by type %s", f.name, t.Name)
+			}
+			if _, ok := given[f.name]; ok {
+				return nil, false, fmt.Errorf("field %q is given twice", f.name)
+			}
+			given[f.name] = f.value
+		}
+		for _, f := range t.Fields {
+			if lit, ok := given[f.Name]; ok {
+				value, present, err := e.coerceLiteral(lit, f.Type)
+				if err != nil {
+					return nil, false, fmt.Errorf("field %s: %w", f.Name, err)
+				}
+				if present {
+					object[f.Name] = value
+					continue
+				}
+			}
+			if err := defaultInput(object, f); err != nil {
+				return nil, false, err
+			}
+		}
+		return object, true, nil
+	case *GraphQLEnum:
+		if v.kind != gqlEnum {
+			return nil, false, fmt.Errorf("enum %s cannot represent a non-enum value", t.Name)
+		}
+		value, err := enumValue(t, v.text)
+		return value, true, err
+	case *GraphQLScalar:
+		var raw interface{}
+		switch v.kind {
+		case gqlInt:
+			n, err := strconv.ParseInt(v.text, 10, 64)
+			if err != nil {
+				return nil, false, fmt.Errorf("%s is out of range", v.text)
+			}
+			raw = n
+		case gqlFloat:
+			f, err := strconv.ParseFloat(v.text, 64)
+			if err != nil {
+				return nil, false, fmt.Errorf("%s is out of range", v.text)
+			}
+			raw = f
+		case gqlString:
+			raw = v.text
+		case gqlBoolean:
+			raw = v.text == "true"
+		default:
+			return nil, false, fmt.Errorf("%s cannot represent a non-scalar value", t.Name)
+		}
+		value, err := t.Parse(raw)
+		return value, true, err
+	}
+	return nil, false, fmt.Errorf("%s is not an input type", t)
+}
+
+// typeFits reports whether a variable of type v can be used where a value
+// of type t is expected.
+func typeFits(v GraphQLType, t GraphQLType) bool {
+	if tn, ok := t.(*graphQLNonNull); ok {
+		vn, ok := v.(*graphQLNonNull)
+		return ok && typeFits(vn.of, tn.of)
+	}
+	if vn, ok := v.(*graphQLNonNull); ok {
+		return typeFits(vn.of, t)
+	}
+	if tl, ok := t.(*graphQLList); ok {
+		vl, ok := v.(*graphQLList)
+		return ok && typeFits(vl.of, tl.of)
+	}
+	return v == t
+}
+
+// coerceArguments coerces the arguments given to a field or directive.
+func (e *gqlExecutor) coerceArguments(defs []*GraphQLArgument, given []*gqlArgument) (map[string]interface{}, error) {
+	byName := map[string]*gqlArgument{}
+	for _, arg := range given {
+		found := false
+		for _, def := range defs {
+			found = found || def.Name == arg.name
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown argument %q", arg.name)
+		}
+		if _, ok := byName[arg.name]; ok {
+			return nil, fmt.Errorf("argument %q is given twice", arg.name)
+		}
+		byName[arg.name] = arg
+	}
+	args := map[string]interface{}{}
+	for _, def := range defs {
+		if arg, ok := byName[def.Name]; ok {
+			value, present, err := e.coerceLiteral(arg.value, def.Type)
+			if err != nil {
+				return nil, fmt.Errorf("argument %q has an invalid value: %w", def.Name, err)
+			}
+			if present {
+				args[def.Name] = value
+				continue
+			}
+		}
+		if err := defaultInput(args, def); err != nil {
+			return nil, fmt.Errorf("argument %w", err)
+		}
+	}
+	return args, nil
+}
+
+// checkFragments checks that fragments are defined on object types and that
+// they do not spread themselves.
+func (e *gqlExecutor) checkFragments() {
+	for _, f := range e.doc.fragments {
+		if _, ok := e.schema.types[f.typeCondition].(*GraphQLObject); !ok {
+			e.requestError(f.loc, "fragment %q cannot condition on the non-object type %s", f.name, f.typeCondition)
+		}
+	}
+	done := map[string]bool{}
+	var visit func(name string, stack []string)
+	visit = func(name string, stack []string) {
+		for _, seen := range stack {
+			if seen == name {
+				e.requestError(e.doc.fragments[name].loc, "cannot spread fragment %q within itself via %s", name, strings.Join(stack, ", "))
+				return
+			}
+		}
+		if done[name] {
+			return
+		}
+		done[name] = true
+		for _, spread := range fragmentSpreads(e.doc.fragments[name].selections) {
+			if _, ok := e.doc.fragments[spread]; ok {
+				visit(spread, append(stack, name))
+			}
+		}
+	}
+	for name := range e.doc.fragments {
+		visit(name, nil)
+	}
+}
+
+// fragmentSpreads lists the fragments spread anywhere in the selections.
+func fragmentSpreads(selections []gqlSelection) []string {
+	var names []string
+	for _, selection := range selections {
+		switch s := selection.(type) {
+		case *gqlField:
+			names = append(names, fragmentSpreads(s.selections)...)
+		case *gqlInlineFragment:
+			names = append(names, fragmentSpreads(s.selections)...)
+		case *gqlFragmentSpread:
+			names = append(names, s.name)
+		}
+	}
+	return names
+}
+
+// include evaluates the @skip and @include directives of a selection.
+func (e *gqlExecutor) include(directives []*gqlDirective, report bool) bool {
+	include := true
+	for _, d := range directives {
+		if d.name != "skip" && d.name != "include" {
+			if report {
+				e.requestError(d.loc, "unknown directive @%s", d.name)
+			}
+			continue
+		}
+		args, err := e.coerceArguments([]*GraphQLArgument{{Name: "if", Type: GraphQLNonNullOf(GraphQLBoolean)}}, d.arguments)
+		if err != nil {
+			if report {
+				e.requestError(d.loc, "directive @%s: %v", d.name, err)
+			}
+			continue
+		}
+		if args["if"] == (d.name == "skip") {
+			include = false
+		}
+	}
+	return include
+}
+
+// collectFields groups the fields selected on an object by response key,
+// spreading the fragments that apply to it. With report, invalid
+// directives and fragments are reported as errors.
+func (e *gqlExecutor) collectFields(t *GraphQLObject, selections []gqlSelection, report bool) []*gqlFieldGroup {
+	var groups []*gqlFieldGroup
+	byKey := map[string]*gqlFieldGroup{}
+	visited := map[string]bool{}
+	var collect func(selections []gqlSelection)
+	collect = func(selections []gqlSelection) {
+		for _, selection := range selections {
+			switch s := selection.(type) {
+			case *gqlField:
+				if !e.include(s.directives, report) {
+					continue
+				}
+				g, ok := byKey[s.responseKey()]
+				if !ok {
+					g = &gqlFieldGroup{key: s.responseKey()}
+					byKey[g.key] = g
+					groups = append(groups, g)
+				}
+				g.fields = append(g.fields, s)
+			case *gqlFragmentSpread:
+				if visited[s.name] || !e.include(s.directives, report) {
+					continue
+				}
+				visited[s.name] = true
+				f, ok := e.doc.fragments[s.name]
+				if !ok {
+					if report {
+						e.requestError(s.loc, "unknown fragment %q", s.name)
+					}
+					continue
+				}
+				if f.typeCondition != t.Name {
+					if report {
+						e.requestError(s.loc, "fragment %q cannot be spread here as objects of type %s can never be of type %s", s.name, t.Name, f.typeCondition)
+					}
+					continue
+				}
+				collect(f.selections)
+			case *gqlInlineFragment:
+				if !e.include(s.directives, report) {
+					continue
+				}
+				if s.typeCondition != "" && s.typeCondition != t.Name {
+					if report {
+						e.requestError(s.loc, "fragment cannot be spread here as objects of type %s can never be of type %s", t.Name, s.typeCondition)
+					}
+					continue
+				}
+				collect(s.selections)
+			}
+		}
+	}
+	collect(selections)
+	return groups
+}
+
+// validateSelectionSet checks the fields selected on an object at a depth,
+// coerces their arguments and returns the complexity of the selection.
+func (e *gqlExecutor) validateSelectionSet(t *GraphQLObject, selections []gqlSelection, depth int) int {
+	if e.schema.MaxDepth > 0 && depth > e.schema.MaxDepth {
+		if !e.tooDeep {
+			e.tooDeep = true
+			e.errors = append(e.errors, &GraphQLError{
+				Message: fmt.Sprintf("query is more than %d levels deep", e.schema.MaxDepth),
+				Err:     ErrGraphQLTooDeep,
+			})
+		}
+		return 0
+	}
+	complexity := 0
+	for _, g := range e.collectFields(t, selections, true) {
+		f := g.fields[0]
+		for _, other := range g.fields[1:] {
+			if other.name != f.name {
+				e.requestError(other.loc, "fields %q conflict because %s and %s are different fields", g.key, f.name, other.name)
+			}
+		}
+		if f.name == "__typename" {
+			if len(f.arguments) > 0 || len(f.selections) > 0 {
+				e.requestError(f.loc, "field __typename takes no arguments and has no subfields")
+			}
+			continue
+		}
+		def := t.Field(f.name)
+		if def == nil {
+			e.requestError(f.loc, "cannot query field %q on type %s", f.name, t.Name)
+			continue
+		}
+		e.visited++
+		if e.schema.MaxComplexity > 0 && e.visited > e.schema.MaxComplexity {
+			// Each field costs at least one, so the walk can stop here.
+			return e.visited
+		}
+		var args map[string]interface{}
+		for _, field := range g.fields {
+			var err error
+			if args, err = e.coerceArguments(def.Args, field.arguments); err != nil {
+				e.errors = append(e.errors, &GraphQLError{
+					Message:   fmt.Sprintf("field %q: %v", f.name, err),
+					Locations: []GraphQLLocation{field.loc},
+					Err:       ErrGraphQLRequest,
+				})
+				break
+			}
+			e.args[field] = args
+		}
+
+		child := 0
+		subselections := g.subselections()
+		switch named := namedType(def.Type).(type) {
+		case *GraphQLObject:
+			if len(subselections) == 0 {
+				e.requestError(f.loc, "field %q of type %s must have a selection of subfields", f.name, def.Type)
+				continue
+			}
+			child = e.validateSelectionSet(named, subselections, depth+1)
+		default:
+			if len(subselections) > 0 {
+				e.requestError(f.loc, "field %q must not have a selection since type %s has no subfields", f.name, def.Type)
+				continue
+			}
+		}
+		if def.Complexity != nil && args != nil {
+			complexity += def.Complexity(args, child)
+		} else {
+			complexity += 1 + child
+		}
+	}
+	return complexity
+}
+
+// run resolves the fields of the objects, one depth at a time.
+func (e *gqlExecutor) run(works []*gqlWork) {
+	for len(works) > 0 {
+		var pending []*gqlPendingField
+		for _, w := range works {
+			if !w.slot.alive() {
+				continue
+			}
+			for _, g := range w.groups {
+				if p := e.resolveField(w, g); p != nil {
+					pending = append(pending, p)
+				}
+			}
+		}
+
+		var next []*gqlWork
+		for _, p := range pending {
+			value, err := p.value, p.err
+			if thunk, ok := value.(func() (interface{}, error)); ok && err == nil {
+				value, err = e.call(thunk)
+			}
+			if err != nil {
+				e.fieldError(p.slot, p.group, err)
+				continue
+			}
+			e.complete(p.def.Type, value, p.slot, p.group, &next)
+		}
+		works = next
+	}
+}
+
+func (e *gqlExecutor) resolveField(w *gqlWork, g *gqlFieldGroup) *gqlPendingField {
+	f := g.fields[0]
+	i := w.result.add(g.key)
+	result := w.result
+	slot := &gqlSlot{parent: w.slot, key: g.key, set: func(v interface{}) { result.values[i] = v }}
+	if f.name == "__typename" {
+		slot.set(w.object.Name)
+		return nil
+	}
+	def := w.object.Field(f.name)
+	slot.nonNull = isNonNull(def.Type)
+	params := GraphQLParams{Context: e.ctx, Source: w.source, Args: e.args[f]}
+	value, err := e.call(func() (interface{}, error) {
+		if def.Resolve == nil {
+			return defaultResolve(w.source, def.Name)
+		}
+		return def.Resolve(params)
+	})
+	return &gqlPendingField{def: def, group: g, slot: slot, value: value, err: err}
+}
+
+// call calls a resolver, turning a panic into an error.
+func (e *gqlExecutor) call(fn func() (interface{}, error)) (value interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("resolver panicked: %v", r)
+		}
+	}()
+	return fn()
+}
+
+func (e *gqlExecutor) fieldError(slot *gqlSlot, g *gqlFieldGroup, err error) {
+	gerr := &GraphQLError{Message: err.Error(), Path: slot.path(), Err: err}
+	for _, f := range g.fields {
+		gerr.Locations = append(gerr.Locations, f.loc)
+	}
+	e.errors = append(e.errors, gerr)
+	slot.null()
+}
+
+// defaultResolve returns the member of a map or the struct field whose JSON
+// name is name.
+func defaultResolve(source interface{}, name string) (interface{}, error) {
+	if m, ok := source.(map[string]interface{}); ok {
+		return m[name], nil
+	}
+	v := reflect.ValueOf(source)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Struct {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if tag == name || tag == "" && t.Field(i).Name == name {
+				return v.Field(i).Interface(), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%T has no field %s", source, name)
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Interface, reflect.Func:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// complete turns a resolved value into its response value of type t. The
+// fields of objects are left to the next depth.
+func (e *gqlExecutor) complete(t GraphQLType, value interface{}, slot *gqlSlot, g *gqlFieldGroup, next *[]*gqlWork) {
+	if nn, ok := t.(*graphQLNonNull); ok {
+		if isNil(value) {
+			e.fieldError(slot, g, errGraphQLNullNonNull)
+			return
+		}
+		e.complete(nn.of, value, slot, g, next)
+		return
+	}
+	if isNil(value) {
+		slot.set(nil)
+		return
+	}
+
+	switch t := t.(type) {
+	case *GraphQLScalar, *GraphQLEnum:
+		if rv := reflect.ValueOf(value); rv.Kind() == reflect.Ptr {
+			value = rv.Elem().Interface()
+		}
+		var out interface{}
+		var err error
+		if scalar, ok := t.(*GraphQLScalar); ok {
+			out, err = scalar.Serialize(value)
+		} else {
+			out, err = serializeEnum(t.(*GraphQLEnum), value)
+		}
+		if err != nil {
+			e.fieldError(slot, g, err)
+			return
+		}
+		slot.set(out)
+	case *graphQLList:
+		rv := reflect.ValueOf(value)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			e.fieldError(slot, g, fmt.Errorf("expected a list, got %T", value))
+			return
+		}
+		items := make([]interface{}, rv.Len())
+		slot.set(items)
+		for i := range items {
+			i := i
+			item := rv.Index(i)
+			if item.Kind() == reflect.Struct && item.CanAddr() {
+				item = item.Addr()
+			}
+			child := &gqlSlot{parent: slot, key: i, nonNull: isNonNull(t.of), set: func(v interface{}) { items[i] = v }}
+			e.complete(t.of, item.Interface(), child, g, next)
+		}
+	case *GraphQLObject:
+		result := &gqlResultObject{}
+		slot.set(result)
+		*next = append(*next, &gqlWork{object: t, source: value, groups: e.collectFields(t, g.subselections(), false), result: result, slot: slot})
+	}
+}
+
+func serializeEnum(t *GraphQLEnum, value interface{}) (interface{}, error) {
+	for _, v := range t.Values {
+		if reflect.DeepEqual(v.Value, value) {
+			return v.Name, nil
+		}
+	}
+	return nil, fmt.Errorf("enum %s cannot represent %v", t.Name, value)
+}
@@ -0,0 +1,437 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GraphQLType is a type of a schema: a *GraphQLScalar, *GraphQLEnum,
+// *GraphQLObject or *GraphQLInputObject, or a list or non-null wrapper of
+// one made with GraphQLListOf and GraphQLNonNullOf.
+type GraphQLType interface {
+	String() string
+}
+
+// GraphQLScalar is a leaf type. Serialize turns a resolved value into its
+// JSON form. Parse turns an input value, a decoded JSON value or the int64,
+// float64, string or bool of a literal, into the value resolvers receive.
+type GraphQLScalar struct {
+	Name        string
+	Description string
+	Serialize   func(v interface{}) (interface{}, error)
+	Parse       func(v interface{}) (interface{}, error)
+}
+
+func (t *GraphQLScalar) String() string { return t.Name }
+
+// GraphQLEnum is a leaf type with a fixed set of values. Value is what
+// resolvers receive and return for the name.
+type GraphQLEnum struct {
+	Name        string
+	Description string
+	Values      []GraphQLEnumValue
+}
+
+type GraphQLEnumValue struct {
+	Name        string
+	Description string
+	Value       interface{}
+}
+
+func (t *GraphQLEnum) String() string { return t.Name }
+
+// GraphQLObject is an output type made of fields.
+type GraphQLObject struct {
+	Name        string
+	Description string
+	Fields      []*GraphQLField
+}
+
+func (t *GraphQLObject) String() string { return t.Name }
+
+// Field returns the field of the object with the name, or nil.
+func (t *GraphQLObject) Field(name string) *GraphQLField {
+	for _, f := range t.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// GraphQLResolver returns the value of a field of source. It may return a
+// func() (interface{}, error) instead, such as the one of Loader.Load,
+// which is only called once the other fields at the same depth are
+// resolved, so that their loads are batched.
+type GraphQLResolver func(p GraphQLParams) (interface{}, error)
+
+type GraphQLParams struct {
+	Context context.Context
+	Source  interface{}
+	Args    map[string]interface{}
+}
+
+// GraphQLField is a field of an object. Without Resolve the value is the
+// member of the source map, or the struct field whose JSON name is the name
+// of the field. Complexity returns the cost of the field given its
+// arguments and the cost of its selection set; by default it is one more
+// than the selection set.
+type GraphQLField struct {
+	Name        string
+	Description string
+	Deprecation string
+	Args        []*GraphQLArgument
+	Type        GraphQLType
+	Resolve     GraphQLResolver
+	Complexity  func(args map[string]interface{}, childComplexity int) int
+}
+
+// GraphQLArgument is an argument of a field or a field of an input object.
+// A nil Default leaves the argument out of the arguments when it is not
+// given.
+type GraphQLArgument struct {
+	Name        string
+	Description string
+	Type        GraphQLType
+	Default     interface{}
+}
+
+// GraphQLInputObject is an input type made of fields. It is received as a
+// map[string]interface{}.
+type GraphQLInputObject struct {
+	Name        string
+	Description string
+	Fields      []*GraphQLArgument
+}
+
+func (t *GraphQLInputObject) String() string { return t.Name }
+
+type graphQLList struct {
+	of GraphQLType
+}
+
+func (t *graphQLList) String() string { return "[" + t.of.String() + "]" }
+
+type graphQLNonNull struct {
+	of GraphQLType
+}
+
+func (t *graphQLNonNull) String() string { return t.of.String() + "!" }
+
+func GraphQLListOf(t GraphQLType) GraphQLType {
+	return &graphQLList{of: t}
+}
+
+func GraphQLNonNullOf(t GraphQLType) GraphQLType {
+	return &graphQLNonNull{of: t}
+}
+
+// namedType strips the list and non-null wrappers of t.
+func namedType(t GraphQLType) GraphQLType {
+	for {
+		switch w := t.(type) {
+		case *graphQLList:
+			t = w.of
+		case *graphQLNonNull:
+			t = w.of
+		default:
+			return t
+		}
+	}
+}
+
+func isNonNull(t GraphQLType) bool {
+	_, ok := t.(*graphQLNonNull)
+	return ok
+}
+
+func isInputType(t GraphQLType) bool {
+	switch namedType(t).(type) {
+	case *GraphQLScalar, *GraphQLEnum, *GraphQLInputObject:
+		return true
+	}
+	return false
+}
+
+// GraphQLSchema is the root of a GraphQL API. Queries deeper than MaxDepth
+// fields or costlier than MaxComplexity are refused before they run; zero
+// disables a limit.
+type GraphQLSchema struct {
+	Query         *GraphQLObject
+	Mutation      *GraphQLObject
+	MaxDepth      int
+	MaxComplexity int
+
+	types map[string]GraphQLType
+}
+
+// NewGraphQLSchema collects the types reachable from the roots and checks
+// that their names are unique.
+func NewGraphQLSchema(query *GraphQLObject, mutation *GraphQLObject, maxDepth int, maxComplexity int) (*GraphQLSchema, error) {
+	s := &GraphQLSchema{Query: query, Mutation: mutation, MaxDepth: maxDepth, MaxComplexity: maxComplexity, types: map[string]GraphQLType{}}
+	for _, scalar := range []*GraphQLScalar{GraphQLInt, GraphQLFloat, GraphQLString, GraphQLBoolean, GraphQLID} {
+		s.types[scalar.Name] = scalar
+	}
+	roots := []GraphQLType{query}
+	if mutation != nil {
+		roots = append(roots, mutation)
+	}
+	for _, root := range roots {
+		if err := s.addType(root); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *GraphQLSchema) addType(t GraphQLType) error {
+	t = namedType(t)
+	if have, ok := s.types[t.String()]; ok {
+		if have != t {
+			return fmt.Errorf("GraphQL schema has two types named %s", t)
+		}
+		return nil
+	}
+	s.types[t.String()] = t
+	switch t := t.(type) {
+	case *GraphQLObject:
+		for _, f := range t.Fields {
+			if err := s.addType(f.Type); err != nil {
+				return err
+			}
+			for _, arg := range f.Args {
+				if !isInputType(arg.Type) {
+					return fmt.Errorf("argument %s of %s.%s is not an input type", arg.Name, t.Name, f.Name)
+				}
+				if err := s.addType(arg.Type); err != nil {
+					return err
+				}
+			}
+		}
+	case *GraphQLInputObject:
+		for _, f := range t.Fields {
+			if !isInputType(f.Type) {
+				return fmt.Errorf("field %s of %s is not an input type", f.Name, t.Name)
+			}
+			if err := s.addType(f.Type); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SDL prints the schema in the schema definition language, types in order
+// of name.
+func (s *GraphQLSchema) SDL() string {
+	var b strings.Builder
+	b.WriteString("schema {\n  query: " + s.Query.Name + "\n")
+	if s.Mutation != nil {
+		b.WriteString("  mutation: " + s.Mutation.Name + "\n")
+	}
+	b.WriteString("}\n")
+
+	names := make([]string, 0, len(s.types))
+	for name := range s.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		switch t := s.types[name].(type) {
+		case *GraphQLScalar:
+			if isBuiltinScalar(t) {
+				continue
+			}
+			b.WriteString("\n" + sdlDescription(t.Description, "") + "scalar " + t.Name + "\n")
+		case *GraphQLEnum:
+			b.WriteString("\n" + sdlDescription(t.Description, "") + "enum " + t.Name + " {\n")
+			for _, v := range t.Values {
+				b.WriteString(sdlDescription(v.Description, "  ") + "  " + v.Name + "\n")
+			}
+			b.WriteString("}\n")
+		case *GraphQLObject:
+			b.WriteString("\n" + sdlDescription(t.Description, "") + "type " + t.Name + " {\n")
+			for _, f := range t.Fields {
+				b.WriteString(sdlDescription(f.Description, "  ") + "  " + f.Name + sdlArguments(f.Args) + ": " + f.Type.String())
+				if f.Deprecation != "" {
+					b.WriteString(" @deprecated(reason: " + strconv.Quote(f.Deprecation) + ")")
+				}
+				b.WriteString("\n")
+			}
+			b.WriteString("}\n")
+		case *GraphQLInputObject:
+			b.WriteString("\n" + sdlDescription(t.Description, "") + "input " + t.Name + " {\n")
+			for _, f := range t.Fields {
+				b.WriteString(sdlDescription(f.Description, "  ") + "  " + sdlArgument(f) + "\n")
+			}
+			b.WriteString("}\n")
+		}
+	}
+	return b.String()
+}
+
+func sdlDescription(description string, indent string) string {
+	if description == "" {
+		return ""
+	}
+	return indent + strconv.Quote(description) + "\n"
+}
+
+func sdlArguments(args []*GraphQLArgument) string {
+	if len(args) == 0 {
+		return ""
+	}
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = sdlArgument(arg)
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+func sdlArgument(arg *GraphQLArgument) string {
+	s := arg.Name + ": " + arg.Type.String()
+	if arg.Default != nil {
+		s += " = " + sdlValue(arg.Default, arg.Type)
+	}
+	return s
+}
+
+// sdlValue prints an input value as a literal of the type.
+func sdlValue(v interface{}, t GraphQLType) string {
+	switch t := namedType(t).(type) {
+	case *GraphQLEnum:
+		for _, ev := range t.Values {
+			if reflect.DeepEqual(ev.Value, v) {
+				return ev.Name
+			}
+		}
+	case *GraphQLScalar:
+		if out, err := t.Serialize(v); err == nil {
+			if b, err := json.Marshal(out); err == nil {
+				return string(b)
+			}
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+// The built-in scalars of GraphQL.
+var (
+	GraphQLInt = &GraphQLScalar{
+		Name:        "Int",
+		Description: "A signed 32-bit integer.",
+		Serialize:   graphQLInt,
+		Parse:       graphQLInt,
+	}
+	GraphQLFloat = &GraphQLScalar{
+		Name:        "Float",
+		Description: "A double-precision floating-point number.",
+		Serialize:   graphQLFloat,
+		Parse:       graphQLFloat,
+	}
+	GraphQLString = &GraphQLScalar{
+		Name:        "String",
+		Description: "A UTF-8 character sequence.",
+		Serialize:   graphQLString,
+		Parse:       graphQLString,
+	}
+	GraphQLBoolean = &GraphQLScalar{
+		Name:        "Boolean",
+		Description: "true or false.",
+		Serialize:   graphQLBoolean,
+		Parse:       graphQLBoolean,
+	}
+	GraphQLID = &GraphQLScalar{
+		Name:        "ID",
+		Description: "A unique identifier, serialized as a string.",
+		Serialize: func(v interface{}) (interface{}, error) {
+			if s, ok := v.(fmt.Stringer); ok {
+				return s.String(), nil
+			}
+			return graphQLString(v)
+		},
+		Parse: func(v interface{}) (interface{}, error) {
+			if n, err := graphQLInt(v); err == nil {
+				return strconv.Itoa(n.(int)), nil
+			}
+			return graphQLString(v)
+		},
+	}
+)
+
+func isBuiltinScalar(t *GraphQLScalar) bool {
+	switch t {
+	case GraphQLInt, GraphQLFloat, GraphQLString, GraphQLBoolean, GraphQLID:
+		return true
+	}
+	return false
+}
+
+// graphQLNumber is a json.Number, as variables are decoded with UseNumber.
+type graphQLNumber interface {
+	Int64() (int64, error)
+	Float64() (float64, error)
+}
+
+func graphQLInt(v interface{}) (interface{}, error) {
+	var n int64
+	switch v := v.(type) {
+	case int:
+		n = int64(v)
+	case int32:
+		n = int64(v)
+	case int64:
+		n = v
+	case float64:
+		if v != math.Trunc(v) || math.Abs(v) > math.MaxInt32 {
+			return nil, fmt.Errorf("Int cannot represent %v", v)
+		}
+		n = int64(v)
+	case graphQLNumber:
+		var err error
+		if n, err = v.Int64(); err != nil {
+			return nil, fmt.Errorf("Int cannot represent %v", v)
+		}
+	default:
+		return nil, fmt.Errorf("Int cannot represent %v", v)
+	}
+	if n > math.MaxInt32 || n < math.MinInt32 {
+		return nil, fmt.Errorf("Int cannot represent %d, it is not a 32-bit integer", n)
+	}
+	return int(n), nil
+}
+
+func graphQLFloat(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case graphQLNumber:
+		return v.Float64()
+	}
+	return nil, fmt.Errorf("Float cannot represent %v", v)
+}
+
+func graphQLString(v interface{}) (interface{}, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("String cannot represent %v", v)
+}
+
+func graphQLBoolean(v interface{}) (interface{}, error) {
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	return nil, fmt.Errorf("Boolean cannot represent %v", v)
+}
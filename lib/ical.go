@@ -0,0 +1,458 @@
+package lib
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"tasks/db"
+)
+
+var ErrInvalidICal = errors.New("invalid iCalendar data")
+
+const (
+	icalUTCLayout   = "20060102T150405Z"
+	icalLocalLayout = "20060102T150405"
+	icalDateLayout  = "20060102"
+	// icalLineLimit is the maximum length of a content line in octets,
+	// excluding the line break, before it is folded.
+	icalLineLimit = 75
+)
+
+var (
+	icalStatuses = map[string]string{
+		db.StatusTodo:       "NEEDS-ACTION",
+		db.StatusInProgress: "IN-PROCESS",
+		db.StatusDone:       "COMPLETED",
+	}
+	icalPriorities = map[string]int{
+		db.PriorityHigh:   1,
+		db.PriorityMedium: 5,
+		db.PriorityLow:    9,
+	}
+	icalTextEscaper   = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	icalTextUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+)
+
+// ICalUID returns the UID under which the task is exported: the UID it was
+// imported with, or its ID.
+func ICalUID(t *db.Task) string {
+	if t.ICalUID != "" {
+		return t.ICalUID
+	}
+	return t.ID.String()
+}
+
+// ICalETag returns a strong entity tag of the task as a single-task calendar
+// written by WriteICal with the task's UpdatedAt as DTSTAMP.
+func ICalETag(t *db.Task) string {
+	h := sha1.New()
+	WriteICal(h, []db.Task{*t}, t.UpdatedAt)
+	return `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+}
+
+type icalWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line writes a content line, folding it after icalLineLimit octets without
+// splitting a UTF-8 sequence.
+func (w *icalWriter) line(name string, value string) {
+	if w.err != nil {
+		return
+	}
+	s := name + ":" + value
+	for first := true; ; first = false {
+		limit := icalLineLimit
+		if !first {
+			limit-- // the leading space of the continuation
+			w.w.WriteByte(' ')
+		}
+		if len(s) <= limit {
+			w.w.WriteString(s)
+			_, w.err = w.w.WriteString("\r\n")
+			return
+		}
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		w.w.WriteString(s[:cut])
+		w.w.WriteString("\r\n")
+		s = s[cut:]
+	}
+}
+
+func (w *icalWriter) text(name string, value string) {
+	w.line(name, icalTextEscaper.Replace(value))
+}
+
+func (w *icalWriter) time(name string, t time.Time) {
+	w.line(name, t.UTC().Format(icalUTCLayout))
+}
+
+// WriteICal writes the tasks as an RFC 5545 calendar of VTODO components.
+// now is used as the DTSTAMP of every component.
+func WriteICal(w io.Writer, tasks []db.Task, now time.Time) error {
+	iw := &icalWriter{w: bufio.NewWriter(w)}
+	iw.line("BEGIN", "VCALENDAR")
+	iw.line("VERSION", "2.0")
+	iw.line("PRODID", "-//tasks//tasks//EN")
+	iw.line("CALSCALE", "GREGORIAN")
+	for i := range tasks {
+		writeICalTodo(iw, &tasks[i], now)
+	}
+	iw.line("END", "VCALENDAR")
+	if iw.err != nil {
+		return iw.err
+	}
+	return iw.w.Flush()
+}
+
+func writeICalTodo(w *icalWriter, t *db.Task, now time.Time) {
+	w.line("BEGIN", "VTODO")
+	w.text("UID", ICalUID(t))
+	w.time("DTSTAMP", now)
+	if !t.CreatedAt.IsZero() {
+		w.time("CREATED", t.CreatedAt)
+	}
+	if !t.UpdatedAt.IsZero() {
+		w.time("LAST-MODIFIED", t.UpdatedAt)
+	}
+	w.text("SUMMARY", t.Title)
+	if t.Text != "" {
+		w.text("DESCRIPTION", t.Text)
+	}
+	if t.Due != nil {
+		w.time("DUE", *t.Due)
+	}
+	if status, ok := icalStatuses[t.Status]; ok {
+		w.line("STATUS", status)
+	}
+	if t.CompletedAt != nil {
+		w.time("COMPLETED", *t.CompletedAt)
+	}
+	if priority, ok := icalPriorities[t.Priority]; ok {
+		w.line("PRIORITY", strconv.Itoa(priority))
+	}
+	if len(t.Tags) > 0 {
+		tags := make([]string, len(t.Tags))
+		for i, tag := range t.Tags {
+			tags[i] = icalTextEscaper.Replace(tag)
+		}
+		w.line("CATEGORIES", strings.Join(tags, ","))
+	}
+	if t.Recurrence != "" {
+		w.line("RRULE", t.Recurrence)
+	}
+	w.line("END", "VTODO")
+}
+
+// ICalTodo is a VTODO component read by ParseICal. Task holds the properties
+// that map onto a task; its ID, user, project and rank are left empty.
+type ICalTodo struct {
+	UID  string
+	Line int
+	Task db.Task
+}
+
+// ICalProblem describes a component that was not imported. Line is the line
+// of its BEGIN.
+type ICalProblem struct {
+	Line      int    `json:"line"`
+	Component string `json:"component"`
+	UID       string `json:"uid,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+// ICalResult is the outcome of ParseICal. Skipped lists components that are
+// not tasks or are cancelled, Invalid the VTODOs that could not be read.
+type ICalResult struct {
+	Todos   []ICalTodo
+	Skipped []ICalProblem
+	Invalid []ICalProblem
+}
+
+type icalProperty struct {
+	name   string
+	params map[string]string
+	value  string
+	line   int
+}
+
+type icalComponent struct {
+	name  string
+	line  int
+	props []icalProperty
+}
+
+func (c *icalComponent) get(name string) *icalProperty {
+	for i := range c.props {
+		if c.props[i].name == name {
+			return &c.props[i]
+		}
+	}
+	return nil
+}
+
+// readICalLines unfolds the content lines of r and returns them with the
+// number of their first physical line.
+func readICalLines(r io.Reader) ([]string, []int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lines []string
+	var numbers []int
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(text, " ") || strings.HasPrefix(text, "\t") {
+			if len(lines) == 0 {
+				return nil, nil, fmt.Errorf("%w: line %d: continuation without a content line", ErrInvalidICal, n)
+			}
+			lines[len(lines)-1] += text[1:]
+			continue
+		}
+		if text == "" {
+			continue
+		}
+		lines = append(lines, text)
+		numbers = append(numbers, n)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidICal, err)
+	}
+	return lines, numbers, nil
+}
+
+// parseICalProperty splits a content line into its name, parameters and
+// value. Colons and semicolons inside quoted parameter values are kept.
+func parseICalProperty(text string, line int) (icalProperty, error) {
+	p := icalProperty{params: map[string]string{}, line: line}
+	quoted := false
+	start, paramName := 0, ""
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '=' && p.name != "" && paramName == "":
+			paramName = strings.ToUpper(text[start:i])
+			start = i + 1
+		case c == ';' || c == ':':
+			part := text[start:i]
+			if p.name == "" {
+				p.name = strings.ToUpper(part)
+			} else if paramName != "" {
+				p.params[paramName] = strings.Trim(part, `"`)
+				paramName = ""
+			}
+			start = i + 1
+			if c == ':' && p.name != "" {
+				p.value = text[start:]
+				return p, nil
+			}
+		}
+	}
+	return p, fmt.Errorf("%w: line %d: malformed content line", ErrInvalidICal, line)
+}
+
+// ParseICal reads the VTODO components of an iCalendar stream. Structural
+// errors, such as unbalanced BEGIN and END lines, fail the whole stream and
+// wrap ErrInvalidICal; problems with single components are reported in the
+// result.
+func ParseICal(r io.Reader) (*ICalResult, error) {
+	lines, numbers, err := readICalLines(r)
+	if err != nil {
+		return nil, err
+	}
+	result := &ICalResult{Todos: []ICalTodo{}, Skipped: []ICalProblem{}, Invalid: []ICalProblem{}}
+	var stack []*icalComponent
+	calendars := 0
+	for i, text := range lines {
+		line := numbers[i]
+		p, err := parseICalProperty(text, line)
+		if err != nil {
+			return nil, err
+		}
+		switch p.name {
+		case "BEGIN":
+			name := strings.ToUpper(p.value)
+			if len(stack) == 0 && name != "VCALENDAR" {
+				return nil, fmt.Errorf("%w: line %d: expected BEGIN:VCALENDAR", ErrInvalidICal, line)
+			}
+			stack = append(stack, &icalComponent{name: name, line: line})
+		case "END":
+			name := strings.ToUpper(p.value)
+			if len(stack) == 0 || stack[len(stack)-1].name != name {
+				return nil, fmt.Errorf("%w: line %d: unexpected END:%s", ErrInvalidICal, line, name)
+			}
+			c := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			switch {
+			case len(stack) == 0:
+				calendars++
+			case len(stack) > 1:
+				// Sub-components such as VALARM are not imported.
+			case c.name == "VTODO":
+				todo, problem := icalTodo(c)
+				switch {
+				case problem == nil:
+					result.Todos = append(result.Todos, *todo)
+				case todo != nil:
+					result.Skipped = append(result.Skipped, *problem)
+				default:
+					result.Invalid = append(result.Invalid, *problem)
+				}
+			case c.name != "VTIMEZONE":
+				result.Skipped = append(result.Skipped, ICalProblem{Line: c.line, Component: c.name, Reason: "not a task"})
+			}
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("%w: line %d: content outside of VCALENDAR", ErrInvalidICal, line)
+			}
+			stack[len(stack)-1].props = append(stack[len(stack)-1].props, p)
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%w: missing END:%s", ErrInvalidICal, stack[len(stack)-1].name)
+	}
+	if calendars == 0 {
+		return nil, fmt.Errorf("%w: no VCALENDAR", ErrInvalidICal)
+	}
+	return result, nil
+}
+
+func parseICalTime(p *icalProperty) (time.Time, error) {
+	if p.params["VALUE"] == "DATE" || len(p.value) == len(icalDateLayout) {
+		return time.ParseInLocation(icalDateLayout, p.value, time.UTC)
+	}
+	if strings.HasSuffix(p.value, "Z") {
+		return time.Parse(icalUTCLayout, p.value)
+	}
+	loc := time.UTC
+	if tzid := p.params["TZID"]; tzid != "" {
+		var err error
+		if loc, err = time.LoadLocation(tzid); err != nil {
+			return time.Time{}, fmt.Errorf("unknown time zone %q", tzid)
+		}
+	}
+	return time.ParseInLocation(icalLocalLayout, p.value, loc)
+}
+
+// icalTodo converts a VTODO. A non-nil problem with a nil todo means the
+// component is invalid; with a todo it means the component is skipped.
+func icalTodo(c *icalComponent) (*ICalTodo, *ICalProblem) {
+	todo := &ICalTodo{Line: c.line}
+	invalid := func(format string, args ...interface{}) (*ICalTodo, *ICalProblem) {
+		return nil, &ICalProblem{Line: c.line, Component: c.name, UID: todo.UID, Reason: fmt.Sprintf(format, args...)}
+	}
+	if uid := c.get("UID"); uid != nil {
+		todo.UID = icalTextUnescaper.Replace(uid.value)
+	}
+	if todo.UID == "" {
+		return invalid("missing UID")
+	}
+	t := &todo.Task
+	t.ICalUID = todo.UID
+
+	for _, p := range c.props {
+		p := p
+		switch p.name {
+		case "SUMMARY":
+			t.Title = icalTextUnescaper.Replace(p.value)
+		case "DESCRIPTION":
+			t.Text = icalTextUnescaper.Replace(p.value)
+		case "DUE", "COMPLETED", "CREATED", "LAST-MODIFIED":
+			v, err := parseICalTime(&p)
+			if err != nil {
+				return invalid("line %d: invalid %s: %v", p.line, p.name, err)
+			}
+			switch p.name {
+			case "DUE":
+				t.Due = &v
+			case "COMPLETED":
+				t.CompletedAt = &v
+			case "CREATED":
+				t.CreatedAt = v
+			default:
+				t.UpdatedAt = v
+			}
+		case "STATUS":
+			switch strings.ToUpper(p.value) {
+			case "NEEDS-ACTION":
+				t.Status = db.StatusTodo
+			case "IN-PROCESS":
+				t.Status = db.StatusInProgress
+			case "COMPLETED":
+				t.Status = db.StatusDone
+			case "CANCELLED":
+				return todo, &ICalProblem{Line: c.line, Component: c.name, UID: todo.UID, Reason: "cancelled"}
+			default:
+				return invalid("line %d: unknown STATUS %q", p.line, p.value)
+			}
+		case "PRIORITY":
+			n, err := strconv.Atoi(p.value)
+			switch {
+			case err != nil || n < 0 || n > 9:
+				return invalid("line %d: invalid PRIORITY %q", p.line, p.value)
+			case n == 0:
+			case n < 5:
+				t.Priority = db.PriorityHigh
+			case n == 5:
+				t.Priority = db.PriorityMedium
+			default:
+				t.Priority = db.PriorityLow
+			}
+		case "CATEGORIES":
+			for _, tag := range splitICalList(p.value) {
+				t.AddTags(strings.Join(strings.Fields(tag), "-"))
+			}
+		case "RRULE":
+			if !strings.Contains(strings.ToUpper(p.value), "FREQ=") {
+				return invalid("line %d: RRULE without FREQ", p.line)
+			}
+			t.Recurrence = p.value
+		}
+	}
+	if t.Title == "" {
+		return invalid("missing SUMMARY")
+	}
+	if t.CompletedAt != nil && t.Status == "" {
+		t.Status = db.StatusDone
+	}
+	if t.Status == "" {
+		t.Status = db.StatusTodo
+	}
+	if t.Status != db.StatusDone {
+		t.CompletedAt = nil
+	}
+	return todo, nil
+}
+
+// splitICalList splits a comma separated list of text values, honouring
+// escaped commas.
+func splitICalList(value string) []string {
+	var items []string
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value):
+			b.WriteString(value[i : i+2])
+			i++
+		case value[i] == ',':
+			items = append(items, icalTextUnescaper.Replace(b.String()))
+			b.Reset()
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return append(items, icalTextUnescaper.Replace(b.String()))
+}
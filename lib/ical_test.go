@@ -0,0 +1,130 @@
+package lib
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"tasks/db"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestICal(t *testing.T) {
+	now := time.Date(2023, 4, 5, 15, 0, 0, 0, time.UTC)
+	due := time.Date(2023, 4, 7, 9, 30, 0, 0, time.UTC)
+
+	t.Run("round trip", func(t *testing.T) {
+		task := db.Task{
+			ID:          uuid.New(),
+			Title:       "Call the plumber; ask about the boiler, again",
+			Text:        "Line one\nLine two with a long enough text to be folded over more than one line in the file, with ünïcödé",
+			Status:      db.StatusDone,
+			Priority:    db.PriorityHigh,
+			Tags:        []string{"home", "a,b"},
+			Due:         &due,
+			Recurrence:  "FREQ=WEEKLY;BYDAY=MO,WE",
+			CreatedAt:   now.Add(-time.Hour),
+			UpdatedAt:   now,
+			CompletedAt: &now,
+		}
+		var buf bytes.Buffer
+		require.NoError(t, WriteICal(&buf, []db.Task{task}, now))
+		for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+			require.LessOrEqual(t, len(line), 75, line)
+		}
+
+		result, err := ParseICal(&buf)
+		require.NoError(t, err)
+		require.Empty(t, result.Skipped)
+		require.Empty(t, result.Invalid)
+		require.Len(t, result.Todos, 1)
+		got := result.Todos[0]
+		require.Equal(t, task.ID.String(), got.UID)
+		require.Equal(t, task.Title, got.Task.Title)
+		require.Equal(t, task.Text, got.Task.Text)
+		require.Equal(t, task.Status, got.Task.Status)
+		require.Equal(t, task.Priority, got.Task.Priority)
+		require.Equal(t, task.Tags, got.Task.Tags)
+		require.Equal(t, task.Recurrence, got.Task.Recurrence)
+		require.True(t, due.Equal(*got.Task.Due))
+		require.True(t, now.Equal(*got.Task.CompletedAt))
+	})
+
+	t.Run("components", func(t *testing.T) {
+		data := strings.Join([]string{
+			"BEGIN:VCALENDAR",
+			"VERSION:2.0",
+			"BEGIN:VEVENT",
+			"UID:event",
+			"END:VEVENT",
+			"BEGIN:VTODO",
+			"UID:zoned",
+			"SUMMARY:Water the ",
+			" plants",
+			"DUE;TZID=Europe/Moscow:20230407T120000",
+			"STATUS:IN-PROCESS",
+			"PRIORITY:7",
+			"BEGIN:VALARM",
+			"TRIGGER:-PT15M",
+			"END:VALARM",
+			"END:VTODO",
+			"BEGIN:VTODO",
+			"UID:dated",
+			"SUMMARY:Pay the rent",
+			"DUE;VALUE=DATE:20230501",
+			"CATEGORIES:money,home office",
+			"END:VTODO",
+			"BEGIN:VTODO",
+			"UID:cancelled",
+			"SUMMARY:Never mind",
+			"STATUS:CANCELLED",
+			"END:VTODO",
+			"BEGIN:VTODO",
+			"SUMMARY:No UID",
+			"END:VTODO",
+			"BEGIN:VTODO",
+			"UID:bad-due",
+			"SUMMARY:Bad date",
+			"DUE:tomorrow",
+			"END:VTODO",
+			"END:VCALENDAR",
+		}, "\r\n")
+		result, err := ParseICal(strings.NewReader(data))
+		require.NoError(t, err)
+
+		require.Len(t, result.Todos, 2)
+		zoned := result.Todos[0].Task
+		require.Equal(t, "Water the plants", zoned.Title)
+		require.Equal(t, db.StatusInProgress, zoned.Status)
+		require.Equal(t, db.PriorityLow, zoned.Priority)
+		require.True(t, time.Date(2023, 4, 7, 9, 0, 0, 0, time.UTC).Equal(*zoned.Due))
+		dated := result.Todos[1].Task
+		require.Equal(t, db.StatusTodo, dated.Status)
+		require.Equal(t, []string{"money", "home-office"}, dated.Tags)
+		require.True(t, time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC).Equal(*dated.Due))
+
+		require.Equal(t, []ICalProblem{
+			{Line: 3, Component: "VEVENT", Reason: "not a task"},
+			{Line: 23, Component: "VTODO", UID: "cancelled", Reason: "cancelled"},
+		}, result.Skipped)
+		require.Len(t, result.Invalid, 2)
+		require.Equal(t, "missing UID", result.Invalid[0].Reason)
+		require.Equal(t, "bad-due", result.Invalid[1].UID)
+	})
+
+	t.Run("structure", func(t *testing.T) {
+		for _, data := range []string{
+			"",
+			"BEGIN:VTODO\r\nEND:VTODO",
+			"BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nEND:VCALENDAR",
+			"BEGIN:VCALENDAR\r\nnonsense\r\nEND:VCALENDAR",
+			"BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nUID:x\r\nEND:VTODO",
+		} {
+			_, err := ParseICal(strings.NewReader(data))
+			require.ErrorIs(t, err, ErrInvalidICal, data)
+		}
+	})
+}
@@ -0,0 +1,148 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"tasks/db"
+)
+
+var ErrInvalidImport = errors.New("invalid export file")
+
+// Sources in ImportParsers.
+const (
+	ImportTodoist = "todoist"
+	ImportTrello  = "trello"
+)
+
+// ImportParsers read the export files of other services by source name.
+var ImportParsers = map[string]func(r io.Reader, now time.Time) (*ImportedData, error){
+	ImportTodoist: ParseTodoistExport,
+	ImportTrello:  ParseTrelloExport,
+}
+
+// ImportedItem is a checklist item read from an export file.
+type ImportedItem struct {
+	SourceID string
+	Text     string
+	Done     bool
+}
+
+type ImportedComment struct {
+	Author string
+	Text   string
+	Time   time.Time
+}
+
+// ImportedTask is a task read from the export file of another service, in
+// the terms of db.Task. Parent is the source ID of the parent task.
+type ImportedTask struct {
+	SourceID    string
+	Parent      string
+	Title       string
+	Description string
+	Comments    []ImportedComment
+	Project     string
+	Tags        []string
+	Status      string
+	Priority    string
+	Due         *time.Time
+	Recurrence  string
+	CreatedAt   time.Time
+	CompletedAt *time.Time
+	Checklist   []ImportedItem
+}
+
+// ImportProblem describes an item of an export file that was not imported.
+type ImportProblem struct {
+	SourceID string `json:"sourceId"`
+	Title    string `json:"title,omitempty"`
+	Reason   string `json:"reason"`
+}
+
+// ImportedData is the content of an export file. Skipped lists the items
+// that are deleted or archived at the source.
+type ImportedData struct {
+	Projects []string
+	Tasks    []ImportedTask
+	Skipped  []ImportProblem
+}
+
+// Text renders the description and the comments of the task as Markdown,
+// the comments in the order they were posted.
+func (t *ImportedTask) Text() string {
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(t.Description))
+	for _, c := range t.Comments {
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString("---\n\n")
+		if c.Author != "" {
+			fmt.Fprintf(&b, "**%s**, ", c.Author)
+		}
+		fmt.Fprintf(&b, "%s:\n\n%s", c.Time.UTC().Format("2006-01-02 15:04"), strings.TrimSpace(c.Text))
+	}
+	return b.String()
+}
+
+// ImportTag turns a label into a tag, joining its words with dashes.
+func ImportTag(label string) string {
+	return strings.Join(strings.Fields(label), "-")
+}
+
+// importID reads the ID of an item, which some export formats write as a
+// number and others as a string.
+type importID string
+
+func (id *importID) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch value := v.(type) {
+	case nil:
+		*id = ""
+	case string:
+		*id = importID(value)
+	case float64:
+		*id = importID(strconv.FormatFloat(value, 'f', -1, 64))
+	default:
+		return fmt.Errorf("%w: id must be a string or a number", ErrInvalidImport)
+	}
+	return nil
+}
+
+// parseImportTime reads a timestamp, in UTC unless it carries an offset.
+// A zero time is returned for an empty string.
+func parseImportTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return t, fmt.Errorf("%w: %q is not a timestamp", ErrInvalidImport, s)
+	}
+	return t.UTC(), nil
+}
+
+// importStatus guesses the status of the tasks in a list or section from its
+// name.
+func importStatus(name string) string {
+	name = strings.ToLower(name)
+	for _, word := range []string{"done", "complete", "finished", "closed", "готово", "сделано"} {
+		if strings.Contains(name, word) {
+			return db.StatusDone
+		}
+	}
+	for _, word := range []string{"doing", "progress", "review", "в работе"} {
+		if strings.Contains(name, word) {
+			return db.StatusInProgress
+		}
+	}
+	return db.StatusTodo
+}
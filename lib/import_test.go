@@ -0,0 +1,126 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"tasks/db"
+
+	"github.com/stretchr/testify/require"
+)
+
+const todoistFixture = `{
+	"projects": [{"id": "220474322", "name": "Home"}, {"id": "220474323", "name": "Gone", "is_deleted": true}],
+	"sections": [{"id": "7025", "name": "In progress", "project_id": "220474322"}],
+	"labels": [{"id": "2156154810", "name": "errands"}],
+	"items": [
+		{"id": "2995104339", "content": "Buy milk", "description": "Semi-skimmed", "project_id": "220474322",
+		 "labels": ["errands", "quick win"], "priority": 4, "added_at": "2023-04-01T09:00:00.000000Z",
+		 "due": {"date": "2023-04-05T18:00:00", "timezone": "Europe/Berlin", "is_recurring": true, "string": "every week"}},
+		{"id": "2995104340", "content": "Lactose free", "project_id": "220474322", "parent_id": "2995104339",
+		 "section_id": "7025", "labels": [2156154810], "priority": 1, "added_at": "2023-04-01T09:05:00Z"},
+		{"id": "2995104341", "content": "Pay rent", "project_id": "220474322", "checked": true,
+		 "completed_at": "2023-04-02T10:00:00Z", "due": {"date": "2023-04-01"}},
+		{"id": "2995104342", "content": "Old", "is_deleted": true}
+	],
+	"notes": [
+		{"id": "1", "item_id": "2995104339", "content": "Second", "posted_at": "2023-04-03T10:00:00Z"},
+		{"id": "2", "item_id": "2995104339", "content": "First", "posted_at": "2023-04-02T10:00:00Z"}
+	]
+}`
+
+const trelloFixture = `{
+	"name": "Launch",
+	"lists": [{"id": "l2", "name": "Done", "pos": 2}, {"id": "l1", "name": "To do", "pos": 1}, {"id": "l3", "name": "Old", "closed": true, "pos": 3}],
+	"labels": [{"id": "b1", "name": "Urgent work", "color": "red"}, {"id": "b2", "name": "", "color": "green"}],
+	"cards": [
+		{"id": "642d9a80a1b2c3d4e5f60001", "name": "Ship it", "idList": "l2", "pos": 1, "idLabels": ["b1"]},
+		{"id": "642d9a80a1b2c3d4e5f60002", "name": "Write docs", "desc": "API first", "idList": "l1", "pos": 2,
+		 "idLabels": ["b2"], "due": "2023-04-10T12:00:00.000Z"},
+		{"id": "642d9a80a1b2c3d4e5f60003", "name": "Plan release", "idList": "l1", "pos": 1, "dueComplete": true},
+		{"id": "642d9a80a1b2c3d4e5f60004", "name": "Archived", "idList": "l1", "closed": true},
+		{"id": "642d9a80a1b2c3d4e5f60005", "name": "Forgotten", "idList": "l3"}
+	],
+	"checklists": [
+		{"idCard": "642d9a80a1b2c3d4e5f60002", "pos": 2, "checkItems": [{"id": "c3", "name": "Examples", "state": "incomplete"}]},
+		{"idCard": "642d9a80a1b2c3d4e5f60002", "pos": 1, "checkItems": [
+			{"id": "c2", "name": "Reference", "state": "complete", "pos": 2}, {"id": "c1", "name": "Outline", "state": "complete", "pos": 1}]}
+	],
+	"actions": [
+		{"type": "commentCard", "date": "2023-04-06T09:00:00.000Z", "data": {"text": "Looks good", "card": {"id": "642d9a80a1b2c3d4e5f60002"}}, "memberCreator": {"fullName": "Ann"}},
+		{"type": "updateCard", "date": "2023-04-05T09:00:00.000Z", "data": {"card": {"id": "642d9a80a1b2c3d4e5f60002"}}}
+	]
+}`
+
+func TestImportParsers(t *testing.T) {
+	now := time.Date(2023, 4, 5, 15, 0, 0, 0, time.UTC)
+	at := func(d, h int) *time.Time {
+		t := time.Date(2023, 4, d, h, 0, 0, 0, time.UTC)
+		return &t
+	}
+
+	t.Run("todoist", func(t *testing.T) {
+		data, err := ParseTodoistExport(strings.NewReader(todoistFixture), now)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Home"}, data.Projects)
+		require.Equal(t, []ImportProblem{{SourceID: "2995104342", Title: "Old", Reason: "deleted"}}, data.Skipped)
+		require.Len(t, data.Tasks, 3)
+
+		milk := data.Tasks[0]
+		require.Equal(t, "Home", milk.Project)
+		require.Equal(t, []string{"errands", "quick-win"}, milk.Tags)
+		require.Equal(t, db.PriorityHigh, milk.Priority)
+		require.Equal(t, at(5, 16), milk.Due)
+		require.Equal(t, "FREQ=WEEKLY", milk.Recurrence)
+		require.Equal(t, *at(1, 9), milk.CreatedAt)
+		require.Equal(t, "Semi-skimmed\n\n---\n\n2023-04-02 10:00:\n\nFirst\n\n---\n\n2023-04-03 10:00:\n\nSecond", milk.Text())
+
+		sub := data.Tasks[1]
+		require.Equal(t, "2995104339", sub.Parent)
+		require.Equal(t, []string{"In-progress", "errands"}, sub.Tags)
+		require.Equal(t, db.StatusInProgress, sub.Status)
+		require.Empty(t, sub.Priority)
+
+		rent := data.Tasks[2]
+		require.Equal(t, db.StatusDone, rent.Status)
+		require.Equal(t, at(2, 10), rent.CompletedAt)
+		require.Equal(t, at(1, 0), rent.Due)
+	})
+
+	t.Run("trello", func(t *testing.T) {
+		data, err := ParseTrelloExport(strings.NewReader(trelloFixture), now)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Launch"}, data.Projects)
+		require.Equal(t, []ImportProblem{
+			{SourceID: "642d9a80a1b2c3d4e5f60004", Title: "Archived", Reason: "archived"},
+			{SourceID: "642d9a80a1b2c3d4e5f60005", Title: "Forgotten", Reason: "in an archived list"},
+		}, data.Skipped)
+
+		titles := []string{}
+		for _, task := range data.Tasks {
+			titles = append(titles, task.Title)
+		}
+		require.Equal(t, []string{"Plan release", "Write docs", "Ship it"}, titles)
+
+		plan, docs, ship := data.Tasks[0], data.Tasks[1], data.Tasks[2]
+		require.Equal(t, db.StatusDone, plan.Status)
+		require.Equal(t, db.StatusTodo, docs.Status)
+		require.Equal(t, db.StatusDone, ship.Status)
+		require.Equal(t, []string{"Done", "Urgent-work"}, ship.Tags)
+		require.Equal(t, []string{"To-do", "green"}, docs.Tags)
+		require.Equal(t, at(10, 12), docs.Due)
+		require.Equal(t, time.Date(2023, 4, 5, 15, 57, 52, 0, time.UTC), docs.CreatedAt)
+		require.Equal(t, []ImportedItem{{"c1", "Outline", true}, {"c2", "Reference", true}, {"c3", "Examples", false}}, docs.Checklist)
+		require.Equal(t, "API first\n\n---\n\n**Ann**, 2023-04-06 09:00:\n\nLooks good", docs.Text())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for source, parse := range ImportParsers {
+			for _, data := range []string{"", "[]", `{"name": "x"}`, `{"items": [{"id": {}}], "cards": [], "lists": []`} {
+				_, err := parse(strings.NewReader(data), now)
+				require.ErrorIs(t, err, ErrInvalidImport, source+": "+data)
+			}
+		}
+	})
+}
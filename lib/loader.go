@@ -0,0 +1,71 @@
+package lib
+
+// Loader batches loads by key: the keys of the loads whose values are read
+// after the last batch are fetched together, once. Values stay cached, so a
+// Loader serves a single request and is not safe for concurrent use.
+type Loader struct {
+	fetch   func(keys []interface{}) (map[interface{}]interface{}, error)
+	pending []interface{}
+	values  map[interface{}]interface{}
+	errs    map[interface{}]error
+}
+
+// NewLoader returns a Loader reading values with fetch. fetch returns the
+// value of each key it found; the keys it leaves out load as nil.
+func NewLoader(fetch func(keys []interface{}) (map[interface{}]interface{}, error)) *Loader {
+	return &Loader{fetch: fetch, values: map[interface{}]interface{}{}, errs: map[interface{}]error{}}
+}
+
+// Load queues the key and returns a function reading its value, which
+// fetches the queued keys if the value is not known yet.
+func (l *Loader) Load(key interface{}) func() (interface{}, error) {
+	if _, ok := l.values[key]; !ok {
+		if _, ok := l.errs[key]; !ok {
+			l.pending = append(l.pending, key)
+		}
+	}
+	return func() (interface{}, error) {
+		if err, ok := l.errs[key]; ok {
+			return nil, err
+		}
+		if v, ok := l.values[key]; ok {
+			return v, nil
+		}
+		l.dispatch()
+		if err, ok := l.errs[key]; ok {
+			return nil, err
+		}
+		return l.values[key], nil
+	}
+}
+
+func (l *Loader) dispatch() {
+	var keys []interface{}
+	queued := map[interface{}]bool{}
+	for _, key := range l.pending {
+		_, known := l.values[key]
+		if !known && !queued[key] {
+			queued[key] = true
+			keys = append(keys, key)
+		}
+	}
+	l.pending = nil
+	if len(keys) == 0 {
+		return
+	}
+	found, err := l.fetch(keys)
+	for _, key := range keys {
+		if err != nil {
+			l.errs[key] = err
+			continue
+		}
+		l.values[key] = found[key]
+	}
+}
+
+// Clear forgets the cached values, for instance once they changed.
+func (l *Loader) Clear() {
+	l.values = map[interface{}]interface{}{}
+	l.errs = map[interface{}]error{}
+	l.pending = nil
+}
@@ -0,0 +1,63 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var (
+	markdown = goldmark.New(
+		goldmark.WithExtensions(
+			extension.Table,
+			extension.TaskList,
+			extension.Strikethrough,
+			extension.Linkify,
+		),
+	)
+	htmlPolicy = newHTMLPolicy()
+)
+
+// newHTMLPolicy builds the allowlist applied to rendered Markdown. Anything
+// not explicitly allowed here, including raw HTML passed through the
+// Markdown source, is stripped.
+func newHTMLPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements(
+		"p", "br", "hr", "blockquote", "pre", "code",
+		"h1", "h2", "h3", "h4", "h5", "h6",
+		"ul", "ol", "li", "em", "strong", "del",
+		"table", "thead", "tbody", "tr", "th", "td",
+	)
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+	p.AllowAttrs("style").Matching(regexp.MustCompile(`^text-align:(left|center|right);?$`)).OnElements("th", "td")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+
+	// Task list items are rendered as disabled checkboxes.
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^(|checked|disabled)$`)).OnElements("input")
+
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowAttrs("title").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	p.RequireNoReferrerOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+// RenderMarkdown converts CommonMark text, with table and task list
+// extensions, into sanitized HTML that is safe to embed in a page.
+func RenderMarkdown(src string) (string, error) {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(src), &buf); err != nil {
+		return "", fmt.Errorf("could not render markdown! %v", err)
+	}
+	return htmlPolicy.Sanitize(buf.String()), nil
+}
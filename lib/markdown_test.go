@@ -0,0 +1,94 @@
+package lib
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/html"
+)
+
+// requireSafeHTML walks the parsed document and fails on any element or
+// attribute that can execute script or load remote content.
+func requireSafeHTML(t *testing.T, doc string) {
+	root, err := html.Parse(strings.NewReader(doc))
+	require.NoError(t, err)
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "script", "iframe", "svg", "img", "object", "embed", "style":
+				t.Fatalf("unsafe element <%s> in %q", n.Data, doc)
+			}
+			for _, a := range n.Attr {
+				key := strings.ToLower(a.Key)
+				val := strings.ToLower(strings.TrimSpace(a.Val))
+				require.False(t, strings.HasPrefix(key, "on"), "event handler %q in %q", key, doc)
+				require.NotEqual(t, "style", key, "style attribute in %q", doc)
+				if key == "href" || key == "src" {
+					require.False(t, strings.HasPrefix(val, "javascript:"), "javascript url in %q", doc)
+					require.False(t, strings.HasPrefix(val, "data:"), "data url in %q", doc)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(root)
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	t.Run("renders commonmark", func(t *testing.T) {
+		out, err := RenderMarkdown("# Title\n\nSome *emphasis* and `code`.")
+		require.NoError(t, err)
+		require.Contains(t, out, "<h1>Title</h1>")
+		require.Contains(t, out, "<em>emphasis</em>")
+		require.Contains(t, out, "<code>code</code>")
+	})
+	t.Run("renders task lists", func(t *testing.T) {
+		out, err := RenderMarkdown("- [x] done\n- [ ] todo")
+		require.NoError(t, err)
+		require.Contains(t, out, `<input checked="" disabled="" type="checkbox"`)
+		require.Contains(t, out, `<input disabled="" type="checkbox"`)
+	})
+	t.Run("renders tables", func(t *testing.T) {
+		out, err := RenderMarkdown("| a | b |\n|:--|--:|\n| 1 | 2 |")
+		require.NoError(t, err)
+		require.Contains(t, out, "<table>")
+		require.Contains(t, out, "<td")
+	})
+	t.Run("keeps safe links", func(t *testing.T) {
+		out, err := RenderMarkdown("[site](https://example.com)")
+		require.NoError(t, err)
+		require.Contains(t, out, `href="https://example.com"`)
+		require.Contains(t, out, `rel="nofollow noreferrer noopener"`)
+	})
+
+	xss := []struct {
+		name string
+		src  string
+	}{
+		{"script tag", `<script>alert(1)</script>`},
+		{"inline script in html block", "<div>\n<script>alert(1)</script>\n</div>"},
+		{"img onerror", `<img src=x onerror=alert(1)>`},
+		{"javascript link", `[click](javascript:alert(1))`},
+		{"encoded javascript link", `[click](jav&#x09;ascript:alert(1))`},
+		{"javascript autolink", `<javascript:alert(1)>`},
+		{"data uri link", `[click](data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg==)`},
+		{"svg onload", `<svg onload=alert(1)>`},
+		{"iframe", `<iframe src="https://evil.example"></iframe>`},
+		{"style attribute", `<p style="background:url(javascript:alert(1))">x</p>`},
+		{"event handler on anchor", `<a href="https://example.com" onclick="alert(1)">x</a>`},
+		{"link title breakout", `[x](https://example.com "a\" onmouseover=\"alert(1)")`},
+		{"image with javascript", `![x](javascript:alert(1))`},
+	}
+	for _, tc := range xss {
+		t.Run("strips "+tc.name, func(t *testing.T) {
+			out, err := RenderMarkdown(tc.src)
+			require.NoError(t, err)
+			requireSafeHTML(t, out)
+		})
+	}
+}
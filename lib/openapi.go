@@ -0,0 +1,305 @@
+package lib
+
+import (
+	"encoding"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// OpenAPI is an OpenAPI 3 document. Paths map a path template to its
+// operations, keyed by lower-case method. Methods OpenAPI does not know,
+// such as PROPFIND, are keyed as x-propfind extensions.
+type OpenAPI struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type OpenAPIComponents struct {
+	Schemas         Schemas                          `json:"schemas"`
+	SecuritySchemes map[string]OpenAPISecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type OpenAPISecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	In          string `json:"in,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []OpenAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+	Security    []map[string][]string       `json:"security,omitempty"`
+}
+
+type OpenAPIParameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is a JSON schema as used by OpenAPI 3.0.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+// Schemas holds the named schemas of a document.
+type Schemas map[string]*Schema
+
+// SchemaGenerator derives schemas from Go types, collecting the schemas of
+// named structs so they are referenced rather than repeated.
+type SchemaGenerator struct {
+	schemas Schemas
+	names   map[reflect.Type]string
+}
+
+func NewSchemaGenerator() *SchemaGenerator {
+	return &SchemaGenerator{schemas: Schemas{}, names: map[reflect.Type]string{}}
+}
+
+// Schemas returns the named schemas referenced so far.
+func (g *SchemaGenerator) Schemas() Schemas {
+	return g.schemas
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	uuidType          = reflect.TypeOf(uuid.UUID{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// SchemaOf returns the schema of the JSON encoding of the type of v. Named
+// structs are added to the schemas and referenced. Properties follow the
+// json tags of the fields and their validate tags become constraints:
+// required, min, max, len, oneof, email, url, uuid and alphanum are
+// understood, and the tags after dive apply to the items of slices and maps.
+func (g *SchemaGenerator) SchemaOf(v interface{}) *Schema {
+	return g.schema(reflect.TypeOf(v))
+}
+
+func (g *SchemaGenerator) schema(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == uuidType:
+		return &Schema{Type: "string", Format: "uuid"}
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		name, ok := g.names[t]
+		if !ok {
+			name = g.name(t)
+			// Register the name first so recursive types end.
+			g.names[t] = name
+			g.schemas[name] = &Schema{}
+			*g.schemas[name] = *g.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+// name returns an unused name for the schema of a struct type: its Go name,
+// capitalised, prefixed with its package when another package took it.
+func (g *SchemaGenerator) name(t reflect.Type) string {
+	name := capitalize(t.Name())
+	if _, taken := g.schemas[name]; !taken {
+		return name
+	}
+	return capitalize(t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]) + name
+}
+
+func capitalize(s string) string {
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+func (g *SchemaGenerator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	g.addFields(s, t)
+	sort.Strings(s.Required)
+	return s
+}
+
+// addFields adds the fields of the struct to the object schema, flattening
+// embedded structs as encoding/json does.
+func (g *SchemaGenerator) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || !f.IsExported() && !f.Anonymous {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			g.addFields(s, ft)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := g.schema(f.Type)
+		if f.Type.Kind() == reflect.Ptr && prop.Ref == "" && !strings.Contains(opts, "omitempty") {
+			prop.Nullable = true
+		}
+		if applyValidateTag(prop, f.Tag.Get("validate")) {
+			s.Required = append(s.Required, name)
+		}
+		s.Properties[name] = prop
+	}
+}
+
+// applyValidateTag sets the constraints of a validate tag on the schema and
+// reports whether the tag makes the property required.
+func applyValidateTag(s *Schema, tag string) bool {
+	if tag == "" || tag == "-" {
+		return false
+	}
+	required := false
+	rules := strings.Split(tag, ",")
+	for i, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			target := s.Items
+			if target == nil {
+				target = s.AdditionalProperties
+			}
+			if target != nil && target.Ref == "" {
+				applyValidateTag(target, strings.Join(rules[i+1:], ","))
+			}
+			return required
+		case "required":
+			required = true
+			if s.Type == "string" && s.MinLength == nil {
+				s.MinLength = intPtr(1)
+			}
+		case "min", "max", "len", "gte", "lte":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			lower, upper := name != "max" && name != "lte", name != "min" && name != "gte"
+			switch s.Type {
+			case "string":
+				if lower {
+					s.MinLength = intPtr(n)
+				}
+				if upper {
+					s.MaxLength = intPtr(n)
+				}
+			case "array":
+				if lower {
+					s.MinItems = intPtr(n)
+				}
+				if upper {
+					s.MaxItems = intPtr(n)
+				}
+			case "integer", "number":
+				f := float64(n)
+				if lower {
+					s.Minimum = &f
+				}
+				if upper {
+					s.Maximum = &f
+				}
+			}
+		case "oneof":
+			s.Enum = strings.Fields(param)
+		case "email", "url", "uuid":
+			s.Format = map[string]string{"email": "email", "url": "uri", "uuid": "uuid"}[name]
+		case "alphanum":
+			s.Pattern = "^[a-zA-Z0-9]*$"
+		}
+	}
+	return required
+}
+
+func intPtr(n int) *int {
+	return &n
+}
@@ -1,6 +1,10 @@
 package lib
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 
@@ -31,3 +35,19 @@ func Validate(hashedPassword string, password string) error {
 	}
 	return nil
 }
+
+// NewSecretToken returns a random URL-safe token of 32 bytes of entropy.
+func NewSecretToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate a token! %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hash under which a secret token is stored. Tokens
+// carry enough entropy for a fast hash, unlike passwords.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
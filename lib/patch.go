@@ -0,0 +1,354 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	MergePatchContentType = "application/merge-patch+json"
+	JSONPatchContentType  = "application/json-patch+json"
+)
+
+var (
+	ErrInvalidPatch    = errors.New("invalid patch")
+	ErrPatchTestFailed = errors.New("patch test failed")
+)
+
+// PatchOperation is an operation of a JSON Patch (RFC 6902). Value is used
+// by add, replace and test, From by move and copy.
+type PatchOperation struct {
+	Op    string      `json:"op" validate:"required,oneof=add remove replace move copy test"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to the JSON document
+// doc: members of objects in the patch replace those of doc, recursively,
+// and null members remove them.
+func MergePatch(doc []byte, patch []byte) ([]byte, error) {
+	var target, p interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	return json.Marshal(mergePatch(target, p))
+}
+
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for name, v := range p {
+		if v == nil {
+			delete(t, name)
+		} else {
+			t[name] = mergePatch(t[name], v)
+		}
+	}
+	return t
+}
+
+// JSONPatch applies a JSON Patch (RFC 6902) to the JSON document doc. The
+// operations are applied in order and all of them must succeed: a failed
+// test operation is reported as ErrPatchTestFailed, any other failure as
+// ErrInvalidPatch.
+func JSONPatch(doc []byte, patch []byte) ([]byte, error) {
+	ops, err := parseJSONPatch(patch)
+	if err != nil {
+		return nil, err
+	}
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		target, err = op.apply(target)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+	return json.Marshal(target)
+}
+
+// patchOperation is a parsed PatchOperation.
+type patchOperation struct {
+	op       string
+	path     []string
+	from     []string
+	value    interface{}
+	hasValue bool
+}
+
+func parseJSONPatch(patch []byte) ([]patchOperation, error) {
+	var raw []map[string]interface{}
+	if err := json.Unmarshal(patch, &raw); err != nil {
+		return nil, fmt.Errorf("%w: a JSON Patch is an array of operations: %v", ErrInvalidPatch, err)
+	}
+	ops := make([]patchOperation, 0, len(raw))
+	for i, members := range raw {
+		op, err := parsePatchOperation(members)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+func parsePatchOperation(members map[string]interface{}) (patchOperation, error) {
+	var op patchOperation
+	name, _ := members["op"].(string)
+	path, ok := members["path"].(string)
+	if !ok {
+		return op, fmt.Errorf("%w: path is required", ErrInvalidPatch)
+	}
+	var err error
+	if op.path, err = parsePointer(path); err != nil {
+		return op, err
+	}
+	op.op = name
+	op.value, op.hasValue = members["value"]
+
+	switch name {
+	case "add", "replace", "test":
+		if !op.hasValue {
+			return op, fmt.Errorf("%w: %s needs a value", ErrInvalidPatch, name)
+		}
+	case "move", "copy":
+		from, ok := members["from"].(string)
+		if !ok {
+			return op, fmt.Errorf("%w: %s needs from", ErrInvalidPatch, name)
+		}
+		if op.from, err = parsePointer(from); err != nil {
+			return op, err
+		}
+		if name == "move" && len(op.from) < len(op.path) && reflect.DeepEqual(op.from, op.path[:len(op.from)]) {
+			return op, fmt.Errorf("%w: %s cannot be moved into itself", ErrInvalidPatch, from)
+		}
+	case "remove":
+	default:
+		return op, fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, name)
+	}
+	return op, nil
+}
+
+func (op patchOperation) apply(doc interface{}) (interface{}, error) {
+	switch op.op {
+	case "add":
+		return patchAdd(doc, op.path, op.value)
+	case "remove":
+		doc, _, err := patchRemove(doc, op.path)
+		return doc, err
+	case "replace":
+		if len(op.path) == 0 {
+			return op.value, nil
+		}
+		if _, err := pointerGet(doc, op.path); err != nil {
+			return nil, err
+		}
+		doc, _, err := patchRemove(doc, op.path)
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, op.path, op.value)
+	case "move":
+		doc, value, err := patchRemove(doc, op.from)
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, op.path, value)
+	case "copy":
+		value, err := pointerGet(doc, op.from)
+		if err != nil {
+			return nil, err
+		}
+		return patchAdd(doc, op.path, copyJSON(value))
+	case "test":
+		value, err := pointerGet(doc, op.path)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPatchTestFailed, err)
+		}
+		if !reflect.DeepEqual(value, op.value) {
+			return nil, fmt.Errorf("%w: %s is not the expected value", ErrPatchTestFailed, formatPointer(op.path))
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("%w: unknown operation %q", ErrInvalidPatch, op.op)
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into its reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("%w: pointer %q does not start with /", ErrInvalidPatch, pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+func formatPointer(tokens []string) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		b.WriteString("/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(token))
+	}
+	return b.String()
+}
+
+// arrayIndex parses the token as an index of an array of length n. With
+// insert, n itself and "-" for the end are allowed too.
+func arrayIndex(token string, n int, insert bool) (int, error) {
+	if insert && token == "-" {
+		return n, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || token != strconv.Itoa(i) {
+		return 0, fmt.Errorf("%w: %q is not an array index", ErrInvalidPatch, token)
+	}
+	if i > n || i == n && !insert {
+		return 0, fmt.Errorf("%w: index %d is out of range", ErrInvalidPatch, i)
+	}
+	return i, nil
+}
+
+func pointerGet(doc interface{}, tokens []string) (interface{}, error) {
+	for i, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			v, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s does not exist", ErrInvalidPatch, formatPointer(tokens[:i+1]))
+			}
+			doc = v
+		case []interface{}:
+			j, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[j]
+		default:
+			return nil, fmt.Errorf("%w: %s does not exist", ErrInvalidPatch, formatPointer(tokens[:i+1]))
+		}
+	}
+	return doc, nil
+}
+
+// updateParent calls fn with the container holding the last token of the
+// pointer and stores the container fn returns in its place, since arrays
+// change when they grow or shrink.
+func updateParent(doc interface{}, tokens []string, fn func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return fn(doc, tokens[0])
+	}
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[tokens[0]]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s does not exist", ErrInvalidPatch, tokens[0])
+		}
+		child, err := updateParent(child, tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		node[tokens[0]] = child
+		return node, nil
+	case []interface{}:
+		i, err := arrayIndex(tokens[0], len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		child, err := updateParent(node[i], tokens[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = child
+		return node, nil
+	}
+	return nil, fmt.Errorf("%w: %s is neither an object nor an array", ErrInvalidPatch, tokens[0])
+}
+
+func patchAdd(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return updateParent(doc, tokens, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		}
+		return nil, fmt.Errorf("%w: the parent of %s is neither an object nor an array", ErrInvalidPatch, formatPointer(tokens))
+	})
+}
+
+func patchRemove(doc interface{}, tokens []string) (interface{}, interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, nil, fmt.Errorf("%w: the whole document cannot be removed", ErrInvalidPatch)
+	}
+	var removed interface{}
+	doc, err := updateParent(doc, tokens, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			v, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s does not exist", ErrInvalidPatch, formatPointer(tokens))
+			}
+			removed = v
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = node[i]
+			return append(node[:i], node[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("%w: %s does not exist", ErrInvalidPatch, formatPointer(tokens))
+	})
+	return doc, removed, err
+}
+
+// copyJSON returns a deep copy of a decoded JSON value.
+func copyJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for name, member := range v {
+			c[name] = copyJSON(member)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, item := range v {
+			c[i] = copyJSON(item)
+		}
+		return c
+	}
+	return v
+}
@@ -0,0 +1,78 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatch(t *testing.T) {
+	t.Run("merge patch", func(t *testing.T) {
+		// The example of RFC 7396, section 3.
+		doc := `{"title": "Goodbye!", "author": {"givenName": "John", "familyName": "Doe"}, "tags": ["example", "sample"], "content": "This will be unchanged"}`
+		patch := `{"title": "Hello!", "phoneNumber": "+01-123-456-7890", "author": {"familyName": null}, "tags": ["example"]}`
+		got, err := MergePatch([]byte(doc), []byte(patch))
+		require.NoError(t, err)
+		require.JSONEq(t, `{"title": "Hello!", "author": {"givenName": "John"}, "tags": ["example"], "content": "This will be unchanged", "phoneNumber": "+01-123-456-7890"}`, string(got))
+
+		got, err = MergePatch([]byte(`{"a": "b"}`), []byte(`["c"]`))
+		require.NoError(t, err)
+		require.JSONEq(t, `["c"]`, string(got))
+
+		_, err = MergePatch([]byte(`{}`), []byte(`{"a": `))
+		require.ErrorIs(t, err, ErrInvalidPatch)
+	})
+
+	t.Run("json patch", func(t *testing.T) {
+		// Examples of RFC 6902, appendix A.
+		tests := []struct {
+			name  string
+			doc   string
+			patch string
+			want  string
+		}{
+			{"add member", `{"foo": "bar"}`, `[{"op": "add", "path": "/baz", "value": "qux"}]`, `{"baz": "qux", "foo": "bar"}`},
+			{"add item", `{"foo": ["bar", "baz"]}`, `[{"op": "add", "path": "/foo/1", "value": "qux"}]`, `{"foo": ["bar", "qux", "baz"]}`},
+			{"append item", `{"foo": ["bar"]}`, `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`, `{"foo": ["bar", ["abc", "def"]]}`},
+			{"add null", `{}`, `[{"op": "add", "path": "/foo", "value": null}]`, `{"foo": null}`},
+			{"remove member", `{"baz": "qux", "foo": "bar"}`, `[{"op": "remove", "path": "/baz"}]`, `{"foo": "bar"}`},
+			{"remove item", `{"foo": ["bar", "qux", "baz"]}`, `[{"op": "remove", "path": "/foo/1"}]`, `{"foo": ["bar", "baz"]}`},
+			{"replace", `{"baz": "qux", "foo": "bar"}`, `[{"op": "replace", "path": "/baz", "value": "boo"}]`, `{"baz": "boo", "foo": "bar"}`},
+			{"move member", `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`, `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+				`{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`},
+			{"move item", `{"foo": ["all", "grass", "cows", "eat"]}`, `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`, `{"foo": ["all", "cows", "eat", "grass"]}`},
+			{"copy", `{"foo": {"bar": 1}}`, `[{"op": "copy", "from": "/foo", "path": "/baz"}, {"op": "replace", "path": "/baz/bar", "value": 2}]`, `{"foo": {"bar": 1}, "baz": {"bar": 2}}`},
+			{"test", `{"baz": "qux", "foo": ["a", 2, "c"]}`, `[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2}]`, `{"baz": "qux", "foo": ["a", 2, "c"]}`},
+			{"escaped pointer", `{"/": 9, "~1": 10}`, `[{"op": "test", "path": "/~01", "value": 10}, {"op": "remove", "path": "/~1"}]`, `{"~1": 10}`},
+			{"replace document", `{"foo": 1}`, `[{"op": "replace", "path": "", "value": [1]}]`, `[1]`},
+		}
+		for _, tt := range tests {
+			got, err := JSONPatch([]byte(tt.doc), []byte(tt.patch))
+			require.NoError(t, err, tt.name)
+			require.JSONEq(t, tt.want, string(got), tt.name)
+		}
+	})
+
+	t.Run("json patch errors", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			patch string
+			want  error
+		}{
+			{"failed test", `[{"op": "test", "path": "/baz", "value": "bar"}]`, ErrPatchTestFailed},
+			{"test of a missing member", `[{"op": "test", "path": "/qux", "value": null}]`, ErrPatchTestFailed},
+			{"add to a missing parent", `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`, ErrInvalidPatch},
+			{"remove a missing member", `[{"op": "remove", "path": "/qux"}]`, ErrInvalidPatch},
+			{"index out of range", `[{"op": "add", "path": "/foo/3", "value": 1}]`, ErrInvalidPatch},
+			{"index with leading zero", `[{"op": "replace", "path": "/foo/01", "value": 1}]`, ErrInvalidPatch},
+			{"unknown operation", `[{"op": "merge", "path": "/foo"}]`, ErrInvalidPatch},
+			{"missing value", `[{"op": "add", "path": "/qux"}]`, ErrInvalidPatch},
+			{"move into itself", `[{"op": "move", "from": "/foo", "path": "/foo/0"}]`, ErrInvalidPatch},
+			{"not an array", `{"op": "remove", "path": "/baz"}`, ErrInvalidPatch},
+		}
+		for _, tt := range tests {
+			_, err := JSONPatch([]byte(`{"baz": "qux", "foo": ["a", "b"]}`), []byte(tt.patch))
+			require.ErrorIs(t, err, tt.want, tt.name)
+		}
+	})
+}
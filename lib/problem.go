@@ -0,0 +1,53 @@
+package lib
+
+import "net/http"
+
+// ProblemContentType is the media type of problem details (RFC 9457).
+const ProblemContentType = "application/problem+json"
+
+// Problem is the body of an error response as described by RFC 9457.
+// Extensions holds members specific to the type of the problem and is
+// marshalled next to the standard members.
+type Problem struct {
+	Type       string                 `json:"type"`
+	Title      string                 `json:"title"`
+	Status     int                    `json:"status"`
+	Detail     string                 `json:"detail,omitempty"`
+	Instance   string                 `json:"instance,omitempty"`
+	RequestID  string                 `json:"requestId,omitempty"`
+	Errors     []FieldError           `json:"errors,omitempty"`
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// FieldError tells which validation rule a field of the request breaks.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type problem Problem
+	if len(p.Extensions) == 0 {
+		return json.Marshal(problem(p))
+	}
+	standard, err := json.Marshal(problem(p))
+	if err != nil {
+		return nil, err
+	}
+	members := map[string]interface{}{}
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+	if err := json.Unmarshal(standard, &members); err != nil {
+		return nil, err
+	}
+	return json.Marshal(members)
+}
+
+// WriteProblem writes p as the response with its status.
+func WriteProblem(w http.ResponseWriter, p Problem) {
+	w.Header().Set("Content-Type", ProblemContentType)
+	JSON(w, p, p.Status)
+}
@@ -0,0 +1,660 @@
+package lib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	QuickAddTag        = "tag"
+	QuickAddPriority   = "priority"
+	QuickAddDue        = "due"
+	QuickAddRecurrence = "recurrence"
+)
+
+// QuickAddMatch is a recognised span of the input. Start and End are
+// character (rune) offsets into the original text, End exclusive.
+type QuickAddMatch struct {
+	Kind  string `json:"kind"`
+	Text  string `json:"text"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// QuickAdd is the result of parsing a one-line task description.
+type QuickAdd struct {
+	Title      string          `json:"title"`
+	Tags       []string        `json:"tags,omitempty"`
+	Priority   string          `json:"priority,omitempty"`
+	Due        *time.Time      `json:"due,omitempty"`
+	Recurrence string          `json:"recurrence,omitempty"`
+	Matches    []QuickAddMatch `json:"matches"`
+}
+
+type quickToken struct {
+	text  string
+	word  string // lower-cased, without surrounding punctuation
+	start int
+	end   int
+}
+
+// quickParser holds the state of a single ParseQuickAdd call.
+type quickParser struct {
+	text   []rune
+	tokens []quickToken
+	now    time.Time
+	result QuickAdd
+
+	date    *time.Time
+	hour    int
+	minute  int
+	hasTime bool
+	byDay   []time.Weekday
+	byMonth int
+}
+
+var quickPriorities = map[string]string{
+	"high": "high", "h": "high", "1": "high", "высокий": "high", "срочно": "high",
+	"medium": "medium", "med": "medium", "m": "medium", "2": "medium", "средний": "medium",
+	"low": "low", "l": "low", "3": "low", "низкий": "low",
+}
+
+var quickWeekdays = map[string]time.Weekday{
+	"monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+	"sunday":      time.Sunday,
+	"понедельник": time.Monday, "вторник": time.Tuesday, "среда": time.Wednesday,
+	"среду": time.Wednesday, "четверг": time.Thursday, "пятница": time.Friday,
+	"пятницу": time.Friday, "суббота": time.Saturday, "субботу": time.Saturday,
+	"воскресенье": time.Sunday,
+}
+
+// quickShortWeekdays are only recognised after a keyword such as "on" or
+// "every", since words like "sun" are common in titles.
+var quickShortWeekdays = map[string]time.Weekday{
+	"mon": time.Monday, "tue": time.Tuesday, "tues": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "thurs": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+	"sun": time.Sunday,
+	"пн":  time.Monday, "вт": time.Tuesday, "ср": time.Wednesday, "чт": time.Thursday,
+	"пт": time.Friday, "сб": time.Saturday, "вс": time.Sunday,
+}
+
+func quickWeekday(w string, allowShort bool) (time.Weekday, bool) {
+	if wd, ok := quickWeekdays[w]; ok {
+		return wd, true
+	}
+	wd, ok := quickShortWeekdays[w]
+	return wd, ok && allowShort
+}
+
+var quickMonths = map[string]time.Month{
+	"january": time.January, "jan": time.January, "января": time.January, "январь": time.January,
+	"february": time.February, "feb": time.February, "февраля": time.February, "февраль": time.February,
+	"march": time.March, "mar": time.March, "марта": time.March, "март": time.March,
+	"april": time.April, "apr": time.April, "апреля": time.April, "апрель": time.April,
+	"may": time.May, "мая": time.May, "май": time.May,
+	"june": time.June, "jun": time.June, "июня": time.June, "июнь": time.June,
+	"july": time.July, "jul": time.July, "июля": time.July, "июль": time.July,
+	"august": time.August, "aug": time.August, "августа": time.August, "август": time.August,
+	"september": time.September, "sep": time.September, "sept": time.September, "сентября": time.September, "сентябрь": time.September,
+	"october": time.October, "oct": time.October, "октября": time.October, "октябрь": time.October,
+	"november": time.November, "nov": time.November, "ноября": time.November, "ноябрь": time.November,
+	"december": time.December, "dec": time.December, "декабря": time.December, "декабрь": time.December,
+}
+
+// quickUnits maps duration words to a unit: "d", "w", "m" (month), "y", "h"
+// or "min".
+var quickUnits = map[string]string{
+	"day": "d", "days": "d", "день": "d", "дня": "d", "дней": "d",
+	"week": "w", "weeks": "w", "неделю": "w", "недели": "w", "недель": "w", "неделя": "w", "неделе": "w",
+	"month": "m", "months": "m", "месяц": "m", "месяца": "m", "месяцев": "m",
+	"year": "y", "years": "y", "год": "y", "года": "y", "лет": "y",
+	"hour": "h", "hours": "h", "час": "h", "часа": "h", "часов": "h",
+	"minute": "min", "minutes": "min", "min": "min", "минуту": "min", "минуты": "min", "минут": "min",
+}
+
+// quickUnitFrequencies maps quickUnits to RRULE frequencies.
+var quickUnitFrequencies = map[string]string{
+	"d": "DAILY", "w": "WEEKLY", "m": "MONTHLY", "y": "YEARLY",
+}
+
+var quickFrequencies = map[string]string{
+	"daily": "DAILY", "weekly": "WEEKLY", "monthly": "MONTHLY", "yearly": "YEARLY", "annually": "YEARLY",
+	"ежедневно": "DAILY", "еженедельно": "WEEKLY", "ежемесячно": "MONTHLY", "ежегодно": "YEARLY",
+}
+
+var rruleDays = map[time.Weekday]string{
+	time.Monday: "MO", time.Tuesday: "TU", time.Wednesday: "WE", time.Thursday: "TH",
+	time.Friday: "FR", time.Saturday: "SA", time.Sunday: "SU",
+}
+
+// ParseQuickAdd extracts tags (#tag), priority (!high), a due date and a
+// recurrence rule from a one-line task description in English or Russian,
+// such as "Pay rent every month on the 1st #home !high due tomorrow 9am".
+// Relative dates are resolved against now and in its location. Everything
+// that is not recognised becomes the title. A due date without a time is set
+// to the end of that day.
+func ParseQuickAdd(text string, now time.Time) QuickAdd {
+	runes := []rune(text)
+	p := &quickParser{text: runes, tokens: tokenizeQuickAdd(runes), now: now}
+	p.result.Matches = []QuickAddMatch{}
+
+	var title []string
+	for i := 0; i < len(p.tokens); {
+		if n := p.match(i); n > 0 {
+			i += n
+			continue
+		}
+		title = append(title, p.tokens[i].text)
+		i++
+	}
+
+	p.result.Title = strings.Join(title, " ")
+	p.result.Due = p.due()
+	return p.result
+}
+
+func tokenizeQuickAdd(runes []rune) []quickToken {
+	var tokens []quickToken
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		t := string(runes[start:end])
+		tokens = append(tokens, quickToken{
+			text:  t,
+			word:  strings.ToLower(strings.TrimRight(t, ",.;:?")),
+			start: start,
+			end:   end,
+		})
+		start = -1
+	}
+	for i, r := range runes {
+		if unicode.IsSpace(r) {
+			flush(i)
+		} else if start < 0 {
+			start = i
+		}
+	}
+	flush(len(runes))
+	return tokens
+}
+
+func (p *quickParser) word(i int) string {
+	if i < len(p.tokens) {
+		return p.tokens[i].word
+	}
+	return ""
+}
+
+func (p *quickParser) record(kind string, i, n int) {
+	first, last := p.tokens[i], p.tokens[i+n-1]
+	p.result.Matches = append(p.result.Matches, QuickAddMatch{
+		Kind:  kind,
+		Text:  string(p.text[first.start:last.end]),
+		Start: first.start,
+		End:   last.end,
+	})
+}
+
+// match tries every recogniser at token i and returns the number of tokens
+// consumed.
+func (p *quickParser) match(i int) int {
+	w := p.word(i)
+	switch {
+	case strings.HasPrefix(w, "#") && utf8.RuneCountInString(w) > 1:
+		tag := strings.TrimPrefix(strings.TrimRight(p.tokens[i].text, ",.;:?"), "#")
+		for _, have := range p.result.Tags {
+			if strings.EqualFold(have, tag) {
+				// A repeated tag leaves the title without being reported again.
+				return 1
+			}
+		}
+		p.result.Tags = append(p.result.Tags, tag)
+		p.record(QuickAddTag, i, 1)
+		return 1
+	case strings.HasPrefix(w, "!"):
+		if prio, ok := quickPriorities[strings.TrimPrefix(w, "!")]; ok && p.result.Priority == "" {
+			p.result.Priority = prio
+			p.record(QuickAddPriority, i, 1)
+			return 1
+		}
+		return 0
+	}
+
+	if p.result.Recurrence == "" {
+		if n := p.matchRecurrence(i); n > 0 {
+			p.record(QuickAddRecurrence, i, n)
+			return n
+		}
+	}
+	if n := p.matchDue(i); n > 0 {
+		p.record(QuickAddDue, i, n)
+		return n
+	}
+	return 0
+}
+
+// parseOrdinal reads numbers such as "1", "1st", "2nd", "1-го" or "1-е".
+func parseOrdinal(w string) (int, bool) {
+	for _, suffix := range []string{"st", "nd", "rd", "th", "-го", "-ого", "-е", "-ое", "-ый"} {
+		if strings.HasSuffix(w, suffix) {
+			w = strings.TrimSuffix(w, suffix)
+			break
+		}
+	}
+	n, err := strconv.Atoi(w)
+	return n, err == nil
+}
+
+func isEvery(w string) bool {
+	switch w {
+	case "every", "each", "каждый", "каждую", "каждое", "каждые", "каждых", "каждого":
+		return true
+	}
+	return false
+}
+
+func (p *quickParser) matchRecurrence(i int) int {
+	n := 0
+	freq, interval := "", 1
+	w := p.word(i)
+
+	switch {
+	case quickFrequencies[w] != "":
+		freq, n = quickFrequencies[w], 1
+	case isEvery(w):
+		j := i + 1
+		if v, err := strconv.Atoi(p.word(j)); err == nil && v > 0 {
+			interval = v
+			j++
+		}
+		if unit := quickUnits[p.word(j)]; quickUnitFrequencies[unit] != "" {
+			freq, n = quickUnitFrequencies[unit], j-i+1
+			break
+		}
+		if interval != 1 {
+			return 0
+		}
+		switch p.word(j) {
+		case "weekday", "weekdays", "будний", "будни":
+			p.byDay = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+			freq, n = "WEEKLY", j-i+1
+		default:
+			for {
+				wd, ok := quickWeekday(p.word(j), true)
+				if !ok {
+					break
+				}
+				p.byDay = append(p.byDay, wd)
+				j++
+				if sep := p.word(j); sep == "and" || sep == "и" {
+					j++
+				}
+			}
+			if len(p.byDay) == 0 {
+				return 0
+			}
+			if w := p.word(j - 1); w == "and" || w == "и" {
+				j--
+			}
+			freq, n = "WEEKLY", j-i
+		}
+	default:
+		return 0
+	}
+
+	if freq == "MONTHLY" {
+		n += p.matchMonthDay(i + n)
+	}
+
+	rule := "FREQ=" + freq
+	if interval > 1 {
+		rule += fmt.Sprintf(";INTERVAL=%d", interval)
+	}
+	if len(p.byDay) > 0 {
+		days := make([]string, 0, len(p.byDay))
+		for _, d := range p.byDay {
+			days = append(days, rruleDays[d])
+		}
+		rule += ";BYDAY=" + strings.Join(days, ",")
+	}
+	if p.byMonth > 0 {
+		rule += fmt.Sprintf(";BYMONTHDAY=%d", p.byMonth)
+	}
+	p.result.Recurrence = rule
+	return n
+}
+
+// matchMonthDay reads "on the 1st", "on 15th" or "1-го числа" after a
+// monthly recurrence.
+func (p *quickParser) matchMonthDay(i int) int {
+	j := i
+	if p.word(j) == "on" {
+		j++
+		if p.word(j) == "the" {
+			j++
+		}
+	}
+	day, ok := parseOrdinal(p.word(j))
+	if !ok || day < 1 || day > 31 {
+		return 0
+	}
+	j++
+	if p.word(j) == "числа" {
+		j++
+	} else if j == i+1 {
+		// A bare number needs "on" or "числа" to belong to the recurrence.
+		return 0
+	}
+	p.byMonth = day
+	return j - i
+}
+
+func isDueKeyword(w string) bool {
+	switch w {
+	case "due", "by", "on", "at", "до", "к", "ко", "в", "во", "на":
+		return true
+	}
+	return false
+}
+
+// matchDue reads an optional keyword followed by a date, a time or both.
+func (p *quickParser) matchDue(i int) int {
+	j := i
+	for isDueKeyword(p.word(j)) && j-i < 2 {
+		j++
+	}
+	start := j
+
+	if n := p.matchDate(j, j > i); n > 0 {
+		j += n
+		for isDueKeyword(p.word(j)) && p.peekTime(j+1) > 0 {
+			j++
+		}
+	}
+	if n := p.matchTime(j, j > i && isDueKeyword(p.word(j-1))); n > 0 {
+		j += n
+	}
+
+	if j == start {
+		return 0
+	}
+	return j - i
+}
+
+func (p *quickParser) today() time.Time {
+	y, m, d := p.now.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, p.now.Location())
+}
+
+func (p *quickParser) setDate(t time.Time) {
+	p.date = &t
+}
+
+// addUnit moves t by n units as understood by quickUnits.
+func addUnit(t time.Time, unit string, n int) time.Time {
+	switch unit {
+	case "d":
+		return t.AddDate(0, 0, n)
+	case "w":
+		return t.AddDate(0, 0, 7*n)
+	case "m":
+		return t.AddDate(0, n, 0)
+	case "y":
+		return t.AddDate(n, 0, 0)
+	case "h":
+		return t.Add(time.Duration(n) * time.Hour)
+	case "min":
+		return t.Add(time.Duration(n) * time.Minute)
+	}
+	return t
+}
+
+func nextWeekday(from time.Time, wd time.Weekday) time.Time {
+	days := (int(wd) - int(from.Weekday()) + 7) % 7
+	if days == 0 {
+		days = 7
+	}
+	return from.AddDate(0, 0, days)
+}
+
+// matchDate reads a date at token i. Ambiguous forms such as "12.05" or
+// "fri" are only accepted right after a keyword.
+func (p *quickParser) matchDate(i int, afterKeyword bool) int {
+	if p.date != nil {
+		return 0
+	}
+	today := p.today()
+	w := p.word(i)
+
+	switch w {
+	case "today", "сегодня":
+		p.setDate(today)
+		return 1
+	case "tonight", "вечером":
+		p.setDate(today)
+		if !p.hasTime {
+			p.hour, p.minute, p.hasTime = 20, 0, true
+		}
+		return 1
+	case "tomorrow", "завтра":
+		p.setDate(today.AddDate(0, 0, 1))
+		return 1
+	case "послезавтра":
+		p.setDate(today.AddDate(0, 0, 2))
+		return 1
+	case "day":
+		if p.word(i+1) == "after" && p.word(i+2) == "tomorrow" {
+			p.setDate(today.AddDate(0, 0, 2))
+			return 3
+		}
+	case "in", "через":
+		n, j := 1, i+1
+		if v, err := strconv.Atoi(p.word(j)); err == nil {
+			n, j = v, j+1
+		} else if a := p.word(j); a == "a" || a == "an" || a == "one" {
+			j++
+		}
+		unit, ok := quickUnits[p.word(j)]
+		if !ok {
+			return 0
+		}
+		if unit == "h" || unit == "min" {
+			t := addUnit(p.now, unit, n)
+			p.setDate(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()))
+			p.hour, p.minute, p.hasTime = t.Hour(), t.Minute(), true
+		} else {
+			p.setDate(addUnit(today, unit, n))
+		}
+		return j - i + 1
+	case "next", "следующей", "следующий", "следующую", "следующем":
+		if wd, ok := quickWeekday(p.word(i+1), true); ok {
+			p.setDate(nextWeekday(today, wd))
+			return 2
+		}
+		if unit, ok := quickUnits[p.word(i+1)]; ok && unit != "h" && unit != "min" {
+			p.setDate(addUnit(today, unit, 1))
+			return 2
+		}
+		return 0
+	}
+
+	if wd, ok := quickWeekday(w, afterKeyword); ok {
+		p.setDate(nextWeekday(today, wd))
+		return 1
+	}
+	if t, err := time.ParseInLocation("2006-01-02", w, p.now.Location()); err == nil {
+		p.setDate(t)
+		return 1
+	}
+	// "5 april", "april 5", "5th of april"
+	if day, ok := parseOrdinal(w); ok {
+		j := i + 1
+		if p.word(j) == "of" {
+			j++
+		}
+		if month, ok := quickMonths[p.word(j)]; ok {
+			return p.setMonthDay(month, day, j-i+1)
+		}
+	}
+	if month, ok := quickMonths[w]; ok {
+		if day, ok := parseOrdinal(p.word(i + 1)); ok {
+			return p.setMonthDay(month, day, 2)
+		}
+	}
+	if !afterKeyword {
+		return 0
+	}
+	for _, layout := range []string{"02.01.2006", "2.1.2006", "02.01", "2.1"} {
+		if t, err := time.ParseInLocation(layout, w, p.now.Location()); err == nil {
+			year := t.Year()
+			if year == 0 {
+				year = today.Year()
+				if time.Date(year, t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Before(today) {
+					year++
+				}
+			}
+			p.setDate(time.Date(year, t.Month(), t.Day(), 0, 0, 0, 0, p.now.Location()))
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func (p *quickParser) setMonthDay(month time.Month, day int, n int) int {
+	today := p.today()
+	if day < 1 || day > 31 {
+		return 0
+	}
+	t := time.Date(today.Year(), month, day, 0, 0, 0, 0, today.Location())
+	if t.Before(today) {
+		t = t.AddDate(1, 0, 0)
+	}
+	p.setDate(t)
+	return n
+}
+
+// peekTime reports how many tokens a time at i would consume without
+// recording it.
+func (p *quickParser) peekTime(i int) int {
+	_, _, n := p.parseTime(i, true)
+	return n
+}
+
+// matchTime reads "9am", "9:30 pm", "21:00", "noon" and, after a keyword
+// such as "at" or "в", a bare hour.
+func (p *quickParser) matchTime(i int, bareHour bool) int {
+	hour, minute, n := p.parseTime(i, bareHour)
+	if n > 0 {
+		p.hour, p.minute, p.hasTime = hour, minute, true
+	}
+	return n
+}
+
+func (p *quickParser) parseTime(i int, bareHour bool) (int, int, int) {
+	w := p.word(i)
+	switch w {
+	case "noon", "полдень":
+		return 12, 0, 1
+	case "midnight", "полночь":
+		return 0, 0, 1
+	}
+
+	n := 1
+	suffix := ""
+	for _, s := range []string{"am", "pm"} {
+		if strings.HasSuffix(w, s) {
+			suffix, w = s, strings.TrimSuffix(w, s)
+		}
+	}
+	if suffix == "" {
+		if next := p.word(i + 1); next == "am" || next == "pm" {
+			suffix, n = next, 2
+		}
+	}
+
+	hourStr, minStr, hasMinutes := strings.Cut(w, ":")
+	hour, err := strconv.Atoi(hourStr)
+	if err != nil || hourStr == "" {
+		return 0, 0, 0
+	}
+	minute := 0
+	if hasMinutes {
+		if minute, err = strconv.Atoi(minStr); err != nil || len(minStr) != 2 || minute > 59 {
+			return 0, 0, 0
+		}
+	}
+	if !hasMinutes && suffix == "" && !bareHour {
+		return 0, 0, 0
+	}
+
+	switch suffix {
+	case "am", "pm":
+		if hour < 1 || hour > 12 {
+			return 0, 0, 0
+		}
+		hour %= 12
+		if suffix == "pm" {
+			hour += 12
+		}
+	default:
+		if hour > 23 {
+			return 0, 0, 0
+		}
+	}
+	return hour, minute, n
+}
+
+// due combines the recognised date, time and recurrence into a due date.
+func (p *quickParser) due() *time.Time {
+	date := p.date
+	if date == nil {
+		if first := p.firstOccurrence(); first != nil {
+			date = first
+		}
+	}
+	if date == nil && !p.hasTime {
+		return nil
+	}
+
+	if date == nil {
+		t := time.Date(p.now.Year(), p.now.Month(), p.now.Day(), p.hour, p.minute, 0, 0, p.now.Location())
+		if t.Before(p.now) {
+			t = t.AddDate(0, 0, 1)
+		}
+		return &t
+	}
+
+	y, m, d := date.Date()
+	hour, minute, sec := 23, 59, 59
+	if p.hasTime {
+		hour, minute, sec = p.hour, p.minute, 0
+	}
+	t := time.Date(y, m, d, hour, minute, sec, 0, p.now.Location())
+	return &t
+}
+
+// firstOccurrence returns the first day on or after today that matches a
+// weekday or month-day recurrence.
+func (p *quickParser) firstOccurrence() *time.Time {
+	today := p.today()
+	for i := 0; i < 62; i++ {
+		day := today.AddDate(0, 0, i)
+		if p.byMonth > 0 && day.Day() == p.byMonth {
+			return &day
+		}
+		for _, wd := range p.byDay {
+			if day.Weekday() == wd {
+				return &day
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package lib
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQuickAdd(t *testing.T) {
+	// Wednesday, 5 April 2023, 15:00.
+	now := time.Date(2023, 4, 5, 15, 0, 0, 0, time.UTC)
+	at := func(y int, m time.Month, d, h, min int) *time.Time {
+		t := time.Date(y, m, d, h, min, 0, 0, time.UTC)
+		return &t
+	}
+	endOf := func(y int, m time.Month, d int) *time.Time {
+		t := time.Date(y, m, d, 23, 59, 59, 0, time.UTC)
+		return &t
+	}
+
+	tests := []struct {
+		in         string
+		title      string
+		tags       []string
+		priority   string
+		due        *time.Time
+		recurrence string
+	}{
+		{
+			in:         "Pay rent every month on the 1st #home !high due tomorrow 9am",
+			title:      "Pay rent",
+			tags:       []string{"home"},
+			priority:   "high",
+			due:        at(2023, 4, 6, 9, 0),
+			recurrence: "FREQ=MONTHLY;BYMONTHDAY=1",
+		},
+		{in: "Buy milk", title: "Buy milk"},
+		{in: "Call mom on the phone", title: "Call mom on the phone"},
+		{in: "Fix the sun visor", title: "Fix the sun visor"},
+		{in: "Release 2.1 notes", title: "Release 2.1 notes"},
+		{in: "Submit report by friday", title: "Submit report", due: endOf(2023, 4, 7)},
+		{in: "Standup at 9:30 !low", title: "Standup", priority: "low", due: at(2023, 4, 6, 9, 30)},
+		{in: "Dentist on 12.05 at 14:00", title: "Dentist", due: at(2023, 5, 12, 14, 0)},
+		{in: "Review in 3 days", title: "Review", due: endOf(2023, 4, 8)},
+		{in: "Ping in 2 hours", title: "Ping", due: at(2023, 4, 5, 17, 0)},
+		{in: "Plan next week #work", title: "Plan", tags: []string{"work"}, due: endOf(2023, 4, 12)},
+		{in: "Sync #work with #team #Work", title: "Sync with", tags: []string{"work", "team"}},
+		{in: "Birthday april 20", title: "Birthday", due: endOf(2023, 4, 20)},
+		{in: "Taxes 2023-04-15 5pm", title: "Taxes", due: at(2023, 4, 15, 17, 0)},
+		{in: "Water plants every 2 days", title: "Water plants", recurrence: "FREQ=DAILY;INTERVAL=2"},
+		{in: "Gym every monday and thursday 7am", title: "Gym", recurrence: "FREQ=WEEKLY;BYDAY=MO,TH", due: at(2023, 4, 6, 7, 0)},
+		{in: "Standup every weekday", title: "Standup", recurrence: "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR", due: endOf(2023, 4, 5)},
+		{in: "Backup weekly", title: "Backup", recurrence: "FREQ=WEEKLY"},
+		{
+			in:         "Оплатить аренду каждый месяц 1-го числа #дом !высокий",
+			title:      "Оплатить аренду",
+			tags:       []string{"дом"},
+			priority:   "high",
+			recurrence: "FREQ=MONTHLY;BYMONTHDAY=1",
+			due:        endOf(2023, 5, 1),
+		},
+		{in: "Позвонить маме завтра в 10:00", title: "Позвонить маме", due: at(2023, 4, 6, 10, 0)},
+		{in: "Купить хлеб послезавтра", title: "Купить хлеб", due: endOf(2023, 4, 7)},
+		{in: "Отчёт через неделю", title: "Отчёт", due: endOf(2023, 4, 12)},
+		{in: "Отчёт через 2 дня в 9", title: "Отчёт", due: at(2023, 4, 7, 9, 0)},
+		{in: "Встреча в пятницу", title: "Встреча", due: endOf(2023, 4, 7)},
+		{in: "Созвон 20 апреля", title: "Созвон", due: endOf(2023, 4, 20)},
+		{in: "Планёрка на следующей неделе", title: "Планёрка", due: endOf(2023, 4, 12)},
+		{in: "Зарядка ежедневно", title: "Зарядка", recurrence: "FREQ=DAILY"},
+		{in: "Уборка каждую субботу", title: "Уборка", recurrence: "FREQ=WEEKLY;BYDAY=SA", due: endOf(2023, 4, 8)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got := ParseQuickAdd(tc.in, now)
+			require.Equal(t, tc.title, got.Title)
+			require.Equal(t, tc.tags, got.Tags)
+			require.Equal(t, tc.priority, got.Priority)
+			require.Equal(t, tc.recurrence, got.Recurrence)
+			if tc.due == nil {
+				require.Nil(t, got.Due)
+			} else {
+				require.NotNil(t, got.Due)
+				require.True(t, tc.due.Equal(*got.Due), "want %v, got %v", tc.due, got.Due)
+			}
+		})
+	}
+
+	t.Run("reports recognised spans", func(t *testing.T) {
+		in := "Пить воду #здоровье завтра"
+		got := ParseQuickAdd(in, now)
+		require.Equal(t, []QuickAddMatch{
+			{Kind: QuickAddTag, Text: "#здоровье", Start: 10, End: 19},
+			{Kind: QuickAddDue, Text: "завтра", Start: 20, End: 26},
+		}, got.Matches)
+		runes := []rune(in)
+		for _, m := range got.Matches {
+			require.Equal(t, m.Text, string(runes[m.Start:m.End]))
+		}
+
+		// A repeated tag is reported once.
+		got = ParseQuickAdd("Call #home #home", now)
+		require.Equal(t, []QuickAddMatch{{Kind: QuickAddTag, Text: "#home", Start: 5, End: 10}}, got.Matches)
+	})
+}
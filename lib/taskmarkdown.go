@@ -0,0 +1,470 @@
+package lib
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"tasks/db"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrInvalidMarkdown = errors.New("invalid Markdown task")
+
+const (
+	// maxMarkdownFileSize bounds a file read from a Markdown archive once
+	// decompressed.
+	maxMarkdownFileSize = 1 << 20
+	// maxMarkdownFiles bounds the number of files read from an archive.
+	maxMarkdownFiles = 10000
+
+	markdownDateLayout = "2006-01-02"
+)
+
+// taskFrontMatter is the metadata of a task written as YAML front matter
+// ahead of its Markdown text. The title is the heading that follows it.
+type taskFrontMatter struct {
+	ID          uuid.UUID              `yaml:"id,omitempty"`
+	Project     string                 `yaml:"project,omitempty"`
+	Status      string                 `yaml:"status,omitempty"`
+	Priority    string                 `yaml:"priority,omitempty"`
+	Tags        []string               `yaml:"tags,omitempty,flow"`
+	Parent      *uuid.UUID             `yaml:"parent,omitempty"`
+	Due         *time.Time             `yaml:"due,omitempty"`
+	Recurrence  string                 `yaml:"recurrence,omitempty"`
+	DeferUntil  *time.Time             `yaml:"deferUntil,omitempty"`
+	Someday     bool                   `yaml:"someday,omitempty"`
+	Fields      map[string]interface{} `yaml:"fields,omitempty"`
+	Checklist   []taskMarkdownItem     `yaml:"checklist,omitempty"`
+	CreatedAt   *time.Time             `yaml:"created,omitempty"`
+	UpdatedAt   *time.Time             `yaml:"updated,omitempty"`
+	CompletedAt *time.Time             `yaml:"completed,omitempty"`
+}
+
+type taskMarkdownItem struct {
+	ID   uuid.UUID `yaml:"id,omitempty"`
+	Text string    `yaml:"text"`
+	Done bool      `yaml:"done"`
+}
+
+func utcTime(t *time.Time) *time.Time {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// TaskMarkdown renders the task as a Markdown file: its metadata as YAML
+// front matter, followed by the title as a heading and the text.
+func TaskMarkdown(t *db.Task) ([]byte, error) {
+	fm := taskFrontMatter{
+		ID:          t.ID,
+		Project:     t.Project,
+		Status:      t.Status,
+		Priority:    t.Priority,
+		Tags:        t.Tags,
+		Parent:      t.ParentID,
+		Due:         utcTime(t.Due),
+		Recurrence:  t.Recurrence,
+		DeferUntil:  utcTime(t.DeferUntil),
+		Someday:     t.Someday,
+		Fields:      t.Fields,
+		CreatedAt:   utcTime(&t.CreatedAt),
+		UpdatedAt:   utcTime(&t.UpdatedAt),
+		CompletedAt: utcTime(t.CompletedAt),
+	}
+	for _, item := range t.Checklist {
+		fm.Checklist = append(fm.Checklist, taskMarkdownItem{ID: item.ID, Text: item.Text, Done: item.Done})
+	}
+	meta, err := yaml.Marshal(&fm)
+	if err != nil {
+		return nil, fmt.Errorf("could not write the front matter of task %s! %v", t.ID, err)
+	}
+
+	var b bytes.Buffer
+	b.WriteString("---\n")
+	b.Write(meta)
+	b.WriteString("---\n\n# ")
+	b.WriteString(t.Title)
+	b.WriteString("\n")
+	if text := strings.TrimSpace(t.Text); text != "" {
+		b.WriteString("\n")
+		b.WriteString(text)
+		b.WriteString("\n")
+	}
+	return b.Bytes(), nil
+}
+
+// TaskMarkdownName returns the file name of the Markdown rendering of the
+// task: its title reduced to a slug, followed by the start of its ID to keep
+// names unique.
+func TaskMarkdownName(t *db.Task) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(t.Title) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+			dash = false
+		case !dash && b.Len() > 0:
+			b.WriteByte('-')
+			dash = true
+		}
+		if b.Len() >= 60 {
+			break
+		}
+	}
+	slug := strings.TrimSuffix(b.String(), "-")
+	if slug == "" {
+		slug = "task"
+	}
+	return slug + "-" + t.ID.String()[:8] + ".md"
+}
+
+// markdownFieldValue turns a custom field value read from YAML into the form
+// it has in JSON: numbers as float64, and timestamps as dates or RFC 3339
+// strings.
+func markdownFieldValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case int:
+		return float64(value)
+	case time.Time:
+		value = value.UTC()
+		if value.Equal(value.Truncate(24 * time.Hour)) {
+			return value.Format(markdownDateLayout)
+		}
+		return value.Format(time.RFC3339)
+	default:
+		return v
+	}
+}
+
+// ParseTaskMarkdown reads a task written by TaskMarkdown. The front matter
+// is optional, so plain Markdown files become tasks too: the first level one
+// heading is the title, or else the file name. Checklist items without an
+// ID, and the task itself without one, get uuid.Nil.
+func ParseTaskMarkdown(name string, data []byte) (*db.Task, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.TrimPrefix(text, "\ufeff")
+	fm := taskFrontMatter{}
+	if rest, ok := cutPrefix(text, "---\n"); ok {
+		end := strings.Index(rest, "\n---\n")
+		if end < 0 {
+			if !strings.HasSuffix(rest, "\n---") {
+				return nil, fmt.Errorf("%w: front matter is not closed", ErrInvalidMarkdown)
+			}
+			end = len(rest) - len("\n---")
+		}
+		if err := yaml.Unmarshal([]byte(rest[:end]), &fm); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidMarkdown, err)
+		}
+		text = strings.TrimPrefix(rest[end+1:], "---")
+	}
+	text = strings.TrimSpace(text)
+
+	t := &db.Task{
+		ID:          fm.ID,
+		Project:     fm.Project,
+		Status:      fm.Status,
+		Priority:    fm.Priority,
+		Tags:        fm.Tags,
+		ParentID:    fm.Parent,
+		Due:         utcTime(fm.Due),
+		Recurrence:  fm.Recurrence,
+		DeferUntil:  utcTime(fm.DeferUntil),
+		Someday:     fm.Someday,
+		CompletedAt: utcTime(fm.CompletedAt),
+	}
+	if fm.CreatedAt != nil {
+		t.CreatedAt = fm.CreatedAt.UTC()
+	}
+	if fm.UpdatedAt != nil {
+		t.UpdatedAt = fm.UpdatedAt.UTC()
+	}
+	for key, v := range fm.Fields {
+		if t.Fields == nil {
+			t.Fields = map[string]interface{}{}
+		}
+		t.Fields[key] = markdownFieldValue(v)
+	}
+	for _, item := range fm.Checklist {
+		t.Checklist = append(t.Checklist, db.ChecklistItem{ID: item.ID, Text: item.Text, Done: item.Done})
+	}
+
+	if heading, ok := cutPrefix(text, "# "); ok {
+		t.Title, text, _ = strings.Cut(heading, "\n")
+		t.Title = strings.TrimSpace(t.Title)
+		text = strings.TrimSpace(text)
+	} else {
+		t.Title = strings.TrimSuffix(path.Base(name), path.Ext(name))
+	}
+	t.Text = text
+	return t, nil
+}
+
+func cutPrefix(s string, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
+
+// WriteMarkdownArchive writes the tasks as a ZIP archive of Markdown files,
+// in a folder per project. Tasks without a project are at the top.
+func WriteMarkdownArchive(w io.Writer, tasks []db.Task, now time.Time) error {
+	z := zip.NewWriter(w)
+	for i := range tasks {
+		content, err := TaskMarkdown(&tasks[i])
+		if err != nil {
+			return err
+		}
+		name := path.Join(markdownFolder(tasks[i].Project), TaskMarkdownName(&tasks[i]))
+		f, err := z.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: now})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(content); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+// markdownFolder returns the folder name of a project, without characters
+// that are not allowed in file names or a leading dot.
+func markdownFolder(project string) string {
+	folder := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || unicode.IsControl(r) {
+			return '-'
+		}
+		return r
+	}, project)
+	if strings.HasPrefix(folder, ".") {
+		folder = "-" + folder[1:]
+	}
+	return folder
+}
+
+// MarkdownFile is a file read by ReadMarkdownImport. Err is set when the
+// file could not be read as a task.
+type MarkdownFile struct {
+	Name string
+	Task *db.Task
+	Err  error
+}
+
+// ReadMarkdownImport reads Markdown tasks from a ZIP archive, as written by
+// WriteMarkdownArchive, or from a single Markdown file. Files of an archive
+// that are not Markdown, or hidden, are ignored. The project of a task
+// without one in its front matter is the folder it is in.
+func ReadMarkdownImport(data []byte) ([]MarkdownFile, error) {
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		t, err := ParseTaskMarkdown("task.md", data)
+		if err != nil {
+			return nil, err
+		}
+		return []MarkdownFile{{Name: "task.md", Task: t}}, nil
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMarkdown, err)
+	}
+	files := []MarkdownFile{}
+	for _, f := range z.File {
+		name := f.Name
+		if f.FileInfo().IsDir() || !strings.EqualFold(path.Ext(name), ".md") || hiddenPath(name) {
+			continue
+		}
+		if len(files) == maxMarkdownFiles {
+			return nil, fmt.Errorf("%w: more than %d files", ErrInvalidMarkdown, maxMarkdownFiles)
+		}
+		file := MarkdownFile{Name: name}
+		file.Task, file.Err = readMarkdownFile(f)
+		if file.Task != nil && file.Task.Project == "" {
+			if dir := path.Dir(name); dir != "." {
+				file.Task.Project = path.Base(dir)
+			}
+		}
+		files = append(files, file)
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+func hiddenPath(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if strings.HasPrefix(part, ".") || part == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
+
+func readMarkdownFile(f *zip.File) (*db.Task, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMarkdown, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(io.LimitReader(r, maxMarkdownFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMarkdown, err)
+	}
+	if len(data) > maxMarkdownFileSize {
+		return nil, fmt.Errorf("%w: file is larger than %d bytes", ErrInvalidMarkdown, maxMarkdownFileSize)
+	}
+	return ParseTaskMarkdown(f.Name, data)
+}
+
+// WriteMarkdownDocument writes the tasks as a single Markdown document for
+// reading, titled title: a section per project, and per task its metadata,
+// text and checklist, in the order of their ranks. Sub-tasks follow their
+// parent one heading level deeper.
+func WriteMarkdownDocument(w io.Writer, title string, tasks []db.Task) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# %s\n", title)
+
+	children := map[uuid.UUID][]*db.Task{}
+	byID := make(map[uuid.UUID]bool, len(tasks))
+	for i := range tasks {
+		byID[tasks[i].ID] = true
+	}
+	var projects []string
+	roots := map[string][]*db.Task{}
+	for i := range tasks {
+		t := &tasks[i]
+		if t.ParentID != nil && byID[*t.ParentID] {
+			children[*t.ParentID] = append(children[*t.ParentID], t)
+			continue
+		}
+		if _, ok := roots[t.Project]; !ok {
+			projects = append(projects, t.Project)
+		}
+		roots[t.Project] = append(roots[t.Project], t)
+	}
+	sort.Strings(projects)
+	byRank := func(list []*db.Task) {
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].Rank != list[j].Rank {
+				return list[i].Rank < list[j].Rank
+			}
+			return list[i].CreatedAt.Before(list[j].CreatedAt)
+		})
+	}
+	for _, list := range roots {
+		byRank(list)
+	}
+	for _, list := range children {
+		byRank(list)
+	}
+
+	var write func(t *db.Task, level int)
+	write = func(t *db.Task, level int) {
+		box := " "
+		if t.Status == db.StatusDone {
+			box = "x"
+		}
+		fmt.Fprintf(bw, "\n%s [%s] %s\n", strings.Repeat("#", level), box, t.Title)
+		if meta := markdownMetadata(t); meta != "" {
+			fmt.Fprintf(bw, "\n%s", meta)
+		}
+		if text := strings.TrimSpace(t.Text); text != "" {
+			fmt.Fprintf(bw, "\n%s\n", text)
+		}
+		if len(t.Checklist) > 0 {
+			bw.WriteString("\n")
+			for _, item := range t.Checklist {
+				box := " "
+				if item.Done {
+					box = "x"
+				}
+				fmt.Fprintf(bw, "- [%s] %s\n", box, item.Text)
+			}
+		}
+		for _, child := range children[t.ID] {
+			write(child, level+1)
+		}
+	}
+
+	single := len(projects) == 1
+	for _, project := range projects {
+		level := 3
+		if single {
+			level = 2
+		} else {
+			name := project
+			if name == "" {
+				name = "No project"
+			}
+			fmt.Fprintf(bw, "\n## %s\n", name)
+		}
+		for _, t := range roots[project] {
+			write(t, level)
+		}
+	}
+	return bw.Flush()
+}
+
+// markdownMetadata renders the metadata of the task shown in a Markdown
+// document as a list.
+func markdownMetadata(t *db.Task) string {
+	var b strings.Builder
+	item := func(name string, value string) {
+		fmt.Fprintf(&b, "- **%s:** %s\n", name, value)
+	}
+	if t.Status != "" {
+		item("Status", strings.ReplaceAll(t.Status, "_", " "))
+	}
+	if t.Priority != "" {
+		item("Priority", t.Priority)
+	}
+	if t.Due != nil {
+		item("Due", markdownTime(*t.Due))
+	}
+	if t.Recurrence != "" {
+		item("Repeats", t.Recurrence)
+	}
+	if t.DeferUntil != nil {
+		item("Deferred until", markdownTime(*t.DeferUntil))
+	}
+	if t.Someday {
+		item("Someday", "yes")
+	}
+	if len(t.Tags) > 0 {
+		item("Tags", strings.Join(t.Tags, ", "))
+	}
+	keys := make([]string, 0, len(t.Fields))
+	for key := range t.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		item(key, fmt.Sprint(t.Fields[key]))
+	}
+	if t.CompletedAt != nil {
+		item("Completed", markdownTime(*t.CompletedAt))
+	}
+	return b.String()
+}
+
+// markdownTime writes a time as a date when it is midnight UTC, and with the
+// time of day otherwise.
+func markdownTime(t time.Time) string {
+	t = t.UTC()
+	if t.Equal(t.Truncate(24 * time.Hour)) {
+		return t.Format(markdownDateLayout)
+	}
+	return t.Format("2006-01-02 15:04 UTC")
+}
@@ -0,0 +1,123 @@
+package lib
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"tasks/db"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskMarkdown(t *testing.T) {
+	created := time.Date(2023, 4, 1, 9, 30, 0, 0, time.UTC)
+	due := time.Date(2023, 4, 5, 0, 0, 0, 0, time.UTC)
+	parent := uuid.MustParse("5b0e7a4c-3f1d-4a4e-9c6b-2d1e0f9a8b7c")
+	task := db.Task{
+		ID: uuid.MustParse("9f1c2d3e-4b5a-4c6d-8e7f-0a1b2c3d4e5f"), Title: "Pack bag", Text: "Passport\n\n---\n\nand *charger*",
+		Project: "trip", Status: db.StatusInProgress, Priority: db.PriorityHigh, Tags: []string{"travel", "home"},
+		ParentID: &parent, Due: &due, Recurrence: "FREQ=WEEKLY", Fields: map[string]interface{}{"km": 12.0, "day": "2023-04-06"},
+		Checklist: []db.ChecklistItem{{ID: uuid.MustParse("0c3b1a2d-6e5f-4a7b-8c9d-1e2f3a4b5c6d"), Text: "Socks", Done: true}},
+		CreatedAt: created, UpdatedAt: created,
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		data, err := TaskMarkdown(&task)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(string(data), "---\nid: 9f1c2d3e-4b5a-4c6d-8e7f-0a1b2c3d4e5f\nproject: trip\n"), string(data))
+		require.Contains(t, string(data), "tags: [travel, home]\n")
+		require.True(t, strings.HasSuffix(string(data), "---\n\n# Pack bag\n\nPassport\n\n---\n\nand *charger*\n"), string(data))
+
+		got, err := ParseTaskMarkdown("trip/pack-bag-9f1c2d3e.md", data)
+		require.NoError(t, err)
+		require.Equal(t, &task, got)
+		require.Equal(t, "pack-bag-9f1c2d3e.md", TaskMarkdownName(&task))
+	})
+
+	t.Run("plain file", func(t *testing.T) {
+		got, err := ParseTaskMarkdown("notes/Buy milk.md", []byte("\ufeffSemi-skimmed\r\n"))
+		require.NoError(t, err)
+		require.Equal(t, &db.Task{Title: "Buy milk", Text: "Semi-skimmed"}, got)
+
+		got, err = ParseTaskMarkdown("x.md", []byte("---\nstatus: done\nfields: {n: 2, d: 2023-04-06}\n---\n# Call mum\n"))
+		require.NoError(t, err)
+		require.Equal(t, "Call mum", got.Title)
+		require.Equal(t, db.StatusDone, got.Status)
+		require.Equal(t, map[string]interface{}{"n": 2.0, "d": "2023-04-06"}, got.Fields)
+
+		_, err = ParseTaskMarkdown("x.md", []byte("---\nstatus: done\n# Call mum\n"))
+		require.ErrorIs(t, err, ErrInvalidMarkdown)
+		_, err = ParseTaskMarkdown("x.md", []byte("---\ntags: {\n---\n"))
+		require.ErrorIs(t, err, ErrInvalidMarkdown)
+	})
+
+	t.Run("archive", func(t *testing.T) {
+		loose := db.Task{ID: uuid.New(), Title: "Water plants"}
+		var buf bytes.Buffer
+		require.NoError(t, WriteMarkdownArchive(&buf, []db.Task{task, loose}, created))
+		files, err := ReadMarkdownImport(buf.Bytes())
+		require.NoError(t, err)
+		require.Len(t, files, 2)
+		require.Equal(t, "trip/pack-bag-9f1c2d3e.md", files[0].Name)
+		require.Equal(t, &task, files[0].Task)
+		require.Equal(t, "", files[1].Task.Project)
+
+		var archive bytes.Buffer
+		z := zip.NewWriter(&archive)
+		for name, content := range map[string]string{
+			"Garden/Mow lawn.md": "Front and back", "Garden/.hidden.md": "x", "__MACOSX/Garden/._Mow lawn.md": "x",
+			"Garden/photo.png": "x", "Garden/bad.md": "---\nnot closed",
+		} {
+			f, err := z.Create(name)
+			require.NoError(t, err)
+			f.Write([]byte(content))
+		}
+		require.NoError(t, z.Close())
+		files, err = ReadMarkdownImport(archive.Bytes())
+		require.NoError(t, err)
+		require.Len(t, files, 2)
+		require.ErrorIs(t, files[1].Err, ErrInvalidMarkdown)
+		require.Equal(t, &db.Task{Title: "Mow lawn", Text: "Front and back", Project: "Garden"}, files[0].Task)
+	})
+
+	t.Run("document", func(t *testing.T) {
+		sub := db.Task{ID: uuid.New(), Title: "Buy adapter", Project: "trip", ParentID: &task.ID, Status: db.StatusDone}
+		other := db.Task{ID: uuid.New(), Title: "Call mum", Rank: "a"}
+		var buf bytes.Buffer
+		require.NoError(t, WriteMarkdownDocument(&buf, "Tasks", []db.Task{sub, task, other}))
+		require.Equal(t, `# Tasks
+
+## No project
+
+### [ ] Call mum
+
+## trip
+
+### [ ] Pack bag
+
+- **Status:** in progress
+- **Priority:** high
+- **Due:** 2023-04-05
+- **Repeats:** FREQ=WEEKLY
+- **Tags:** travel, home
+- **day:** 2023-04-06
+- **km:** 12
+
+Passport
+
+---
+
+and *charger*
+
+- [x] Socks
+
+#### [x] Buy adapter
+
+- **Status:** done
+`, buf.String())
+	})
+}
@@ -0,0 +1,192 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"tasks/db"
+)
+
+// todoistExport is the part of a Todoist sync export (API v8 and v9) that is
+// imported.
+type todoistExport struct {
+	Projects []struct {
+		ID        importID `json:"id"`
+		Name      string   `json:"name"`
+		IsDeleted bool     `json:"is_deleted"`
+	} `json:"projects"`
+	Sections []struct {
+		ID   importID `json:"id"`
+		Name string   `json:"name"`
+	} `json:"sections"`
+	Labels []struct {
+		ID   importID `json:"id"`
+		Name string   `json:"name"`
+	} `json:"labels"`
+	Items *[]todoistItem `json:"items"`
+	Notes []struct {
+		ItemID    importID `json:"item_id"`
+		Content   string   `json:"content"`
+		PostedAt  string   `json:"posted_at"`
+		IsDeleted bool     `json:"is_deleted"`
+	} `json:"notes"`
+}
+
+type todoistItem struct {
+	ID          importID   `json:"id"`
+	Content     string     `json:"content"`
+	Description string     `json:"description"`
+	ProjectID   importID   `json:"project_id"`
+	SectionID   importID   `json:"section_id"`
+	ParentID    importID   `json:"parent_id"`
+	Labels      []importID `json:"labels"`
+	Priority    int        `json:"priority"`
+	Due         *struct {
+		Date        string `json:"date"`
+		String      string `json:"string"`
+		IsRecurring bool   `json:"is_recurring"`
+		Timezone    string `json:"timezone"`
+	} `json:"due"`
+	Checked       bool   `json:"checked"`
+	IsDeleted     bool   `json:"is_deleted"`
+	AddedAt       string `json:"added_at"`
+	DateAdded     string `json:"date_added"`
+	CompletedAt   string `json:"completed_at"`
+	DateCompleted string `json:"date_completed"`
+}
+
+// todoistPriorities maps the priorities of the API, where 4 is the most
+// urgent, to ours.
+var todoistPriorities = map[int]string{
+	4: db.PriorityHigh,
+	3: db.PriorityMedium,
+	2: db.PriorityLow,
+}
+
+// parseTodoistDue reads a due date: a date, a floating date and time taken
+// in the given time zone, or a UTC timestamp.
+func parseTodoistDue(date string, timezone string) (*time.Time, error) {
+	if t, err := time.Parse("2006-01-02", date); err == nil {
+		return &t, nil
+	}
+	loc := time.UTC
+	if timezone != "" {
+		if l, err := time.LoadLocation(timezone); err == nil {
+			loc = l
+		}
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04:05", date, loc); err == nil {
+		t = t.UTC()
+		return &t, nil
+	}
+	t, err := parseImportTime(date)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ParseTodoistExport reads a Todoist sync export. Sections become tags, and
+// set the status like the lists of a Trello board; notes become comments and
+// sub-tasks keep their parent. Deleted items are skipped.
+func ParseTodoistExport(r io.Reader, now time.Time) (*ImportedData, error) {
+	var export todoistExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	if export.Items == nil {
+		return nil, fmt.Errorf("%w: not a Todoist export, items are missing", ErrInvalidImport)
+	}
+
+	data := &ImportedData{Projects: []string{}, Tasks: []ImportedTask{}, Skipped: []ImportProblem{}}
+	projects := map[importID]string{}
+	for _, p := range export.Projects {
+		if !p.IsDeleted {
+			projects[p.ID] = p.Name
+			data.Projects = append(data.Projects, p.Name)
+		}
+	}
+	sections := map[importID]string{}
+	for _, s := range export.Sections {
+		sections[s.ID] = s.Name
+	}
+	labels := map[importID]string{}
+	for _, l := range export.Labels {
+		labels[l.ID] = l.Name
+	}
+	comments := map[importID][]ImportedComment{}
+	for _, n := range export.Notes {
+		if n.IsDeleted {
+			continue
+		}
+		posted, err := parseImportTime(n.PostedAt)
+		if err != nil {
+			return nil, err
+		}
+		comments[n.ItemID] = append(comments[n.ItemID], ImportedComment{Text: n.Content, Time: posted})
+	}
+
+	for _, item := range *export.Items {
+		if item.IsDeleted {
+			data.Skipped = append(data.Skipped, ImportProblem{SourceID: string(item.ID), Title: item.Content, Reason: "deleted"})
+			continue
+		}
+		t := ImportedTask{
+			SourceID:    string(item.ID),
+			Parent:      string(item.ParentID),
+			Title:       item.Content,
+			Description: item.Description,
+			Comments:    comments[item.ID],
+			Project:     projects[item.ProjectID],
+			Status:      db.StatusTodo,
+			Priority:    todoistPriorities[item.Priority],
+		}
+		sort.SliceStable(t.Comments, func(i, j int) bool { return t.Comments[i].Time.Before(t.Comments[j].Time) })
+		if section, ok := sections[item.SectionID]; ok {
+			t.Tags = append(t.Tags, ImportTag(section))
+			t.Status = importStatus(section)
+		}
+		for _, label := range item.Labels {
+			// API v9 lists label names, v8 label IDs.
+			if name, ok := labels[label]; ok {
+				label = importID(name)
+			}
+			t.Tags = append(t.Tags, ImportTag(string(label)))
+		}
+
+		var err error
+		added := item.AddedAt
+		if added == "" {
+			added = item.DateAdded
+		}
+		if t.CreatedAt, err = parseImportTime(added); err != nil {
+			return nil, err
+		}
+		if item.Checked {
+			t.Status = db.StatusDone
+			completed := item.CompletedAt
+			if completed == "" {
+				completed = item.DateCompleted
+			}
+			if completed != "" {
+				at, err := parseImportTime(completed)
+				if err != nil {
+					return nil, err
+				}
+				t.CompletedAt = &at
+			}
+		}
+		if item.Due != nil && item.Due.Date != "" {
+			if t.Due, err = parseTodoistDue(item.Due.Date, item.Due.Timezone); err != nil {
+				return nil, err
+			}
+			if item.Due.IsRecurring {
+				t.Recurrence = ParseQuickAdd("Task "+item.Due.String, now).Recurrence
+			}
+		}
+		data.Tasks = append(data.Tasks, t)
+	}
+	return data, nil
+}
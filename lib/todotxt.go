@@ -0,0 +1,376 @@
+package lib
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"tasks/db"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidTodoTxt = errors.New("invalid todo.txt file")
+
+const (
+	todoTxtDateLayout = "2006-01-02"
+	// TodoTxtIDKey is the extension carrying the ID of an exported task, so
+	// importing the file again updates the tasks instead of duplicating them.
+	TodoTxtIDKey        = "uuid"
+	todoTxtBusinessDays = ";BYDAY=MO,TU,WE,TH,FR"
+)
+
+var (
+	todoTxtPriorities = map[string]string{
+		db.PriorityHigh:   "A",
+		db.PriorityMedium: "B",
+		db.PriorityLow:    "C",
+	}
+	todoTxtPriorityRe  = regexp.MustCompile(`^\(([A-Z])\)$`)
+	todoTxtPairRe      = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9_.-]*):(\S+)$`)
+	todoTxtRecRe       = regexp.MustCompile(`^\+?([1-9][0-9]*)?([dwmyb])$`)
+	todoTxtEscaper     = strings.NewReplacer("%", "%25", " ", "%20", ":", "%3A")
+	todoTxtFrequencies = map[string]string{
+		"d": "DAILY",
+		"w": "WEEKLY",
+		"m": "MONTHLY",
+		"y": "YEARLY",
+	}
+)
+
+// todoTxtPriority maps a priority letter to a priority: A is high, B medium
+// and every later letter low.
+func todoTxtPriority(letter string) string {
+	switch letter {
+	case "A":
+		return db.PriorityHigh
+	case "B":
+		return db.PriorityMedium
+	default:
+		return db.PriorityLow
+	}
+}
+
+func todoTxtEscape(s string) string {
+	return todoTxtEscaper.Replace(s)
+}
+
+// TodoTxtUnescape decodes a value escaped for a todo.txt line.
+func TodoTxtUnescape(s string) string {
+	if u, err := url.PathUnescape(s); err == nil {
+		return u
+	}
+	return s
+}
+
+// todoTxtTime writes a time as a date when it is midnight UTC, and in full
+// otherwise.
+func todoTxtTime(t time.Time) string {
+	t = t.UTC()
+	if t.Equal(t.Truncate(24 * time.Hour)) {
+		return t.Format(todoTxtDateLayout)
+	}
+	return t.Format(time.RFC3339)
+}
+
+func parseTodoTxtTime(s string) (*time.Time, bool) {
+	for _, layout := range []string{todoTxtDateLayout, time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &t, true
+		}
+	}
+	return nil, false
+}
+
+// todoTxtRecurrence writes an RRULE as a rec: value. Rules that only have a
+// frequency and an interval use the short form of todo.txt tools, such as
+// 2w; others are written as they are.
+func todoTxtRecurrence(rule string) string {
+	freq, interval := "", "1"
+	for _, part := range strings.Split(strings.ToUpper(rule), ";") {
+		name, value, _ := strings.Cut(part, "=")
+		switch name {
+		case "FREQ":
+			freq = value
+		case "INTERVAL":
+			interval = value
+		default:
+			return rule
+		}
+	}
+	for unit, f := range todoTxtFrequencies {
+		if f == freq {
+			if interval == "1" {
+				return unit
+			}
+			return interval + unit
+		}
+	}
+	return rule
+}
+
+// parseTodoTxtRecurrence reads a rec: value written by todoTxtRecurrence or
+// by todo.txt tools, where b counts business days.
+func parseTodoTxtRecurrence(value string) (string, bool) {
+	if m := todoTxtRecRe.FindStringSubmatch(value); m != nil {
+		rule := "FREQ=DAILY"
+		if f, ok := todoTxtFrequencies[m[2]]; ok {
+			rule = "FREQ=" + f
+		}
+		if m[1] != "" && m[1] != "1" {
+			rule += ";INTERVAL=" + m[1]
+		}
+		if m[2] == "b" {
+			rule += todoTxtBusinessDays
+		}
+		return rule, true
+	}
+	if strings.Contains(strings.ToUpper(value), "FREQ=") {
+		return value, true
+	}
+	return "", false
+}
+
+func todoTxtFieldValue(v interface{}) string {
+	switch value := v.(type) {
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return todoTxtEscape(fmt.Sprint(value))
+	}
+}
+
+// FormatTodoTxt writes the task as a todo.txt line. Tags become contexts,
+// custom fields and preserved extensions key:value pairs; the text of the
+// task has no place in the format and is left out.
+func FormatTodoTxt(t *db.Task) string {
+	var parts []string
+	done := t.Status == db.StatusDone
+	if done {
+		parts = append(parts, "x")
+		if t.CompletedAt != nil {
+			parts = append(parts, t.CompletedAt.UTC().Format(todoTxtDateLayout))
+		}
+	} else if p, ok := todoTxtPriorities[t.Priority]; ok {
+		parts = append(parts, "("+p+")")
+	}
+	if !t.CreatedAt.IsZero() && (!done || t.CompletedAt != nil) {
+		parts = append(parts, t.CreatedAt.UTC().Format(todoTxtDateLayout))
+	}
+
+	// The first +project of a line is the project, so it goes before a
+	// title that has +words of its own.
+	project := ""
+	if t.Project != "" {
+		project = "+" + todoTxtEscape(t.Project)
+	}
+	if project != "" && strings.Contains(" "+t.Title, " +") {
+		parts = append(parts, project)
+		project = ""
+	}
+	parts = append(parts, t.Title)
+	if project != "" {
+		parts = append(parts, project)
+	}
+	for _, tag := range t.Tags {
+		parts = append(parts, "@"+tag)
+	}
+
+	pair := func(key string, value string) {
+		parts = append(parts, key+":"+value)
+	}
+	if t.Due != nil {
+		pair("due", todoTxtTime(*t.Due))
+	}
+	if t.DeferUntil != nil {
+		pair("t", todoTxtTime(*t.DeferUntil))
+	}
+	if t.Recurrence != "" {
+		pair("rec", todoTxtRecurrence(t.Recurrence))
+	}
+	if p, ok := todoTxtPriorities[t.Priority]; ok && done {
+		pair("pri", p)
+	}
+	if t.Status == db.StatusInProgress {
+		pair("status", db.StatusInProgress)
+	}
+	if t.Someday {
+		pair("someday", "true")
+	}
+	for _, key := range sortedKeys(t.Fields) {
+		pair(key, todoTxtFieldValue(t.Fields[key]))
+	}
+	extensions := make([]string, 0, len(t.Extensions))
+	for key := range t.Extensions {
+		extensions = append(extensions, key)
+	}
+	sort.Strings(extensions)
+	for _, key := range extensions {
+		pair(key, t.Extensions[key])
+	}
+	pair(TodoTxtIDKey, t.ID.String())
+	return strings.Join(parts, " ")
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteTodoTxt writes the tasks as a todo.txt file, one line per task.
+func WriteTodoTxt(w io.Writer, tasks []db.Task) error {
+	bw := bufio.NewWriter(w)
+	for i := range tasks {
+		bw.WriteString(FormatTodoTxt(&tasks[i]))
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// TodoTxtItem is a task read from a line of a todo.txt file. ID is set by
+// the uuid: extension. The key:value pairs that are not part of the task are
+// kept in Values as written, for the importer to match with custom fields or
+// preserve. CreatedAt and CompletedAt only carry dates.
+type TodoTxtItem struct {
+	Line   int
+	Task   db.Task
+	Values map[string]string
+}
+
+// ParseTodoTxtLine reads a todo.txt line. Every non-blank line is a task;
+// pairs such as due:soon that do not parse are kept as values.
+func ParseTodoTxtLine(line string) TodoTxtItem {
+	item := TodoTxtItem{}
+	t := &item.Task
+	t.Status = db.StatusTodo
+	tokens := strings.Fields(line)
+
+	date := func() *time.Time {
+		if len(tokens) > 0 {
+			if d, err := time.Parse(todoTxtDateLayout, tokens[0]); err == nil {
+				tokens = tokens[1:]
+				return &d
+			}
+		}
+		return nil
+	}
+	if len(tokens) > 0 && tokens[0] == "x" {
+		tokens = tokens[1:]
+		t.Status = db.StatusDone
+		t.CompletedAt = date()
+		if t.CompletedAt != nil {
+			if created := date(); created != nil {
+				t.CreatedAt = *created
+			}
+		}
+	} else {
+		if len(tokens) > 0 {
+			if m := todoTxtPriorityRe.FindStringSubmatch(tokens[0]); m != nil {
+				t.Priority = todoTxtPriority(m[1])
+				tokens = tokens[1:]
+			}
+		}
+		if created := date(); created != nil {
+			t.CreatedAt = *created
+		}
+	}
+
+	var title []string
+	for _, token := range tokens {
+		switch {
+		case len(token) > 1 && token[0] == '+' && t.Project == "":
+			t.Project = TodoTxtUnescape(token[1:])
+			continue
+		case len(token) > 1 && token[0] == '@':
+			t.AddTags(token[1:])
+			continue
+		}
+		m := todoTxtPairRe.FindStringSubmatch(token)
+		if m == nil || strings.HasPrefix(m[2], "//") || !item.setPair(m[1], m[2]) {
+			title = append(title, token)
+		}
+	}
+	t.Title = strings.Join(title, " ")
+	return item
+}
+
+// setPair applies a key:value pair to the item and reports whether it was
+// one.
+func (item *TodoTxtItem) setPair(key string, value string) bool {
+	t := &item.Task
+	switch key {
+	case "due":
+		if d, ok := parseTodoTxtTime(value); ok {
+			t.Due = d
+			return true
+		}
+	case "t":
+		if d, ok := parseTodoTxtTime(value); ok {
+			t.DeferUntil = d
+			return true
+		}
+	case "rec":
+		if rule, ok := parseTodoTxtRecurrence(value); ok {
+			t.Recurrence = rule
+			return true
+		}
+	case "pri":
+		if len(value) == 1 && value[0] >= 'A' && value[0] <= 'Z' {
+			t.Priority = todoTxtPriority(value)
+			return true
+		}
+	case "status":
+		if value == db.StatusInProgress {
+			if t.Status != db.StatusDone {
+				t.Status = value
+			}
+			return true
+		}
+	case "someday":
+		if b, err := strconv.ParseBool(value); err == nil {
+			t.Someday = b
+			return true
+		}
+	case TodoTxtIDKey:
+		if id, err := uuid.Parse(value); err == nil {
+			t.ID = id
+			return true
+		}
+	}
+	if item.Values == nil {
+		item.Values = map[string]string{}
+	}
+	item.Values[key] = value
+	return true
+}
+
+// ParseTodoTxt reads the tasks of a todo.txt file, skipping blank lines.
+func ParseTodoTxt(r io.Reader) ([]TodoTxtItem, error) {
+	items := []TodoTxtItem{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if line == "" {
+			continue
+		}
+		item := ParseTodoTxtLine(line)
+		item.Line = n
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTodoTxt, err)
+	}
+	return items, nil
+}
@@ -0,0 +1,80 @@
+package lib
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"tasks/db"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTodoTxt(t *testing.T) {
+	day := func(d int, h int) *time.Time {
+		t := time.Date(2023, 4, d, h, 0, 0, 0, time.UTC)
+		return &t
+	}
+	id := uuid.MustParse("6b7439e0-4def-446c-a64a-3bfe75b74907")
+
+	t.Run("parse", func(t *testing.T) {
+		tests := []struct {
+			line   string
+			want   db.Task
+			values map[string]string
+		}{
+			{
+				line: "(A) 2023-04-01 Call mum +Family @phone @home due:2023-04-05 see http://example.com",
+				want: db.Task{Title: "Call mum see http://example.com", Project: "Family", Status: db.StatusTodo, Priority: db.PriorityHigh,
+					Tags: []string{"phone", "home"}, Due: day(5, 0), CreatedAt: *day(1, 0)},
+			},
+			{
+				line: "x 2023-04-03 2023-04-01 Water plants pri:B rec:2w t:2023-04-02T09:00:00Z",
+				want: db.Task{Title: "Water plants", Status: db.StatusDone, Priority: db.PriorityMedium, Recurrence: "FREQ=WEEKLY;INTERVAL=2",
+					DeferUntil: day(2, 9), CreatedAt: *day(1, 0), CompletedAt: day(3, 0)},
+			},
+			{
+				line:   "(D) Meet at 10:30 +work +later status:in_progress due:soon points:3 uuid:" + id.String(),
+				want:   db.Task{ID: id, Title: "Meet at 10:30 +later", Project: "work", Status: db.StatusInProgress, Priority: db.PriorityLow},
+				values: map[string]string{"due": "soon", "points": "3"},
+			},
+			{
+				line: "x Plain done rec:b someday:true",
+				want: db.Task{Title: "Plain done", Status: db.StatusDone, Recurrence: "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", Someday: true},
+			},
+		}
+		for _, tt := range tests {
+			item := ParseTodoTxtLine(tt.line)
+			require.Equal(t, tt.want, item.Task, tt.line)
+			require.Equal(t, tt.values, item.Values, tt.line)
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		tasks := []db.Task{
+			{ID: uuid.New(), Title: "Call mum", Project: "Family visits", Status: db.StatusTodo, Priority: db.PriorityHigh,
+				Tags: []string{"phone"}, Due: day(5, 15), Recurrence: "FREQ=MONTHLY;BYMONTHDAY=1", CreatedAt: *day(1, 0),
+				Fields: map[string]interface{}{"points": 2.5, "room": "living room"}, Extensions: map[string]string{"h": "10:30"}},
+			{ID: uuid.New(), Title: "Pay +extra rent", Project: "home", Status: db.StatusDone, Priority: db.PriorityLow,
+				Recurrence: "FREQ=DAILY", CreatedAt: *day(1, 0), CompletedAt: day(3, 0)},
+			{ID: uuid.New(), Title: "Someday trip", Status: db.StatusInProgress, Someday: true, DeferUntil: day(9, 0), CreatedAt: *day(2, 0)},
+		}
+		var buf bytes.Buffer
+		require.NoError(t, WriteTodoTxt(&buf, tasks))
+		require.Contains(t, buf.String(), "(A) 2023-04-01 Call mum +Family%20visits @phone due:2023-04-05T15:00:00Z rec:FREQ=MONTHLY;BYMONTHDAY=1 points:2.5 room:living%20room h:10:30 uuid:")
+		require.Contains(t, buf.String(), "x 2023-04-03 2023-04-01 +home Pay +extra rent rec:d pri:C uuid:")
+
+		items, err := ParseTodoTxt(&buf)
+		require.NoError(t, err)
+		require.Len(t, items, len(tasks))
+		for i, item := range items {
+			want := tasks[i]
+			want.Fields, want.Extensions = nil, nil
+			require.Equal(t, i+1, item.Line)
+			require.Equal(t, want, item.Task)
+		}
+		require.Equal(t, map[string]string{"h": "10:30", "points": "2.5", "room": "living%20room"}, items[0].Values)
+		require.Equal(t, "living room", TodoTxtUnescape(items[0].Values["room"]))
+	})
+}
@@ -0,0 +1,177 @@
+package lib
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"tasks/db"
+)
+
+// trelloExport is the part of a Trello board export that is imported.
+type trelloExport struct {
+	Name  string `json:"name"`
+	Lists []struct {
+		ID     string  `json:"id"`
+		Name   string  `json:"name"`
+		Closed bool    `json:"closed"`
+		Pos    float64 `json:"pos"`
+	} `json:"lists"`
+	Labels []struct {
+		ID    string `json:"id"`
+		Name  string `json:"name"`
+		Color string `json:"color"`
+	} `json:"labels"`
+	Cards *[]struct {
+		ID          string   `json:"id"`
+		Name        string   `json:"name"`
+		Desc        string   `json:"desc"`
+		IDList      string   `json:"idList"`
+		IDLabels    []string `json:"idLabels"`
+		Due         string   `json:"due"`
+		DueComplete bool     `json:"dueComplete"`
+		Closed      bool     `json:"closed"`
+		Pos         float64  `json:"pos"`
+	} `json:"cards"`
+	Checklists []struct {
+		IDCard     string  `json:"idCard"`
+		Pos        float64 `json:"pos"`
+		CheckItems []struct {
+			ID    string  `json:"id"`
+			Name  string  `json:"name"`
+			State string  `json:"state"`
+			Pos   float64 `json:"pos"`
+		} `json:"checkItems"`
+	} `json:"checklists"`
+	Actions []struct {
+		Type string `json:"type"`
+		Date string `json:"date"`
+		Data struct {
+			Text string `json:"text"`
+			Card struct {
+				ID string `json:"id"`
+			} `json:"card"`
+		} `json:"data"`
+		MemberCreator struct {
+			FullName string `json:"fullName"`
+		} `json:"memberCreator"`
+	} `json:"actions"`
+}
+
+// trelloIDTime returns the creation time encoded in the first four bytes of
+// a Trello ID, or the zero time.
+func trelloIDTime(id string) time.Time {
+	if len(id) < 8 {
+		return time.Time{}
+	}
+	seconds, err := strconv.ParseInt(id[:8], 16, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0).UTC()
+}
+
+// ParseTrelloExport reads the JSON export of a Trello board. The board
+// becomes the project; the list of a card becomes a tag and, for lists such
+// as "Doing" or "Done", its status. Comments and checklists are kept in
+// their order. Archived cards and the cards of archived lists are skipped.
+func ParseTrelloExport(r io.Reader, now time.Time) (*ImportedData, error) {
+	var export trelloExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	if export.Cards == nil || export.Lists == nil {
+		return nil, fmt.Errorf("%w: not a Trello board export, cards or lists are missing", ErrInvalidImport)
+	}
+
+	data := &ImportedData{Projects: []string{export.Name}, Tasks: []ImportedTask{}, Skipped: []ImportProblem{}}
+	lists := map[string]int{}
+	sort.SliceStable(export.Lists, func(i, j int) bool { return export.Lists[i].Pos < export.Lists[j].Pos })
+	for i, l := range export.Lists {
+		lists[l.ID] = i
+	}
+	labels := map[string]string{}
+	for _, l := range export.Labels {
+		name := l.Name
+		if name == "" {
+			name = l.Color
+		}
+		labels[l.ID] = ImportTag(name)
+	}
+
+	sort.SliceStable(export.Checklists, func(i, j int) bool { return export.Checklists[i].Pos < export.Checklists[j].Pos })
+	checklists := map[string][]ImportedItem{}
+	for _, c := range export.Checklists {
+		sort.SliceStable(c.CheckItems, func(i, j int) bool { return c.CheckItems[i].Pos < c.CheckItems[j].Pos })
+		for _, item := range c.CheckItems {
+			checklists[c.IDCard] = append(checklists[c.IDCard], ImportedItem{SourceID: item.ID, Text: item.Name, Done: item.State == "complete"})
+		}
+	}
+
+	comments := map[string][]ImportedComment{}
+	for _, a := range export.Actions {
+		if a.Type != "commentCard" {
+			continue
+		}
+		posted, err := parseImportTime(a.Date)
+		if err != nil {
+			return nil, err
+		}
+		comments[a.Data.Card.ID] = append(comments[a.Data.Card.ID], ImportedComment{Author: a.MemberCreator.FullName, Text: a.Data.Text, Time: posted})
+	}
+
+	cards := *export.Cards
+	sort.SliceStable(cards, func(i, j int) bool {
+		li, lj := lists[cards[i].IDList], lists[cards[j].IDList]
+		if li != lj {
+			return li < lj
+		}
+		return cards[i].Pos < cards[j].Pos
+	})
+	for _, card := range cards {
+		list, ok := lists[card.IDList]
+		switch {
+		case card.Closed:
+			data.Skipped = append(data.Skipped, ImportProblem{SourceID: card.ID, Title: card.Name, Reason: "archived"})
+			continue
+		case ok && export.Lists[list].Closed:
+			data.Skipped = append(data.Skipped, ImportProblem{SourceID: card.ID, Title: card.Name, Reason: "in an archived list"})
+			continue
+		}
+
+		t := ImportedTask{
+			SourceID:    card.ID,
+			Title:       card.Name,
+			Description: card.Desc,
+			Comments:    comments[card.ID],
+			Project:     export.Name,
+			Status:      db.StatusTodo,
+			CreatedAt:   trelloIDTime(card.ID),
+			Checklist:   checklists[card.ID],
+		}
+		sort.SliceStable(t.Comments, func(i, j int) bool { return t.Comments[i].Time.Before(t.Comments[j].Time) })
+		if ok {
+			t.Tags = append(t.Tags, ImportTag(export.Lists[list].Name))
+			t.Status = importStatus(export.Lists[list].Name)
+		}
+		for _, id := range card.IDLabels {
+			if label, ok := labels[id]; ok && label != "" {
+				t.Tags = append(t.Tags, label)
+			}
+		}
+		if card.DueComplete {
+			t.Status = db.StatusDone
+		}
+		if card.Due != "" {
+			due, err := parseImportTime(card.Due)
+			if err != nil {
+				return nil, err
+			}
+			t.Due = &due
+		}
+		data.Tasks = append(data.Tasks, t)
+	}
+	return data, nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"tasks/db"
@@ -27,6 +28,10 @@ func main() {
 
 	s := service.NewTask(sqldb)
 
+	ctx, cancel := context.WithCancel(l.WithContext(context.Background()))
+	defer cancel()
+	go s.RunResurfacer(ctx, config.ResurfaceInterval)
+
 	r, err := server.NewChiRouter(s, config.PASETOSecret, config.AccessTokenDuration, &l)
 	if err != nil {
 		l.Fatal().Err(err).Send()
@@ -1,24 +1,64 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"time"
 
+	"tasks/lib"
+
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/rs/zerolog"
 )
 
+type contextKey struct{}
+
+type basicUserKey struct{}
+
+var payloadKey = contextKey{}
+
+// PayloadFromContext returns the verified PASETO payload stored by
+// AuthMiddleware, or nil if the request was not authenticated.
+func PayloadFromContext(ctx context.Context) *PasetoPayload {
+	payload, _ := ctx.Value(payloadKey).(*PasetoPayload)
+	return payload
+}
+
+// UsernameFromContext returns the name of the authenticated user, or an
+// empty string if the request was not authenticated.
+func UsernameFromContext(ctx context.Context) string {
+	if payload := PayloadFromContext(ctx); payload != nil {
+		return payload.Username
+	}
+	username, _ := ctx.Value(basicUserKey{}).(string)
+	return username
+}
+
 type TokenManager interface {
 	CreateToken(username string, duration time.Duration) (string, *PasetoPayload, error)
 	VerifyToken(token string) (*PasetoPayload, error)
 }
 
+// writeProblem refuses r with problem details of the generic type of the
+// status.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	lib.WriteProblem(w, lib.Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: middleware.GetReqID(r.Context()),
+	})
+}
+
 func AuthMiddleware(t TokenManager, l *zerolog.Logger) func(http.Handler) http.Handler {
 	f := func(h http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
 			tokenCookie, err := r.Cookie("paseto")
 			if err != nil {
-				http.Error(w, "paseto auth cookie not set", http.StatusUnauthorized)
+				writeProblem(w, r, http.StatusUnauthorized, "paseto auth cookie not set")
 				l.Error().Err(err).Msgf("authentication cookie is not set!")
 				return
 			}
@@ -27,20 +67,46 @@ func AuthMiddleware(t TokenManager, l *zerolog.Logger) func(http.Handler) http.H
 			if err != nil {
 				if errors.Is(err, ErrTokenInvalid) {
 					l.Error().Err(err).Msgf("PASETO is invalid!")
-					http.Error(w, "invalid token", http.StatusForbidden)
+					writeProblem(w, r, http.StatusForbidden, "invalid token")
 					return
 				}
 				if errors.Is(err, ErrTokenExpired) {
 					l.Error().Err(err).Msgf("PASETO has expired")
-					http.Error(w, "token has expired", http.StatusUnauthorized)
+					writeProblem(w, r, http.StatusUnauthorized, "token has expired")
 					return
 				}
+				l.Error().Err(err).Msgf("PASETO could not be verified")
+				writeProblem(w, r, http.StatusUnauthorized, "missing token")
+				return
 			}
 
 			l.Info().Msgf("User %s is authorized! tokenID: %v, issuedAt: %v, expiresAt: %v", payload.Username, payload.ID, payload.IssuedAt, payload.ExpiresAt)
-			h.ServeHTTP(w, r)
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), payloadKey, payload)))
 		}
 		return http.HandlerFunc(fn)
 	}
 	return f
 }
+
+// BasicAuthMiddleware authenticates requests with HTTP Basic credentials
+// checked by verify, for clients such as CalDAV ones that cannot log in to
+// get a token cookie.
+func BasicAuthMiddleware(realm string, verify func(ctx context.Context, username string, password string) error, l *zerolog.Logger) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			username, password, ok := r.BasicAuth()
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Basic realm="`+realm+`", charset="UTF-8"`)
+				http.Error(w, "authentication required", http.StatusUnauthorized)
+				return
+			}
+			if err := verify(r.Context(), username, password); err != nil {
+				l.Error().Err(err).Msgf("basic authentication failed for %s", username)
+				w.Header().Set("WWW-Authenticate", `Basic realm="`+realm+`", charset="UTF-8"`)
+				http.Error(w, "invalid credentials", http.StatusUnauthorized)
+				return
+			}
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), basicUserKey{}, username)))
+		})
+	}
+}
@@ -27,8 +27,7 @@ type PasetoPayload struct {
 func NewPasetoPayload(username string, tokenDuration time.Duration) (*PasetoPayload, error) {
 	tokenID, err := uuid.NewRandom()
 	if err != nil {
-		fmt.Errorf("Could not generate a random token ID! %v", err)
-		return nil, err
+		return nil, fmt.Errorf("could not generate a random token ID! %v", err)
 	}
 
 	payload := &PasetoPayload{
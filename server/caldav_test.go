@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tasks/db"
+
+	"github.com/stretchr/testify/require"
+)
+
+type davClient struct {
+	t        *testing.T
+	url      string
+	username string
+	password string
+}
+
+type davResult struct {
+	status int
+	header http.Header
+	body   string
+}
+
+func (c *davClient) do(method string, path string, body string, header ...string) davResult {
+	req, err := http.NewRequest(method, c.url+path, strings.NewReader(body))
+	require.NoError(c.t, err)
+	req.SetBasicAuth(c.username, c.password)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(c.t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(c.t, err)
+	return davResult{status: resp.StatusCode, header: resp.Header, body: string(data)}
+}
+
+// multistatus is the part of a 207 response the client looks at.
+type multistatus struct {
+	Responses []struct {
+		Href      string `xml:"href"`
+		Status    string `xml:"status"`
+		Propstats []struct {
+			Status string `xml:"status"`
+			Prop   struct {
+				ETag     string `xml:"getetag"`
+				Data     string `xml:"calendar-data"`
+				CTag     string `xml:"getctag"`
+				HomeSet  string `xml:"calendar-home-set>href"`
+				Resource struct {
+					Calendar *struct{} `xml:"calendar"`
+				} `xml:"resourcetype"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+	SyncToken string `xml:"sync-token"`
+}
+
+func (r davResult) multistatus(t *testing.T) multistatus {
+	require.Equal(t, http.StatusMultiStatus, r.status, r.body)
+	var ms multistatus
+	require.NoError(t, xml.Unmarshal([]byte(r.body), &ms))
+	return ms
+}
+
+func vtodo(uid string, summary string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\nBEGIN:VTODO\r\nUID:" + uid +
+		"\r\nSUMMARY:" + summary + "\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"
+}
+
+// TestCalDAV drives the CalDAV endpoints the way a synchronising client
+// does: discovery, an initial sync, local edits and an incremental sync.
+func TestCalDAV(t *testing.T) {
+	s, _ := newTestService(t, "alice")
+	_, err := s.CreateTask(context.Background(), &db.Task{Title: "Pack bag", User: "alice", Project: "home"})
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(newTestRouter(t, s))
+	defer srv.Close()
+	c := &davClient{t: t, url: srv.URL, username: "alice", password: testPassword}
+	calendar := "/dav/calendars/alice/p-home/"
+
+	t.Run("authentication", func(t *testing.T) {
+		res := (&davClient{t: t, url: srv.URL, username: "alice", password: "wrong"}).do("PROPFIND", "/dav/", "", "Depth", "0")
+		require.Equal(t, http.StatusUnauthorized, res.status)
+		require.Contains(t, res.header.Get("WWW-Authenticate"), "Basic")
+
+		res = c.do("PROPFIND", "/dav/calendars/bob/", "", "Depth", "1")
+		require.Equal(t, http.StatusNotFound, res.status)
+	})
+
+	t.Run("discovery", func(t *testing.T) {
+		res := c.do("OPTIONS", "/dav/", "")
+		require.Equal(t, http.StatusOK, res.status)
+		require.Contains(t, res.header.Get("DAV"), "calendar-access")
+
+		ms := c.do("PROPFIND", "/dav/principals/alice/", `<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop><c:calendar-home-set/></d:prop></d:propfind>`, "Depth", "0").multistatus(t)
+		require.Len(t, ms.Responses, 1)
+		require.Equal(t, "/dav/calendars/alice/", ms.Responses[0].Propstats[0].Prop.HomeSet)
+
+		ms = c.do("PROPFIND", "/dav/calendars/alice/", "", "Depth", "1").multistatus(t)
+		calendars := []string{}
+		for _, resp := range ms.Responses {
+			if resp.Propstats[0].Prop.Resource.Calendar != nil {
+				calendars = append(calendars, resp.Href)
+			}
+		}
+		require.Equal(t, []string{"/dav/calendars/alice/inbox/", calendar}, calendars)
+	})
+
+	var token string
+	var etag string
+	t.Run("initial sync", func(t *testing.T) {
+		ms := c.do("REPORT", calendar, `<d:sync-collection xmlns:d="DAV:"><d:sync-token/><d:prop><d:getetag/></d:prop></d:sync-collection>`).multistatus(t)
+		require.Len(t, ms.Responses, 1)
+		require.NotEmpty(t, ms.SyncToken)
+		token = ms.SyncToken
+
+		res := c.do("PUT", calendar+"walk.ics", vtodo("walk", "Walk the dog"), "If-None-Match", "*")
+		require.Equal(t, http.StatusCreated, res.status, res.body)
+		etag = res.header.Get("ETag")
+		require.NotEmpty(t, etag)
+
+		res = c.do("PUT", calendar+"walk.ics", vtodo("walk", "Walk the cat"), "If-None-Match", "*")
+		require.Equal(t, http.StatusPreconditionFailed, res.status)
+
+		res = c.do("PUT", calendar+"other.ics", vtodo("walk", "Walk the cat"))
+		require.Equal(t, http.StatusBadRequest, res.status)
+
+		res = c.do("GET", calendar+"walk.ics", "")
+		require.Equal(t, http.StatusOK, res.status)
+		require.Equal(t, etag, res.header.Get("ETag"))
+		require.Contains(t, res.body, "SUMMARY:Walk the dog")
+	})
+
+	t.Run("edits", func(t *testing.T) {
+		res := c.do("PUT", calendar+"walk.ics", vtodo("walk", "Walk the cat"), "If-Match", `"stale"`)
+		require.Equal(t, http.StatusPreconditionFailed, res.status)
+
+		res = c.do("PUT", calendar+"walk.ics", vtodo("walk", "Walk the cat"), "If-Match", etag)
+		require.Equal(t, http.StatusNoContent, res.status, res.body)
+		require.NotEqual(t, etag, res.header.Get("ETag"))
+		etag = res.header.Get("ETag")
+
+		ms := c.do("REPORT", calendar, `<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop><d:getetag/><c:calendar-data/></d:prop><d:href>`+calendar+`walk.ics</d:href><d:href>`+calendar+`missing.ics</d:href></c:calendar-multiget>`).multistatus(t)
+		require.Len(t, ms.Responses, 2)
+		require.Equal(t, etag, ms.Responses[0].Propstats[0].Prop.ETag)
+		require.Contains(t, ms.Responses[0].Propstats[0].Prop.Data, "SUMMARY:Walk the cat")
+		require.Contains(t, ms.Responses[1].Status, "404")
+
+		tasks, err := s.GetAllTasksFromUser(context.Background(), "alice")
+		require.NoError(t, err)
+		require.Len(t, tasks, 2)
+	})
+
+	t.Run("incremental sync", func(t *testing.T) {
+		ms := c.do("REPORT", calendar, `<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop><d:getetag/></d:prop><c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VTODO"/></c:comp-filter></c:filter></c:calendar-query>`).multistatus(t)
+		require.Len(t, ms.Responses, 2)
+		pack := ms.Responses[0].Href
+		if pack == calendar+"walk.ics" {
+			pack = ms.Responses[1].Href
+		}
+
+		res := c.do("DELETE", pack, "", "If-Match", `"stale"`)
+		require.Equal(t, http.StatusPreconditionFailed, res.status)
+		res = c.do("DELETE", pack, "")
+		require.Equal(t, http.StatusNoContent, res.status)
+
+		ms = c.do("REPORT", calendar, `<d:sync-collection xmlns:d="DAV:"><d:sync-token>`+token+`</d:sync-token><d:prop><d:getetag/></d:prop></d:sync-collection>`).multistatus(t)
+		require.NotEqual(t, token, ms.SyncToken)
+		statuses := map[string]string{}
+		for _, resp := range ms.Responses {
+			statuses[resp.Href] = resp.Status
+		}
+		require.Len(t, statuses, 2)
+		require.Contains(t, statuses[pack], "404")
+		require.Empty(t, statuses[calendar+"walk.ics"])
+
+		res = c.do("REPORT", calendar, `<d:sync-collection xmlns:d="DAV:"><d:sync-token>urn:tasks:sync:999</d:sync-token><d:prop><d:getetag/></d:prop></d:sync-collection>`)
+		require.Equal(t, http.StatusForbidden, res.status)
+		require.Contains(t, res.body, "valid-sync-token")
+	})
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tasks/db"
+	"tasks/server/handlers"
+	"tasks/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestChecklistOwner checks that only the owner of a note changes its
+// checklist, and that converting an item is audited with the checklist
+// before and after.
+func TestChecklistOwner(t *testing.T) {
+	s, d := newTestService(t, "alice", "bob")
+	ctx := context.Background()
+	id, err := s.CreateTask(ctx, &db.Task{Title: "Pack bag", User: "alice"})
+	require.NoError(t, err)
+	for _, text := range []string{"Passport", "Charger"} {
+		_, err = s.AddChecklistItem(ctx, "alice", id, text, -1)
+		require.NoError(t, err)
+	}
+	task, err := d.GetTask(id.String())
+	require.NoError(t, err)
+	itemID := task.Checklist[0].ID
+
+	srv := httptest.NewServer(newTestRouter(t, s))
+	defer srv.Close()
+	do := func(t *testing.T, client *http.Client, method string, path string, body string) *http.Response {
+		req, err := http.NewRequest(method, srv.URL+handlers.APIv1Root+"/notes/"+id.String()+"/checklist"+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	t.Run("other user", func(t *testing.T) {
+		bob := login(t, srv.URL, "bob")
+		item := "/" + itemID.String()
+		for _, tt := range []struct{ method, path, body string }{
+			{http.MethodPost, "", `{"text": "Knife"}`},
+			{http.MethodPost, item + "/move", `{"position": 1}`},
+			{http.MethodPost, item + "/check", ""},
+			{http.MethodDelete, item, ""},
+			{http.MethodPost, item + "/convert", ""},
+		} {
+			resp := do(t, bob, tt.method, tt.path, tt.body)
+			resp.Body.Close()
+			require.Equal(t, http.StatusNotFound, resp.StatusCode, "%s %s", tt.method, tt.path)
+		}
+		after, err := d.GetTask(id.String())
+		require.NoError(t, err)
+		require.Equal(t, task.Checklist, after.Checklist)
+		tasks, err := d.GetAllTasksFromUser(ctx, "bob")
+		require.NoError(t, err)
+		require.Empty(t, tasks)
+	})
+
+	t.Run("convert", func(t *testing.T) {
+		from := time.Now()
+		resp := do(t, login(t, srv.URL, "alice"), http.MethodPost, "/"+itemID.String()+"/convert", "")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		var created db.Task
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+		require.Equal(t, "alice", created.User)
+		require.Equal(t, "Passport", created.Title)
+
+		events, err := d.GetAuditEvents("alice", from, time.Now().Add(time.Second))
+		require.NoError(t, err)
+		var update *db.AuditEvent
+		for i := range events {
+			if events[i].Action == service.AuditTaskUpdate && events[i].Target == id.String() {
+				update = &events[i]
+			}
+		}
+		require.NotNil(t, update)
+		require.Equal(t, "0/2", update.Before.Checklist)
+		require.Equal(t, "0/1", update.After.Checklist)
+	})
+}
@@ -1,6 +1,8 @@
 package server
 
 import (
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -13,14 +15,35 @@ import (
 	handlers "tasks/server/handlers"
 )
 
+func init() {
+	// Methods of WebDAV and CalDAV, served under handlers.DAVRoot.
+	for _, method := range []string{"PROPFIND", "PROPPATCH", "REPORT", "MKCOL", "MKCALENDAR", "COPY", "MOVE", "LOCK", "UNLOCK"} {
+		chi.RegisterMethod(method)
+	}
+}
+
+// redirectSlashes applies middleware.RedirectSlashes except to the CalDAV
+// tree, where collection paths end in a slash.
+func redirectSlashes(next http.Handler) http.Handler {
+	redirect := middleware.RedirectSlashes(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, handlers.DAVRoot) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		redirect.ServeHTTP(w, r)
+	})
+}
+
 func registerChiMiddlewares(r *chi.Mux, l *zerolog.Logger) {
 	// Request logger has middleware.Recoverer and RequestID baked into it.
 	r.Use(httplog.RequestLogger(*l),
+		handlers.AuditRequest,
 		middleware.Heartbeat("/ping"),
-		middleware.RedirectSlashes,
+		redirectSlashes,
 		cors.Handler(cors.Options{
 			AllowedOrigins:   []string{"http://localhost:3000"},
-			AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "Bearer", "Set-Cookie", "X-Powered-By", "X-Content-Type-Options"},
 			ExposedHeaders:   []string{"Link", "Access-Control-Expose-Headers"},
 			AllowCredentials: true,
@@ -28,16 +51,123 @@ func registerChiMiddlewares(r *chi.Mux, l *zerolog.Logger) {
 		}))
 }
 
+// Dates announced for the paths of version 1 of the API outside of
+// handlers.APIv1Root, which predate it.
+var (
+	rootAliasDeprecation = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	rootAliasSunset      = time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC)
+)
+
 func registerChiHandlers(r *chi.Mux, s handlers.TaskService, t auth.TokenManager, tokenDuration time.Duration, l *zerolog.Logger) {
+	// Calendar clients are configured with these URLs, so they stay outside
+	// of the versions of the API.
+	r.HandleFunc("/.well-known/caldav", handlers.RedirectToCalDAV)
+	r.Get(handlers.FeedRoot+"{token}.ics", handlers.CalendarFeed(s))
+	r.Route(strings.TrimSuffix(handlers.DAVRoot, "/"), func(r chi.Router) {
+		r.Use(auth.BasicAuthMiddleware("tasks", handlers.VerifyPassword(s), l), handlers.AuditActor)
+		r.Handle("/*", handlers.CalDAV(s))
+	})
+	// The GraphQL schema evolves by adding fields and deprecating old ones
+	// rather than by versions.
+	r.Route("/graphql", func(r chi.Router) {
+		r.Get("/schema", handlers.GetGraphQLSchema)
+		r.With(auth.AuthMiddleware(t, l), handlers.AuditActor).Post("/", handlers.GraphQL(s))
+	})
+
+	// Each version of the API is a router of its own mounted under its
+	// root. A new version registers the handlers it keeps from the previous
+	// one next to its own, so both are served side by side.
+	v1 := chi.NewRouter()
+	registerAPIv1(v1, s, t, tokenDuration, l)
+	r.Mount(handlers.APIv1Root, v1)
+	r.With(handlers.DeprecatedAlias(v1, handlers.APIv1Root, rootAliasDeprecation, rootAliasSunset)).Mount("/", v1)
+}
+
+// registerAPIv1 registers the routes of version 1 of the API.
+func registerAPIv1(r chi.Router, s handlers.TaskService, t auth.TokenManager, tokenDuration time.Duration, l *zerolog.Logger) {
 	r.Post("/register", handlers.RegisterUser(s))
 	r.Post("/login", handlers.LoginUser(s, t, tokenDuration))
-	r.Post("/logout", handlers.LogoutUser())
+	r.Post("/logout", handlers.LogoutUser(s, t))
+	r.Get("/openapi.json", handlers.OpenAPI)
+	r.Get("/docs", handlers.APIDocs)
 	r.Route("/notes", func(r chi.Router) {
-		r.Use(auth.AuthMiddleware(t, l))
+		r.Use(auth.AuthMiddleware(t, l), handlers.AuditActor)
 		r.Post("/create", handlers.CreateTask(s))
 		r.Get("/", handlers.GetAllTasksFromUser(s))
+		r.Post("/bulk", handlers.BulkTasks(s))
+		r.Post("/quick", handlers.QuickAddTask(s))
+		r.Get("/search", handlers.SearchTasks(s))
+		r.Get("/deferred", handlers.GetDeferredTasks(s))
+		r.Get("/someday", handlers.GetSomedayTasks(s))
+		r.Get("/ical", handlers.ExportICal(s))
+		r.Post("/ical", handlers.ImportICal(s))
+		r.Get("/csv", handlers.ExportCSV(s))
+		r.Post("/csv", handlers.ImportCSV(s))
+		r.Get("/todotxt", handlers.ExportTodoTxt(s))
+		r.Post("/todotxt", handlers.ImportTodoTxt(s))
+		r.Get("/markdown", handlers.ExportMarkdown(s))
+		r.Post("/markdown", handlers.ImportMarkdown(s))
+		r.Post("/import/{source}", handlers.ImportFrom(s))
 		r.Put("/{id}", handlers.UpdateTask(s))
+		r.Patch("/{id}", handlers.PatchTask(s))
 		r.Delete("/{id}", handlers.DeleteTask(s))
+		r.Post("/{id}/move", handlers.MoveTask(s))
+		r.Put("/{id}/fields", handlers.SetTaskFields(s))
+		r.Post("/{id}/snooze", handlers.SnoozeTask(s))
+		r.Delete("/{id}/snooze", handlers.UnsnoozeTask(s))
+		r.Route("/{id}/checklist", func(r chi.Router) {
+			r.Post("/", handlers.AddChecklistItem(s))
+			r.Delete("/{itemID}", handlers.DeleteChecklistItem(s))
+			r.Post("/{itemID}/move", handlers.MoveChecklistItem(s))
+			r.Post("/{itemID}/check", handlers.SetChecklistItemDone(s, true))
+			r.Post("/{itemID}/uncheck", handlers.SetChecklistItemDone(s, false))
+			r.Post("/{itemID}/convert", handlers.ConvertChecklistItem(s))
+		})
+		r.Post("/{id}/timer", handlers.StartTimer(s))
+		r.Get("/{id}/time", handlers.GetTimeEntries(s))
+		r.Post("/{id}/time", handlers.AddTimeEntry(s))
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(auth.AuthMiddleware(t, l), handlers.AuditActor)
+		r.Get("/timer", handlers.GetRunningTimer(s))
+		r.Post("/timer/stop", handlers.StopTimer(s))
+		r.Delete("/time/{entryID}", handlers.DeleteTimeEntry(s))
+		r.Get("/timesheet", handlers.GetTimesheet(s))
+		r.Get("/audit", handlers.GetAuditEvents(s))
+		r.Get("/stats", handlers.GetStats(s))
+	})
+	r.Route("/templates", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware(t, l), handlers.AuditActor)
+		r.Post("/", handlers.CreateTemplate(s))
+		r.Get("/", handlers.GetTemplates(s))
+		r.Get("/{id}", handlers.GetTemplate(s))
+		r.Put("/{id}", handlers.UpdateTemplate(s))
+		r.Delete("/{id}", handlers.DeleteTemplate(s))
+		r.Post("/{id}/instantiate", handlers.InstantiateTemplate(s))
+	})
+	r.Route("/projects/{project}/fields", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware(t, l), handlers.AuditActor)
+		r.Post("/", handlers.CreateFieldDefinition(s))
+		r.Get("/", handlers.GetFieldDefinitions(s))
+		r.Put("/{key}", handlers.UpdateFieldDefinition(s))
+		r.Delete("/{key}", handlers.DeleteFieldDefinition(s))
+	})
+	r.Route("/account", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware(t, l), handlers.AuditActor)
+		r.Get("/export", handlers.ExportAccount(s))
+		r.Delete("/", handlers.DeleteAccount(s))
+		r.Post("/feed", handlers.CreateFeedToken(s))
+		r.Get("/feed", handlers.GetFeedToken(s))
+		r.Delete("/feed", handlers.DeleteFeedToken(s))
+	})
+	r.Route("/filters", func(r chi.Router) {
+		r.Use(auth.AuthMiddleware(t, l), handlers.AuditActor)
+		r.Post("/", handlers.CreateFilter(s))
+		r.Get("/", handlers.GetFilters(s))
+		r.Get("/{id}", handlers.GetFilter(s))
+		r.Put("/{id}", handlers.UpdateFilter(s))
+		r.Delete("/{id}", handlers.DeleteFilter(s))
+		r.Get("/{id}/tasks", handlers.RunFilter(s))
 	})
 }
 
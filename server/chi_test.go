@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tasks/db"
+	"tasks/lib"
+	"tasks/server/handlers"
+	"tasks/service"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testPassword is the password of the users registered by newTestService.
+const testPassword = "secret123"
+
+// newTestService returns the service over an empty database, with the users
+// registered. The database is closed when the test ends.
+func newTestService(t *testing.T, usernames ...string) (handlers.TaskService, *db.DB) {
+	l := lib.NewLogger("error")
+	d, err := db.NewSQL(filepath.Join(t.TempDir(), "tasks.db"), &l)
+	require.NoError(t, err)
+	t.Cleanup(d.Close)
+	s := service.NewTask(d)
+	password, err := lib.Hash(testPassword)
+	require.NoError(t, err)
+	for _, name := range usernames {
+		_, err = s.RegisterUser(context.Background(), &db.User{Username: name, Password: password, Email: name + "@example.com"})
+		require.NoError(t, err)
+	}
+	return s, d
+}
+
+// newTestRouter returns the router serving s.
+func newTestRouter(t *testing.T, s handlers.TaskService) http.Handler {
+	l := lib.NewLogger("error")
+	r, err := NewChiRouter(s, "12345678901234567890123456789012", 3600, &l)
+	require.NoError(t, err)
+	return r
+}
+
+// login signs the user in on the server at url and returns a client that
+// sends the session cookie.
+func login(t *testing.T, url string, username string) *http.Client {
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{Jar: jar}
+	resp, err := client.Post(url+handlers.APIv1Root+"/login", "application/json", strings.NewReader(`{"username": "`+username+`", "password": "`+testPassword+`"}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	return client
+}
+
+func TestAPIVersions(t *testing.T) {
+	r := newTestRouter(t, nil)
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	t.Run("versioned", func(t *testing.T) {
+		rec := get(handlers.APIv1Root + "/docs")
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Empty(t, rec.Header().Get("Deprecation"))
+
+		rec = get(handlers.APIv1Root + "/notes")
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("deprecated alias", func(t *testing.T) {
+		rec := get("/docs")
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Equal(t, "@1792368000", rec.Header().Get("Deprecation"))
+		require.Equal(t, "Mon, 19 Apr 2027 00:00:00 GMT", rec.Header().Get("Sunset"))
+		require.Equal(t, `<`+handlers.APIv1Root+`/docs>; rel="successor-version"`, rec.Header().Get("Link"))
+
+		rec = get("/notes")
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+		require.NotEmpty(t, rec.Header().Get("Deprecation"))
+	})
+
+	t.Run("unversioned", func(t *testing.T) {
+		for _, path := range []string{"/missing", "/.well-known/caldav", handlers.FeedRoot + "x.ics"} {
+			rec := get(path)
+			require.NotEqual(t, http.StatusOK, rec.Code, path)
+			require.Empty(t, rec.Header().Get("Deprecation"), path)
+		}
+		require.Equal(t, http.StatusNotFound, get(handlers.APIv1Root+"/api/v1/docs").Code)
+	})
+}
+
+func TestCORSPreflight(t *testing.T) {
+	r := newTestRouter(t, nil)
+
+	for _, method := range []string{http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		req := httptest.NewRequest(http.MethodOptions, handlers.APIv1Root+"/notes/3f2c4d5e-0000-4000-8000-000000000000", nil)
+		req.Header.Set("Origin", "http://localhost:3000")
+		req.Header.Set("Access-Control-Request-Method", method)
+		req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		require.Less(t, rec.Code, 300, method)
+		require.Equal(t, "http://localhost:3000", rec.Header().Get("Access-Control-Allow-Origin"), method)
+		require.Equal(t, method, rec.Header().Get("Access-Control-Allow-Methods"), method)
+	}
+}
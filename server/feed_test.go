@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tasks/db"
+	"tasks/server/handlers"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCalendarFeedRevalidation checks that a poller of the feed sees tasks
+// leave it.
+func TestCalendarFeedRevalidation(t *testing.T) {
+	s, _ := newTestService(t, "alice")
+	ctx := context.Background()
+	due := time.Date(2026, time.November, 2, 9, 0, 0, 0, time.UTC)
+	var ids []uuid.UUID
+	for _, title := range []string{"Pay rent", "Renew passport"} {
+		id, err := s.CreateTask(ctx, &db.Task{Title: title, User: "alice", Due: &due})
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	secret, _, err := s.CreateFeedToken(ctx, "alice")
+	require.NoError(t, err)
+
+	r := newTestRouter(t, s)
+	poll := func(header string, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, handlers.FeedRoot+secret+".ics", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := poll("", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	modified := rec.Header().Get("Last-Modified")
+	require.NotEmpty(t, modified)
+	etag := rec.Header().Get("ETag")
+	require.Equal(t, http.StatusNotModified, poll("If-None-Match", etag).Code)
+	require.Equal(t, http.StatusNotModified, poll("If-Modified-Since", modified).Code)
+
+	// The task updated last stays in the feed, so its newest change does
+	// not advance, while the time of the deletion does. Last-Modified has a
+	// resolution of seconds.
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+	_, err = s.DeleteTask(ctx, "alice", ids[0])
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, poll("If-None-Match", etag).Code)
+	rec = poll("If-Modified-Since", modified)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotContains(t, rec.Body.String(), "Pay rent")
+}
@@ -0,0 +1,173 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tasks/db"
+	"tasks/lib"
+	"tasks/server/handlers"
+	"tasks/service"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// countingService counts the reads of all tasks of a user.
+type countingService struct {
+	handlers.TaskService
+	reads int
+}
+
+func (s *countingService) GetAllTasksFromUser(ctx context.Context, username string) ([]db.Task, error) {
+	s.reads++
+	return s.TaskService.GetAllTasksFromUser(ctx, username)
+}
+
+// TestGraphQL checks queries and mutations of the GraphQL endpoint over
+// the notes of the signed in user.
+func TestGraphQL(t *testing.T) {
+	base, d := newTestService(t, "alice", "bob")
+	s := &countingService{TaskService: base}
+	ctx := context.Background()
+	var ids []uuid.UUID
+	for _, title := range []string{"Pack bag", "Book hotel", "Rent car"} {
+		id, err := s.CreateTask(ctx, &db.Task{Title: title, User: "alice", Project: "trip"})
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+	for i := range ids {
+		now := time.Now()
+		_, err := d.CreateTask(&db.Task{ID: uuid.New(), Title: "Check twice", User: "alice", ParentID: &ids[i], CreatedAt: now, UpdatedAt: now})
+		require.NoError(t, err)
+	}
+	start := time.Date(2026, time.October, 1, 9, 0, 0, 0, time.UTC)
+	_, err := s.AddTimeEntry(ctx, "alice", ids[0], start, start.Add(time.Hour), "")
+	require.NoError(t, err)
+	other, err := s.CreateTask(ctx, &db.Task{Title: "Water plants", User: "bob"})
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(newTestRouter(t, s))
+	defer srv.Close()
+	client := login(t, srv.URL, "alice")
+
+	type response struct {
+		Data   map[string]interface{}
+		Errors []lib.GraphQLError
+	}
+	query := func(t *testing.T, query string, variables map[string]interface{}) response {
+		body, err := json.Marshal(lib.GraphQLRequest{Query: query, Variables: variables})
+		require.NoError(t, err)
+		resp, err := client.Post(srv.URL+"/graphql", "application/json", strings.NewReader(string(body)))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var v response
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&v))
+		return v
+	}
+
+	t.Run("query", func(t *testing.T) {
+		s.reads = 0
+		v := query(t, `{
+			me { username }
+			project(name: "trip") {
+				tasks(filter: "status:todo", first: 2) {
+					totalCount
+					nodes { title subtasks { title parent { title } } timeEntries { seconds } }
+					pageInfo { hasNextPage endCursor }
+				}
+			}
+		}`, nil)
+		require.Empty(t, v.Errors)
+		require.Equal(t, "alice", v.Data["me"].(map[string]interface{})["username"])
+		tasks := v.Data["project"].(map[string]interface{})["tasks"].(map[string]interface{})
+		require.EqualValues(t, 3, tasks["totalCount"])
+		nodes := tasks["nodes"].([]interface{})
+		require.Len(t, nodes, 2)
+		for _, node := range nodes {
+			subtasks := node.(map[string]interface{})["subtasks"].([]interface{})
+			require.Len(t, subtasks, 1)
+			parent := subtasks[0].(map[string]interface{})["parent"].(map[string]interface{})
+			require.Equal(t, node.(map[string]interface{})["title"], parent["title"])
+		}
+		// The subtasks of all nodes are read at once.
+		require.Equal(t, 1, s.reads)
+
+		pageInfo := tasks["pageInfo"].(map[string]interface{})
+		require.Equal(t, true, pageInfo["hasNextPage"])
+		v = query(t, `query($after: String) { project(name: "trip") { tasks(filter: "status:todo", after: $after) { nodes { title } pageInfo { hasNextPage } } } }`,
+			map[string]interface{}{"after": pageInfo["endCursor"]})
+		require.Empty(t, v.Errors)
+		tasks = v.Data["project"].(map[string]interface{})["tasks"].(map[string]interface{})
+		require.Len(t, tasks["nodes"], 1)
+		require.Equal(t, false, tasks["pageInfo"].(map[string]interface{})["hasNextPage"])
+	})
+
+	t.Run("other users", func(t *testing.T) {
+		v := query(t, `query($id: ID!) { task(id: $id) { title } }`, map[string]interface{}{"id": other.String()})
+		require.Empty(t, v.Errors)
+		require.Nil(t, v.Data["task"])
+
+		v = query(t, `mutation($id: ID!) { deleteTask(id: $id) }`, map[string]interface{}{"id": other.String()})
+		require.Len(t, v.Errors, 1)
+		require.Equal(t, string(service.KindNotFound), v.Errors[0].Extensions["code"])
+		_, err := d.GetTask(other.String())
+		require.NoError(t, err)
+	})
+
+	t.Run("mutations", func(t *testing.T) {
+		v := query(t, `mutation { createTask(input: {title: "Buy milk", priority: HIGH, tags: ["home"]}) { id status priority tags } }`, nil)
+		require.Empty(t, v.Errors)
+		created := v.Data["createTask"].(map[string]interface{})
+		require.Equal(t, "TODO", created["status"])
+		require.Equal(t, "HIGH", created["priority"])
+		require.Equal(t, []interface{}{"home"}, created["tags"])
+
+		v = query(t, `mutation($id: ID!) { updateTask(id: $id, input: {status: DONE, priority: null}) { status priority completedAt } }`,
+			map[string]interface{}{"id": created["id"]})
+		require.Empty(t, v.Errors)
+		updated := v.Data["updateTask"].(map[string]interface{})
+		require.Equal(t, "DONE", updated["status"])
+		require.Nil(t, updated["priority"])
+		require.NotNil(t, updated["completedAt"])
+
+		v = query(t, `mutation($id: ID!) { deleteTask(id: $id) }`, map[string]interface{}{"id": created["id"]})
+		require.Empty(t, v.Errors)
+		require.Equal(t, created["id"], v.Data["deleteTask"])
+
+		v = query(t, `mutation { createTask(input: {title: "Go"}) { id } }`, nil)
+		require.Len(t, v.Errors, 1)
+		require.Equal(t, string(service.KindInvalid), v.Errors[0].Extensions["code"])
+		require.NotEmpty(t, v.Errors[0].Extensions["errors"])
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		v := query(t, `{ tasks { nodes { subtasks { subtasks { subtasks { subtasks { subtasks { subtasks { subtasks { subtasks { id } } } } } } } } } } }`, nil)
+		require.Nil(t, v.Data)
+		require.Len(t, v.Errors, 1)
+		require.Contains(t, v.Errors[0].Message, "levels deep")
+		require.Equal(t, string(service.KindInvalid), v.Errors[0].Extensions["code"])
+
+		v = query(t, `{ tasks(first: 100) { nodes { timeEntries { id } subtasks { timeEntries { id } } } } }`, nil)
+		require.Len(t, v.Errors, 1)
+		require.Contains(t, v.Errors[0].Message, "complexity")
+	})
+
+	t.Run("authentication", func(t *testing.T) {
+		resp, err := http.Post(srv.URL+"/graphql", "application/json", strings.NewReader(`{"query": "{ me { username } }"}`))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+		resp, err = http.Get(srv.URL + "/graphql/schema")
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
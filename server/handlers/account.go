@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"time"
+
+	"tasks/lib"
+	"tasks/server/auth"
+	"tasks/service"
+)
+
+// accountExportRetryAfter is the number of seconds clients are asked to wait
+// before polling a pending account export again.
+const accountExportRetryAfter = "5"
+
+// ExportAccount serves the ZIP archive of the account data of the user. The
+// archive is built in the background: until it is ready, or when
+// refresh=true asks for a new one, the export is started and its status is
+// answered with 202 Accepted, to be polled until the archive is served.
+func ExportAccount(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		username := auth.UsernameFromContext(ctx)
+		export, archive, err := s.GetAccountExportArchive(ctx, username)
+		if r.URL.Query().Get("refresh") == "true" || errors.Is(err, service.ErrExportNotFound) || errors.Is(err, service.ErrExportNotReady) {
+			export, err = s.StartAccountExport(ctx, username)
+			if err == nil {
+				err = service.ErrExportNotReady
+			}
+		}
+		switch {
+		case errors.Is(err, service.ErrExportNotReady):
+			w.Header().Set("Retry-After", accountExportRetryAfter)
+			lib.JSON(w, export, http.StatusAccepted)
+			return
+		case err != nil:
+			l.Error().Err(err).Msgf("Could not export the account of %s", username)
+			writeProblem(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="account-`+username+`.zip"`)
+		http.ServeContent(w, r, "", *export.FinishedAt, bytes.NewReader(archive))
+		l.Info().Msgf("Account export of %s was served", username)
+	}
+}
+
+type deleteAccountRequest struct {
+	Password string `json:"password" validate:"required"`
+}
+
+// DeleteAccount removes the user and all of their data after checking the
+// password given in the body, and ends the session.
+func DeleteAccount(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		var req deleteAccountRequest
+		if err := decodeRequest(r, &req); err != nil {
+			l.Error().Err(err).Msgf("Missing password for account deletion")
+			writeProblem(w, r, err)
+			return
+		}
+
+		username := auth.UsernameFromContext(ctx)
+		err := s.DeleteAccount(ctx, username, req.Password)
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not delete the account of %s", username)
+			writeProblem(w, r, err)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     "paseto",
+			Path:     "/",
+			Value:    "",
+			Expires:  time.Unix(0, 0),
+			HttpOnly: true,
+			Secure:   true,
+		})
+		lib.JSON(w, lib.Msg{"success": "account deleted"}, http.StatusOK)
+		l.Info().Msgf("Account of %s was deleted", username)
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"tasks/db"
+	"tasks/lib"
+	"tasks/server/auth"
+	"tasks/service"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// AuditRequest stores the client address, user agent and request ID of the
+// request for the audit trail. It must run after the request ID middleware.
+func AuditRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		info := service.RequestInfo{
+			IP:        ip,
+			UserAgent: r.UserAgent(),
+			RequestID: middleware.GetReqID(r.Context()),
+		}
+		next.ServeHTTP(w, r.WithContext(service.WithRequestInfo(r.Context(), info)))
+	})
+}
+
+// AuditActor adds the authenticated user to the request description. It must
+// run after auth.AuthMiddleware.
+func AuditActor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		info := service.RequestInfoFromContext(ctx)
+		info.Actor = auth.UsernameFromContext(ctx)
+		next.ServeHTTP(w, r.WithContext(service.WithRequestInfo(ctx, info)))
+	})
+}
+
+// parseAuditRange reads from and to (RFC 3339 or YYYY-MM-DD in UTC) from the
+// query string.
+func parseAuditRange(r *http.Request) (time.Time, time.Time, error) {
+	var bounds [2]time.Time
+	for i, name := range []string{"from", "to"} {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			t, err = time.Parse(time.RFC3339, v)
+		}
+		if err != nil {
+			return bounds[0], bounds[1], fmt.Errorf("%s must be a date or an RFC 3339 timestamp", name)
+		}
+		bounds[i] = t
+	}
+	if !bounds[0].IsZero() && !bounds[1].IsZero() && !bounds[1].After(bounds[0]) {
+		return bounds[0], bounds[1], fmt.Errorf("to must be after from")
+	}
+	return bounds[0], bounds[1], nil
+}
+
+// GetAuditEvents lists the audit trail of the authenticated user. With
+// format=jsonl the events are streamed as JSON Lines.
+func GetAuditEvents(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		from, to, err := parseAuditRange(r)
+		if err != nil {
+			l.Error().Err(err).Msgf("Invalid audit query")
+			writeProblem(w, r, fmt.Errorf("%w: %v", service.ErrInvalidParameter, err))
+			return
+		}
+
+		username := auth.UsernameFromContext(ctx)
+		if r.URL.Query().Get("format") == "jsonl" {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+			w.WriteHeader(http.StatusOK)
+			enc := json.NewEncoder(w)
+			err := s.ExportAuditEvents(ctx, username, from, to, func(e *db.AuditEvent) error {
+				return enc.Encode(e)
+			})
+			if err != nil {
+				l.Error().Err(err).Msgf("Could not export audit events for user %s", username)
+			}
+			return
+		}
+
+		events, err := s.GetAuditEvents(ctx, username, from, to)
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not get audit events for user %s", username)
+			writeProblem(w, r, err)
+			return
+		}
+		lib.JSON(w, events, http.StatusOK)
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"tasks/lib"
+	"tasks/server/auth"
+	"tasks/service"
+)
+
+type bulkTasksRequest struct {
+	Mode       string                  `json:"mode" validate:"omitempty,oneof=atomic best_effort"`
+	Operations []service.BulkOperation `json:"operations" validate:"required,min=1,max=500,dive"`
+}
+
+func BulkTasks(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		var bulkRequest bulkTasksRequest
+
+		err := decodeRequest(r, &bulkRequest)
+		if err != nil {
+			l.Error().Err(err).Msgf("error decoding the bulk request. %v", err)
+			writeProblem(w, r, err)
+			return
+		}
+
+		atomic := bulkRequest.Mode != "best_effort"
+		results, err := s.ApplyBulk(ctx, auth.UsernameFromContext(ctx), bulkRequest.Operations, atomic)
+		switch {
+		case errors.Is(err, service.ErrBulkFailed):
+			l.Info().Msgf("Bulk request with %d operations was rolled back", len(bulkRequest.Operations))
+			lib.JSON(w, results, http.StatusUnprocessableEntity)
+		case err != nil:
+			l.Error().Err(err).Msgf("Bulk request failed. %v", err)
+			writeProblem(w, r, err)
+		default:
+			l.Info().Msgf("Bulk request with %d operations was applied", len(bulkRequest.Operations))
+			lib.JSON(w, results, http.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,711 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"tasks/db"
+	"tasks/lib"
+	"tasks/server/auth"
+	"tasks/service"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	davNS = "DAV:"
+	calNS = "urn:ietf:params:xml:ns:caldav"
+	csNS  = "http://calendarserver.org/ns/"
+
+	// DAVRoot is the path under which the CalDAV tree is served.
+	DAVRoot = "/dav/"
+	// davInbox is the calendar of the tasks without a project. Project
+	// calendars are named "p-" followed by the project, so they cannot clash.
+	davInbox           = "inbox"
+	davProjectPrefix   = "p-"
+	davSyncTokenPrefix = "urn:tasks:sync:"
+	davContentType     = "text/calendar; charset=utf-8; component=VTODO"
+	maxDAVRequestSize  = 1 << 20
+)
+
+// davResource is a node of the CalDAV tree, parsed from a request path:
+//
+//	/dav/                                  root
+//	/dav/principals/{user}/                principal
+//	/dav/calendars/{user}/                 calendar home
+//	/dav/calendars/{user}/{calendar}/      calendar of a project
+//	/dav/calendars/{user}/{calendar}/{uid}.ics  task
+type davResource struct {
+	kind     string
+	user     string
+	calendar string
+	project  string
+	name     string
+	task     *db.Task
+}
+
+const (
+	davRootKind      = "root"
+	davPrincipalKind = "principal"
+	davHomeKind      = "home"
+	davCalendarKind  = "calendar"
+	davTaskKind      = "task"
+)
+
+func davCalendarName(project string) string {
+	if project == "" {
+		return davInbox
+	}
+	return davProjectPrefix + project
+}
+
+func davPrincipalHref(user string) string {
+	return DAVRoot + "principals/" + url.PathEscape(user) + "/"
+}
+
+func davHomeHref(user string) string {
+	return DAVRoot + "calendars/" + url.PathEscape(user) + "/"
+}
+
+func davCalendarHref(user string, project string) string {
+	return davHomeHref(user) + url.PathEscape(davCalendarName(project)) + "/"
+}
+
+func davTaskHref(user string, t *db.Task) string {
+	return davCalendarHref(user, t.Project) + url.PathEscape(service.CalendarTaskName(t))
+}
+
+func (res *davResource) href() string {
+	switch res.kind {
+	case davPrincipalKind:
+		return davPrincipalHref(res.user)
+	case davHomeKind:
+		return davHomeHref(res.user)
+	case davCalendarKind:
+		return davCalendarHref(res.user, res.project)
+	case davTaskKind:
+		return davCalendarHref(res.user, res.project) + url.PathEscape(res.name)
+	default:
+		return DAVRoot
+	}
+}
+
+var errDAVPath = errors.New("no such CalDAV resource")
+
+// parseDAVPath maps an escaped request path or href onto a resource of the
+// tree. The user in the path must be the authenticated one.
+func parseDAVPath(escaped string, username string) (*davResource, error) {
+	if u, err := url.Parse(escaped); err == nil && u.Path != "" {
+		escaped = u.EscapedPath()
+	}
+	rest := strings.TrimPrefix(escaped, strings.TrimSuffix(DAVRoot, "/"))
+	if rest == escaped && escaped != strings.TrimSuffix(DAVRoot, "/") {
+		return nil, errDAVPath
+	}
+	var segments []string
+	for _, part := range strings.Split(strings.Trim(rest, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		segment, err := url.PathUnescape(part)
+		if err != nil {
+			return nil, errDAVPath
+		}
+		segments = append(segments, segment)
+	}
+
+	res := &davResource{kind: davRootKind}
+	if len(segments) == 0 {
+		return res, nil
+	}
+	if len(segments) < 2 || segments[1] != username {
+		return nil, errDAVPath
+	}
+	res.user = segments[1]
+	switch {
+	case segments[0] == "principals" && len(segments) == 2:
+		res.kind = davPrincipalKind
+		return res, nil
+	case segments[0] != "calendars" || len(segments) > 4:
+		return nil, errDAVPath
+	case len(segments) == 2:
+		res.kind = davHomeKind
+		return res, nil
+	}
+	res.kind, res.calendar = davCalendarKind, segments[2]
+	switch {
+	case res.calendar == davInbox:
+	case strings.HasPrefix(res.calendar, davProjectPrefix) && len(res.calendar) > len(davProjectPrefix):
+		res.project = strings.TrimPrefix(res.calendar, davProjectPrefix)
+	default:
+		return nil, errDAVPath
+	}
+	if len(segments) == 4 {
+		res.kind, res.name = davTaskKind, segments[3]
+	}
+	return res, nil
+}
+
+// davProperty is a property element of a response. Nested elements are
+// written verbatim from InnerXML.
+type davProperty struct {
+	XMLName  xml.Name
+	Text     string `xml:",chardata"`
+	InnerXML string `xml:",innerxml"`
+}
+
+// davProp holds the properties of a propstat. The elements are named by the
+// XMLName of each property.
+type davProp struct {
+	Properties []davProperty
+}
+
+type davPropstat struct {
+	Prop   davProp `xml:"prop"`
+	Status string  `xml:"status"`
+}
+
+type davResponse struct {
+	Href      string        `xml:"href"`
+	Propstats []davPropstat `xml:"propstat,omitempty"`
+	Status    string        `xml:"status,omitempty"`
+}
+
+type davMultistatus struct {
+	XMLName   xml.Name      `xml:"DAV: multistatus"`
+	Responses []davResponse `xml:"response"`
+	SyncToken string        `xml:"sync-token,omitempty"`
+}
+
+type davName struct {
+	XMLName xml.Name
+}
+
+type davPropfind struct {
+	AllProp  *struct{} `xml:"DAV: allprop"`
+	PropName *struct{} `xml:"DAV: propname"`
+	Prop     struct {
+		Names []davName `xml:",any"`
+	} `xml:"DAV: prop"`
+}
+
+type calCompFilter struct {
+	Name         string          `xml:"name,attr"`
+	IsNotDefined *struct{}       `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+	CompFilters  []calCompFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	PropFilters  []struct {
+		Name         string    `xml:"name,attr"`
+		IsNotDefined *struct{} `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+	} `xml:"urn:ietf:params:xml:ns:caldav prop-filter"`
+}
+
+type davReport struct {
+	XMLName xml.Name
+	Prop    struct {
+		Names []davName `xml:",any"`
+	} `xml:"DAV: prop"`
+	Hrefs     []string       `xml:"DAV: href"`
+	SyncToken string         `xml:"DAV: sync-token"`
+	Filter    *calCompFilter `xml:"urn:ietf:params:xml:ns:caldav filter>comp-filter"`
+}
+
+func davStatus(code int) string {
+	return fmt.Sprintf("HTTP/1.1 %d %s", code, http.StatusText(code))
+}
+
+func davEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// davHref is a DAV:href element that may be nested in a property of another
+// namespace.
+func davHref(href string) string {
+	return `<href xmlns="DAV:">` + davEscape(href) + `</href>`
+}
+
+func davText(space string, local string, text string) davProperty {
+	return davProperty{XMLName: xml.Name{Space: space, Local: local}, Text: text}
+}
+
+func davInner(space string, local string, inner string) davProperty {
+	return davProperty{XMLName: xml.Name{Space: space, Local: local}, InnerXML: inner}
+}
+
+// davHandler serves the CalDAV tree of the authenticated user.
+type davHandler struct {
+	s        TaskService
+	l        *zerolog.Logger
+	username string
+}
+
+// properties returns the properties of the resource. calendar-data is only
+// included if withData is set, as it is not part of allprop.
+func (h *davHandler) properties(r *http.Request, res *davResource, withData bool) ([]davProperty, error) {
+	props := []davProperty{
+		davInner(davNS, "current-user-principal", davHref(davPrincipalHref(h.username))),
+	}
+	switch res.kind {
+	case davRootKind:
+		props = append(props, davInner(davNS, "resourcetype", "<collection/>"))
+	case davPrincipalKind:
+		props = append(props,
+			davInner(davNS, "resourcetype", "<principal/>"),
+			davText(davNS, "displayname", res.user),
+			davInner(davNS, "principal-URL", davHref(davPrincipalHref(res.user))),
+			davInner(calNS, "calendar-home-set", davHref(davHomeHref(res.user))),
+		)
+	case davHomeKind:
+		props = append(props,
+			davInner(davNS, "resourcetype", "<collection/>"),
+			davText(davNS, "displayname", res.user),
+		)
+	case davCalendarKind:
+		rev, tag, err := h.s.CalendarTag(r.Context(), h.username, res.project)
+		if err != nil {
+			return nil, err
+		}
+		name := res.project
+		if name == "" {
+			name = "Inbox"
+		}
+		props = append(props,
+			davInner(davNS, "resourcetype", `<collection/><calendar xmlns="urn:ietf:params:xml:ns:caldav"/>`),
+			davText(davNS, "displayname", name),
+			davInner(calNS, "supported-calendar-component-set", `<comp name="VTODO"/>`),
+			davInner(davNS, "supported-report-set",
+				`<supported-report><report><calendar-query xmlns="urn:ietf:params:xml:ns:caldav"/></report></supported-report>`+
+					`<supported-report><report><calendar-multiget xmlns="urn:ietf:params:xml:ns:caldav"/></report></supported-report>`+
+					`<supported-report><report><sync-collection/></report></supported-report>`),
+			davInner(davNS, "current-user-privilege-set",
+				`<privilege><read/></privilege><privilege><write/></privilege><privilege><write-content/></privilege>`+
+					`<privilege><bind/></privilege><privilege><unbind/></privilege>`),
+			davText(csNS, "getctag", strconv.FormatUint(tag, 10)),
+			davText(davNS, "sync-token", davSyncTokenPrefix+strconv.FormatUint(rev, 10)),
+		)
+	case davTaskKind:
+		props = append(props,
+			davInner(davNS, "resourcetype", ""),
+			davText(davNS, "getetag", lib.ICalETag(res.task)),
+			davText(davNS, "getcontenttype", davContentType),
+			davText(davNS, "getlastmodified", res.task.UpdatedAt.UTC().Format(http.TimeFormat)),
+		)
+		if withData {
+			var b strings.Builder
+			if err := lib.WriteICal(&b, []db.Task{*res.task}, res.task.UpdatedAt); err != nil {
+				return nil, err
+			}
+			props = append(props, davText(calNS, "calendar-data", b.String()))
+		}
+	}
+	return props, nil
+}
+
+// response describes the resource with the requested properties, or every
+// property if names is nil, split into found and missing ones.
+func (h *davHandler) response(r *http.Request, res *davResource, names []davName, nameOnly bool) (davResponse, error) {
+	withData := false
+	for _, n := range names {
+		if n.XMLName == (xml.Name{Space: calNS, Local: "calendar-data"}) {
+			withData = true
+		}
+	}
+	props, err := h.properties(r, res, withData)
+	if err != nil {
+		return davResponse{}, err
+	}
+	resp := davResponse{Href: res.href()}
+	if names == nil {
+		if nameOnly {
+			for i := range props {
+				props[i].Text, props[i].InnerXML = "", ""
+			}
+		}
+		resp.Propstats = []davPropstat{{Prop: davProp{props}, Status: davStatus(http.StatusOK)}}
+		return resp, nil
+	}
+
+	var found, missing []davProperty
+	for _, n := range names {
+		ok := false
+		for _, p := range props {
+			if p.XMLName == n.XMLName {
+				found, ok = append(found, p), true
+				break
+			}
+		}
+		if !ok {
+			missing = append(missing, davProperty{XMLName: n.XMLName})
+		}
+	}
+	if len(found) > 0 {
+		resp.Propstats = append(resp.Propstats, davPropstat{Prop: davProp{found}, Status: davStatus(http.StatusOK)})
+	}
+	if len(missing) > 0 {
+		resp.Propstats = append(resp.Propstats, davPropstat{Prop: davProp{missing}, Status: davStatus(http.StatusNotFound)})
+	}
+	return resp, nil
+}
+
+// children lists the members of a collection resource.
+func (h *davHandler) children(r *http.Request, res *davResource) ([]*davResource, error) {
+	switch res.kind {
+	case davRootKind:
+		return []*davResource{
+			{kind: davPrincipalKind, user: h.username},
+			{kind: davHomeKind, user: h.username},
+		}, nil
+	case davHomeKind:
+		projects, err := h.s.GetCalendars(r.Context(), h.username)
+		if err != nil {
+			return nil, err
+		}
+		children := make([]*davResource, 0, len(projects))
+		for _, p := range projects {
+			children = append(children, &davResource{kind: davCalendarKind, user: h.username, calendar: davCalendarName(p), project: p})
+		}
+		return children, nil
+	case davCalendarKind:
+		tasks, err := h.s.GetCalendarTasks(r.Context(), h.username, res.project)
+		if err != nil {
+			return nil, err
+		}
+		return h.taskResources(res, tasks), nil
+	}
+	return nil, nil
+}
+
+func (h *davHandler) taskResources(calendar *davResource, tasks []db.Task) []*davResource {
+	children := make([]*davResource, 0, len(tasks))
+	for i := range tasks {
+		children = append(children, &davResource{
+			kind:     davTaskKind,
+			user:     h.username,
+			calendar: calendar.calendar,
+			project:  calendar.project,
+			name:     service.CalendarTaskName(&tasks[i]),
+			task:     &tasks[i],
+		})
+	}
+	return children
+}
+
+// resolve loads the task of a task resource. Calendars always exist, an
+// empty one simply has no members.
+func (h *davHandler) resolve(r *http.Request, res *davResource) error {
+	if res.kind != davTaskKind {
+		return nil
+	}
+	t, err := h.s.GetCalendarTask(r.Context(), h.username, res.project, res.name)
+	if err != nil {
+		return err
+	}
+	res.task = t
+	return nil
+}
+
+func (h *davHandler) writeMultistatus(w http.ResponseWriter, ms *davMultistatus) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	io.WriteString(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(ms); err != nil {
+		h.l.Error().Err(err).Msgf("Could not write CalDAV multistatus")
+	}
+}
+
+// writeError maps service errors onto CalDAV responses. Preconditions are
+// reported with an error element naming them.
+func (h *davHandler) writeError(w http.ResponseWriter, err error) {
+	precondition := func(code int, space string, name string) {
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.WriteHeader(code)
+		fmt.Fprintf(w, `%s<error xmlns="DAV:"><%s xmlns="%s"/></error>`, xml.Header, name, space)
+	}
+	switch {
+	case errors.Is(err, errDAVPath), errors.Is(err, service.ErrNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrPreconditionFailed):
+		http.Error(w, "precondition failed", http.StatusPreconditionFailed)
+	case errors.Is(err, service.ErrInvalidSyncToken):
+		precondition(http.StatusForbidden, davNS, "valid-sync-token")
+	case errors.Is(err, service.ErrUnsupportedComponent):
+		precondition(http.StatusForbidden, calNS, "supported-calendar-component")
+	case errors.Is(err, service.ErrInvalidICal):
+		h.l.Error().Err(err).Msgf("Invalid calendar data")
+		precondition(http.StatusBadRequest, calNS, "valid-calendar-data")
+	case errors.Is(err, service.ErrInvalidTask):
+		precondition(http.StatusForbidden, calNS, "valid-calendar-object-resource")
+	default:
+		h.l.Error().Err(err).Msgf("CalDAV request failed. %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
+
+func (h *davHandler) propfind(w http.ResponseWriter, r *http.Request, res *davResource) {
+	var req davPropfind
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxDAVRequestSize))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := xml.Unmarshal(body, &req); err != nil {
+			http.Error(w, "malformed PROPFIND body", http.StatusBadRequest)
+			return
+		}
+	}
+	names := req.Prop.Names
+	if req.AllProp != nil || req.PropName != nil || names == nil {
+		names = nil
+	}
+	if err := h.resolve(r, res); err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	resources := []*davResource{res}
+	if r.Header.Get("Depth") != "0" {
+		children, err := h.children(r, res)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+		resources = append(resources, children...)
+	}
+	ms := &davMultistatus{}
+	for _, res := range resources {
+		resp, err := h.response(r, res, names, req.PropName != nil)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+		ms.Responses = append(ms.Responses, resp)
+	}
+	h.writeMultistatus(w, ms)
+}
+
+// matchCalendarFilter applies the comp-filter of a calendar-query. Only
+// VTODO components and the COMPLETED is-not-defined property filter are
+// understood; other conditions, such as time ranges, match every task.
+func matchCalendarFilter(f *calCompFilter, t *db.Task) bool {
+	if f == nil || len(f.CompFilters) == 0 {
+		return true
+	}
+	for _, c := range f.CompFilters {
+		if c.Name != "VTODO" || c.IsNotDefined != nil {
+			continue
+		}
+		match := true
+		for _, p := range c.PropFilters {
+			if p.Name == "COMPLETED" && p.IsNotDefined != nil && t.Status == db.StatusDone {
+				match = false
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *davHandler) report(w http.ResponseWriter, r *http.Request, res *davResource) {
+	var req davReport
+	if err := xml.NewDecoder(io.LimitReader(r.Body, maxDAVRequestSize)).Decode(&req); err != nil {
+		http.Error(w, "malformed REPORT body", http.StatusBadRequest)
+		return
+	}
+	if res.kind != davCalendarKind {
+		http.Error(w, "reports are supported on calendars only", http.StatusForbidden)
+		return
+	}
+	names := req.Prop.Names
+	ms := &davMultistatus{}
+	add := func(res *davResource) bool {
+		resp, err := h.response(r, res, names, false)
+		if err != nil {
+			h.writeError(w, err)
+			return false
+		}
+		ms.Responses = append(ms.Responses, resp)
+		return true
+	}
+
+	switch req.XMLName {
+	case xml.Name{Space: calNS, Local: "calendar-query"}:
+		tasks, err := h.s.GetCalendarTasks(r.Context(), h.username, res.project)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+		for _, child := range h.taskResources(res, tasks) {
+			if matchCalendarFilter(req.Filter, child.task) && !add(child) {
+				return
+			}
+		}
+	case xml.Name{Space: calNS, Local: "calendar-multiget"}:
+		for _, href := range req.Hrefs {
+			child, err := parseDAVPath(href, h.username)
+			if err == nil && child.kind == davTaskKind && child.calendar == res.calendar {
+				err = h.resolve(r, child)
+			} else if err == nil {
+				err = errDAVPath
+			}
+			if err != nil {
+				ms.Responses = append(ms.Responses, davResponse{Href: href, Status: davStatus(http.StatusNotFound)})
+				continue
+			}
+			if !add(child) {
+				return
+			}
+		}
+	case xml.Name{Space: davNS, Local: "sync-collection"}:
+		var since uint64
+		if req.SyncToken != "" {
+			rev, err := strconv.ParseUint(strings.TrimPrefix(req.SyncToken, davSyncTokenPrefix), 10, 64)
+			if err != nil || !strings.HasPrefix(req.SyncToken, davSyncTokenPrefix) {
+				h.writeError(w, service.ErrInvalidSyncToken)
+				return
+			}
+			since = rev
+		}
+		changes, err := h.s.SyncCalendar(r.Context(), h.username, res.project, since)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+		for _, child := range h.taskResources(res, changes.Changed) {
+			if !add(child) {
+				return
+			}
+		}
+		for _, name := range changes.Removed {
+			ms.Responses = append(ms.Responses, davResponse{
+				Href:   davCalendarHref(h.username, res.project) + url.PathEscape(name),
+				Status: davStatus(http.StatusNotFound),
+			})
+		}
+		ms.SyncToken = davSyncTokenPrefix + strconv.FormatUint(changes.Revision, 10)
+	default:
+		http.Error(w, "unsupported report", http.StatusForbidden)
+		return
+	}
+	h.writeMultistatus(w, ms)
+}
+
+func (h *davHandler) get(w http.ResponseWriter, r *http.Request, res *davResource) {
+	var tasks []db.Task
+	switch res.kind {
+	case davTaskKind:
+		if err := h.resolve(r, res); err != nil {
+			h.writeError(w, err)
+			return
+		}
+		tasks = []db.Task{*res.task}
+		w.Header().Set("ETag", lib.ICalETag(res.task))
+		w.Header().Set("Last-Modified", res.task.UpdatedAt.UTC().Format(http.TimeFormat))
+	case davCalendarKind:
+		var err error
+		if tasks, err = h.s.GetCalendarTasks(r.Context(), h.username, res.project); err != nil {
+			h.writeError(w, err)
+			return
+		}
+	default:
+		http.Error(w, "not a calendar resource", http.StatusMethodNotAllowed)
+		return
+	}
+	dtstamp := time.Now()
+	if res.task != nil {
+		dtstamp = res.task.UpdatedAt
+	}
+	w.Header().Set("Content-Type", davContentType)
+	w.WriteHeader(http.StatusOK)
+	if err := lib.WriteICal(w, tasks, dtstamp); err != nil {
+		h.l.Error().Err(err).Msgf("Could not write calendar data")
+	}
+}
+
+func (h *davHandler) put(w http.ResponseWriter, r *http.Request, res *davResource) {
+	if res.kind != davTaskKind {
+		http.Error(w, "only calendar object resources can be written", http.StatusMethodNotAllowed)
+		return
+	}
+	t, created, err := h.s.PutCalendarTask(r.Context(), h.username, res.project, res.name,
+		io.LimitReader(r.Body, maxDAVRequestSize), r.Header.Get("If-Match"), r.Header.Get("If-None-Match"))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	w.Header().Set("ETag", lib.ICalETag(t))
+	if created {
+		w.Header().Set("Location", davTaskHref(h.username, t))
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *davHandler) delete(w http.ResponseWriter, r *http.Request, res *davResource) {
+	if res.kind != davTaskKind {
+		http.Error(w, "collections cannot be deleted", http.StatusForbidden)
+		return
+	}
+	err := h.s.DeleteCalendarTask(r.Context(), h.username, res.project, res.name, r.Header.Get("If-Match"))
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CalDAV serves the tasks of the authenticated user as a CalDAV tree, one
+// calendar of VTODO resources per project. It must run after an
+// authentication middleware.
+func CalDAV(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+		r = r.WithContext(ctx)
+		w.Header().Del("Content-Type")
+		w.Header().Set("DAV", "1, 3, calendar-access")
+
+		h := &davHandler{s: s, l: l, username: auth.UsernameFromContext(ctx)}
+		res, err := parseDAVPath(r.URL.EscapedPath(), h.username)
+		if err != nil {
+			h.writeError(w, err)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodOptions:
+			w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT")
+			w.WriteHeader(http.StatusOK)
+		case "PROPFIND":
+			h.propfind(w, r, res)
+		case "REPORT":
+			h.report(w, r, res)
+		case http.MethodGet, http.MethodHead:
+			h.get(w, r, res)
+		case http.MethodPut:
+			h.put(w, r, res)
+		case http.MethodDelete:
+			h.delete(w, r, res)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// RedirectToCalDAV points clients discovering the service through
+// /.well-known/caldav (RFC 6764) at the CalDAV root.
+func RedirectToCalDAV(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, DAVRoot, http.StatusMovedPermanently)
+}
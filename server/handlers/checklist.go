@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"net/http"
+	"tasks/db"
+	"tasks/lib"
+	"tasks/server/auth"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+func urlParamUUID(r *http.Request, name string) (uuid.UUID, error) {
+	return uuid.Parse(chi.URLParam(r, name))
+}
+
+// checklistIDs parses the task and checklist item IDs from the request path.
+// It writes the error response itself and reports whether parsing succeeded.
+func checklistIDs(w http.ResponseWriter, r *http.Request, l *zerolog.Logger) (uuid.UUID, uuid.UUID, bool) {
+	taskID, err := urlParamUUID(r, "id")
+	if err != nil {
+		l.Error().Err(err).Msgf("Could not convert ID to UUID.")
+		writeProblem(w, r, invalidParam("id", err))
+		return uuid.Nil, uuid.Nil, false
+	}
+	itemID, err := urlParamUUID(r, "itemID")
+	if err != nil {
+		l.Error().Err(err).Msgf("Could not convert checklist item ID to UUID.")
+		writeProblem(w, r, invalidParam("itemID", err))
+		return uuid.Nil, uuid.Nil, false
+	}
+	return taskID, itemID, true
+}
+
+func writeChecklistResult(w http.ResponseWriter, r *http.Request, l *zerolog.Logger, t *db.Task, err error, code int) {
+	switch {
+	case err != nil:
+		l.Error().Err(err).Msgf("Checklist operation failed. %v", err)
+		writeProblem(w, r, err)
+	default:
+		l.Info().Msgf("Checklist operation on task %v was successful!", t.ID)
+		lib.JSON(w, newTaskResponse(t), code)
+	}
+}
+
+type addChecklistItemRequest struct {
+	Text     string `json:"text" validate:"required"`
+	Position *int   `json:"position"`
+}
+
+func AddChecklistItem(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		taskID, err := urlParamUUID(r, "id")
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not convert ID to UUID.")
+			writeProblem(w, r, invalidParam("id", err))
+			return
+		}
+
+		var req addChecklistItemRequest
+
+		err = decodeRequest(r, &req)
+		if err != nil {
+			l.Error().Err(err).Msgf("error decoding the checklist item. %v", err)
+			writeProblem(w, r, err)
+			return
+		}
+
+		position := -1
+		if req.Position != nil {
+			position = *req.Position
+		}
+
+		t, err := s.AddChecklistItem(ctx, auth.UsernameFromContext(ctx), taskID, req.Text, position)
+		writeChecklistResult(w, r, l, t, err, http.StatusCreated)
+	}
+}
+
+type moveChecklistItemRequest struct {
+	Position *int `json:"position" validate:"required"`
+}
+
+func MoveChecklistItem(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		taskID, itemID, ok := checklistIDs(w, r, l)
+		if !ok {
+			return
+		}
+
+		var req moveChecklistItemRequest
+
+		err := decodeRequest(r, &req)
+		if err != nil {
+			l.Error().Err(err).Msgf("error decoding the checklist move request. %v", err)
+			writeProblem(w, r, err)
+			return
+		}
+
+		t, err := s.MoveChecklistItem(ctx, auth.UsernameFromContext(ctx), taskID, itemID, *req.Position)
+		writeChecklistResult(w, r, l, t, err, http.StatusOK)
+	}
+}
+
+func SetChecklistItemDone(s TaskService, done bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		taskID, itemID, ok := checklistIDs(w, r, l)
+		if !ok {
+			return
+		}
+
+		t, err := s.SetChecklistItemDone(ctx, auth.UsernameFromContext(ctx), taskID, itemID, done)
+		writeChecklistResult(w, r, l, t, err, http.StatusOK)
+	}
+}
+
+func DeleteChecklistItem(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		taskID, itemID, ok := checklistIDs(w, r, l)
+		if !ok {
+			return
+		}
+
+		t, err := s.DeleteChecklistItem(ctx, auth.UsernameFromContext(ctx), taskID, itemID)
+		writeChecklistResult(w, r, l, t, err, http.StatusOK)
+	}
+}
+
+func ConvertChecklistItem(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		taskID, itemID, ok := checklistIDs(w, r, l)
+		if !ok {
+			return
+		}
+
+		t, err := s.ConvertChecklistItem(ctx, auth.UsernameFromContext(ctx), taskID, itemID)
+		writeChecklistResult(w, r, l, t, err, http.StatusCreated)
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"tasks/db"
+	"tasks/lib"
+	"tasks/server/auth"
+	"tasks/service"
+)
+
+// maxCSVImportSize bounds the size of an uploaded CSV file.
+const maxCSVImportSize = 10 << 20
+
+// ExportCSV streams the notes of the user as CSV. The columns query
+// parameter selects and orders the columns (see lib.CSVColumns, and cf.<key>
+// for custom fields); q restricts the export to the notes matching a filter.
+func ExportCSV(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		columns, err := lib.ParseCSVColumns(r.URL.Query().Get("columns"))
+		if err != nil {
+			l.Error().Err(err).Msgf("Invalid CSV columns")
+			writeProblem(w, r, fmt.Errorf("%w: %v", service.ErrInvalidParameter, err))
+			return
+		}
+
+		username := auth.UsernameFromContext(ctx)
+		var tasks []db.Task
+		if q := r.URL.Query().Get("q"); q != "" {
+			tasks, err = s.SearchTasks(ctx, username, q, "", time.Now())
+		} else {
+			tasks, err = s.GetAllTasksFromUser(ctx, username)
+		}
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not fetch notes for CSV export")
+			writeProblem(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="tasks.csv"`)
+		w.WriteHeader(http.StatusOK)
+		cw := lib.NewCSVWriter(w, columns)
+		err = cw.WriteHeader()
+		for i := 0; err == nil && i < len(tasks); i++ {
+			err = cw.Write(&tasks[i])
+		}
+		if err == nil {
+			err = cw.Flush()
+		}
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not write CSV export")
+		}
+	}
+}
+
+// parseCSVMapping reads the map query parameters, each of the form
+// header:column.
+func parseCSVMapping(r *http.Request) (map[string]string, error) {
+	mapping := map[string]string{}
+	for _, m := range r.URL.Query()["map"] {
+		i := strings.LastIndexByte(m, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("map must have the form header:column, got %q", m)
+		}
+		mapping[strings.TrimSpace(m[:i])] = strings.TrimSpace(m[i+1:])
+	}
+	return mapping, nil
+}
+
+// ImportCSV creates or updates notes from a CSV file sent as the request
+// body. The header is mapped to columns by name unless overridden with
+// map=header:column parameters (column "-" ignores the header). With
+// dryRun=true nothing is stored and the result previews the notes. Rows
+// that fail validation are listed in the result.
+func ImportCSV(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		mapping, err := parseCSVMapping(r)
+		if err != nil {
+			l.Error().Err(err).Msgf("Invalid CSV mapping")
+			writeProblem(w, r, fmt.Errorf("%w: %v", service.ErrInvalidParameter, err))
+			return
+		}
+		dryRun := r.URL.Query().Get("dryRun") == "true"
+
+		body := http.MaxBytesReader(w, r.Body, maxCSVImportSize)
+		result, err := s.ImportCSV(ctx, auth.UsernameFromContext(ctx), body, mapping, dryRun)
+		switch {
+		case err != nil:
+			l.Error().Err(err).Msgf("CSV import failed. %v", err)
+			writeProblem(w, r, err)
+		default:
+			l.Info().Msgf("CSV import created %d and updated %d notes, %d rows failed (dry run %t)", result.Created, result.Updated, result.Failed, dryRun)
+			lib.JSON(w, result, http.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"tasks/lib"
+	"tasks/server/auth"
+	"tasks/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// FeedRoot is the path under which calendar feeds are served, by token.
+const FeedRoot = "/feed/"
+
+// feedURL returns the absolute URL of the feed with the token, as seen by
+// the client of the request.
+func feedURL(r *http.Request, token string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + FeedRoot + token + ".ics"
+}
+
+// CreateFeedToken generates a new secret URL for the calendar feed of the
+// user. The previous URL stops working.
+func CreateFeedToken(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		username := auth.UsernameFromContext(ctx)
+		secret, token, err := s.CreateFeedToken(ctx, username)
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not create the feed token of %s", username)
+			writeProblem(w, r, err)
+			return
+		}
+		lib.JSON(w, lib.Msg{"url": feedURL(r, secret), "createdAt": token.CreatedAt.Format(time.RFC3339)}, http.StatusCreated)
+		l.Info().Msgf("Feed token of %s was created", username)
+	}
+}
+
+// GetFeedToken tells whether the user has a calendar feed. Its URL cannot
+// be shown again.
+func GetFeedToken(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		token, err := s.GetFeedToken(ctx, auth.UsernameFromContext(ctx))
+		switch {
+		case err != nil:
+			l.Error().Err(err).Msgf("Could not fetch the feed token")
+			writeProblem(w, r, err)
+		default:
+			lib.JSON(w, lib.Msg{"createdAt": token.CreatedAt.Format(time.RFC3339)}, http.StatusOK)
+		}
+	}
+}
+
+func DeleteFeedToken(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		err := s.DeleteFeedToken(ctx, auth.UsernameFromContext(ctx))
+		switch {
+		case err != nil:
+			l.Error().Err(err).Msgf("Could not delete the feed token")
+			writeProblem(w, r, err)
+		default:
+			lib.JSON(w, lib.Msg{"success": "feed deleted"}, http.StatusOK)
+		}
+	}
+}
+
+// CalendarFeed serves the tasks with a due date of the owner of the token
+// as an iCalendar feed, restricted by the project and tag query parameters,
+// each of which may be repeated. The token in the path is the only
+// credential, so calendar applications can poll it. The most recent change
+// of a task in the feed is its DTSTAMP, which keeps the body, and so its
+// entity tag, stable until a task changes. Last-Modified is the last change
+// of any task of the user instead, so that it also advances when a task
+// leaves the feed.
+func CalendarFeed(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		q := service.FeedQuery{Projects: r.URL.Query()["project"], Tags: r.URL.Query()["tag"]}
+		tasks, lastModified, err := s.GetFeedTasks(ctx, chi.URLParam(r, "token"), q)
+		switch {
+		case errors.Is(err, service.ErrFeedNotFound):
+			http.Error(w, "feed is not found", http.StatusNotFound)
+			return
+		case err != nil:
+			l.Error().Err(err).Msgf("Could not fetch the tasks of a feed")
+			http.Error(w, "internal error during feed export", http.StatusInternalServerError)
+			return
+		}
+
+		var modified time.Time
+		for i := range tasks {
+			if tasks[i].UpdatedAt.After(modified) {
+				modified = tasks[i].UpdatedAt
+			}
+		}
+		var body bytes.Buffer
+		if err := lib.WriteICal(&body, tasks, modified); err != nil {
+			l.Error().Err(err).Msgf("Could not write a feed")
+			http.Error(w, "internal error during feed export", http.StatusInternalServerError)
+			return
+		}
+		sum := sha1.Sum(body.Bytes())
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Cache-Control", "private, no-cache")
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+		http.ServeContent(w, r, "", lastModified, bytes.NewReader(body.Bytes()))
+	}
+}
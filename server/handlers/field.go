@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"tasks/db"
+	"tasks/lib"
+	"tasks/server/auth"
+	"tasks/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+)
+
+// fieldConflictProblem documents the problem reported when a change of a
+// custom field would remove values, with the number of notes that have them.
+type fieldConflictProblem struct {
+	lib.Problem
+	Affected int `json:"affected"`
+}
+
+type fieldUpdateResponse struct {
+	*db.FieldDefinition
+	Removed int `json:"removed"`
+}
+
+type fieldDeleteResponse struct {
+	Success string `json:"success"`
+	Removed int    `json:"removed"`
+}
+
+func writeFieldError(w http.ResponseWriter, r *http.Request, l *zerolog.Logger, err error, lost int) {
+	l.Error().Err(err).Msgf("Custom field operation failed. %v", err)
+	p := newProblem(r, err)
+	if errors.Is(err, service.ErrFieldInUse) {
+		p.Detail = "the change would remove values from notes, repeat it with force=true"
+		p.Extensions = map[string]interface{}{"affected": lost}
+	}
+	lib.WriteProblem(w, p)
+}
+
+// decodeFieldDefinition reads a definition from the body. The key from the
+// URL, if any, takes precedence over the one in the body.
+func decodeFieldDefinition(w http.ResponseWriter, r *http.Request, l *zerolog.Logger, key string) (*db.FieldDefinition, bool) {
+	var req db.FieldDefinition
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		l.Error().Err(err).Msgf("error decoding the custom field. %v", err)
+		writeProblem(w, r, fmt.Errorf("%w: %v", service.ErrMalformedRequest, err))
+		return nil, false
+	}
+	if key != "" {
+		req.Key = key
+	}
+
+	err = service.Validate(&req, service.ErrInvalidRequest)
+	if err != nil {
+		l.Error().Err(err).Msgf("error during custom field validation %v", err)
+		writeProblem(w, r, err)
+		return nil, false
+	}
+	return &req, true
+}
+
+// urlParamProject returns the unescaped project name from the URL.
+func urlParamProject(r *http.Request) (string, error) {
+	return url.PathUnescape(chi.URLParam(r, "project"))
+}
+
+// fieldRequest reads the project and, for routes that have one, the field key
+// from the URL.
+func fieldRequest(w http.ResponseWriter, r *http.Request, l *zerolog.Logger) (string, string, bool) {
+	project, err := urlParamProject(r)
+	if err == nil && project == "" {
+		err = errors.New("project name is empty")
+	}
+	if err != nil {
+		l.Error().Err(err).Msgf("Invalid project in URL")
+		writeProblem(w, r, invalidParam("project", err))
+		return "", "", false
+	}
+	return project, chi.URLParam(r, "key"), true
+}
+
+func CreateFieldDefinition(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		project, _, ok := fieldRequest(w, r, l)
+		if !ok {
+			return
+		}
+		req, ok := decodeFieldDefinition(w, r, l, "")
+		if !ok {
+			return
+		}
+
+		def, err := s.CreateFieldDefinition(ctx, auth.UsernameFromContext(ctx), project, req)
+		if err != nil {
+			writeFieldError(w, r, l, err, 0)
+			return
+		}
+		l.Info().Msgf("Custom field %s has been created in project %s", def.Key, project)
+		lib.JSON(w, def, http.StatusCreated)
+	}
+}
+
+func GetFieldDefinitions(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		project, _, ok := fieldRequest(w, r, l)
+		if !ok {
+			return
+		}
+
+		defs, err := s.GetFieldDefinitions(ctx, auth.UsernameFromContext(ctx), project)
+		if err != nil {
+			writeFieldError(w, r, l, err, 0)
+			return
+		}
+		lib.JSON(w, defs, http.StatusOK)
+	}
+}
+
+// UpdateFieldDefinition changes a custom field and converts the values on the
+// notes. If values cannot be converted the change is refused with 409 unless
+// the force query parameter is true.
+func UpdateFieldDefinition(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		project, key, ok := fieldRequest(w, r, l)
+		if !ok {
+			return
+		}
+		req, ok := decodeFieldDefinition(w, r, l, key)
+		if !ok {
+			return
+		}
+		force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+
+		def, lost, err := s.UpdateFieldDefinition(ctx, auth.UsernameFromContext(ctx), project, key, req, force)
+		if err != nil {
+			writeFieldError(w, r, l, err, lost)
+			return
+		}
+		l.Info().Msgf("Custom field %s in project %s has been updated, %d values removed", key, project, lost)
+		lib.JSON(w, fieldUpdateResponse{def, lost}, http.StatusOK)
+	}
+}
+
+// DeleteFieldDefinition removes a custom field and its values. If notes have
+// values it is refused with 409 unless the force query parameter is true.
+func DeleteFieldDefinition(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		project, key, ok := fieldRequest(w, r, l)
+		if !ok {
+			return
+		}
+		force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+
+		lost, err := s.DeleteFieldDefinition(ctx, auth.UsernameFromContext(ctx), project, key, force)
+		if err != nil {
+			writeFieldError(w, r, l, err, lost)
+			return
+		}
+		l.Info().Msgf("Custom field %s in project %s has been deleted, %d values removed", key, project, lost)
+		lib.JSON(w, fieldDeleteResponse{"custom field deleted", lost}, http.StatusOK)
+	}
+}
+
+// SetTaskFields merges custom field values into a note. A null value clears
+// the field.
+func SetTaskFields(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		taskID, err := urlParamUUID(r, "id")
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not convert ID to UUID.")
+			writeProblem(w, r, invalidParam("id", err))
+			return
+		}
+
+		var values map[string]interface{}
+		err = json.NewDecoder(r.Body).Decode(&values)
+		if err != nil {
+			l.Error().Err(err).Msgf("error decoding the custom field values. %v", err)
+			writeProblem(w, r, fmt.Errorf("%w: %v", service.ErrMalformedRequest, err))
+			return
+		}
+
+		t, err := s.SetTaskFields(ctx, auth.UsernameFromContext(ctx), taskID, values)
+		if err != nil {
+			writeFieldError(w, r, l, err, 0)
+			return
+		}
+		l.Info().Msgf("Custom fields of task %v have been updated", taskID)
+		lib.JSON(w, newTaskResponse(t), http.StatusOK)
+	}
+}
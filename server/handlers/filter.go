@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"tasks/db"
+	"tasks/lib"
+	"tasks/server/auth"
+	"tasks/service"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func writeFilterError(w http.ResponseWriter, r *http.Request, l *zerolog.Logger, err error) {
+	l.Error().Err(err).Msgf("Filter operation failed. %v", err)
+	writeProblem(w, r, err)
+}
+
+func decodeFilter(w http.ResponseWriter, r *http.Request, l *zerolog.Logger) (*db.SavedFilter, bool) {
+	var req db.SavedFilter
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		l.Error().Err(err).Msgf("error decoding the filter. %v", err)
+		writeProblem(w, r, fmt.Errorf("%w: %v", service.ErrMalformedRequest, err))
+		return nil, false
+	}
+
+	err = service.Validate(&req, service.ErrInvalidRequest)
+	if err != nil {
+		l.Error().Err(err).Msgf("error during filter validation %v", err)
+		writeProblem(w, r, err)
+		return nil, false
+	}
+	return &req, true
+}
+
+// writeSearchResult answers a search, resolving relative dates in the time
+// zone given by the tz query parameter.
+func writeSearchResult(w http.ResponseWriter, r *http.Request, l *zerolog.Logger, search func(now time.Time) ([]db.Task, error)) {
+	loc, err := requestLocation(r)
+	if err != nil {
+		l.Error().Err(err).Msgf("Invalid time zone for search")
+		writeProblem(w, r, fmt.Errorf("%w: %v", service.ErrInvalidParameter, err))
+		return
+	}
+
+	tasks, err := search(time.Now().In(loc))
+	if err != nil {
+		writeFilterError(w, r, l, err)
+		return
+	}
+
+	resp, err := newTaskResponses(tasks, r.URL.Query().Get("render") == "html")
+	if err != nil {
+		l.Error().Err(err).Msgf("Could not render note texts")
+		writeProblem(w, r, err)
+		return
+	}
+	lib.JSON(w, resp, http.StatusOK)
+}
+
+// SearchTasks lists the notes matching the filter expression in q, sorted by
+// sort.
+func SearchTasks(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		query := r.URL.Query()
+		writeSearchResult(w, r, l, func(now time.Time) ([]db.Task, error) {
+			return s.SearchTasks(ctx, auth.UsernameFromContext(ctx), query.Get("q"), query.Get("sort"), now)
+		})
+	}
+}
+
+func CreateFilter(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		req, ok := decodeFilter(w, r, l)
+		if !ok {
+			return
+		}
+
+		filter, err := s.CreateFilter(ctx, auth.UsernameFromContext(ctx), req)
+		if err != nil {
+			writeFilterError(w, r, l, err)
+			return
+		}
+		l.Info().Msgf("Filter %v has been created", filter.ID)
+		lib.JSON(w, filter, http.StatusCreated)
+	}
+}
+
+func GetFilters(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		filters, err := s.GetFilters(ctx, auth.UsernameFromContext(ctx))
+		if err != nil {
+			writeFilterError(w, r, l, err)
+			return
+		}
+		lib.JSON(w, filters, http.StatusOK)
+	}
+}
+
+func GetFilter(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		id, err := urlParamUUID(r, "id")
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not convert filter ID to UUID.")
+			writeProblem(w, r, invalidParam("id", err))
+			return
+		}
+
+		filter, err := s.GetFilter(ctx, auth.UsernameFromContext(ctx), id)
+		if err != nil {
+			writeFilterError(w, r, l, err)
+			return
+		}
+		lib.JSON(w, filter, http.StatusOK)
+	}
+}
+
+func UpdateFilter(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		id, err := urlParamUUID(r, "id")
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not convert filter ID to UUID.")
+			writeProblem(w, r, invalidParam("id", err))
+			return
+		}
+
+		req, ok := decodeFilter(w, r, l)
+		if !ok {
+			return
+		}
+
+		filter, err := s.UpdateFilter(ctx, auth.UsernameFromContext(ctx), id, req)
+		if err != nil {
+			writeFilterError(w, r, l, err)
+			return
+		}
+		l.Info().Msgf("Filter %v has been updated", id)
+		lib.JSON(w, filter, http.StatusOK)
+	}
+}
+
+func DeleteFilter(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		id, err := urlParamUUID(r, "id")
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not convert filter ID to UUID.")
+			writeProblem(w, r, invalidParam("id", err))
+			return
+		}
+
+		err = s.DeleteFilter(ctx, auth.UsernameFromContext(ctx), id)
+		if err != nil {
+			writeFilterError(w, r, l, err)
+			return
+		}
+		l.Info().Msgf("Filter %v has been deleted", id)
+		lib.JSON(w, lib.Msg{"success": "filter deleted"}, http.StatusOK)
+	}
+}
+
+// RunFilter lists the notes matching a saved filter. The sort query
+// parameter overrides the stored sort order.
+func RunFilter(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		id, err := urlParamUUID(r, "id")
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not convert filter ID to UUID.")
+			writeProblem(w, r, invalidParam("id", err))
+			return
+		}
+
+		writeSearchResult(w, r, l, func(now time.Time) ([]db.Task, error) {
+			return s.RunFilter(ctx, auth.UsernameFromContext(ctx), id, r.URL.Query().Get("sort"), now)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"tasks/db"
+	"tasks/lib"
+	"tasks/server/auth"
+	"tasks/service"
+
+	"github.com/google/uuid"
+)
+
+// maxGraphQLSize bounds the size of a GraphQL request.
+const maxGraphQLSize = 1 << 20
+
+// graphQLResponse documents the body of a response of GraphQL.
+type graphQLResponse struct {
+	Data   map[string]interface{} `json:"data,omitempty"`
+	Errors []lib.GraphQLError     `json:"errors,omitempty"`
+}
+
+// graphQLStateKey is the context key of the graphQLState of a request.
+type graphQLStateKey struct{}
+
+// graphQLState is what the resolvers of a GraphQL request share: the user
+// and the loaders batching their reads, so that a field selected on every
+// task of a list is read once for all of them.
+type graphQLState struct {
+	s        TaskService
+	username string
+
+	tasks        *lib.Loader // *db.Task by uuid.UUID
+	subtasks     *lib.Loader // []db.Task by the uuid.UUID of the parent
+	projectTasks *lib.Loader // map[string][]db.Task by project, by taskSearch
+	timeEntries  *lib.Loader // []db.TimeEntry by the uuid.UUID of the task
+	fields       *lib.Loader // []db.FieldDefinition by project
+}
+
+// taskSearch is a search of the tasks of a project, which is run once for
+// all projects.
+type taskSearch struct {
+	filter string
+	sort   string
+}
+
+func newGraphQLState(ctx context.Context, s TaskService, username string) *graphQLState {
+	st := &graphQLState{s: s, username: username}
+	st.tasks = lib.NewLoader(func(keys []interface{}) (map[interface{}]interface{}, error) {
+		ids := make([]uuid.UUID, len(keys))
+		for i, key := range keys {
+			ids[i] = key.(uuid.UUID)
+		}
+		tasks, err := s.GetTasks(ctx, username, ids)
+		if err != nil {
+			return nil, err
+		}
+		found := map[interface{}]interface{}{}
+		for id, t := range tasks {
+			found[id] = t
+		}
+		return found, nil
+	})
+	st.subtasks = lib.NewLoader(func(keys []interface{}) (map[interface{}]interface{}, error) {
+		tasks, err := s.GetAllTasksFromUser(ctx, username)
+		if err != nil {
+			return nil, err
+		}
+		found := map[interface{}]interface{}{}
+		for _, key := range keys {
+			found[key] = []db.Task{}
+		}
+		for _, t := range tasks {
+			if t.ParentID == nil {
+				continue
+			}
+			if children, ok := found[*t.ParentID]; ok {
+				found[*t.ParentID] = append(children.([]db.Task), t)
+			}
+		}
+		return found, nil
+	})
+	st.projectTasks = lib.NewLoader(func(keys []interface{}) (map[interface{}]interface{}, error) {
+		now := time.Now()
+		found := map[interface{}]interface{}{}
+		for _, key := range keys {
+			search := key.(taskSearch)
+			tasks, err := s.SearchTasks(ctx, username, search.filter, search.sort, now)
+			if err != nil {
+				return nil, err
+			}
+			byProject := map[string][]db.Task{}
+			for _, t := range tasks {
+				byProject[t.Project] = append(byProject[t.Project], t)
+			}
+			found[key] = byProject
+		}
+		return found, nil
+	})
+	st.timeEntries = lib.NewLoader(func(keys []interface{}) (map[interface{}]interface{}, error) {
+		ids := make([]uuid.UUID, len(keys))
+		for i, key := range keys {
+			ids[i] = key.(uuid.UUID)
+		}
+		entries, err := s.GetTimeEntriesByTask(ctx, username, ids)
+		if err != nil {
+			return nil, err
+		}
+		found := map[interface{}]interface{}{}
+		for id, list := range entries {
+			found[id] = list
+		}
+		return found, nil
+	})
+	st.fields = lib.NewLoader(func(keys []interface{}) (map[interface{}]interface{}, error) {
+		defs, err := s.GetAllFieldDefinitions(ctx, username)
+		if err != nil {
+			return nil, err
+		}
+		found := map[interface{}]interface{}{}
+		for _, key := range keys {
+			found[key] = []db.FieldDefinition{}
+		}
+		for _, def := range defs {
+			if list, ok := found[def.Project]; ok {
+				found[def.Project] = append(list.([]db.FieldDefinition), def)
+			}
+		}
+		return found, nil
+	})
+	return st
+}
+
+// changed forgets what the loaders read, after a mutation.
+func (st *graphQLState) changed() {
+	for _, l := range []*lib.Loader{st.tasks, st.subtasks, st.projectTasks, st.timeEntries, st.fields} {
+		l.Clear()
+	}
+}
+
+func graphQLStateFrom(ctx context.Context) *graphQLState {
+	return ctx.Value(graphQLStateKey{}).(*graphQLState)
+}
+
+// GraphQL runs a GraphQL query or mutation over the notes, projects and
+// user of the request. Requests the schema refuses and errors of fields are
+// reported in the errors of the response, with the kind of the error as
+// their code.
+func GraphQL(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		var req lib.GraphQLRequest
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxGraphQLSize))
+		// Variables keep the precision of their numbers.
+		dec.UseNumber()
+		if err := dec.Decode(&req); err != nil {
+			l.Error().Err(err).Msgf("error decoding the GraphQL request. %v", err)
+			writeProblem(w, r, fmt.Errorf("%w: %v", service.ErrMalformedRequest, err))
+			return
+		}
+		if strings.TrimSpace(req.Query) == "" {
+			writeProblem(w, r, fmt.Errorf("%w: query is required", service.ErrInvalidRequest))
+			return
+		}
+
+		username := auth.UsernameFromContext(ctx)
+		ctx = context.WithValue(ctx, graphQLStateKey{}, newGraphQLState(ctx, s, username))
+		resp := graphQLSchema.Execute(ctx, req)
+		for _, e := range resp.Errors {
+			kind := service.KindOf(e.Err)
+			if kind == service.KindInternal {
+				l.Error().Err(e.Err).Msgf("GraphQL field %v failed", e.Path)
+				e.Message = "internal error"
+			}
+			e.Extensions = map[string]interface{}{"code": kind}
+			var verr *service.ValidationError
+			if errors.As(e.Err, &verr) {
+				e.Extensions["errors"] = verr.Fields
+			}
+		}
+		l.Info().Msgf("GraphQL request of %s ran with %d errors", username, len(resp.Errors))
+		lib.JSON(w, resp, http.StatusOK)
+	}
+}
+
+// GetGraphQLSchema serves the schema of the GraphQL endpoint in the schema
+// definition language.
+func GetGraphQLSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(graphQLSchema.SDL()))
+}
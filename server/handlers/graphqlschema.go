@@ -0,0 +1,424 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"tasks/db"
+	"tasks/lib"
+	"tasks/service"
+
+	"github.com/google/uuid"
+)
+
+// Limits of the GraphQL requests. A list of tasks counts as the number of
+// tasks it asks for, lists without pagination as listComplexity items.
+const (
+	graphQLMaxDepth      = 10
+	graphQLMaxComplexity = 5000
+	defaultPageSize      = 50
+	maxPageSize          = 100
+	listComplexity       = 10
+)
+
+// graphQLProject is a project of the user, named after the tasks in it.
+type graphQLProject struct {
+	Name string `json:"name"`
+}
+
+type taskConnection struct {
+	Edges      []taskEdge   `json:"edges"`
+	Nodes      []db.Task    `json:"nodes"`
+	PageInfo   taskPageInfo `json:"pageInfo"`
+	TotalCount int          `json:"totalCount"`
+}
+
+type taskEdge struct {
+	Cursor string   `json:"cursor"`
+	Node   *db.Task `json:"node"`
+}
+
+type taskPageInfo struct {
+	HasNextPage bool    `json:"hasNextPage"`
+	EndCursor   *string `json:"endCursor"`
+}
+
+func taskCursor(id uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id.String()))
+}
+
+// newTaskConnection returns the page of first tasks after the task of the
+// cursor.
+func newTaskConnection(tasks []db.Task, first int, after string) (*taskConnection, error) {
+	if first < 0 || first > maxPageSize {
+		return nil, invalidParam("first", fmt.Errorf("must be between 0 and %d", maxPageSize))
+	}
+	start := 0
+	if after != "" {
+		start = -1
+		for i := range tasks {
+			if taskCursor(tasks[i].ID) == after {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			return nil, invalidParam("after", fmt.Errorf("unknown cursor %q", after))
+		}
+	}
+	end := start + first
+	if end > len(tasks) {
+		end = len(tasks)
+	}
+	c := &taskConnection{
+		Edges:      make([]taskEdge, 0, end-start),
+		Nodes:      tasks[start:end],
+		PageInfo:   taskPageInfo{HasNextPage: end < len(tasks)},
+		TotalCount: len(tasks),
+	}
+	for i := start; i < end; i++ {
+		c.Edges = append(c.Edges, taskEdge{Cursor: taskCursor(tasks[i].ID), Node: &tasks[i]})
+	}
+	if len(c.Edges) > 0 {
+		c.PageInfo.EndCursor = &c.Edges[len(c.Edges)-1].Cursor
+	}
+	return c, nil
+}
+
+func uuidArg(p lib.GraphQLParams, name string) (uuid.UUID, error) {
+	id, err := uuid.Parse(p.Args[name].(string))
+	if err != nil {
+		return uuid.Nil, invalidParam(name, err)
+	}
+	return id, nil
+}
+
+func stringArg(p lib.GraphQLParams, name string) string {
+	s, _ := p.Args[name].(string)
+	return s
+}
+
+// decodeInput reads an input object into v through its JSON form, so that
+// the input fields are named as the members of the REST bodies.
+func decodeInput(input interface{}, v interface{}) error {
+	b, err := json.Marshal(input)
+	if err == nil {
+		err = json.Unmarshal(b, v)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", service.ErrMalformedRequest, err)
+	}
+	return nil
+}
+
+// optional resolves an empty string as null.
+func optional(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func connectionComplexity(args map[string]interface{}, child int) int {
+	return 1 + args["first"].(int)*child
+}
+
+func listFieldComplexity(args map[string]interface{}, child int) int {
+	return 1 + listComplexity*child
+}
+
+var (
+	graphQLTime = &lib.GraphQLScalar{
+		Name:        "Time",
+		Description: "A point in time in RFC 3339 format.",
+		Serialize: func(v interface{}) (interface{}, error) {
+			t, ok := v.(time.Time)
+			if !ok {
+				return nil, fmt.Errorf("Time cannot represent %v", v)
+			}
+			return t.Format(time.RFC3339Nano), nil
+		},
+		Parse: func(v interface{}) (interface{}, error) {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("Time cannot represent %v", v)
+			}
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return nil, fmt.Errorf("Time cannot represent %q, it is not in RFC 3339 format", s)
+			}
+			return t, nil
+		},
+	}
+	graphQLJSON = &lib.GraphQLScalar{
+		Name:        "JSON",
+		Description: "Any JSON value.",
+		Serialize:   func(v interface{}) (interface{}, error) { return v, nil },
+		Parse:       func(v interface{}) (interface{}, error) { return v, nil },
+	}
+
+	graphQLStatus = &lib.GraphQLEnum{Name: "Status", Values: []lib.GraphQLEnumValue{
+		{Name: "TODO", Value: db.StatusTodo},
+		{Name: "IN_PROGRESS", Value: db.StatusInProgress},
+		{Name: "DONE", Value: db.StatusDone},
+	}}
+	graphQLPriority = &lib.GraphQLEnum{Name: "Priority", Values: []lib.GraphQLEnumValue{
+		{Name: "LOW", Value: "low"},
+		{Name: "MEDIUM", Value: "medium"},
+		{Name: "HIGH", Value: "high"},
+	}}
+
+	graphQLUser = &lib.GraphQLObject{Name: "User", Fields: []*lib.GraphQLField{
+		{Name: "username", Type: lib.GraphQLNonNullOf(lib.GraphQLString)},
+		{Name: "email", Type: lib.GraphQLNonNullOf(lib.GraphQLString)},
+	}}
+	graphQLChecklistItem = &lib.GraphQLObject{Name: "ChecklistItem", Fields: []*lib.GraphQLField{
+		{Name: "id", Type: lib.GraphQLNonNullOf(lib.GraphQLID)},
+		{Name: "text", Type: lib.GraphQLNonNullOf(lib.GraphQLString)},
+		{Name: "done", Type: lib.GraphQLNonNullOf(lib.GraphQLBoolean)},
+	}}
+	graphQLTimeEntry = &lib.GraphQLObject{Name: "TimeEntry", Fields: []*lib.GraphQLField{
+		{Name: "id", Type: lib.GraphQLNonNullOf(lib.GraphQLID)},
+		{Name: "start", Type: lib.GraphQLNonNullOf(graphQLTime)},
+		{Name: "end", Type: graphQLTime},
+		{Name: "note", Type: lib.GraphQLNonNullOf(lib.GraphQLString)},
+		{Name: "running", Type: lib.GraphQLNonNullOf(lib.GraphQLBoolean), Resolve: func(p lib.GraphQLParams) (interface{}, error) {
+			return p.Source.(*db.TimeEntry).Running(), nil
+		}},
+		{Name: "seconds", Description: "Tracked time, up to now for a running entry.", Type: lib.GraphQLNonNullOf(lib.GraphQLInt),
+			Resolve: func(p lib.GraphQLParams) (interface{}, error) {
+				return int(p.Source.(*db.TimeEntry).Duration(time.Now()).Seconds()), nil
+			}},
+	}}
+	graphQLFieldDefinition = &lib.GraphQLObject{Name: "FieldDefinition", Fields: []*lib.GraphQLField{
+		{Name: "key", Type: lib.GraphQLNonNullOf(lib.GraphQLString)},
+		{Name: "name", Type: lib.GraphQLNonNullOf(lib.GraphQLString)},
+		{Name: "type", Type: lib.GraphQLNonNullOf(lib.GraphQLString)},
+		{Name: "options", Type: lib.GraphQLNonNullOf(lib.GraphQLListOf(lib.GraphQLNonNullOf(lib.GraphQLString)))},
+	}}
+
+	graphQLTask        = &lib.GraphQLObject{Name: "Task", Description: "A note of the user."}
+	graphQLProjectType = &lib.GraphQLObject{Name: "Project", Description: "The notes of the user sharing a project; the empty name holds the notes without one."}
+	graphQLTaskEdge    = &lib.GraphQLObject{Name: "TaskEdge", Fields: []*lib.GraphQLField{
+		{Name: "cursor", Type: lib.GraphQLNonNullOf(lib.GraphQLString)},
+		{Name: "node", Type: lib.GraphQLNonNullOf(graphQLTask)},
+	}}
+	graphQLPageInfo = &lib.GraphQLObject{Name: "PageInfo", Fields: []*lib.GraphQLField{
+		{Name: "hasNextPage", Type: lib.GraphQLNonNullOf(lib.GraphQLBoolean)},
+		{Name: "endCursor", Type: lib.GraphQLString},
+	}}
+	graphQLTaskConnection = &lib.GraphQLObject{Name: "TaskConnection", Description: "A page of notes.", Fields: []*lib.GraphQLField{
+		{Name: "edges", Type: lib.GraphQLNonNullOf(lib.GraphQLListOf(lib.GraphQLNonNullOf(graphQLTaskEdge)))},
+		{Name: "nodes", Type: lib.GraphQLNonNullOf(lib.GraphQLListOf(lib.GraphQLNonNullOf(graphQLTask)))},
+		{Name: "pageInfo", Type: lib.GraphQLNonNullOf(graphQLPageInfo)},
+		{Name: "totalCount", Description: "Number of notes on all pages.", Type: lib.GraphQLNonNullOf(lib.GraphQLInt)},
+	}}
+
+	// taskListArgs are the arguments of a paginated search of notes.
+	taskListArgs = []*lib.GraphQLArgument{
+		{Name: "filter", Description: "Query in the syntax of /notes/search.", Type: lib.GraphQLString},
+		{Name: "sort", Description: "Sort order in the syntax of /notes/search.", Type: lib.GraphQLString},
+		{Name: "first", Description: fmt.Sprintf("Number of notes, at most %d.", maxPageSize), Type: lib.GraphQLNonNullOf(lib.GraphQLInt), Default: defaultPageSize},
+		{Name: "after", Description: "Cursor of the note to start after.", Type: lib.GraphQLString},
+	}
+
+	graphQLCreateTaskInput = &lib.GraphQLInputObject{Name: "CreateTaskInput", Fields: []*lib.GraphQLArgument{
+		{Name: "title", Type: lib.GraphQLNonNullOf(lib.GraphQLString)},
+		{Name: "text", Type: lib.GraphQLString},
+		{Name: "project", Type: lib.GraphQLString},
+		{Name: "status", Type: graphQLStatus},
+		{Name: "priority", Type: graphQLPriority},
+		{Name: "tags", Type: lib.GraphQLListOf(lib.GraphQLNonNullOf(lib.GraphQLString))},
+		{Name: "due", Type: graphQLTime},
+		{Name: "recurrence", Type: lib.GraphQLString},
+		{Name: "deferUntil", Type: graphQLTime},
+		{Name: "someday", Type: lib.GraphQLBoolean},
+		{Name: "fields", Description: "Values of the custom fields of the project by key.", Type: graphQLJSON},
+	}}
+	graphQLUpdateTaskInput = &lib.GraphQLInputObject{
+		Name:        "UpdateTaskInput",
+		Description: "Changes to a note as a JSON Merge Patch: fields left out are kept, fields set to null are removed.",
+		Fields: []*lib.GraphQLArgument{
+			{Name: "title", Type: lib.GraphQLString},
+			{Name: "text", Type: lib.GraphQLString},
+			{Name: "project", Type: lib.GraphQLString},
+			{Name: "status", Type: graphQLStatus},
+			{Name: "priority", Type: graphQLPriority},
+			{Name: "tags", Type: lib.GraphQLListOf(lib.GraphQLNonNullOf(lib.GraphQLString))},
+			{Name: "due", Type: graphQLTime},
+			{Name: "recurrence", Type: lib.GraphQLString},
+			{Name: "deferUntil", Type: graphQLTime},
+			{Name: "someday", Type: lib.GraphQLBoolean},
+			{Name: "fields", Description: "Values of the custom fields of the project by key.", Type: graphQLJSON},
+		},
+	}
+)
+
+// graphQLSchema is the schema served by GraphQL.
+var graphQLSchema *lib.GraphQLSchema
+
+func init() {
+	// Tasks and projects refer to each other, so their fields are set once
+	// both exist.
+	graphQLTask.Fields = []*lib.GraphQLField{
+		{Name: "id", Type: lib.GraphQLNonNullOf(lib.GraphQLID)},
+		{Name: "title", Type: lib.GraphQLNonNullOf(lib.GraphQLString)},
+		{Name: "text", Type: lib.GraphQLNonNullOf(lib.GraphQLString)},
+		{Name: "project", Type: lib.GraphQLNonNullOf(graphQLProjectType), Resolve: func(p lib.GraphQLParams) (interface{}, error) {
+			return &graphQLProject{Name: p.Source.(*db.Task).Project}, nil
+		}},
+		{Name: "status", Type: graphQLStatus, Resolve: func(p lib.GraphQLParams) (interface{}, error) {
+			return optional(p.Source.(*db.Task).Status), nil
+		}},
+		{Name: "priority", Type: graphQLPriority, Resolve: func(p lib.GraphQLParams) (interface{}, error) {
+			return optional(p.Source.(*db.Task).Priority), nil
+		}},
+		{Name: "tags", Type: lib.GraphQLNonNullOf(lib.GraphQLListOf(lib.GraphQLNonNullOf(lib.GraphQLString)))},
+		{Name: "rank", Type: lib.GraphQLString},
+		{Name: "due", Type: graphQLTime},
+		{Name: "recurrence", Type: lib.GraphQLString},
+		{Name: "deferUntil", Type: graphQLTime},
+		{Name: "someday", Type: lib.GraphQLNonNullOf(lib.GraphQLBoolean)},
+		{Name: "checklist", Type: lib.GraphQLNonNullOf(lib.GraphQLListOf(lib.GraphQLNonNullOf(graphQLChecklistItem)))},
+		{Name: "fields", Description: "Values of the custom fields by key.", Type: graphQLJSON},
+		{Name: "parent", Type: graphQLTask, Resolve: func(p lib.GraphQLParams) (interface{}, error) {
+			parent := p.Source.(*db.Task).ParentID
+			if parent == nil {
+				return nil, nil
+			}
+			return graphQLStateFrom(p.Context).tasks.Load(*parent), nil
+		}},
+		{Name: "subtasks", Type: lib.GraphQLNonNullOf(lib.GraphQLListOf(lib.GraphQLNonNullOf(graphQLTask))), Complexity: listFieldComplexity,
+			Resolve: func(p lib.GraphQLParams) (interface{}, error) {
+				return graphQLStateFrom(p.Context).subtasks.Load(p.Source.(*db.Task).ID), nil
+			}},
+		{Name: "timeEntries", Type: lib.GraphQLNonNullOf(lib.GraphQLListOf(lib.GraphQLNonNullOf(graphQLTimeEntry))), Complexity: listFieldComplexity,
+			Resolve: func(p lib.GraphQLParams) (interface{}, error) {
+				return graphQLStateFrom(p.Context).timeEntries.Load(p.Source.(*db.Task).ID), nil
+			}},
+		{Name: "createdAt", Type: lib.GraphQLNonNullOf(graphQLTime)},
+		{Name: "updatedAt", Type: lib.GraphQLNonNullOf(graphQLTime)},
+		{Name: "completedAt", Type: graphQLTime},
+	}
+
+	graphQLProjectType.Fields = []*lib.GraphQLField{
+		{Name: "name", Type: lib.GraphQLNonNullOf(lib.GraphQLString)},
+		{Name: "tasks", Args: taskListArgs, Type: lib.GraphQLNonNullOf(graphQLTaskConnection), Complexity: connectionComplexity,
+			Resolve: func(p lib.GraphQLParams) (interface{}, error) {
+				project := p.Source.(*graphQLProject).Name
+				load := graphQLStateFrom(p.Context).projectTasks.Load(taskSearch{filter: stringArg(p, "filter"), sort: stringArg(p, "sort")})
+				return func() (interface{}, error) {
+					byProject, err := load()
+					if err != nil {
+						return nil, err
+					}
+					return newTaskConnection(byProject.(map[string][]db.Task)[project], p.Args["first"].(int), stringArg(p, "after"))
+				}, nil
+			}},
+		{Name: "fieldDefinitions", Type: lib.GraphQLNonNullOf(lib.GraphQLListOf(lib.GraphQLNonNullOf(graphQLFieldDefinition))), Complexity: listFieldComplexity,
+			Resolve: func(p lib.GraphQLParams) (interface{}, error) {
+				return graphQLStateFrom(p.Context).fields.Load(p.Source.(*graphQLProject).Name), nil
+			}},
+	}
+
+	var err error
+	graphQLSchema, err = lib.NewGraphQLSchema(graphQLQuery, graphQLMutation, graphQLMaxDepth, graphQLMaxComplexity)
+	if err != nil {
+		panic(err)
+	}
+}
+
+var graphQLQuery = &lib.GraphQLObject{Name: "Query", Fields: []*lib.GraphQLField{
+	{Name: "me", Type: lib.GraphQLNonNullOf(graphQLUser), Resolve: func(p lib.GraphQLParams) (interface{}, error) {
+		st := graphQLStateFrom(p.Context)
+		return st.s.GetUser(p.Context, st.username)
+	}},
+	{Name: "task", Args: []*lib.GraphQLArgument{{Name: "id", Type: lib.GraphQLNonNullOf(lib.GraphQLID)}}, Type: graphQLTask,
+		Resolve: func(p lib.GraphQLParams) (interface{}, error) {
+			id, err := uuidArg(p, "id")
+			if err != nil {
+				return nil, err
+			}
+			return graphQLStateFrom(p.Context).tasks.Load(id), nil
+		}},
+	{Name: "tasks", Description: "Searches the notes of all projects.", Args: taskListArgs, Type: lib.GraphQLNonNullOf(graphQLTaskConnection), Complexity: connectionComplexity,
+		Resolve: func(p lib.GraphQLParams) (interface{}, error) {
+			st := graphQLStateFrom(p.Context)
+			tasks, err := st.s.SearchTasks(p.Context, st.username, stringArg(p, "filter"), stringArg(p, "sort"), time.Now())
+			if err != nil {
+				return nil, err
+			}
+			return newTaskConnection(tasks, p.Args["first"].(int), stringArg(p, "after"))
+		}},
+	{Name: "projects", Type: lib.GraphQLNonNullOf(lib.GraphQLListOf(lib.GraphQLNonNullOf(graphQLProjectType))), Complexity: listFieldComplexity,
+		Resolve: func(p lib.GraphQLParams) (interface{}, error) {
+			st := graphQLStateFrom(p.Context)
+			names, err := st.s.GetCalendars(p.Context, st.username)
+			if err != nil {
+				return nil, err
+			}
+			projects := make([]graphQLProject, len(names))
+			for i, name := range names {
+				projects[i].Name = name
+			}
+			return projects, nil
+		}},
+	{Name: "project", Args: []*lib.GraphQLArgument{{Name: "name", Type: lib.GraphQLNonNullOf(lib.GraphQLString)}}, Type: lib.GraphQLNonNullOf(graphQLProjectType),
+		Resolve: func(p lib.GraphQLParams) (interface{}, error) {
+			return &graphQLProject{Name: p.Args["name"].(string)}, nil
+		}},
+}}
+
+var graphQLMutation = &lib.GraphQLObject{Name: "Mutation", Fields: []*lib.GraphQLField{
+	{Name: "createTask", Args: []*lib.GraphQLArgument{{Name: "input", Type: lib.GraphQLNonNullOf(graphQLCreateTaskInput)}}, Type: lib.GraphQLNonNullOf(graphQLTask),
+		Resolve: func(p lib.GraphQLParams) (interface{}, error) {
+			st := graphQLStateFrom(p.Context)
+			var t db.Task
+			if err := decodeInput(p.Args["input"], &t); err != nil {
+				return nil, err
+			}
+			t.User = st.username
+			if err := service.Validate(&t, service.ErrInvalidRequest); err != nil {
+				return nil, err
+			}
+			id, err := st.s.CreateTask(p.Context, &t)
+			if err != nil {
+				return nil, err
+			}
+			st.changed()
+			return st.tasks.Load(id), nil
+		}},
+	{Name: "updateTask", Args: []*lib.GraphQLArgument{{Name: "id", Type: lib.GraphQLNonNullOf(lib.GraphQLID)}, {Name: "input", Type: lib.GraphQLNonNullOf(graphQLUpdateTaskInput)}},
+		Type: lib.GraphQLNonNullOf(graphQLTask),
+		Resolve: func(p lib.GraphQLParams) (interface{}, error) {
+			st := graphQLStateFrom(p.Context)
+			id, err := uuidArg(p, "id")
+			if err != nil {
+				return nil, err
+			}
+			patch, err := json.Marshal(p.Args["input"])
+			if err != nil {
+				return nil, err
+			}
+			t, err := st.s.PatchTask(p.Context, st.username, id, lib.MergePatchContentType, patch)
+			if err != nil {
+				return nil, err
+			}
+			st.changed()
+			return t, nil
+		}},
+	{Name: "deleteTask", Args: []*lib.GraphQLArgument{{Name: "id", Type: lib.GraphQLNonNullOf(lib.GraphQLID)}}, Type: lib.GraphQLNonNullOf(lib.GraphQLID),
+		Resolve: func(p lib.GraphQLParams) (interface{}, error) {
+			st := graphQLStateFrom(p.Context)
+			id, err := uuidArg(p, "id")
+			if err != nil {
+				return nil, err
+			}
+			if _, err := st.s.DeleteTask(p.Context, st.username, id); err != nil {
+				return nil, err
+			}
+			st.changed()
+			return id, nil
+		}},
+}}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"tasks/lib"
+	"tasks/server/auth"
+)
+
+// maxICalImportSize bounds the size of an uploaded calendar.
+const maxICalImportSize = 10 << 20
+
+// ExportICal serves the notes of the user as an iCalendar file of VTODOs.
+func ExportICal(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		tasks, err := s.GetAllTasksFromUser(ctx, auth.UsernameFromContext(ctx))
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not fetch notes for iCalendar export")
+			writeProblem(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="tasks.ics"`)
+		w.WriteHeader(http.StatusOK)
+		if err := lib.WriteICal(w, tasks, time.Now()); err != nil {
+			l.Error().Err(err).Msgf("Could not write iCalendar export")
+		}
+	}
+}
+
+// ImportICal upserts the VTODOs of an iCalendar file sent as the request
+// body and reports the components it skipped or could not import.
+func ImportICal(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		body := http.MaxBytesReader(w, r.Body, maxICalImportSize)
+		result, err := s.ImportICal(ctx, auth.UsernameFromContext(ctx), body)
+		switch {
+		case err != nil:
+			l.Error().Err(err).Msgf("iCalendar import failed. %v", err)
+			writeProblem(w, r, err)
+		default:
+			l.Info().Msgf("iCalendar import created %d and updated %d notes", result.Created, result.Updated)
+			lib.JSON(w, result, http.StatusOK)
+		}
+	}
+}
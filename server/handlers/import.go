@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"tasks/lib"
+	"tasks/server/auth"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxImportSize bounds the size of an uploaded export file. Board exports
+// with their history of actions get large.
+const maxImportSize = 50 << 20
+
+// ImportFrom imports the JSON export of another service, named by the source
+// URL parameter (todoist or trello), and reports what was created, updated
+// and left out. Importing the same file again does not duplicate notes.
+func ImportFrom(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		source := chi.URLParam(r, "source")
+		body := http.MaxBytesReader(w, r.Body, maxImportSize)
+		result, err := s.ImportFrom(ctx, auth.UsernameFromContext(ctx), source, body)
+		switch {
+		case err != nil:
+			l.Error().Err(err).Msgf("%s import failed. %v", source, err)
+			writeProblem(w, r, err)
+		default:
+			l.Info().Msgf("%s import created %d, updated %d and kept %d notes", source, result.Created, result.Updated, result.Unchanged)
+			lib.JSON(w, result, http.StatusOK)
+		}
+	}
+}
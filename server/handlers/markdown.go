@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"tasks/db"
+	"tasks/lib"
+	"tasks/server/auth"
+	"tasks/service"
+)
+
+// maxMarkdownImportSize bounds the size of an uploaded Markdown file or
+// archive.
+const maxMarkdownImportSize = 20 << 20
+
+// ExportMarkdown serves the notes of the user as a single Markdown document,
+// or with format=zip as a ZIP archive of one Markdown file per note, with
+// its metadata as front matter, that ImportMarkdown reads back. project
+// restricts the export to one project and q to the notes matching a filter.
+func ExportMarkdown(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		format := r.URL.Query().Get("format")
+		if format != "" && format != "md" && format != "zip" {
+			writeProblem(w, r, fmt.Errorf("%w: format must be md or zip", service.ErrInvalidParameter))
+			return
+		}
+
+		username := auth.UsernameFromContext(ctx)
+		var tasks []db.Task
+		var err error
+		if q := r.URL.Query().Get("q"); q != "" {
+			tasks, err = s.SearchTasks(ctx, username, q, "", time.Now())
+		} else {
+			tasks, err = s.GetAllTasksFromUser(ctx, username)
+		}
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not fetch notes for Markdown export")
+			writeProblem(w, r, err)
+			return
+		}
+
+		title := "Tasks"
+		if project, ok := r.URL.Query()["project"]; ok {
+			title = project[0]
+			kept := tasks[:0]
+			for _, t := range tasks {
+				if t.Project == project[0] {
+					kept = append(kept, t)
+				}
+			}
+			tasks = kept
+		}
+
+		if format == "zip" {
+			w.Header().Set("Content-Type", "application/zip")
+			w.Header().Set("Content-Disposition", `attachment; filename="tasks.zip"`)
+			w.WriteHeader(http.StatusOK)
+			err = lib.WriteMarkdownArchive(w, tasks, time.Now())
+		} else {
+			w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+			w.Header().Set("Content-Disposition", `attachment; filename="tasks.md"`)
+			w.WriteHeader(http.StatusOK)
+			err = lib.WriteMarkdownDocument(w, title, tasks)
+		}
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not write Markdown export")
+		}
+	}
+}
+
+// ImportMarkdown upserts the notes of a ZIP archive of Markdown files, or of
+// a single Markdown file, sent as the request body and reports the files it
+// could not import.
+func ImportMarkdown(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		body := http.MaxBytesReader(w, r.Body, maxMarkdownImportSize)
+		result, err := s.ImportMarkdown(ctx, auth.UsernameFromContext(ctx), body)
+		switch {
+		case err != nil:
+			l.Error().Err(err).Msgf("Markdown import failed. %v", err)
+			writeProblem(w, r, err)
+		default:
+			l.Info().Msgf("Markdown import created %d and updated %d notes", result.Created, result.Updated)
+			lib.JSON(w, result, http.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	_ "embed"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"tasks/db"
+	"tasks/lib"
+	"tasks/service"
+)
+
+// apiDocsPage renders the OpenAPI document served next to it without
+// loading anything from elsewhere.
+//
+//go:embed openapi.html
+var apiDocsPage []byte
+
+// rawBody is a body that is not JSON, in one of the media types.
+type rawBody []string
+
+func raw(mediaTypes ...string) rawBody {
+	return mediaTypes
+}
+
+// typedBody is a JSON body that comes in several media types, each with a
+// schema of its own.
+type typedBody map[string]interface{}
+
+// problemBody is an error body served as problem details, of a type that
+// extends lib.Problem.
+type problemBody struct {
+	details interface{}
+}
+
+// apiParam is a query parameter of an operation.
+type apiParam struct {
+	name        string
+	description string
+	values      []string
+	repeated    bool
+}
+
+// apiOperation describes a route for the OpenAPI document. Its path is
+// relative to APIv1Root unless the route is unversioned. request and the
+// values of responses are Go values whose type is the JSON body, a rawBody,
+// a typedBody or a problemBody. Other errors are documented as lib.Problem.
+type apiOperation struct {
+	method      string
+	path        string
+	unversioned bool
+	id          string
+	tag         string
+	summary     string
+	auth        string
+	query       []apiParam
+	request     interface{}
+	responses   map[int]interface{}
+}
+
+const (
+	authPaseto = "paseto"
+	authBasic  = "basic"
+)
+
+var (
+	renderParam    = apiParam{name: "render", description: "html adds the text rendered as HTML", values: []string{"html"}}
+	tzParam        = apiParam{name: "tz", description: "IANA time zone relative dates are resolved in, UTC by default"}
+	sortParam      = apiParam{name: "sort", description: "comma separated fields, - for descending order"}
+	filterParam    = apiParam{name: "q", description: "filter expression"}
+	fromParam      = apiParam{name: "from", description: "date or RFC 3339 timestamp"}
+	toParam        = apiParam{name: "to", description: "date or RFC 3339 timestamp"}
+	forceParam     = apiParam{name: "force", description: "true to remove values that cannot be kept", values: []string{"true", "false"}}
+	taskResponses  = map[int]interface{}{http.StatusOK: []taskResponse{}}
+	messageOK      = map[int]interface{}{http.StatusOK: lib.Msg{}}
+	noteOK         = map[int]interface{}{http.StatusOK: taskResponse{}}
+	davMethods     = []string{http.MethodOptions, http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, "PROPFIND", "REPORT"}
+	pathParamRegex = regexp.MustCompile(`\{(\w+)\}`)
+)
+
+// apiOperations lists every route of the API. A test checks it against the
+// routes of the router.
+var apiOperations = []apiOperation{
+	{method: "POST", path: "/register", id: "registerUser", tag: "users", summary: "Register a user",
+		request: db.User{}, responses: map[int]interface{}{http.StatusCreated: lib.Msg{}}},
+	{method: "POST", path: "/login", id: "loginUser", tag: "users", summary: "Log in and receive the paseto cookie",
+		request: loginRequest{}, responses: messageOK},
+	{method: "POST", path: "/logout", id: "logoutUser", tag: "users", summary: "Log out and clear the paseto cookie",
+		responses: messageOK},
+	{method: "GET", path: "/openapi.json", id: "getOpenAPI", tag: "docs", summary: "This document",
+		responses: map[int]interface{}{http.StatusOK: raw("application/json")}},
+	{method: "GET", path: "/docs", id: "getAPIDocs", tag: "docs", summary: "Documentation of the API",
+		responses: map[int]interface{}{http.StatusOK: raw("text/html")}},
+
+	{method: "POST", path: "/notes/create", id: "createTask", tag: "notes", summary: "Create a note", auth: authPaseto,
+		request: db.Task{}, responses: map[int]interface{}{http.StatusCreated: lib.Msg{}}},
+	{method: "GET", path: "/notes", id: "getTasks", tag: "notes", summary: "List the notes of the user, without the snoozed ones unless all is true", auth: authPaseto,
+		query: []apiParam{
+			{name: "status", values: []string{db.StatusTodo, db.StatusInProgress, db.StatusDone}},
+			{name: "project"},
+			{name: "all", values: []string{"true", "false"}},
+			renderParam,
+		}, responses: taskResponses},
+	{method: "PUT", path: "/notes/{id}", id: "updateTask", tag: "notes", summary: "Change the title and text of a note", auth: authPaseto,
+		request: updateTaskRequest{}, responses: messageOK},
+	{method: "PATCH", path: "/notes/{id}", id: "patchTask", tag: "notes", summary: "Change a note with a JSON Merge Patch or a JSON Patch", auth: authPaseto,
+		request:   typedBody{lib.MergePatchContentType: map[string]interface{}{}, lib.JSONPatchContentType: []lib.PatchOperation{}},
+		responses: noteOK},
+	{method: "DELETE", path: "/notes/{id}", id: "deleteTask", tag: "notes", summary: "Delete a note", auth: authPaseto,
+		responses: messageOK},
+	{method: "POST", path: "/notes/{id}/move", id: "moveTask", tag: "notes", summary: "Move a card between others, to a status or a project", auth: authPaseto,
+		request: moveTaskRequest{}, responses: noteOK},
+	{method: "POST", path: "/notes/bulk", id: "bulkTasks", tag: "notes", summary: "Apply operations to many notes", auth: authPaseto,
+		request: bulkTasksRequest{}, responses: map[int]interface{}{http.StatusOK: []service.BulkResult{}, http.StatusUnprocessableEntity: []service.BulkResult{}}},
+	{method: "POST", path: "/notes/quick", id: "quickAddTask", tag: "notes", summary: "Create a note from a line of text", auth: authPaseto,
+		query:   []apiParam{tzParam},
+		request: quickAddRequest{}, responses: map[int]interface{}{http.StatusOK: quickAddResponse{}, http.StatusCreated: quickAddResponse{}, http.StatusUnprocessableEntity: quickAddResponse{}}},
+	{method: "GET", path: "/notes/search", id: "searchTasks", tag: "notes", summary: "List the notes matching a filter", auth: authPaseto,
+		query: []apiParam{filterParam, sortParam, tzParam, renderParam}, responses: taskResponses},
+	{method: "GET", path: "/notes/deferred", id: "getDeferredTasks", tag: "notes", summary: "List the snoozed notes", auth: authPaseto,
+		query: []apiParam{tzParam, renderParam}, responses: taskResponses},
+	{method: "GET", path: "/notes/someday", id: "getSomedayTasks", tag: "notes", summary: "List the notes put off to someday", auth: authPaseto,
+		query: []apiParam{tzParam, renderParam}, responses: taskResponses},
+	{method: "POST", path: "/notes/{id}/snooze", id: "snoozeTask", tag: "notes", summary: "Snooze a note until a time or someday", auth: authPaseto,
+		request: snoozeRequest{}, responses: noteOK},
+	{method: "DELETE", path: "/notes/{id}/snooze", id: "unsnoozeTask", tag: "notes", summary: "Bring back a snoozed note", auth: authPaseto,
+		responses: noteOK},
+	{method: "PUT", path: "/notes/{id}/fields", id: "setTaskFields", tag: "fields", summary: "Set custom field values of a note, null clears one", auth: authPaseto,
+		request: map[string]interface{}{}, responses: noteOK},
+
+	{method: "POST", path: "/notes/{id}/checklist", id: "addChecklistItem", tag: "checklist", summary: "Add a checklist item", auth: authPaseto,
+		request: addChecklistItemRequest{}, responses: map[int]interface{}{http.StatusCreated: taskResponse{}}},
+	{method: "DELETE", path: "/notes/{id}/checklist/{itemID}", id: "deleteChecklistItem", tag: "checklist", summary: "Delete a checklist item", auth: authPaseto,
+		responses: noteOK},
+	{method: "POST", path: "/notes/{id}/checklist/{itemID}/move", id: "moveChecklistItem", tag: "checklist", summary: "Move a checklist item", auth: authPaseto,
+		request: moveChecklistItemRequest{}, responses: noteOK},
+	{method: "POST", path: "/notes/{id}/checklist/{itemID}/check", id: "checkChecklistItem", tag: "checklist", summary: "Check a checklist item", auth: authPaseto,
+		responses: noteOK},
+	{method: "POST", path: "/notes/{id}/checklist/{itemID}/uncheck", id: "uncheckChecklistItem", tag: "checklist", summary: "Uncheck a checklist item", auth: authPaseto,
+		responses: noteOK},
+	{method: "POST", path: "/notes/{id}/checklist/{itemID}/convert", id: "convertChecklistItem", tag: "checklist", summary: "Turn a checklist item into a sub-task", auth: authPaseto,
+		responses: map[int]interface{}{http.StatusCreated: taskResponse{}}},
+
+	{method: "GET", path: "/notes/ical", id: "exportICal", tag: "import and export", summary: "Export the notes as iCalendar", auth: authPaseto,
+		responses: map[int]interface{}{http.StatusOK: raw("text/calendar")}},
+	{method: "POST", path: "/notes/ical", id: "importICal", tag: "import and export", summary: "Import the VTODOs of an iCalendar file", auth: authPaseto,
+		request: raw("text/calendar"), responses: map[int]interface{}{http.StatusOK: service.ICalImportResult{}}},
+	{method: "GET", path: "/notes/csv", id: "exportCSV", tag: "import and export", summary: "Export the notes as CSV", auth: authPaseto,
+		query: []apiParam{
+			{name: "columns", description: "comma separated columns, cf.<key> for custom fields"},
+			filterParam,
+		}, responses: map[int]interface{}{http.StatusOK: raw("text/csv")}},
+	{method: "POST", path: "/notes/csv", id: "importCSV", tag: "import and export", summary: "Import notes from CSV", auth: authPaseto,
+		query: []apiParam{
+			{name: "map", description: "header=column, maps a header to a column, - to ignore it", repeated: true},
+			{name: "dryRun", values: []string{"true", "false"}},
+		}, request: raw("text/csv"), responses: map[int]interface{}{http.StatusOK: service.CSVImportResult{}}},
+	{method: "GET", path: "/notes/todotxt", id: "exportTodoTxt", tag: "import and export", summary: "Export the notes as todo.txt", auth: authPaseto,
+		query: []apiParam{filterParam}, responses: map[int]interface{}{http.StatusOK: raw("text/plain")}},
+	{method: "POST", path: "/notes/todotxt", id: "importTodoTxt", tag: "import and export", summary: "Import a todo.txt file", auth: authPaseto,
+		request: raw("text/plain"), responses: map[int]interface{}{http.StatusOK: service.TodoTxtImportResult{}}},
+	{method: "GET", path: "/notes/markdown", id: "exportMarkdown", tag: "import and export", summary: "Export the notes as a Markdown document or a ZIP of Markdown files", auth: authPaseto,
+		query:     []apiParam{{name: "format", values: []string{"md", "zip"}}, {name: "project"}, filterParam},
+		responses: map[int]interface{}{http.StatusOK: raw("text/markdown", "application/zip")}},
+	{method: "POST", path: "/notes/markdown", id: "importMarkdown", tag: "import and export", summary: "Import a Markdown file or a ZIP of Markdown files", auth: authPaseto,
+		request: raw("text/markdown", "application/zip"), responses: map[int]interface{}{http.StatusOK: service.MarkdownImportResult{}}},
+	{method: "POST", path: "/notes/import/{source}", id: "importFrom", tag: "import and export", summary: "Import the JSON export of todoist or trello", auth: authPaseto,
+		request: raw("application/json"), responses: map[int]interface{}{http.StatusOK: service.ImportResult{}}},
+
+	{method: "POST", path: "/notes/{id}/timer", id: "startTimer", tag: "time", summary: "Start the timer on a note", auth: authPaseto,
+		request: startTimerRequest{}, responses: map[int]interface{}{http.StatusCreated: db.TimeEntry{}}},
+	{method: "GET", path: "/notes/{id}/time", id: "getTimeEntries", tag: "time", summary: "List the time entries of a note", auth: authPaseto,
+		responses: map[int]interface{}{http.StatusOK: []db.TimeEntry{}}},
+	{method: "POST", path: "/notes/{id}/time", id: "addTimeEntry", tag: "time", summary: "Record time spent on a note", auth: authPaseto,
+		request: timeEntryRequest{}, responses: map[int]interface{}{http.StatusCreated: db.TimeEntry{}}},
+	{method: "GET", path: "/timer", id: "getRunningTimer", tag: "time", summary: "Show the running timer", auth: authPaseto,
+		responses: map[int]interface{}{http.StatusOK: db.TimeEntry{}}},
+	{method: "POST", path: "/timer/stop", id: "stopTimer", tag: "time", summary: "Stop the running timer", auth: authPaseto,
+		responses: map[int]interface{}{http.StatusOK: db.TimeEntry{}}},
+	{method: "DELETE", path: "/time/{entryID}", id: "deleteTimeEntry", tag: "time", summary: "Delete a time entry", auth: authPaseto,
+		responses: messageOK},
+	{method: "GET", path: "/timesheet", id: "getTimesheet", tag: "time", summary: "Sum the time spent per period", auth: authPaseto,
+		query: []apiParam{
+			fromParam, toParam, tzParam,
+			{name: "period", values: []string{service.PeriodDay, service.PeriodWeek, service.PeriodMonth}},
+			{name: "by", values: []string{service.GroupByTask, service.GroupByProject}},
+			{name: "format", description: "csv for a CSV file", values: []string{"csv"}},
+		}, responses: map[int]interface{}{http.StatusOK: []service.TimesheetRow{}}},
+	{method: "GET", path: "/audit", id: "getAuditEvents", tag: "account", summary: "List the audit trail of the user", auth: authPaseto,
+		query:     []apiParam{fromParam, toParam, {name: "format", description: "jsonl to stream JSON Lines", values: []string{"jsonl"}}},
+		responses: map[int]interface{}{http.StatusOK: []db.AuditEvent{}}},
+	{method: "GET", path: "/stats", id: "getStats", tag: "account", summary: "Summarise the activity of the user", auth: authPaseto,
+		query:     []apiParam{fromParam, toParam, tzParam, {name: "period", values: []string{service.PeriodDay, service.PeriodWeek, service.PeriodMonth}}},
+		responses: map[int]interface{}{http.StatusOK: service.Stats{}}},
+
+	{method: "POST", path: "/templates", id: "createTemplate", tag: "templates", summary: "Create a template", auth: authPaseto,
+		request: db.Template{}, responses: map[int]interface{}{http.StatusCreated: db.Template{}}},
+	{method: "GET", path: "/templates", id: "getTemplates", tag: "templates", summary: "List the templates", auth: authPaseto,
+		responses: map[int]interface{}{http.StatusOK: []db.Template{}}},
+	{method: "GET", path: "/templates/{id}", id: "getTemplate", tag: "templates", summary: "Show a template", auth: authPaseto,
+		responses: map[int]interface{}{http.StatusOK: db.Template{}}},
+	{method: "PUT", path: "/templates/{id}", id: "updateTemplate", tag: "templates", summary: "Replace a template", auth: authPaseto,
+		request: db.Template{}, responses: map[int]interface{}{http.StatusOK: db.Template{}}},
+	{method: "DELETE", path: "/templates/{id}", id: "deleteTemplate", tag: "templates", summary: "Delete a template", auth: authPaseto,
+		responses: messageOK},
+	{method: "POST", path: "/templates/{id}/instantiate", id: "instantiateTemplate", tag: "templates", summary: "Create the notes of a template", auth: authPaseto,
+		request: instantiateTemplateRequest{}, responses: map[int]interface{}{http.StatusCreated: []taskResponse{}}},
+
+	{method: "POST", path: "/projects/{project}/fields", id: "createFieldDefinition", tag: "fields", summary: "Define a custom field", auth: authPaseto,
+		request: db.FieldDefinition{}, responses: map[int]interface{}{http.StatusCreated: db.FieldDefinition{}}},
+	{method: "GET", path: "/projects/{project}/fields", id: "getFieldDefinitions", tag: "fields", summary: "List the custom fields of a project", auth: authPaseto,
+		responses: map[int]interface{}{http.StatusOK: []db.FieldDefinition{}}},
+	{method: "PUT", path: "/projects/{project}/fields/{key}", id: "updateFieldDefinition", tag: "fields", summary: "Change a custom field and convert its values", auth: authPaseto,
+		query: []apiParam{forceParam}, request: db.FieldDefinition{},
+		responses: map[int]interface{}{http.StatusOK: fieldUpdateResponse{}, http.StatusConflict: problemBody{fieldConflictProblem{}}}},
+	{method: "DELETE", path: "/projects/{project}/fields/{key}", id: "deleteFieldDefinition", tag: "fields", summary: "Delete a custom field and its values", auth: authPaseto,
+		query:     []apiParam{forceParam},
+		responses: map[int]interface{}{http.StatusOK: fieldDeleteResponse{}, http.StatusConflict: problemBody{fieldConflictProblem{}}}},
+
+	{method: "POST", path: "/filters", id: "createFilter", tag: "filters", summary: "Save a filter", auth: authPaseto,
+		request: db.SavedFilter{}, responses: map[int]interface{}{http.StatusCreated: db.SavedFilter{}}},
+	{method: "GET", path: "/filters", id: "getFilters", tag: "filters", summary: "List the saved filters", auth: authPaseto,
+		responses: map[int]interface{}{http.StatusOK: []db.SavedFilter{}}},
+	{method: "GET", path: "/filters/{id}", id: "getFilter", tag: "filters", summary: "Show a saved filter", auth: authPaseto,
+		responses: map[int]interface{}{http.StatusOK: db.SavedFilter{}}},
+	{method: "PUT", path: "/filters/{id}", id: "updateFilter", tag: "filters", summary: "Replace a saved filter", auth: authPaseto,
+		request: db.SavedFilter{}, responses: map[int]interface{}{http.StatusOK: db.SavedFilter{}}},
+	{method: "DELETE", path: "/filters/{id}", id: "deleteFilter", tag: "filters", summary: "Delete a saved filter", auth: authPaseto,
+		responses: messageOK},
+	{method: "GET", path: "/filters/{id}/tasks", id: "runFilter", tag: "filters", summary: "List the notes matching a saved filter", auth: authPaseto,
+		query: []apiParam{sortParam, tzParam, renderParam}, responses: taskResponses},
+
+	{method: "GET", path: "/account/export", id: "exportAccount", tag: "account", summary: "Export all data of the user, 202 until the archive is ready", auth: authPaseto,
+		query:     []apiParam{{name: "refresh", description: "true to start a new export", values: []string{"true"}}},
+		responses: map[int]interface{}{http.StatusOK: raw("application/zip"), http.StatusAccepted: db.AccountExport{}}},
+	{method: "DELETE", path: "/account", id: "deleteAccount", tag: "account", summary: "Delete the user and all of their data", auth: authPaseto,
+		request: deleteAccountRequest{}, responses: messageOK},
+	{method: "POST", path: "/account/feed", id: "createFeedToken", tag: "calendar", summary: "Create the secret URL of the calendar feed, replacing the previous one", auth: authPaseto,
+		responses: map[int]interface{}{http.StatusCreated: lib.Msg{}}},
+	{method: "GET", path: "/account/feed", id: "getFeedToken", tag: "calendar", summary: "Tell when the calendar feed was created", auth: authPaseto,
+		responses: messageOK},
+	{method: "DELETE", path: "/account/feed", id: "deleteFeedToken", tag: "calendar", summary: "Revoke the calendar feed", auth: authPaseto,
+		responses: messageOK},
+	{method: "GET", path: FeedRoot + "{token}.ics", unversioned: true, id: "calendarFeed", tag: "calendar", summary: "iCalendar feed of the notes with a due date",
+		query:     []apiParam{{name: "project", repeated: true}, {name: "tag", repeated: true}},
+		responses: map[int]interface{}{http.StatusOK: raw("text/calendar")}},
+	{method: "POST", path: "/graphql", unversioned: true, id: "graphql", tag: "graphql", summary: "Run a GraphQL query or mutation over the notes and projects", auth: authPaseto,
+		request: lib.GraphQLRequest{}, responses: map[int]interface{}{http.StatusOK: graphQLResponse{}}},
+	{method: "GET", path: "/graphql/schema", unversioned: true, id: "graphqlSchema", tag: "graphql", summary: "Schema of the GraphQL endpoint in SDL",
+		responses: map[int]interface{}{http.StatusOK: raw("text/plain")}},
+	{method: "GET", path: "/.well-known/caldav", unversioned: true, id: "caldavRedirect", tag: "calendar", summary: "Redirect to the CalDAV tree",
+		responses: map[int]interface{}{http.StatusMovedPermanently: nil}},
+}
+
+func init() {
+	// The CalDAV tree answers every method on every path under it.
+	for _, method := range davMethods {
+		apiOperations = append(apiOperations, apiOperation{
+			method: method, path: DAVRoot + "{path}", unversioned: true, id: "caldav" + method[:1] + strings.ToLower(method[1:]), tag: "calendar",
+			summary: "CalDAV " + method, auth: authBasic,
+			responses: map[int]interface{}{http.StatusOK: raw("text/calendar", "application/xml")},
+		})
+	}
+}
+
+// OpenAPIMethodKey returns the key of the operations of the method in a path
+// item: the lower-case method, or an extension for methods OpenAPI does not
+// define.
+func OpenAPIMethodKey(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete, http.MethodOptions,
+		http.MethodHead, http.MethodPatch, http.MethodTrace:
+		return strings.ToLower(method)
+	}
+	return "x-" + strings.ToLower(method)
+}
+
+func apiContent(g *lib.SchemaGenerator, body interface{}) map[string]*lib.OpenAPIMediaType {
+	if mediaTypes, ok := body.(rawBody); ok {
+		content := map[string]*lib.OpenAPIMediaType{}
+		for _, mediaType := range mediaTypes {
+			content[mediaType] = &lib.OpenAPIMediaType{Schema: &lib.Schema{Type: "string", Format: "binary"}}
+		}
+		return content
+	}
+	if bodies, ok := body.(typedBody); ok {
+		content := map[string]*lib.OpenAPIMediaType{}
+		for mediaType, v := range bodies {
+			content[mediaType] = &lib.OpenAPIMediaType{Schema: g.SchemaOf(v)}
+		}
+		return content
+	}
+	if problem, ok := body.(problemBody); ok {
+		return map[string]*lib.OpenAPIMediaType{lib.ProblemContentType: {Schema: g.SchemaOf(problem.details)}}
+	}
+	return map[string]*lib.OpenAPIMediaType{"application/json": {Schema: g.SchemaOf(body)}}
+}
+
+func (op apiOperation) document(g *lib.SchemaGenerator) *lib.OpenAPIOperation {
+	doc := &lib.OpenAPIOperation{
+		OperationID: op.id,
+		Summary:     op.summary,
+		Tags:        []string{op.tag},
+		Responses: map[string]*lib.OpenAPIResponse{
+			"default": {Description: "Error", Content: apiContent(g, problemBody{lib.Problem{}})},
+		},
+	}
+	if op.auth != "" {
+		doc.Security = []map[string][]string{{op.auth: {}}}
+	}
+
+	for _, match := range pathParamRegex.FindAllStringSubmatch(op.path, -1) {
+		schema := &lib.Schema{Type: "string"}
+		if match[1] == "id" || strings.HasSuffix(match[1], "ID") {
+			schema.Format = "uuid"
+		}
+		doc.Parameters = append(doc.Parameters, lib.OpenAPIParameter{Name: match[1], In: "path", Required: true, Schema: schema})
+	}
+	for _, p := range op.query {
+		schema := &lib.Schema{Type: "string", Enum: p.values}
+		if p.repeated {
+			schema = &lib.Schema{Type: "array", Items: schema}
+		}
+		doc.Parameters = append(doc.Parameters, lib.OpenAPIParameter{Name: p.name, In: "query", Description: p.description, Schema: schema})
+	}
+
+	if op.request != nil {
+		doc.RequestBody = &lib.OpenAPIRequestBody{Required: true, Content: apiContent(g, op.request)}
+	}
+	statuses := make([]int, 0, len(op.responses))
+	for status := range op.responses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		body := op.responses[status]
+		resp := &lib.OpenAPIResponse{Description: http.StatusText(status)}
+		if body != nil {
+			resp.Content = apiContent(g, body)
+		}
+		doc.Responses[strconv.Itoa(status)] = resp
+	}
+	return doc
+}
+
+// OpenAPIDocument describes the API from apiOperations. Schemas are derived
+// from the types of the bodies.
+func OpenAPIDocument() *lib.OpenAPI {
+	g := lib.NewSchemaGenerator()
+	doc := &lib.OpenAPI{
+		OpenAPI: "3.0.3",
+		Info: lib.OpenAPIInfo{
+			Title:   "Tasks",
+			Version: "1.0.0",
+			Description: "Notes with checklists, time tracking, templates, custom fields and calendars. " +
+				"The paths of version 1 are also served without the " + APIv1Root + " prefix until their sunset.",
+		},
+		Paths: map[string]map[string]*lib.OpenAPIOperation{},
+	}
+	ops := append([]apiOperation(nil), apiOperations...)
+	// Sort for stable component names.
+	sort.SliceStable(ops, func(i, j int) bool { return ops[i].path < ops[j].path })
+	for _, op := range ops {
+		path := op.path
+		if !op.unversioned {
+			path = APIv1Root + path
+		}
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*lib.OpenAPIOperation{}
+		}
+		doc.Paths[path][OpenAPIMethodKey(op.method)] = op.document(g)
+	}
+	doc.Components = lib.OpenAPIComponents{
+		Schemas: g.Schemas(),
+		SecuritySchemes: map[string]lib.OpenAPISecurityScheme{
+			authPaseto: {Type: "apiKey", In: "cookie", Name: "paseto", Description: "Token set by POST /login"},
+			authBasic:  {Type: "http", Scheme: "basic", Description: "Username and password, for CalDAV clients"},
+		},
+	}
+	return doc
+}
+
+var (
+	apiDocumentOnce sync.Once
+	apiDocument     []byte
+	apiDocumentErr  error
+)
+
+// OpenAPI serves the OpenAPI document of the API.
+func OpenAPI(w http.ResponseWriter, r *http.Request) {
+	l, _, cancel := lib.SetupHandler(w, r.Context())
+	defer cancel()
+
+	apiDocumentOnce.Do(func() {
+		apiDocument, apiDocumentErr = json.Marshal(OpenAPIDocument())
+	})
+	if apiDocumentErr != nil {
+		l.Error().Err(apiDocumentErr).Msgf("Could not encode the OpenAPI document")
+		writeProblem(w, r, apiDocumentErr)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(apiDocument)
+}
+
+// APIDocs serves a page documenting the API from the OpenAPI document.
+func APIDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(apiDocsPage)
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"tasks/lib"
+	"tasks/server/auth"
+	"tasks/service"
+)
+
+// maxPatchSize bounds the size of a patch document.
+const maxPatchSize = 1 << 20
+
+// acceptPatch lists the patch media types PatchTask accepts.
+var acceptPatch = strings.Join([]string{lib.MergePatchContentType, lib.JSONPatchContentType}, ", ")
+
+// PatchTask changes a note with a JSON Merge Patch (RFC 7396) or a JSON
+// Patch (RFC 6902) and answers with the changed note. A JSON Patch may test
+// the current values first, so that a client only changes the note it has
+// seen.
+func PatchTask(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		id, err := urlParamUUID(r, "id")
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not convert ID to UUID.")
+			writeProblem(w, r, invalidParam("id", err))
+			return
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		patch, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPatchSize))
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not read the patch of note %v", id)
+			writeProblem(w, r, fmt.Errorf("%w: %v", service.ErrMalformedRequest, err))
+			return
+		}
+
+		t, err := s.PatchTask(ctx, auth.UsernameFromContext(ctx), id, mediaType, patch)
+		switch {
+		case errors.Is(err, service.ErrUnsupportedPatch):
+			w.Header().Set("Accept-Patch", acceptPatch)
+			writeProblem(w, r, err)
+		case err != nil:
+			l.Info().Err(err).Msgf("Could not patch note %v", id)
+			writeProblem(w, r, err)
+		default:
+			l.Info().Msgf("Patching note %v was successful!", id)
+			lib.JSON(w, newTaskResponse(t), http.StatusOK)
+		}
+	}
+}
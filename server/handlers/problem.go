@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"tasks/lib"
+	"tasks/service"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// problemKinds describes each kind of service error as a problem type.
+var problemKinds = map[service.Kind]struct {
+	status int
+	title  string
+}{
+	service.KindInvalid:            {http.StatusBadRequest, "Invalid request"},
+	service.KindUnauthorized:       {http.StatusUnauthorized, "Not authorized"},
+	service.KindNotFound:           {http.StatusNotFound, "Resource not found"},
+	service.KindConflict:           {http.StatusConflict, "Conflict with the current state"},
+	service.KindPreconditionFailed: {http.StatusPreconditionFailed, "Precondition failed"},
+	service.KindUnsupported:        {http.StatusUnsupportedMediaType, "Unsupported media type"},
+	service.KindInternal:           {http.StatusInternalServerError, "Internal server error"},
+}
+
+// ProblemType returns the URI of the problem type of a kind of error, which
+// points to its description in the API docs.
+func ProblemType(kind service.Kind) string {
+	return APIv1Root + "/docs#problem-" + string(kind)
+}
+
+// newProblem describes err as the problem details of a response to r. The
+// message of internal errors is left out, it is only logged.
+func newProblem(r *http.Request, err error) lib.Problem {
+	kind := service.KindOf(err)
+	p := lib.Problem{
+		Type:      ProblemType(kind),
+		Title:     problemKinds[kind].title,
+		Status:    problemKinds[kind].status,
+		Instance:  r.URL.Path,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+	if kind != service.KindInternal {
+		p.Detail = err.Error()
+	}
+	var verr *service.ValidationError
+	if errors.As(err, &verr) {
+		p.Errors = verr.Fields
+	}
+	return p
+}
+
+// writeProblem answers r with the problem details of err.
+func writeProblem(w http.ResponseWriter, r *http.Request, err error) {
+	lib.WriteProblem(w, newProblem(r, err))
+}
+
+// decodeRequest decodes the JSON body of r into v and checks v against its
+// validation rules.
+func decodeRequest(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", service.ErrMalformedRequest, err)
+	}
+	return service.Validate(v, service.ErrInvalidRequest)
+}
+
+// invalidParam reports a parameter of the request that cannot be parsed.
+func invalidParam(name string, err error) error {
+	return fmt.Errorf("%w: %s: %v", service.ErrInvalidParameter, name, err)
+}
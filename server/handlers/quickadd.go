@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"tasks/lib"
+	"tasks/server/auth"
+	"tasks/service"
+	"time"
+)
+
+type quickAddRequest struct {
+	Text   string `json:"text" validate:"required"`
+	DryRun bool   `json:"dryRun"`
+}
+
+type quickAddResponse struct {
+	Task   *taskResponse `json:"task,omitempty"`
+	Parsed *lib.QuickAdd `json:"parsed"`
+}
+
+// QuickAddTask creates a note from a single line of text. The tz query
+// parameter selects the time zone relative dates are resolved in, and
+// dryRun only reports what was recognised.
+func QuickAddTask(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		var quickRequest quickAddRequest
+
+		err := decodeRequest(r, &quickRequest)
+		if err != nil {
+			l.Error().Err(err).Msgf("error decoding the quick add request. %v", err)
+			writeProblem(w, r, err)
+			return
+		}
+
+		loc, err := requestLocation(r)
+		if err != nil {
+			l.Error().Err(err).Msgf("Invalid time zone for quick add")
+			writeProblem(w, r, fmt.Errorf("%w: %v", service.ErrInvalidParameter, err))
+			return
+		}
+
+		t, parsed, err := s.QuickAddTask(ctx, auth.UsernameFromContext(ctx), quickRequest.Text, time.Now().In(loc), quickRequest.DryRun)
+		switch {
+		case errors.Is(err, service.ErrInvalidTask):
+			l.Info().Msgf("Quick add text did not produce a valid note")
+			lib.JSON(w, quickAddResponse{Parsed: parsed}, http.StatusUnprocessableEntity)
+		case err != nil:
+			l.Error().Err(err).Msgf("Quick add failed. %v", err)
+			writeProblem(w, r, err)
+		default:
+			resp := newTaskResponse(t)
+			code := http.StatusCreated
+			if quickRequest.DryRun {
+				code = http.StatusOK
+			} else {
+				l.Info().Msgf("Task with ID %v has been created from quick add", t.ID)
+			}
+			lib.JSON(w, quickAddResponse{Task: &resp, Parsed: parsed}, code)
+		}
+	}
+}
@@ -2,8 +2,12 @@ package handlers
 
 import (
 	"context"
+	"io"
+	"time"
 
 	"tasks/db"
+	"tasks/lib"
+	"tasks/service"
 
 	"github.com/google/uuid"
 	jsoniter "github.com/json-iterator/go"
@@ -12,10 +16,110 @@ import (
 var json = jsoniter.ConfigCompatibleWithStandardLibrary
 
 type TaskService interface {
-	CreateTask(ctx context.Context, title string, username string, text string) (uuid.UUID, error)
+	CreateTask(ctx context.Context, args *db.Task) (uuid.UUID, error)
 	GetAllTasksFromUser(ctx context.Context, username string) ([]db.Task, error)
-	DeleteTask(ctx context.Context, id uuid.UUID) (uuid.UUID, error)
-	UpdateTask(ctx context.Context, reqID uuid.UUID, title string, text string, isTextEmpty bool) (uuid.UUID, error)
+	GetTasks(ctx context.Context, username string, ids []uuid.UUID) (map[uuid.UUID]*db.Task, error)
+	DeleteTask(ctx context.Context, username string, id uuid.UUID) (uuid.UUID, error)
+	UpdateTask(ctx context.Context, username string, reqID uuid.UUID, title string, text string, isTextEmpty bool) (uuid.UUID, error)
 	RegisterUser(ctx context.Context, args *db.User) (string, error)
-	GetUser(ctx context.Context, username string) (db.User, error)
+	GetUser(ctx context.Context, username string) (*db.User, error)
+	PatchTask(ctx context.Context, username string, id uuid.UUID, mediaType string, patch []byte) (*db.Task, error)
+	MoveTask(ctx context.Context, username string, id uuid.UUID, after uuid.UUID, before uuid.UUID, status *string, project *string) (*db.Task, error)
+	QuickAddTask(ctx context.Context, username string, text string, now time.Time, dryRun bool) (*db.Task, *lib.QuickAdd, error)
+	Stats(ctx context.Context, username string, q service.StatsQuery) (*service.Stats, error)
+	ApplyBulk(ctx context.Context, username string, ops []service.BulkOperation, atomic bool) ([]service.BulkResult, error)
+	ImportICal(ctx context.Context, username string, r io.Reader) (*service.ICalImportResult, error)
+	ImportCSV(ctx context.Context, username string, r io.Reader, mapping map[string]string, dryRun bool) (*service.CSVImportResult, error)
+	ImportTodoTxt(ctx context.Context, username string, r io.Reader) (*service.TodoTxtImportResult, error)
+	ImportFrom(ctx context.Context, username string, source string, r io.Reader) (*service.ImportResult, error)
+	ImportMarkdown(ctx context.Context, username string, r io.Reader) (*service.MarkdownImportResult, error)
+	ChecklistService
+	TimeService
+	TemplateService
+	AuditService
+	FilterService
+	FieldService
+	SnoozeService
+	CalendarService
+	AccountService
+}
+
+type ChecklistService interface {
+	AddChecklistItem(ctx context.Context, username string, taskID uuid.UUID, text string, position int) (*db.Task, error)
+	MoveChecklistItem(ctx context.Context, username string, taskID uuid.UUID, itemID uuid.UUID, position int) (*db.Task, error)
+	SetChecklistItemDone(ctx context.Context, username string, taskID uuid.UUID, itemID uuid.UUID, done bool) (*db.Task, error)
+	DeleteChecklistItem(ctx context.Context, username string, taskID uuid.UUID, itemID uuid.UUID) (*db.Task, error)
+	ConvertChecklistItem(ctx context.Context, username string, taskID uuid.UUID, itemID uuid.UUID) (*db.Task, error)
+}
+
+type TimeService interface {
+	StartTimer(ctx context.Context, username string, taskID uuid.UUID, note string) (*db.TimeEntry, error)
+	StopTimer(ctx context.Context, username string) (*db.TimeEntry, error)
+	GetRunningTimer(ctx context.Context, username string) (*db.TimeEntry, error)
+	AddTimeEntry(ctx context.Context, username string, taskID uuid.UUID, start time.Time, end time.Time, note string) (*db.TimeEntry, error)
+	GetTimeEntries(ctx context.Context, username string, taskID uuid.UUID) ([]db.TimeEntry, error)
+	GetTimeEntriesByTask(ctx context.Context, username string, taskIDs []uuid.UUID) (map[uuid.UUID][]db.TimeEntry, error)
+	DeleteTimeEntry(ctx context.Context, username string, id uuid.UUID) error
+	Timesheet(ctx context.Context, username string, q service.TimesheetQuery) ([]service.TimesheetRow, error)
+}
+
+type TemplateService interface {
+	CreateTemplate(ctx context.Context, username string, args *db.Template) (*db.Template, error)
+	GetTemplates(ctx context.Context, username string) ([]db.Template, error)
+	GetTemplate(ctx context.Context, username string, id uuid.UUID) (*db.Template, error)
+	UpdateTemplate(ctx context.Context, username string, id uuid.UUID, args *db.Template) (*db.Template, error)
+	DeleteTemplate(ctx context.Context, username string, id uuid.UUID) error
+	InstantiateTemplate(ctx context.Context, username string, id uuid.UUID, vars map[string]string, base time.Time) ([]*db.Task, error)
+}
+
+type AuditService interface {
+	RecordAuthEvent(ctx context.Context, username string, action string)
+	GetAuditEvents(ctx context.Context, username string, from time.Time, to time.Time) ([]db.AuditEvent, error)
+	ExportAuditEvents(ctx context.Context, username string, from time.Time, to time.Time, fn func(*db.AuditEvent) error) error
+}
+
+type FilterService interface {
+	CreateFilter(ctx context.Context, username string, args *db.SavedFilter) (*db.SavedFilter, error)
+	GetFilters(ctx context.Context, username string) ([]db.SavedFilter, error)
+	GetFilter(ctx context.Context, username string, id uuid.UUID) (*db.SavedFilter, error)
+	UpdateFilter(ctx context.Context, username string, id uuid.UUID, args *db.SavedFilter) (*db.SavedFilter, error)
+	DeleteFilter(ctx context.Context, username string, id uuid.UUID) error
+	SearchTasks(ctx context.Context, username string, query string, sortSpec string, now time.Time) ([]db.Task, error)
+	RunFilter(ctx context.Context, username string, id uuid.UUID, sortSpec string, now time.Time) ([]db.Task, error)
+}
+
+type FieldService interface {
+	CreateFieldDefinition(ctx context.Context, username string, project string, args *db.FieldDefinition) (*db.FieldDefinition, error)
+	GetFieldDefinitions(ctx context.Context, username string, project string) ([]db.FieldDefinition, error)
+	GetAllFieldDefinitions(ctx context.Context, username string) ([]db.FieldDefinition, error)
+	UpdateFieldDefinition(ctx context.Context, username string, project string, key string, args *db.FieldDefinition, force bool) (*db.FieldDefinition, int, error)
+	DeleteFieldDefinition(ctx context.Context, username string, project string, key string, force bool) (int, error)
+	SetTaskFields(ctx context.Context, username string, taskID uuid.UUID, values map[string]interface{}) (*db.Task, error)
+}
+
+type SnoozeService interface {
+	SnoozeTask(ctx context.Context, username string, id uuid.UUID, until *time.Time, someday bool) (*db.Task, error)
+	UnsnoozeTask(ctx context.Context, username string, id uuid.UUID) (*db.Task, error)
+	GetDeferredTasks(ctx context.Context, username string, now time.Time) ([]db.Task, error)
+	GetSomedayTasks(ctx context.Context, username string) ([]db.Task, error)
+}
+
+type CalendarService interface {
+	GetCalendars(ctx context.Context, username string) ([]string, error)
+	GetCalendarTasks(ctx context.Context, username string, project string) ([]db.Task, error)
+	GetCalendarTask(ctx context.Context, username string, project string, name string) (*db.Task, error)
+	CalendarTag(ctx context.Context, username string, project string) (uint64, uint64, error)
+	SyncCalendar(ctx context.Context, username string, project string, since uint64) (*service.CalendarChanges, error)
+	PutCalendarTask(ctx context.Context, username string, project string, name string, data io.Reader, ifMatch string, ifNoneMatch string) (*db.Task, bool, error)
+	DeleteCalendarTask(ctx context.Context, username string, project string, name string, ifMatch string) error
+}
+
+type AccountService interface {
+	StartAccountExport(ctx context.Context, username string) (*db.AccountExport, error)
+	GetAccountExportArchive(ctx context.Context, username string) (*db.AccountExport, []byte, error)
+	DeleteAccount(ctx context.Context, username string, password string) error
+	CreateFeedToken(ctx context.Context, username string) (string, *db.FeedToken, error)
+	GetFeedToken(ctx context.Context, username string) (*db.FeedToken, error)
+	DeleteFeedToken(ctx context.Context, username string) error
+	GetFeedTasks(ctx context.Context, secret string, q service.FeedQuery) ([]db.Task, time.Time, error)
 }
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"tasks/db"
+	"tasks/lib"
+	"tasks/server/auth"
+	"tasks/service"
+
+	"github.com/rs/zerolog"
+)
+
+func writeSnoozeResult(w http.ResponseWriter, r *http.Request, l *zerolog.Logger, t *db.Task, err error) {
+	switch {
+	case err != nil:
+		l.Error().Err(err).Msgf("Snooze failed. %v", err)
+		writeProblem(w, r, err)
+	default:
+		l.Info().Msgf("Deferral of task %v has been updated", t.ID)
+		lib.JSON(w, newTaskResponse(t), http.StatusOK)
+	}
+}
+
+type snoozeRequest struct {
+	Until   *time.Time `json:"until"`
+	Someday bool       `json:"someday"`
+}
+
+// SnoozeTask hides a note from the default listing until a time, or for
+// someday.
+func SnoozeTask(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		taskID, err := urlParamUUID(r, "id")
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not convert ID to UUID.")
+			writeProblem(w, r, invalidParam("id", err))
+			return
+		}
+
+		var req snoozeRequest
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			l.Error().Err(err).Msgf("error decoding the snooze request. %v", err)
+			writeProblem(w, r, fmt.Errorf("%w: %v", service.ErrMalformedRequest, err))
+			return
+		}
+
+		t, err := s.SnoozeTask(ctx, auth.UsernameFromContext(ctx), taskID, req.Until, req.Someday)
+		writeSnoozeResult(w, r, l, t, err)
+	}
+}
+
+func UnsnoozeTask(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		taskID, err := urlParamUUID(r, "id")
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not convert ID to UUID.")
+			writeProblem(w, r, invalidParam("id", err))
+			return
+		}
+
+		t, err := s.UnsnoozeTask(ctx, auth.UsernameFromContext(ctx), taskID)
+		writeSnoozeResult(w, r, l, t, err)
+	}
+}
+
+// GetDeferredTasks lists the snoozed notes, the ones that resurface first
+// first.
+func GetDeferredTasks(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		writeSearchResult(w, r, l, func(now time.Time) ([]db.Task, error) {
+			return s.GetDeferredTasks(ctx, auth.UsernameFromContext(ctx), now)
+		})
+	}
+}
+
+func GetSomedayTasks(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		writeSearchResult(w, r, l, func(now time.Time) ([]db.Task, error) {
+			return s.GetSomedayTasks(ctx, auth.UsernameFromContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"tasks/lib"
+	"tasks/server/auth"
+	"tasks/service"
+)
+
+// parseStatsQuery reads from, to (RFC 3339 or YYYY-MM-DD), period and tz
+// from the query string.
+func parseStatsQuery(r *http.Request) (service.StatsQuery, error) {
+	q := service.StatsQuery{}
+
+	loc, err := requestLocation(r)
+	if err != nil {
+		return q, err
+	}
+	q.Location = loc
+
+	if q.From, err = parseQueryTime(r, "from", loc); err != nil {
+		return q, err
+	}
+	if q.To, err = parseQueryTime(r, "to", loc); err != nil {
+		return q, err
+	}
+
+	switch p := r.URL.Query().Get("period"); p {
+	case "", service.PeriodDay, service.PeriodWeek, service.PeriodMonth:
+		q.Period = p
+	default:
+		return q, fmt.Errorf("period must be %q, %q or %q", service.PeriodDay, service.PeriodWeek, service.PeriodMonth)
+	}
+	return q, nil
+}
+
+func GetStats(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		q, err := parseStatsQuery(r)
+		if err != nil {
+			l.Error().Err(err).Msgf("Invalid stats query")
+			writeProblem(w, r, fmt.Errorf("%w: %v", service.ErrInvalidParameter, err))
+			return
+		}
+
+		stats, err := s.Stats(ctx, auth.UsernameFromContext(ctx), q)
+		switch {
+		case err != nil:
+			l.Error().Err(err).Msgf("Could not compute stats. %v", err)
+			writeProblem(w, r, err)
+		default:
+			lib.JSON(w, stats, http.StatusOK)
+		}
+	}
+}
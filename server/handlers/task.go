@@ -1,16 +1,50 @@
 package handlers
 
 import (
-	"errors"
+	"fmt"
 	"net/http"
-	"strings"
 	"tasks/db"
 	"tasks/lib"
+	"tasks/server/auth"
+	"tasks/service"
+	"time"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 )
 
+// taskResponse is the listing representation of a task, extended with
+// values derived from the stored record.
+type taskResponse struct {
+	db.Task
+	ChecklistProgress string `json:"checklistProgress,omitempty"`
+	TextHTML          string `json:"textHtml,omitempty"`
+}
+
+func newTaskResponse(t *db.Task) taskResponse {
+	return taskResponse{
+		Task:              *t,
+		ChecklistProgress: t.ChecklistProgressString(),
+	}
+}
+
+// newTaskResponses builds the listing of the tasks, rendering their text as
+// HTML when requested.
+func newTaskResponses(tasks []db.Task, renderHTML bool) ([]taskResponse, error) {
+	resp := make([]taskResponse, 0, len(tasks))
+	for i := range tasks {
+		tr := newTaskResponse(&tasks[i])
+		if renderHTML {
+			html, err := lib.RenderMarkdown(tasks[i].Text)
+			if err != nil {
+				return nil, fmt.Errorf("task %v: %w", tasks[i].ID, err)
+			}
+			tr.TextHTML = html
+		}
+		resp = append(resp, tr)
+	}
+	return resp, nil
+}
+
 func CreateTask(s TaskService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		l, ctx, cancel := lib.SetupHandler(w, r.Context())
@@ -18,26 +52,24 @@ func CreateTask(s TaskService) http.HandlerFunc {
 
 		var taskRequest db.Task
 
-		err := json.NewDecoder(r.Body).Decode(&taskRequest)
-		if err != nil {
-			l.Error().Err(err).Msgf("error decoding the Note into lib.JSON during registration. %v", err)
-			lib.JSON(w, lib.Msg{"error": "internal error decoding Note struct"}, http.StatusInternalServerError)
+		if err := json.NewDecoder(r.Body).Decode(&taskRequest); err != nil {
+			l.Error().Err(err).Msgf("error decoding the Note during creation. %v", err)
+			writeProblem(w, r, fmt.Errorf("%w: %v", service.ErrMalformedRequest, err))
 			return
 		}
-
-		validate := validator.New()
-		err = validate.Struct(&taskRequest)
-		if err != nil {
-			l.Error().Err(err).Msgf("error during Note struct validation %v", err)
-			lib.JSON(w, lib.Msg{"error": "wrongly formatted or missing Note parameter"}, http.StatusBadRequest)
+		// Notes are created for the signed in user, whatever the body says.
+		taskRequest.User = auth.UsernameFromContext(ctx)
+		if err := service.Validate(&taskRequest, service.ErrInvalidRequest); err != nil {
+			l.Error().Err(err).Msgf("invalid Note during creation. %v", err)
+			writeProblem(w, r, err)
 			return
 		}
 
-		retID, err := s.CreateTask(ctx, taskRequest.Title, taskRequest.User, taskRequest.Text)
+		retID, err := s.CreateTask(ctx, &taskRequest)
 		switch {
-		case errors.Is(err, db.ErrTaskAlreadyExists):
-			l.Error().Err(err).Msgf("Task creation failed, a task with that ID already exists")
-			lib.JSON(w, lib.Msg{"error": "a Task with that id already exists! ID must be unique."}, http.StatusForbidden)
+		case err != nil:
+			l.Error().Err(err).Msgf("Task creation failed. %v", err)
+			writeProblem(w, r, err)
 			return
 		default:
 			l.Info().Msgf("Task with ID %v has been created for user: %s", retID, taskRequest.User)
@@ -51,20 +83,35 @@ func GetAllTasksFromUser(s TaskService) http.HandlerFunc {
 		l, ctx, cancel := lib.SetupHandler(w, r.Context())
 		defer cancel()
 
-		username := r.URL.Query().Get("username")
-		if username == "" {
-			l.Error().Msgf("error fetching username, the request parameter is empty. %s", username)
-			lib.JSON(w, lib.Msg{"error": "user not in request params"}, http.StatusBadRequest)
-			return
-		}
+		username := auth.UsernameFromContext(ctx)
+		renderHTML := r.URL.Query().Get("render") == "html"
+		status, filterStatus := r.URL.Query()["status"]
+		project, filterProject := r.URL.Query()["project"]
+		// Deferred notes stay out of the listing until they resurface.
+		all := r.URL.Query().Get("all") == "true"
+		now := time.Now()
 
 		notes, err := s.GetAllTasksFromUser(ctx, username)
 		switch {
-		case errors.Is(err, db.ErrTaskNotFound):
-			l.Info().Msgf("Requested user has no Task!. %s", username)
+		case err != nil:
+			l.Error().Err(err).Msgf("Could not fetch the tasks of %s", username)
+			writeProblem(w, r, err)
 		default:
+			filtered := make([]db.Task, 0, len(notes))
+			for _, t := range notes {
+				if filterStatus && t.Status != status[0] || filterProject && t.Project != project[0] || !all && t.Deferred(now) {
+					continue
+				}
+				filtered = append(filtered, t)
+			}
+			resp, err := newTaskResponses(filtered, renderHTML)
+			if err != nil {
+				l.Error().Err(err).Msgf("Could not render note texts")
+				writeProblem(w, r, err)
+				return
+			}
 			l.Info().Msgf("Retriving user task for %s was successful!", username)
-			lib.JSON(w, notes, http.StatusOK)
+			lib.JSON(w, resp, http.StatusOK)
 		}
 	}
 }
@@ -74,17 +121,18 @@ func DeleteTask(s TaskService) http.HandlerFunc {
 		l, ctx, cancel := lib.SetupHandler(w, r.Context())
 		defer cancel()
 
-		reqUUID, err := uuid.Parse(strings.Split(r.URL.Path, "/")[2])
+		reqUUID, err := urlParamUUID(r, "id")
 		if err != nil {
 			l.Info().Msgf("Could not convert ID to UUID.")
-			lib.JSON(w, lib.Msg{"error": "could not convert note id to uuid"}, http.StatusBadRequest)
+			writeProblem(w, r, invalidParam("id", err))
 			return
 		}
 
-		id, err := s.DeleteTask(ctx, reqUUID)
+		id, err := s.DeleteTask(ctx, auth.UsernameFromContext(ctx), reqUUID)
 		switch {
-		case errors.Is(err, db.ErrNoRows):
-			l.Info().Msg("User has no tasks to delete from!")
+		case err != nil:
+			l.Info().Err(err).Msgf("Could not delete task %v", reqUUID)
+			writeProblem(w, r, err)
 		default:
 			l.Info().Msgf("Deleting task %v was successful!", id)
 			lib.JSON(w, lib.Msg{"success": "task deleted"}, http.StatusOK)
@@ -93,6 +141,12 @@ func DeleteTask(s TaskService) http.HandlerFunc {
 	}
 }
 
+type updateTaskRequest struct {
+	ID    uuid.UUID `json:"id"`
+	Title string    `json:"title" validate:"required,min=4"`
+	Text  string    `json:"text"`
+}
+
 func UpdateTask(s TaskService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		l, ctx, cancel := lib.SetupHandler(w, r.Context())
@@ -100,30 +154,19 @@ func UpdateTask(s TaskService) http.HandlerFunc {
 
 		var isTextValid bool = true
 
-		reqUUID, err := uuid.Parse(strings.Split(r.URL.Path, "/")[2])
+		reqUUID, err := urlParamUUID(r, "id")
 		if err != nil {
 			l.Error().Err(err).Msgf("Could not convert ID to UUID.")
-			lib.JSON(w, lib.Msg{"error": "could not convert note id to uuid"}, http.StatusBadRequest)
+			writeProblem(w, r, invalidParam("id", err))
 			return
 		}
 
-		updateRequest := struct {
-			ID    uuid.UUID `json:"id"`
-			Title string    `json:"title" validate:"required,min=4"`
-			Text  string    `json:"text"`
-		}{}
+		var updateRequest updateTaskRequest
 
-		err = json.NewDecoder(r.Body).Decode(&updateRequest)
+		err = decodeRequest(r, &updateRequest)
 		if err != nil {
-			l.Error().Err(err).Msgf("error decoding the Note into lib.JSON during registration. %v", err)
-			lib.JSON(w, lib.Msg{"error": "internal error decoding Note struct"}, http.StatusInternalServerError)
-			return
-		}
-		validate := validator.New()
-		err = validate.Struct(&updateRequest)
-		if err != nil {
-			l.Error().Err(err).Msgf("title must be more than 4 characters long!")
-			lib.JSON(w, lib.Msg{"error": "title of a note must be more than 4 characters long!"}, http.StatusBadRequest)
+			l.Error().Err(err).Msgf("error decoding the Note update. %v", err)
+			writeProblem(w, r, err)
 			return
 		}
 
@@ -131,16 +174,58 @@ func UpdateTask(s TaskService) http.HandlerFunc {
 			isTextValid = false
 		}
 
-		id, err := s.UpdateTask(ctx, reqUUID, updateRequest.Title, updateRequest.Text, isTextValid)
+		id, err := s.UpdateTask(ctx, auth.UsernameFromContext(ctx), reqUUID, updateRequest.Title, updateRequest.Text, isTextValid)
 		switch {
 		case err != nil:
 			l.Info().Err(err).Msgf("Could not update Note %v", reqUUID)
-			lib.JSON(w, lib.Msg{"error": "could not update note"}, http.StatusInternalServerError)
+			writeProblem(w, r, err)
 			return
 		default:
 			l.Info().Msgf("Updating note %v was successful!", id)
-			lib.JSON(w, lib.Msg{"success": "note deleted"}, http.StatusOK)
+			lib.JSON(w, lib.Msg{"success": "note updated"}, http.StatusOK)
 			return
 		}
 	}
 }
+
+// moveTaskRequest places a card: After is the card the moved one follows,
+// Before the card it precedes.
+type moveTaskRequest struct {
+	After   uuid.UUID `json:"after"`
+	Before  uuid.UUID `json:"before"`
+	Status  *string   `json:"status" validate:"omitempty,oneof=todo in_progress done"`
+	Project *string   `json:"project"`
+}
+
+func MoveTask(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		reqUUID, err := urlParamUUID(r, "id")
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not convert ID to UUID.")
+			writeProblem(w, r, invalidParam("id", err))
+			return
+		}
+
+		var moveRequest moveTaskRequest
+
+		err = decodeRequest(r, &moveRequest)
+		if err != nil {
+			l.Error().Err(err).Msgf("error decoding the move request. %v", err)
+			writeProblem(w, r, err)
+			return
+		}
+
+		t, err := s.MoveTask(ctx, auth.UsernameFromContext(ctx), reqUUID, moveRequest.After, moveRequest.Before, moveRequest.Status, moveRequest.Project)
+		switch {
+		case err != nil:
+			l.Error().Err(err).Msgf("Could not move note %v", reqUUID)
+			writeProblem(w, r, err)
+		default:
+			l.Info().Msgf("Moving note %v was successful!", reqUUID)
+			lib.JSON(w, newTaskResponse(t), http.StatusOK)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"tasks/db"
+	"tasks/lib"
+	"tasks/server/auth"
+	"tasks/service"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func writeTemplateError(w http.ResponseWriter, r *http.Request, l *zerolog.Logger, err error) {
+	l.Error().Err(err).Msgf("Template operation failed. %v", err)
+	writeProblem(w, r, err)
+}
+
+func decodeTemplate(w http.ResponseWriter, r *http.Request, l *zerolog.Logger) (*db.Template, bool) {
+	var req db.Template
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		l.Error().Err(err).Msgf("error decoding the template. %v", err)
+		writeProblem(w, r, fmt.Errorf("%w: %v", service.ErrMalformedRequest, err))
+		return nil, false
+	}
+
+	err = service.Validate(&req, service.ErrInvalidRequest)
+	if err != nil {
+		l.Error().Err(err).Msgf("error during template validation %v", err)
+		writeProblem(w, r, err)
+		return nil, false
+	}
+	return &req, true
+}
+
+func CreateTemplate(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		req, ok := decodeTemplate(w, r, l)
+		if !ok {
+			return
+		}
+
+		template, err := s.CreateTemplate(ctx, auth.UsernameFromContext(ctx), req)
+		if err != nil {
+			writeTemplateError(w, r, l, err)
+			return
+		}
+		l.Info().Msgf("Template %v has been created", template.ID)
+		lib.JSON(w, template, http.StatusCreated)
+	}
+}
+
+func GetTemplates(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		templates, err := s.GetTemplates(ctx, auth.UsernameFromContext(ctx))
+		if err != nil {
+			writeTemplateError(w, r, l, err)
+			return
+		}
+		lib.JSON(w, templates, http.StatusOK)
+	}
+}
+
+func GetTemplate(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		id, err := urlParamUUID(r, "id")
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not convert template ID to UUID.")
+			writeProblem(w, r, invalidParam("id", err))
+			return
+		}
+
+		template, err := s.GetTemplate(ctx, auth.UsernameFromContext(ctx), id)
+		if err != nil {
+			writeTemplateError(w, r, l, err)
+			return
+		}
+		lib.JSON(w, template, http.StatusOK)
+	}
+}
+
+func UpdateTemplate(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		id, err := urlParamUUID(r, "id")
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not convert template ID to UUID.")
+			writeProblem(w, r, invalidParam("id", err))
+			return
+		}
+
+		req, ok := decodeTemplate(w, r, l)
+		if !ok {
+			return
+		}
+
+		template, err := s.UpdateTemplate(ctx, auth.UsernameFromContext(ctx), id, req)
+		if err != nil {
+			writeTemplateError(w, r, l, err)
+			return
+		}
+		l.Info().Msgf("Template %v has been updated", id)
+		lib.JSON(w, template, http.StatusOK)
+	}
+}
+
+func DeleteTemplate(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		id, err := urlParamUUID(r, "id")
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not convert template ID to UUID.")
+			writeProblem(w, r, invalidParam("id", err))
+			return
+		}
+
+		err = s.DeleteTemplate(ctx, auth.UsernameFromContext(ctx), id)
+		if err != nil {
+			writeTemplateError(w, r, l, err)
+			return
+		}
+		l.Info().Msgf("Template %v has been deleted", id)
+		lib.JSON(w, lib.Msg{"success": "template deleted"}, http.StatusOK)
+	}
+}
+
+type instantiateTemplateRequest struct {
+	Variables map[string]string `json:"variables"`
+	Date      *time.Time        `json:"date"`
+}
+
+func InstantiateTemplate(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		id, err := urlParamUUID(r, "id")
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not convert template ID to UUID.")
+			writeProblem(w, r, invalidParam("id", err))
+			return
+		}
+
+		var req instantiateTemplateRequest
+		if r.ContentLength != 0 {
+			err = json.NewDecoder(r.Body).Decode(&req)
+			if err != nil {
+				l.Error().Err(err).Msgf("error decoding the instantiation request. %v", err)
+				writeProblem(w, r, fmt.Errorf("%w: %v", service.ErrMalformedRequest, err))
+				return
+			}
+		}
+
+		base := time.Now()
+		if req.Date != nil {
+			base = *req.Date
+		}
+
+		tasks, err := s.InstantiateTemplate(ctx, auth.UsernameFromContext(ctx), id, req.Variables, base)
+		if err != nil {
+			writeTemplateError(w, r, l, err)
+			return
+		}
+
+		resp := make([]taskResponse, 0, len(tasks))
+		for _, t := range tasks {
+			resp = append(resp, newTaskResponse(t))
+		}
+		l.Info().Msgf("Template %v instantiated into %d notes", id, len(tasks))
+		lib.JSON(w, resp, http.StatusCreated)
+	}
+}
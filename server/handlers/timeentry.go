@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"tasks/lib"
+	"tasks/server/auth"
+	"tasks/service"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func writeTimeEntryError(w http.ResponseWriter, r *http.Request, l *zerolog.Logger, err error) {
+	l.Error().Err(err).Msgf("Time tracking failed. %v", err)
+	writeProblem(w, r, err)
+}
+
+type startTimerRequest struct {
+	Note string `json:"note"`
+}
+
+func StartTimer(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		taskID, err := urlParamUUID(r, "id")
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not convert ID to UUID.")
+			writeProblem(w, r, invalidParam("id", err))
+			return
+		}
+
+		var req startTimerRequest
+		if r.ContentLength != 0 {
+			err = json.NewDecoder(r.Body).Decode(&req)
+			if err != nil {
+				l.Error().Err(err).Msgf("error decoding the timer request. %v", err)
+				writeProblem(w, r, fmt.Errorf("%w: %v", service.ErrMalformedRequest, err))
+				return
+			}
+		}
+
+		username := auth.UsernameFromContext(ctx)
+		entry, err := s.StartTimer(ctx, username, taskID, req.Note)
+		if err != nil {
+			writeTimeEntryError(w, r, l, err)
+			return
+		}
+		l.Info().Msgf("Timer %v started on task %v for user %s", entry.ID, taskID, username)
+		lib.JSON(w, entry, http.StatusCreated)
+	}
+}
+
+func StopTimer(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		username := auth.UsernameFromContext(ctx)
+		entry, err := s.StopTimer(ctx, username)
+		if err != nil {
+			writeTimeEntryError(w, r, l, err)
+			return
+		}
+		l.Info().Msgf("Timer %v stopped for user %s", entry.ID, username)
+		lib.JSON(w, entry, http.StatusOK)
+	}
+}
+
+func GetRunningTimer(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		entry, err := s.GetRunningTimer(ctx, auth.UsernameFromContext(ctx))
+		if err != nil {
+			writeTimeEntryError(w, r, l, err)
+			return
+		}
+		lib.JSON(w, entry, http.StatusOK)
+	}
+}
+
+type timeEntryRequest struct {
+	Start time.Time `json:"start" validate:"required"`
+	End   time.Time `json:"end" validate:"required"`
+	Note  string    `json:"note"`
+}
+
+func AddTimeEntry(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		taskID, err := urlParamUUID(r, "id")
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not convert ID to UUID.")
+			writeProblem(w, r, invalidParam("id", err))
+			return
+		}
+
+		var req timeEntryRequest
+
+		err = decodeRequest(r, &req)
+		if err != nil {
+			l.Error().Err(err).Msgf("error decoding the time entry. %v", err)
+			writeProblem(w, r, err)
+			return
+		}
+
+		entry, err := s.AddTimeEntry(ctx, auth.UsernameFromContext(ctx), taskID, req.Start, req.End, req.Note)
+		if err != nil {
+			writeTimeEntryError(w, r, l, err)
+			return
+		}
+		l.Info().Msgf("Time entry %v added to task %v", entry.ID, taskID)
+		lib.JSON(w, entry, http.StatusCreated)
+	}
+}
+
+func GetTimeEntries(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		taskID, err := urlParamUUID(r, "id")
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not convert ID to UUID.")
+			writeProblem(w, r, invalidParam("id", err))
+			return
+		}
+
+		entries, err := s.GetTimeEntries(ctx, auth.UsernameFromContext(ctx), taskID)
+		if err != nil {
+			writeTimeEntryError(w, r, l, err)
+			return
+		}
+		lib.JSON(w, entries, http.StatusOK)
+	}
+}
+
+func DeleteTimeEntry(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		entryID, err := urlParamUUID(r, "entryID")
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not convert time entry ID to UUID.")
+			writeProblem(w, r, invalidParam("entryID", err))
+			return
+		}
+
+		err = s.DeleteTimeEntry(ctx, auth.UsernameFromContext(ctx), entryID)
+		if err != nil {
+			writeTimeEntryError(w, r, l, err)
+			return
+		}
+		l.Info().Msgf("Deleting time entry %v was successful!", entryID)
+		lib.JSON(w, lib.Msg{"success": "time entry deleted"}, http.StatusOK)
+	}
+}
+
+// requestLocation returns the time zone named by the tz query parameter,
+// defaulting to UTC.
+func requestLocation(r *http.Request) (*time.Location, error) {
+	tz := r.URL.Query().Get("tz")
+	if tz == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", tz)
+	}
+	return loc, nil
+}
+
+// parseQueryTime reads a date, taken as midnight in loc, or an RFC 3339
+// timestamp from the query parameter. A missing parameter gives a zero time.
+func parseQueryTime(r *http.Request, name string, loc *time.Location) (time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", v, loc); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be a date or an RFC 3339 timestamp", name)
+	}
+	return t, nil
+}
+
+// parseTimesheetQuery reads from, to (RFC 3339 or YYYY-MM-DD), period, by
+// and tz from the query string.
+func parseTimesheetQuery(r *http.Request) (service.TimesheetQuery, error) {
+	params := r.URL.Query()
+	q := service.TimesheetQuery{
+		Period:   service.PeriodDay,
+		GroupBy:  service.GroupByTask,
+		Location: time.UTC,
+	}
+
+	loc, err := requestLocation(r)
+	if err != nil {
+		return q, err
+	}
+	q.Location = loc
+
+	if q.From, err = parseQueryTime(r, "from", loc); err != nil {
+		return q, err
+	}
+	if q.To, err = parseQueryTime(r, "to", loc); err != nil {
+		return q, err
+	}
+
+	switch p := params.Get("period"); p {
+	case "", service.PeriodDay:
+	case service.PeriodWeek, service.PeriodMonth:
+		q.Period = p
+	default:
+		return q, fmt.Errorf("period must be %q, %q or %q", service.PeriodDay, service.PeriodWeek, service.PeriodMonth)
+	}
+
+	switch by := params.Get("by"); by {
+	case "", service.GroupByTask:
+	case service.GroupByProject:
+		q.GroupBy = by
+	default:
+		return q, fmt.Errorf("by must be %q or %q", service.GroupByTask, service.GroupByProject)
+	}
+
+	return q, nil
+}
+
+func writeTimesheetCSV(w http.ResponseWriter, rows []service.TimesheetRow) error {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="timesheet.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"period", "group", "title", "seconds", "hours"}); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		err := cw.Write([]string{
+			row.Period,
+			row.Group,
+			row.Title,
+			strconv.FormatInt(row.Seconds, 10),
+			strconv.FormatFloat(float64(row.Seconds)/3600, 'f', 2, 64),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func GetTimesheet(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		q, err := parseTimesheetQuery(r)
+		if err != nil {
+			l.Error().Err(err).Msgf("Invalid timesheet query")
+			writeProblem(w, r, fmt.Errorf("%w: %v", service.ErrInvalidParameter, err))
+			return
+		}
+
+		rows, err := s.Timesheet(ctx, auth.UsernameFromContext(ctx), q)
+		if err != nil {
+			writeTimeEntryError(w, r, l, err)
+			return
+		}
+
+		if r.URL.Query().Get("format") == "csv" {
+			if err := writeTimesheetCSV(w, rows); err != nil {
+				l.Error().Err(err).Msgf("Could not write timesheet CSV")
+			}
+			return
+		}
+		lib.JSON(w, rows, http.StatusOK)
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"tasks/db"
+	"tasks/lib"
+	"tasks/server/auth"
+)
+
+// maxTodoTxtImportSize bounds the size of an uploaded todo.txt file.
+const maxTodoTxtImportSize = 10 << 20
+
+// ExportTodoTxt serves the notes of the user as a todo.txt file. q restricts
+// the export to the notes matching a filter.
+func ExportTodoTxt(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		username := auth.UsernameFromContext(ctx)
+		var tasks []db.Task
+		var err error
+		if q := r.URL.Query().Get("q"); q != "" {
+			tasks, err = s.SearchTasks(ctx, username, q, "", time.Now())
+		} else {
+			tasks, err = s.GetAllTasksFromUser(ctx, username)
+		}
+		if err != nil {
+			l.Error().Err(err).Msgf("Could not fetch notes for todo.txt export")
+			writeProblem(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="todo.txt"`)
+		w.WriteHeader(http.StatusOK)
+		if err := lib.WriteTodoTxt(w, tasks); err != nil {
+			l.Error().Err(err).Msgf("Could not write todo.txt export")
+		}
+	}
+}
+
+// ImportTodoTxt upserts the lines of a todo.txt file sent as the request
+// body and reports the lines it could not import.
+func ImportTodoTxt(s TaskService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
+		defer cancel()
+
+		body := http.MaxBytesReader(w, r.Body, maxTodoTxtImportSize)
+		result, err := s.ImportTodoTxt(ctx, auth.UsernameFromContext(ctx), body)
+		switch {
+		case err != nil:
+			l.Error().Err(err).Msgf("todo.txt import failed. %v", err)
+			writeProblem(w, r, err)
+		default:
+			l.Info().Msgf("todo.txt import created %d and updated %d notes", result.Created, result.Updated)
+			lib.JSON(w, result, http.StatusOK)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"tasks/db"
@@ -9,8 +11,6 @@ import (
 	"tasks/server/auth"
 	"tasks/service"
 	"time"
-
-	"github.com/go-playground/validator/v10"
 )
 
 func RegisterUser(s TaskService) http.HandlerFunc {
@@ -19,18 +19,10 @@ func RegisterUser(s TaskService) http.HandlerFunc {
 		defer cancel()
 
 		var req db.User
-		err := json.NewDecoder(r.Body).Decode(&req)
+		err := decodeRequest(r, &req)
 		if err != nil {
 			l.Error().Err(err).Msgf("error decoding the User into JSON during registration. %v", err)
-			lib.JSON(w, lib.Msg{"error": "internal error decoding User struct"}, http.StatusInternalServerError)
-			return
-		}
-
-		validate := validator.New()
-		err = validate.Struct(&req)
-		if err != nil {
-			l.Error().Err(err).Msgf("error during User struct validation %v", err)
-			lib.JSON(w, lib.Msg{"error": "wrongly formatted or missing User parameter"}, http.StatusBadRequest)
+			writeProblem(w, r, err)
 			return
 		}
 
@@ -38,11 +30,11 @@ func RegisterUser(s TaskService) http.HandlerFunc {
 		if err != nil {
 			if errors.Is(err, lib.ErrTooShort) {
 				l.Error().Err(err).Msgf("The given password is too short%v", err)
-				lib.JSON(w, lib.Msg{"error": "password is too short"}, http.StatusBadRequest)
+				writeProblem(w, r, fmt.Errorf("%w: password is too short", service.ErrInvalidRequest))
 				return
 			}
 			l.Error().Err(err).Msgf("error during password hashing %v", err)
-			lib.JSON(w, lib.Msg{"error": "internal error during password hashing"}, http.StatusInternalServerError)
+			writeProblem(w, r, err)
 			return
 		}
 
@@ -55,81 +47,111 @@ func RegisterUser(s TaskService) http.HandlerFunc {
 		switch {
 		case errors.Is(err, service.ErrAlreadyExists):
 			l.Error().Err(err).Msgf("registration failed, username or email already in use for user %s", req.Username)
-			lib.JSON(w, lib.Msg{"error": "username or email already in use"}, http.StatusForbidden)
+			writeProblem(w, r, err)
 			return
-		case errors.Is(err, service.ErrDBInternal):
+		case err != nil:
 			l.Error().Err(err).Msgf("Error during User registration! %v", err)
-			lib.JSON(w, lib.Msg{"error": "internal error during user registration"}, http.StatusInternalServerError)
+			writeProblem(w, r, err)
 			return
 		default:
+			s.RecordAuthEvent(ctx, uname, service.AuditRegister)
 			lib.JSON(w, lib.Msg{"success": "User registration successful!"}, http.StatusCreated)
 			l.Info().Msgf("User registration for %s was successful!", uname)
 		}
 	}
 }
 
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
 func LoginUser(s TaskService, t auth.TokenManager, tokenDuration time.Duration) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		l, ctx, cancel := lib.SetupHandler(w, r.Context())
 		defer cancel()
 
-		req := struct {
-			Username string `json:"username"`
-			Password string `json:"password"`
-		}{}
+		var req loginRequest
 
 		err := json.NewDecoder(r.Body).Decode(&req)
 		if err != nil {
 			l.Error().Err(err).Msgf("error decoding the User into JSON during registration. %v", err)
-			lib.JSON(w, lib.Msg{"error": "internal error decoding User struct"}, http.StatusInternalServerError)
+			writeProblem(w, r, fmt.Errorf("%w: %v", service.ErrMalformedRequest, err))
 			return
 		}
 
 		user, err := s.GetUser(ctx, req.Username)
 		switch {
 		case errors.Is(err, service.ErrNotFound):
+			// Unknown users are refused like wrong passwords, so that
+			// usernames cannot be probed.
 			l.Error().Err(err).Msgf("user: %s is not found", req.Username)
-			lib.JSON(w, lib.Msg{"error": "user is not found"}, http.StatusForbidden)
+			writeProblem(w, r, service.ErrWrongPassword)
 			return
-		case errors.Is(err, service.ErrDBInternal):
+		case err != nil:
 			l.Error().Err(err).Msgf("Error during user lookup! %v", err)
-			lib.JSON(w, lib.Msg{"error": "internal error during user lookup!"}, http.StatusInternalServerError)
+			writeProblem(w, r, err)
 			return
 		}
 
 		err = lib.Validate(user.Password, req.Password)
 		if err != nil {
+			s.RecordAuthEvent(ctx, req.Username, service.AuditLoginFailed)
 			l.Info().Err(err).Msgf("Wrong password was provided for user %s", req.Username)
-			lib.JSON(w, lib.Msg{"error": "wrong password was provided"}, http.StatusUnauthorized)
+			writeProblem(w, r, service.ErrWrongPassword)
 			return
 		}
 
 		token, payload, err := t.CreateToken(req.Username, tokenDuration)
 		if err != nil {
 			l.Info().Err(err).Msgf("Could not create PASETO for user. %v", err)
-			lib.JSON(w, lib.Msg{"error": "internal server error while creating the token"}, http.StatusInternalServerError)
+			writeProblem(w, r, err)
 			return
 		}
 
+		s.RecordAuthEvent(ctx, req.Username, service.AuditLogin)
 		lib.SetCookie(w, "paseto", token, payload.ExpiresAt)
 		lib.JSON(w, lib.Msg{"success": "login successful"}, http.StatusOK)
 		l.Info().Msgf("User login for %s was successful!", req.Username)
 	}
 }
 
-func LogoutUser() http.HandlerFunc {
+// VerifyPassword checks the credentials of requests using basic
+// authentication. Wrong passwords are recorded in the audit trail.
+func VerifyPassword(s TaskService) func(ctx context.Context, username string, password string) error {
+	return func(ctx context.Context, username string, password string) error {
+		user, err := s.GetUser(ctx, username)
+		if err != nil {
+			return err
+		}
+		if err := lib.Validate(user.Password, password); err != nil {
+			s.RecordAuthEvent(ctx, username, service.AuditLoginFailed)
+			return err
+		}
+		return nil
+	}
+}
+
+func LogoutUser(s TaskService, t auth.TokenManager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		l, _, cancel := lib.SetupHandler(w, r.Context())
+		l, ctx, cancel := lib.SetupHandler(w, r.Context())
 		defer cancel()
 
 		uname, err := io.ReadAll(r.Body)
 		if err != nil {
-			lib.JSON(w, lib.Msg{"error": "couldn't decode request body"}, http.StatusInternalServerError)
+			writeProblem(w, r, err)
 			return
 		}
 
+		if cookie, err := r.Cookie("paseto"); err == nil {
+			if payload, err := t.VerifyToken(cookie.Value); err == nil {
+				s.RecordAuthEvent(ctx, payload.Username, service.AuditLogout)
+			}
+		}
+
 		http.SetCookie(w, &http.Cookie{
 			Name:     "paseto",
+			Path:     "/",
 			Value:    "",
 			Expires:  time.Unix(0, 0),
 			HttpOnly: true,
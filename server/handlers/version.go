@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+)
+
+// APIv1Root is the path under which version 1 of the API is served.
+const APIv1Root = "/api/v1"
+
+// DeprecatedAlias serves routes of a version of the API from another path
+// for clients written before it moved. Responses of the routes carry the
+// Deprecation (RFC 9745) and Sunset (RFC 8594) headers and a Link to the
+// path under root, and each use is logged so the remaining clients can be
+// found. Requests the routes do not match are passed on unmarked.
+func DeprecatedAlias(routes chi.Routes, root string, deprecation time.Time, sunset time.Time) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !routes.Match(chi.NewRouteContext(), r.Method, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			successor := root + r.URL.Path
+			w.Header().Set("Deprecation", "@"+strconv.FormatInt(deprecation.Unix(), 10))
+			w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			w.Header().Add("Link", "<"+successor+`>; rel="successor-version"`)
+			zerolog.Ctx(r.Context()).Warn().Str("successor", successor).Str("userAgent", r.UserAgent()).
+				Msgf("Deprecated path %s %s was used", r.Method, r.URL.Path)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
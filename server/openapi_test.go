@@ -0,0 +1,112 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tasks/lib"
+	"tasks/server/handlers"
+	"tasks/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// specPath turns a chi route into the path template of the OpenAPI document.
+func specPath(route string) string {
+	if strings.HasSuffix(route, "/*") {
+		return strings.TrimSuffix(route, "*") + "{path}"
+	}
+	if route != "/" {
+		route = strings.TrimSuffix(route, "/")
+	}
+	return route
+}
+
+func TestOpenAPI(t *testing.T) {
+	l := zerolog.Nop()
+	r, err := NewChiRouter(nil, "12345678901234567890123456789012", 3600, &l)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, handlers.APIv1Root+"/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var doc lib.OpenAPI
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+
+	t.Run("every route is documented", func(t *testing.T) {
+		routes := map[string]map[string]bool{}
+		err := chi.Walk(r, func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+			path := specPath(route)
+			if routes[path] == nil {
+				routes[path] = map[string]bool{}
+			}
+			routes[path][method] = true
+			return nil
+		})
+		require.NoError(t, err)
+
+		for path, methods := range routes {
+			// The deprecated aliases of version 1 are not documented.
+			if !strings.HasPrefix(path, handlers.APIv1Root+"/") && routes[handlers.APIv1Root+path] != nil {
+				continue
+			}
+			require.Contains(t, doc.Paths, path, "route %s is missing from the OpenAPI document", path)
+			// A handler mounted for every method decides itself which it
+			// supports, so only those are documented.
+			if methods[http.MethodConnect] {
+				continue
+			}
+			for method := range methods {
+				require.Contains(t, doc.Paths[path], handlers.OpenAPIMethodKey(method), "%s %s is missing from the OpenAPI document", method, path)
+			}
+		}
+		for path, ops := range doc.Paths {
+			require.Contains(t, routes, path, "documented path %s is not routed", path)
+			for key := range ops {
+				method := strings.ToUpper(strings.TrimPrefix(key, "x-"))
+				require.True(t, routes[path][method], "documented %s %s is not routed", method, path)
+			}
+		}
+	})
+
+	t.Run("schemas", func(t *testing.T) {
+		task := doc.Components.Schemas["Task"]
+		require.NotNil(t, task)
+		require.Equal(t, []string{"title", "user"}, task.Required)
+		require.Equal(t, 4, *task.Properties["title"].MinLength)
+		require.Equal(t, []string{"todo", "in_progress", "done"}, task.Properties["status"].Enum)
+		require.Equal(t, "uuid", task.Properties["id"].Format)
+		require.Equal(t, "date-time", task.Properties["due"].Format)
+
+		user := doc.Components.Schemas["User"]
+		require.NotNil(t, user)
+		require.Equal(t, "email", user.Properties["email"].Format)
+		require.Equal(t, 30, *user.Properties["username"].MaxLength)
+
+		bulk := doc.Components.Schemas["BulkTasksRequest"]
+		require.NotNil(t, bulk)
+		require.Equal(t, 500, *bulk.Properties["operations"].MaxItems)
+		require.Equal(t, "#/components/schemas/BulkOperation", bulk.Properties["operations"].Items.Ref)
+
+		create := doc.Paths[handlers.APIv1Root+"/notes/create"]["post"]
+		require.Equal(t, "#/components/schemas/Task", create.RequestBody.Content["application/json"].Schema.Ref)
+		require.Equal(t, []map[string][]string{{"paseto": {}}}, create.Security)
+		require.Equal(t, "#/components/schemas/Problem", create.Responses["default"].Content[lib.ProblemContentType].Schema.Ref)
+		require.Equal(t, "#/components/schemas/FieldError", doc.Components.Schemas["Problem"].Properties["errors"].Items.Ref)
+	})
+
+	t.Run("docs page", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, handlers.APIv1Root+"/docs", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Contains(t, rec.Body.String(), `fetch("openapi.json")`)
+		for _, kind := range service.Kinds {
+			require.Contains(t, rec.Body.String(), `id="`+strings.TrimPrefix(handlers.ProblemType(kind), handlers.APIv1Root+"/docs#")+`"`)
+		}
+	})
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tasks/db"
+	"tasks/lib"
+	"tasks/server/handlers"
+	"tasks/service"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPatchTask checks that patches of a note are applied whole or not at
+// all.
+func TestPatchTask(t *testing.T) {
+	s, d := newTestService(t, "alice", "bob")
+	ctx := context.Background()
+	id, err := s.CreateTask(ctx, &db.Task{Title: "Pack bag", User: "alice", Text: "Passport", Tags: []string{"trip"}})
+	require.NoError(t, err)
+	other, err := s.CreateTask(ctx, &db.Task{Title: "Water plants", User: "bob"})
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(newTestRouter(t, s))
+	defer srv.Close()
+	client := login(t, srv.URL, "alice")
+
+	patch := func(t *testing.T, id uuid.UUID, contentType string, body string) (*http.Response, map[string]interface{}) {
+		req, err := http.NewRequest(http.MethodPatch, srv.URL+handlers.APIv1Root+"/notes/"+id.String(), strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var v map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&v))
+		return resp, v
+	}
+	stored := func(t *testing.T) *db.Task {
+		task, err := d.GetTask(id.String())
+		require.NoError(t, err)
+		return task
+	}
+
+	t.Run("merge patch", func(t *testing.T) {
+		resp, v := patch(t, id, lib.MergePatchContentType, `{"priority": "high", "text": null}`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "high", v["priority"])
+		task := stored(t)
+		require.Equal(t, "Pack bag", task.Title)
+		require.Equal(t, "high", task.Priority)
+		require.Empty(t, task.Text)
+	})
+
+	t.Run("json patch", func(t *testing.T) {
+		resp, _ := patch(t, id, lib.JSONPatchContentType+"; charset=utf-8", `[
+			{"op": "test", "path": "/title", "value": "Pack bag"},
+			{"op": "replace", "path": "/status", "value": "done"},
+			{"op": "add", "path": "/tags/-", "value": "trip"},
+			{"op": "add", "path": "/tags/-", "value": "packing"}
+		]`)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		task := stored(t)
+		require.Equal(t, db.StatusDone, task.Status)
+		require.NotNil(t, task.CompletedAt)
+		require.Equal(t, []string{"trip", "packing"}, task.Tags)
+	})
+
+	t.Run("unset members", func(t *testing.T) {
+		unset, err := s.CreateTask(ctx, &db.Task{Title: "Call plumber", User: "alice"})
+		require.NoError(t, err)
+		resp, v := patch(t, unset, lib.JSONPatchContentType, `[
+			{"op": "test", "path": "/project", "value": ""},
+			{"op": "test", "path": "/due", "value": null},
+			{"op": "replace", "path": "/project", "value": "home"},
+			{"op": "replace", "path": "/priority", "value": "high"},
+			{"op": "replace", "path": "/due", "value": "2026-11-02T09:00:00Z"},
+			{"op": "add", "path": "/tags/-", "value": "repair"}
+		]`)
+		require.Equal(t, http.StatusOK, resp.StatusCode, v)
+		task, err := d.GetTask(unset.String())
+		require.NoError(t, err)
+		require.Equal(t, "home", task.Project)
+		require.Equal(t, "high", task.Priority)
+		require.NotNil(t, task.Due)
+		require.Equal(t, []string{"repair"}, task.Tags)
+
+		resp, _ = patch(t, unset, lib.JSONPatchContentType, `[{"op": "test", "path": "/recurrence", "value": "weekly"}]`)
+		require.Equal(t, http.StatusConflict, resp.StatusCode)
+	})
+
+	t.Run("failed test changes nothing", func(t *testing.T) {
+		before := stored(t)
+		resp, v := patch(t, id, lib.JSONPatchContentType, `[
+			{"op": "replace", "path": "/title", "value": "Pack suitcase"},
+			{"op": "test", "path": "/priority", "value": "low"}
+		]`)
+		require.Equal(t, http.StatusConflict, resp.StatusCode)
+		require.Equal(t, handlers.ProblemType(service.KindConflict), v["type"])
+		require.Equal(t, before, stored(t))
+	})
+
+	t.Run("invalid patches", func(t *testing.T) {
+		before := stored(t)
+		for _, tt := range []struct {
+			contentType string
+			body        string
+		}{
+			{lib.MergePatchContentType, `{"user": "bob"}`},
+			{lib.MergePatchContentType, `{"owner": "bob"}`},
+			{lib.MergePatchContentType, `["Pack bag"]`},
+			{lib.JSONPatchContentType, `[{"op": "remove", "path": "/id"}]`},
+			{lib.JSONPatchContentType, `[{"op": "replace", "path": "/due", "value": "soon"}]`},
+			{lib.JSONPatchContentType, `[{"op": "remove", "path": "/owner"}]`},
+		} {
+			resp, v := patch(t, id, tt.contentType, tt.body)
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode, tt.body)
+			require.Contains(t, v["detail"], service.ErrInvalidPatch.Error(), tt.body)
+		}
+
+		resp, v := patch(t, id, lib.MergePatchContentType, `{"title": "Go", "status": "later"}`)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		require.Len(t, v["errors"], 2)
+		require.Equal(t, before, stored(t))
+	})
+
+	t.Run("unsupported media type", func(t *testing.T) {
+		resp, _ := patch(t, id, "application/json", `{"title": "Pack suitcase"}`)
+		require.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+		require.Equal(t, lib.MergePatchContentType+", "+lib.JSONPatchContentType, resp.Header.Get("Accept-Patch"))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		resp, _ := patch(t, uuid.New(), lib.MergePatchContentType, `{"title": "Pack suitcase"}`)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		resp, _ = patch(t, other, lib.MergePatchContentType, `{"title": "Pack suitcase"}`)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tasks/lib"
+	"tasks/server/handlers"
+	"tasks/service"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// TestProblems checks that errors are answered with problem details whose
+// status follows from the kind of the error.
+func TestProblems(t *testing.T) {
+	s, _ := newTestService(t, "alice")
+	srv := httptest.NewServer(newTestRouter(t, s))
+	defer srv.Close()
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{Jar: jar}
+
+	do := func(t *testing.T, method string, path string, body string) (*http.Response, lib.Problem) {
+		req, err := http.NewRequest(method, srv.URL+handlers.APIv1Root+path, strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var p lib.Problem
+		if resp.Header.Get("Content-Type") == lib.ProblemContentType {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&p))
+		}
+		return resp, p
+	}
+
+	t.Run("unauthenticated", func(t *testing.T) {
+		resp, p := do(t, http.MethodGet, "/notes", "")
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		require.Equal(t, "about:blank", p.Type)
+		require.Equal(t, http.StatusUnauthorized, p.Status)
+		require.NotEmpty(t, p.RequestID)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		resp, p := do(t, http.MethodPost, "/login", `{"username": "alice", "password": "wrong"}`)
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		require.Equal(t, handlers.ProblemType(service.KindUnauthorized), p.Type)
+
+		resp, p = do(t, http.MethodPost, "/login", `{"username": "mallory", "password": "wrong"}`)
+		require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+		require.Equal(t, service.ErrWrongPassword.Error(), p.Detail)
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		resp, p := do(t, http.MethodPost, "/register", `{"username": "alice", "password": "secret123", "email": "alice@example.com"}`)
+		require.Equal(t, http.StatusConflict, resp.StatusCode)
+		require.Equal(t, handlers.ProblemType(service.KindConflict), p.Type)
+	})
+
+	resp, _ := do(t, http.MethodPost, "/login", `{"username": "alice", "password": "secret123"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	t.Run("malformed body", func(t *testing.T) {
+		resp, p := do(t, http.MethodPost, "/notes/create", `{"title": `)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		require.Equal(t, handlers.ProblemType(service.KindInvalid), p.Type)
+		require.Equal(t, "Invalid request", p.Title)
+		require.Equal(t, handlers.APIv1Root+"/notes/create", p.Instance)
+		require.NotEmpty(t, p.RequestID)
+		require.Contains(t, p.Detail, service.ErrMalformedRequest.Error())
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		resp, p := do(t, http.MethodPost, "/notes/create", `{"title": "abc", "user": "alice", "status": "later"}`)
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		require.Equal(t, []lib.FieldError{
+			{Field: "title", Rule: "min", Param: "4", Message: "must be at least 4 characters"},
+			{Field: "status", Rule: "oneof", Param: "todo in_progress done", Message: "must be one of todo, in_progress, done"},
+		}, p.Errors)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		resp, p := do(t, http.MethodDelete, "/notes/"+uuid.NewString(), "")
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		require.Equal(t, handlers.ProblemType(service.KindNotFound), p.Type)
+		require.Equal(t, service.ErrNotFound.Error(), p.Detail)
+
+		resp, p = do(t, http.MethodDelete, "/notes/42", "")
+		require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		require.Contains(t, p.Detail, service.ErrInvalidParameter.Error())
+	})
+}
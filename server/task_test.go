@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tasks/db"
+	"tasks/server/handlers"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestTaskOwner checks that the notes of a user are neither listed, changed
+// nor deleted by another one, and that notes are created for the signed in
+// user.
+func TestTaskOwner(t *testing.T) {
+	s, d := newTestService(t, "alice", "bob")
+	id, err := s.CreateTask(context.Background(), &db.Task{Title: "Pack bag", User: "alice"})
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(newTestRouter(t, s))
+	defer srv.Close()
+	do := func(t *testing.T, client *http.Client, method string, path string, body string) *http.Response {
+		req, err := http.NewRequest(method, srv.URL+handlers.APIv1Root+"/notes"+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+	titles := func(t *testing.T, client *http.Client) []string {
+		// The username parameter of older clients is ignored.
+		resp := do(t, client, http.MethodGet, "?username=alice", "")
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var tasks []db.Task
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&tasks))
+		titles := []string{}
+		for _, task := range tasks {
+			titles = append(titles, task.Title)
+		}
+		return titles
+	}
+	alice := login(t, srv.URL, "alice")
+	bob := login(t, srv.URL, "bob")
+
+	t.Run("other user", func(t *testing.T) {
+		require.Empty(t, titles(t, bob))
+
+		resp := do(t, bob, http.MethodPut, "/"+id.String(), `{"title": "Pack nothing"}`)
+		resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		resp = do(t, bob, http.MethodDelete, "/"+id.String(), "")
+		resp.Body.Close()
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+		task, err := d.GetTask(id.String())
+		require.NoError(t, err)
+		require.Equal(t, "Pack bag", task.Title)
+	})
+
+	t.Run("create", func(t *testing.T) {
+		resp := do(t, bob, http.MethodPost, "/create", `{"title": "Buy socks", "user": "alice"}`)
+		resp.Body.Close()
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		require.Equal(t, []string{"Buy socks"}, titles(t, bob))
+		require.Equal(t, []string{"Pack bag"}, titles(t, alice))
+	})
+
+	t.Run("owner", func(t *testing.T) {
+		resp := do(t, alice, http.MethodPut, "/"+id.String(), `{"title": "Pack suitcase"}`)
+		var msg map[string]string
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&msg))
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "note updated", msg["success"])
+		require.Equal(t, []string{"Pack suitcase"}, titles(t, alice))
+
+		resp = do(t, alice, http.MethodDelete, "/"+id.String(), "")
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Empty(t, titles(t, alice))
+	})
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"tasks/db"
+	"tasks/lib"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// accountExportTimeout bounds a pending export. One that takes longer is
+// considered lost, for instance to a restart, and a new one may be started.
+const accountExportTimeout = 10 * time.Minute
+
+var (
+	ErrExportNotFound = newError(KindNotFound, "requested account export is not found")
+	ErrExportNotReady = newError(KindConflict, "account export is not ready yet")
+	ErrWrongPassword  = newError(KindUnauthorized, "wrong password was provided")
+)
+
+// StartAccountExport starts building an archive of the data of the user in
+// the background and returns the export, whose status tells when it is
+// ready. A pending export is returned as it is instead of starting another.
+func (s *task) StartAccountExport(ctx context.Context, username string) (*db.AccountExport, error) {
+	now := time.Now()
+	export, err := s.db.GetAccountExport(username)
+	switch {
+	case errors.Is(err, db.ErrExportNotFound):
+	case err != nil:
+		return nil, ErrDBInternal
+	case export.Status == db.ExportPending && now.Sub(export.CreatedAt) < accountExportTimeout:
+		return export, nil
+	}
+
+	export = &db.AccountExport{ID: uuid.New(), User: username, Status: db.ExportPending, CreatedAt: now}
+	if err := s.db.PutAccountExport(export, nil); err != nil {
+		return nil, ErrDBInternal
+	}
+	l := zerolog.Ctx(ctx)
+	go s.buildAccountExport(l, *export)
+	return export, nil
+}
+
+// buildAccountExport writes the archive of the export and stores it, unless
+// a newer export was started meanwhile.
+func (s *task) buildAccountExport(l *zerolog.Logger, export db.AccountExport) {
+	var archive bytes.Buffer
+	err := s.writeAccountArchive(export.User, &archive)
+	finished := time.Now()
+	export.FinishedAt = &finished
+	if err != nil {
+		l.Error().Err(err).Msgf("could not export the account of %s", export.User)
+		export.Status = db.ExportFailed
+	} else {
+		export.Status = db.ExportReady
+		export.Size = archive.Len()
+	}
+
+	if current, err := s.db.GetAccountExport(export.User); err != nil || current.ID != export.ID {
+		return
+	}
+	var data []byte
+	if export.Status == db.ExportReady {
+		data = archive.Bytes()
+	}
+	if err := s.db.PutAccountExport(&export, data); err != nil {
+		l.Error().Err(err).Msgf("could not store the account export of %s", export.User)
+	}
+}
+
+func (s *task) writeAccountArchive(username string, archive *bytes.Buffer) error {
+	user, err := s.db.GetUser(username)
+	if err != nil {
+		return err
+	}
+	data := &lib.AccountData{
+		Profile:    lib.AccountProfile{Username: user.Username, Email: user.Email},
+		ExportedAt: time.Now(),
+	}
+	if data.Tasks, err = s.db.GetAllTasksFromUser(context.Background(), username); err != nil {
+		return err
+	}
+	sort.Slice(data.Tasks, func(i, j int) bool { return data.Tasks[i].CreatedAt.Before(data.Tasks[j].CreatedAt) })
+	if data.History, err = s.db.GetAuditEvents(username, time.Time{}, time.Time{}); err != nil {
+		return err
+	}
+	if data.TimeEntries, err = s.db.GetTimeEntries(username, uuid.Nil, time.Time{}, time.Time{}); err != nil {
+		return err
+	}
+	sort.Slice(data.TimeEntries, func(i, j int) bool { return data.TimeEntries[i].Start.Before(data.TimeEntries[j].Start) })
+	if data.Templates, err = s.db.GetTemplates(username); err != nil {
+		return err
+	}
+	if data.Filters, err = s.db.GetFilters(username); err != nil {
+		return err
+	}
+	if data.Fields, err = s.db.GetAllFieldDefinitions(username); err != nil {
+		return err
+	}
+	return lib.WriteAccountArchive(archive, data)
+}
+
+func (s *task) GetAccountExport(ctx context.Context, username string) (*db.AccountExport, error) {
+	export, err := s.db.GetAccountExport(username)
+	switch {
+	case errors.Is(err, db.ErrExportNotFound):
+		return nil, ErrExportNotFound
+	case err != nil:
+		return nil, ErrDBInternal
+	}
+	return export, nil
+}
+
+// GetAccountExportArchive returns the ZIP archive of the latest export of
+// the user, once it is ready.
+func (s *task) GetAccountExportArchive(ctx context.Context, username string) (*db.AccountExport, []byte, error) {
+	export, err := s.GetAccountExport(ctx, username)
+	if err != nil {
+		return nil, nil, err
+	}
+	if export.Status != db.ExportReady {
+		return export, nil, ErrExportNotReady
+	}
+	archive, err := s.db.GetAccountExportArchive(username)
+	switch {
+	case errors.Is(err, db.ErrExportNotFound):
+		return nil, nil, ErrExportNotFound
+	case err != nil:
+		return nil, nil, ErrDBInternal
+	}
+	return export, archive, nil
+}
+
+// DeleteAccount removes the user and all of their data once the password is
+// confirmed. The audit trail of the user goes with it.
+func (s *task) DeleteAccount(ctx context.Context, username string, password string) error {
+	user, err := s.GetUser(ctx, username)
+	if err != nil {
+		return err
+	}
+	if err := lib.Validate(user.Password, password); err != nil {
+		s.RecordAuthEvent(ctx, username, AuditLoginFailed)
+		return ErrWrongPassword
+	}
+	err = s.db.DeleteUser(username)
+	switch {
+	case errors.Is(err, db.ErrUserNotFound):
+		return ErrUserNotFound
+	case err != nil:
+		return ErrDBInternal
+	}
+	return nil
+}
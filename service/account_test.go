@@ -0,0 +1,77 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"tasks/db"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccount(t *testing.T) {
+	s, d := newTestService(t, "alice")
+	ctx := WithRequestInfo(context.Background(), RequestInfo{Actor: "alice"})
+	user, err := d.GetUser("alice")
+	require.NoError(t, err)
+	_, err = s.CreateTask(ctx, &db.Task{Title: "Pack bag", User: "alice", Text: "Passport and *charger*"})
+	require.NoError(t, err)
+
+	t.Run("export", func(t *testing.T) {
+		_, _, err := s.GetAccountExportArchive(ctx, "alice")
+		require.ErrorIs(t, err, ErrExportNotFound)
+
+		export, err := s.StartAccountExport(ctx, "alice")
+		require.NoError(t, err)
+		require.Equal(t, db.ExportPending, export.Status)
+
+		var archive []byte
+		require.Eventually(t, func() bool {
+			_, archive, err = s.GetAccountExportArchive(ctx, "alice")
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond)
+
+		z, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		require.NoError(t, err)
+		files := map[string]string{}
+		for _, f := range z.File {
+			r, err := f.Open()
+			require.NoError(t, err)
+			content, err := io.ReadAll(r)
+			require.NoError(t, err)
+			files[f.Name] = string(content)
+		}
+		require.Len(t, files, 8)
+		require.Contains(t, files["profile.json"], "alice@example.com")
+		require.NotContains(t, files["profile.json"], user.Password)
+		require.Contains(t, files["tasks.json"], "Pack bag")
+		require.Contains(t, files["history.json"], AuditTaskCreate)
+		require.Equal(t, "[]\n", files["templates.json"])
+		for name, content := range files {
+			if strings.HasPrefix(name, "tasks/") {
+				require.True(t, strings.HasPrefix(name, "tasks/pack-bag-"), name)
+				require.True(t, strings.HasPrefix(content, "---\nid: "), content)
+				require.True(t, strings.HasSuffix(content, "---\n\n# Pack bag\n\nPassport and *charger*\n"), content)
+			}
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.ErrorIs(t, s.DeleteAccount(ctx, "alice", "wrong"), ErrWrongPassword)
+		require.NoError(t, s.DeleteAccount(ctx, "alice", "secret123"))
+
+		_, err := s.GetUser(ctx, "alice")
+		require.ErrorIs(t, err, ErrNotFound)
+		tasks, err := s.GetAllTasksFromUser(ctx, "alice")
+		require.NoError(t, err)
+		require.Empty(t, tasks)
+		_, _, err = s.GetAccountExportArchive(ctx, "alice")
+		require.ErrorIs(t, err, ErrExportNotFound)
+		require.ErrorIs(t, s.DeleteAccount(ctx, "alice", "secret123"), ErrNotFound)
+	})
+}
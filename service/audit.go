@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"tasks/db"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+const (
+	AuditRegister    = "user.register"
+	AuditLogin       = "user.login"
+	AuditLoginFailed = "user.login_failed"
+	AuditLogout      = "user.logout"
+
+	AuditTaskCreate = "task.create"
+	AuditTaskUpdate = "task.update"
+	AuditTaskMove   = "task.move"
+	AuditTaskDelete = "task.delete"
+)
+
+// RequestInfo describes the request on whose behalf the service is called.
+// Actor is the authenticated user and is empty for anonymous requests.
+type RequestInfo struct {
+	Actor     string
+	IP        string
+	UserAgent string
+	RequestID string
+}
+
+type requestInfoKey struct{}
+
+// WithRequestInfo stores the request description used for audit events.
+func WithRequestInfo(ctx context.Context, info RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey{}, info)
+}
+
+// RequestInfoFromContext returns the request description stored by
+// WithRequestInfo.
+func RequestInfoFromContext(ctx context.Context) RequestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info
+}
+
+// auditEvent builds an event of the trail. The actor of the request takes
+// precedence over the given one, which is used for anonymous requests such
+// as registration and login.
+func auditEvent(ctx context.Context, actor string, action string, target string, before *db.TaskSummary, after *db.TaskSummary) *db.AuditEvent {
+	info := RequestInfoFromContext(ctx)
+	if info.Actor != "" {
+		actor = info.Actor
+	}
+	return &db.AuditEvent{
+		ID:        uuid.New(),
+		Time:      time.Now(),
+		Actor:     actor,
+		Action:    action,
+		Target:    target,
+		Before:    before,
+		After:     after,
+		IP:        info.IP,
+		UserAgent: info.UserAgent,
+		RequestID: info.RequestID,
+	}
+}
+
+// audit appends an event to the trail on its own, for actions that change
+// nothing else. Failures are logged.
+func (s *task) audit(ctx context.Context, actor string, action string, target string, before *db.TaskSummary, after *db.TaskSummary) {
+	if err := s.db.AppendAuditEvent(auditEvent(ctx, actor, action, target, before, after)); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("could not record audit event %s on %s", action, target)
+	}
+}
+
+// auditTx appends an event to the trail in the transaction of the change it
+// describes, which fails with it.
+func auditTx(ctx context.Context, tx *db.TaskTx, actor string, action string, target string, before *db.TaskSummary, after *db.TaskSummary) error {
+	return tx.AppendAuditEvent(auditEvent(ctx, actor, action, target, before, after))
+}
+
+func auditCreatedTx(ctx context.Context, tx *db.TaskTx, tasks ...*db.Task) error {
+	for _, t := range tasks {
+		if err := auditTx(ctx, tx, t.User, AuditTaskCreate, t.ID.String(), nil, t.Summary()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// auditImportTx records the tasks created and updated by an import of the
+// user. befores and afters hold the summaries of the updated tasks.
+func auditImportTx(ctx context.Context, tx *db.TaskTx, username string, created []*db.Task, updated []uuid.UUID, befores []*db.TaskSummary, afters []*db.TaskSummary) error {
+	if err := auditCreatedTx(ctx, tx, created...); err != nil {
+		return err
+	}
+	for i, id := range updated {
+		if err := auditTx(ctx, tx, username, AuditTaskUpdate, id.String(), befores[i], afters[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createTasks stores new tasks of the user and records their creation in
+// the same transaction.
+func (s *task) createTasks(ctx context.Context, username string, tasks ...*db.Task) error {
+	return s.db.InTransaction(username, func(tx *db.TaskTx) error {
+		for _, t := range tasks {
+			if err := tx.CreateTask(t); err != nil {
+				return err
+			}
+		}
+		return auditCreatedTx(ctx, tx, tasks...)
+	})
+}
+
+// updateTask applies fn to the stored task and records the change under the
+// given action in the same transaction.
+func (s *task) updateTask(ctx context.Context, id uuid.UUID, action string, fn func(t *db.Task) error) (*db.Task, error) {
+	var t *db.Task
+	err := s.db.InTaskTransaction(id, func(tx *db.TaskTx) error {
+		var before *db.TaskSummary
+		var err error
+		t, err = tx.UpdateTask(id, func(t *db.Task) error {
+			before = t.Summary()
+			return fn(t)
+		})
+		if err != nil {
+			return err
+		}
+		return auditTx(ctx, tx, t.User, action, t.ID.String(), before, t.Summary())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// RecordAuthEvent adds a registration, login or logout of the user to the
+// audit trail.
+func (s *task) RecordAuthEvent(ctx context.Context, username string, action string) {
+	s.audit(ctx, username, action, username, nil, nil)
+}
+
+func (s *task) GetAuditEvents(ctx context.Context, username string, from time.Time, to time.Time) ([]db.AuditEvent, error) {
+	events, err := s.db.GetAuditEvents(username, from, to)
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+	return events, nil
+}
+
+// ExportAuditEvents streams the events of the user within [from, to) to fn
+// in chronological order.
+func (s *task) ExportAuditEvents(ctx context.Context, username string, from time.Time, to time.Time, fn func(*db.AuditEvent) error) error {
+	return s.db.ForEachAuditEvent(username, from, to, fn)
+}
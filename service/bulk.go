@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"tasks/db"
+
+	"github.com/google/uuid"
+)
+
+const (
+	BulkUpdate     = "update"
+	BulkStatus     = "status"
+	BulkAddTags    = "add_tags"
+	BulkRemoveTags = "remove_tags"
+	BulkMove       = "move"
+	BulkDelete     = "delete"
+
+	BulkResultOK         = "ok"
+	BulkResultFailed     = "failed"
+	BulkResultRolledBack = "rolled_back"
+
+	// MaxBulkOperations is the number of operations a batch may hold.
+	MaxBulkOperations = 500
+)
+
+var (
+	ErrBulkFailed        = newError(KindConflict, "bulk operation failed and was rolled back")
+	ErrUnknownOperation  = newError(KindInvalid, "unknown bulk operation")
+	ErrTooManyOperations = newError(KindInvalid, "too many bulk operations")
+)
+
+type BulkOperation struct {
+	Op      string    `json:"op" validate:"required,oneof=update status add_tags remove_tags move delete"`
+	ID      uuid.UUID `json:"id" validate:"required"`
+	Title   *string   `json:"title,omitempty"`
+	Text    *string   `json:"text,omitempty"`
+	Project *string   `json:"project,omitempty"`
+	Status  *string   `json:"status,omitempty"`
+	Tags    []string  `json:"tags,omitempty"`
+	After   uuid.UUID `json:"after,omitempty"`
+	Before  uuid.UUID `json:"before,omitempty"`
+}
+
+type BulkResult struct {
+	Index  int       `json:"index"`
+	ID     uuid.UUID `json:"id"`
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+	Task   *db.Task  `json:"task,omitempty"`
+}
+
+// ApplyBulk applies the operations in order within one transaction. With
+// atomic set, the first failure rolls back every operation; otherwise failed
+// operations are skipped and the rest are committed. An operation is checked
+// in full before it writes, so a skipped one leaves no partial change behind.
+// The returned error is ErrBulkFailed when an atomic batch was rolled back.
+func (s *task) ApplyBulk(ctx context.Context, username string, ops []BulkOperation, atomic bool) ([]BulkResult, error) {
+	if len(ops) > MaxBulkOperations {
+		return nil, ErrTooManyOperations
+	}
+	results := make([]BulkResult, len(ops))
+	befores := make([]*db.TaskSummary, len(ops))
+	now := time.Now()
+
+	err := s.db.InTransaction(username, func(tx *db.TaskTx) error {
+		for i, op := range ops {
+			results[i] = BulkResult{Index: i, ID: op.ID, Status: BulkResultOK}
+			if prev, err := tx.GetTask(op.ID); err == nil {
+				befores[i] = prev.Summary()
+			}
+			t, err := applyBulkOperation(tx, op, now)
+			if err != nil && bulkErrorMessage(err) == ErrDBInternal.Error() {
+				// The operation may have failed halfway through its writes.
+				return err
+			}
+			if err != nil {
+				results[i].Status = BulkResultFailed
+				results[i].Error = bulkErrorMessage(err)
+				if atomic {
+					for j := range results[:i] {
+						results[j].Status = BulkResultRolledBack
+						results[j].Task = nil
+					}
+					for j := i + 1; j < len(ops); j++ {
+						results[j] = BulkResult{Index: j, ID: ops[j].ID, Status: BulkResultRolledBack}
+					}
+					return ErrBulkFailed
+				}
+				continue
+			}
+			results[i].Task = t
+		}
+		return auditBulkTx(ctx, tx, username, ops, results, befores)
+	})
+
+	switch {
+	case errors.Is(err, ErrBulkFailed):
+		return results, ErrBulkFailed
+	case err != nil:
+		return nil, ErrDBInternal
+	default:
+		return results, nil
+	}
+}
+
+func auditBulkTx(ctx context.Context, tx *db.TaskTx, username string, ops []BulkOperation, results []BulkResult, befores []*db.TaskSummary) error {
+	for i, r := range results {
+		if r.Status != BulkResultOK {
+			continue
+		}
+		action := AuditTaskUpdate
+		switch ops[i].Op {
+		case BulkMove:
+			action = AuditTaskMove
+		case BulkDelete:
+			action = AuditTaskDelete
+		}
+		if err := auditTx(ctx, tx, username, action, r.ID.String(), befores[i], r.Task.Summary()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyBulkOperation(tx *db.TaskTx, op BulkOperation, now time.Time) (*db.Task, error) {
+	check := func(t *db.Task) error {
+		t.UpdatedAt = now
+		return Validate(t, ErrInvalidTask)
+	}
+
+	switch op.Op {
+	case BulkUpdate:
+		return tx.UpdateTask(op.ID, func(t *db.Task) error {
+			if op.Title != nil {
+				t.Title = *op.Title
+			}
+			if op.Text != nil {
+				t.Text = *op.Text
+			}
+			if op.Project != nil {
+				t.Project = *op.Project
+			}
+			if op.Status != nil {
+				t.SetStatus(*op.Status, now)
+			}
+			return check(t)
+		})
+	case BulkStatus:
+		if op.Status == nil {
+			return nil, ErrInvalidTask
+		}
+		return tx.UpdateTask(op.ID, func(t *db.Task) error {
+			t.SetStatus(*op.Status, now)
+			return check(t)
+		})
+	case BulkAddTags:
+		return tx.UpdateTask(op.ID, func(t *db.Task) error {
+			t.AddTags(op.Tags...)
+			return check(t)
+		})
+	case BulkRemoveTags:
+		return tx.UpdateTask(op.ID, func(t *db.Task) error {
+			t.RemoveTags(op.Tags...)
+			return check(t)
+		})
+	case BulkMove:
+		return tx.MoveTask(op.ID, op.After, op.Before, func(t *db.Task) error {
+			if op.Status != nil {
+				t.SetStatus(*op.Status, now)
+			}
+			if op.Project != nil {
+				t.Project = *op.Project
+			}
+			return check(t)
+		})
+	case BulkDelete:
+		return nil, tx.DeleteTask(op.ID)
+	default:
+		return nil, ErrUnknownOperation
+	}
+}
+
+func bulkErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, db.ErrNoRows):
+		return ErrNotFound.Error()
+	case errors.Is(err, db.ErrInvalidRank):
+		return ErrInvalidPosition.Error()
+	case errors.Is(err, ErrInvalidTask), errors.Is(err, ErrUnknownOperation):
+		return err.Error()
+	default:
+		return ErrDBInternal.Error()
+	}
+}
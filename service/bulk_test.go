@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"tasks/db"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyBulk(t *testing.T) {
+	s, d := newTestService(t)
+	ctx := context.Background()
+	ids := map[string]uuid.UUID{}
+	for _, title := range []string{"Pack bag", "Book flights", "Water plants"} {
+		id, err := s.CreateTask(ctx, &db.Task{Title: title, User: "alice"})
+		require.NoError(t, err)
+		ids[title] = id
+	}
+	title := func(t *testing.T, id uuid.UUID) string {
+		task, err := d.GetTask(id.String())
+		require.NoError(t, err)
+		return task.Title
+	}
+	events := func(t *testing.T) int {
+		events, err := s.GetAuditEvents(ctx, "alice", time.Time{}, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		return len(events)
+	}
+	rename := func(id uuid.UUID, title string) BulkOperation {
+		return BulkOperation{Op: BulkUpdate, ID: id, Title: &title}
+	}
+
+	t.Run("atomic", func(t *testing.T) {
+		before := events(t)
+		results, err := s.ApplyBulk(ctx, "alice", []BulkOperation{
+			rename(ids["Pack bag"], "Pack suitcase"),
+			{Op: BulkDelete, ID: uuid.New()},
+			rename(ids["Book flights"], "Book trains"),
+		}, true)
+		require.ErrorIs(t, err, ErrBulkFailed)
+		require.Equal(t, []string{BulkResultRolledBack, BulkResultFailed, BulkResultRolledBack},
+			[]string{results[0].Status, results[1].Status, results[2].Status})
+		require.Equal(t, ErrNotFound.Error(), results[1].Error)
+		require.Nil(t, results[0].Task)
+		require.Equal(t, "Pack bag", title(t, ids["Pack bag"]))
+		require.Equal(t, before, events(t))
+	})
+
+	t.Run("best effort", func(t *testing.T) {
+		before := events(t)
+		results, err := s.ApplyBulk(ctx, "alice", []BulkOperation{
+			rename(ids["Pack bag"], "Pack suitcase"),
+			rename(ids["Book flights"], "Fly"),
+			{Op: "paint", ID: ids["Water plants"]},
+			{Op: BulkAddTags, ID: ids["Water plants"], Tags: []string{"home"}},
+		}, false)
+		require.NoError(t, err)
+		for i, r := range results {
+			require.Equal(t, i, r.Index)
+		}
+		require.Equal(t, BulkResultOK, results[0].Status)
+		require.Equal(t, "Pack suitcase", results[0].Task.Title)
+		require.Equal(t, BulkResultFailed, results[1].Status)
+		require.Contains(t, results[1].Error, "title must be at least 4 characters")
+		require.Equal(t, ErrUnknownOperation.Error(), results[2].Error)
+		require.Equal(t, []string{"home"}, results[3].Task.Tags)
+
+		require.Equal(t, "Pack suitcase", title(t, ids["Pack bag"]))
+		require.Equal(t, "Book flights", title(t, ids["Book flights"]))
+		require.Equal(t, before+2, events(t))
+	})
+
+	t.Run("failed move", func(t *testing.T) {
+		// An unranked neighbour makes the move rebalance the ranks of the
+		// user, which must not be kept when the move itself fails.
+		require.NoError(t, d.InTransaction("alice", func(tx *db.TaskTx) error {
+			_, err := tx.UpdateTask(ids["Book flights"], func(t *db.Task) error {
+				t.Rank = ""
+				return nil
+			})
+			return err
+		}))
+		status := "later"
+		results, err := s.ApplyBulk(ctx, "alice", []BulkOperation{
+			{Op: BulkMove, ID: ids["Pack bag"], After: ids["Book flights"], Status: &status},
+		}, false)
+		require.NoError(t, err)
+		require.Equal(t, BulkResultFailed, results[0].Status)
+		task, err := d.GetTask(ids["Book flights"].String())
+		require.NoError(t, err)
+		require.Empty(t, task.Rank)
+
+		results, err = s.ApplyBulk(ctx, "alice", []BulkOperation{
+			{Op: BulkMove, ID: ids["Pack bag"], After: ids["Book flights"]},
+		}, false)
+		require.NoError(t, err)
+		require.Equal(t, BulkResultOK, results[0].Status)
+		task, err = d.GetTask(ids["Book flights"].String())
+		require.NoError(t, err)
+		require.Less(t, task.Rank, results[0].Task.Rank)
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		ops := make([]BulkOperation, MaxBulkOperations+1)
+		for i := range ops {
+			ops[i] = BulkOperation{Op: BulkDelete, ID: uuid.New()}
+		}
+		_, err := s.ApplyBulk(ctx, "alice", ops, false)
+		require.ErrorIs(t, err, ErrTooManyOperations)
+
+		results, err := s.ApplyBulk(ctx, "alice", ops[:MaxBulkOperations], false)
+		require.NoError(t, err)
+		require.Len(t, results, MaxBulkOperations)
+	})
+}
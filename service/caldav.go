@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"tasks/db"
+	"tasks/lib"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrPreconditionFailed   = newError(KindPreconditionFailed, "precondition failed")
+	ErrInvalidSyncToken     = newError(KindInvalid, "sync token is invalid")
+	ErrUnsupportedComponent = newError(KindInvalid, "only VTODO components are supported")
+)
+
+// CalendarChanges are the changes of a project calendar after a sync
+// revision. Removed holds the UIDs of the tasks deleted from or moved out of
+// the project.
+type CalendarChanges struct {
+	Revision uint64
+	Changed  []db.Task
+	Removed  []string
+}
+
+// CalendarTaskName returns the name of the CalDAV resource of the task.
+func CalendarTaskName(t *db.Task) string {
+	return lib.ICalUID(t) + ".ics"
+}
+
+// GetCalendars returns the projects of the user that hold tasks, always
+// including the one without a name.
+func (s *task) GetCalendars(ctx context.Context, username string) ([]string, error) {
+	projects, err := s.db.GetSyncProjects(username)
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+	if len(projects) == 0 || projects[0] != "" {
+		projects = append([]string{""}, projects...)
+	}
+	return projects, nil
+}
+
+// GetCalendarTasks returns the tasks of the user in the project.
+func (s *task) GetCalendarTasks(ctx context.Context, username string, project string) ([]db.Task, error) {
+	tasks, err := s.db.GetAllTasksFromUser(ctx, username)
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+	result := []db.Task{}
+	for _, t := range tasks {
+		if t.Project == project {
+			result = append(result, t)
+		}
+	}
+	return result, nil
+}
+
+func (s *task) GetCalendarTask(ctx context.Context, username string, project string, name string) (*db.Task, error) {
+	tasks, err := s.GetCalendarTasks(ctx, username, project)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tasks {
+		if CalendarTaskName(&tasks[i]) == name {
+			return &tasks[i], nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// CalendarTag returns the current sync revision of the user and the latest
+// revision of a change to the project.
+func (s *task) CalendarTag(ctx context.Context, username string, project string) (uint64, uint64, error) {
+	rev, entries, err := s.db.GetSyncEntries(username, project, 0)
+	if err != nil {
+		return 0, 0, ErrDBInternal
+	}
+	var tag uint64
+	for _, e := range entries {
+		if e.Revision > tag {
+			tag = e.Revision
+		}
+	}
+	return rev, tag, nil
+}
+
+// SyncCalendar returns the changes of the project after the since revision.
+// Since 0 requests an initial sync, which lists only the existing tasks.
+func (s *task) SyncCalendar(ctx context.Context, username string, project string, since uint64) (*CalendarChanges, error) {
+	rev, entries, err := s.db.GetSyncEntries(username, project, since)
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+	if since > rev {
+		return nil, ErrInvalidSyncToken
+	}
+	tasks, err := s.GetCalendarTasks(ctx, username, project)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]*db.Task, len(tasks))
+	for i := range tasks {
+		byID[tasks[i].ID] = &tasks[i]
+	}
+
+	changes := &CalendarChanges{Revision: rev, Changed: []db.Task{}, Removed: []string{}}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Revision < entries[j].Revision })
+	for _, e := range entries {
+		switch t, ok := byID[e.TaskID]; {
+		case ok && !e.Deleted:
+			changes.Changed = append(changes.Changed, *t)
+		case e.Deleted && since > 0:
+			changes.Removed = append(changes.Removed, e.Name+".ics")
+		}
+	}
+	return changes, nil
+}
+
+// readCalendarTodo reads the single VTODO of a CalDAV resource body.
+func readCalendarTodo(r io.Reader) (*lib.ICalTodo, error) {
+	parsed, err := lib.ParseICal(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed.Invalid) > 0 {
+		return nil, fmt.Errorf("%w: %s", lib.ErrInvalidICal, parsed.Invalid[0].Reason)
+	}
+	if len(parsed.Skipped) > 0 && len(parsed.Todos) == 0 {
+		if parsed.Skipped[0].Component == "VTODO" {
+			return nil, fmt.Errorf("%w: %s", lib.ErrInvalidICal, parsed.Skipped[0].Reason)
+		}
+		return nil, ErrUnsupportedComponent
+	}
+	if len(parsed.Todos) != 1 {
+		return nil, fmt.Errorf("%w: a resource holds exactly one VTODO", lib.ErrInvalidICal)
+	}
+	return &parsed.Todos[0], nil
+}
+
+// checkETag applies the If-Match and If-None-Match preconditions of a
+// request to the current state of a resource; t is nil if it does not exist.
+func checkETag(t *db.Task, ifMatch string, ifNoneMatch string) error {
+	switch {
+	case ifNoneMatch == "*" && t != nil:
+		return ErrPreconditionFailed
+	case ifMatch == "":
+		return nil
+	case t == nil:
+		return ErrPreconditionFailed
+	case ifMatch != "*" && ifMatch != lib.ICalETag(t):
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// PutCalendarTask stores the VTODO read from data as the resource name of
+// the project calendar, creating the task if the resource does not exist.
+// The name of a new resource must be its UID followed by ".ics". It reports
+// whether the task was created.
+func (s *task) PutCalendarTask(ctx context.Context, username string, project string, name string, data io.Reader, ifMatch string, ifNoneMatch string) (*db.Task, bool, error) {
+	todo, err := readCalendarTodo(data)
+	if err != nil {
+		return nil, false, err
+	}
+	existing, err := s.GetCalendarTask(ctx, username, project, name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, false, err
+	}
+	if err := checkETag(existing, ifMatch, ifNoneMatch); err != nil {
+		return nil, false, err
+	}
+	now := time.Now()
+
+	if existing != nil {
+		t, err := s.updateTask(ctx, existing.ID, AuditTaskUpdate, func(t *db.Task) error {
+			if err := checkETag(t, ifMatch, ""); err != nil {
+				return err
+			}
+			mergeICalTodo(t, &todo.Task, now)
+			return Validate(t, ErrInvalidTask)
+		})
+		switch {
+		case errors.Is(err, db.ErrNoRows):
+			return nil, false, ErrNotFound
+		case errors.Is(err, ErrPreconditionFailed), errors.Is(err, ErrInvalidTask):
+			return nil, false, err
+		case err != nil:
+			return nil, false, ErrDBInternal
+		}
+		return t, false, nil
+	}
+
+	if todo.UID+".ics" != name {
+		return nil, false, fmt.Errorf("%w: the resource name must be the UID followed by .ics", lib.ErrInvalidICal)
+	}
+	t := &db.Task{
+		ID:        uuid.New(),
+		User:      username,
+		Project:   project,
+		ICalUID:   todo.UID,
+		CreatedAt: todo.Task.CreatedAt,
+	}
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = now
+	}
+	mergeICalTodo(t, &todo.Task, now)
+	if err := Validate(t, ErrInvalidTask); err != nil {
+		return nil, false, err
+	}
+	if err := s.createTasks(ctx, username, t); err != nil {
+		return nil, false, ErrDBInternal
+	}
+	return t, true, nil
+}
+
+// DeleteCalendarTask deletes the task behind the resource name of the
+// project calendar.
+func (s *task) DeleteCalendarTask(ctx context.Context, username string, project string, name string, ifMatch string) error {
+	t, err := s.GetCalendarTask(ctx, username, project, name)
+	if err != nil {
+		return err
+	}
+	if err := checkETag(t, ifMatch, ""); err != nil {
+		return err
+	}
+	_, err = s.DeleteTask(ctx, username, t.ID)
+	return err
+}
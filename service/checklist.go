@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"tasks/db"
+
+	"github.com/google/uuid"
+)
+
+func checklistError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, db.ErrNoRows):
+		return ErrNotFound
+	case errors.Is(err, db.ErrChecklistItemNotFound):
+		return ErrItemNotFound
+	case errors.Is(err, db.ErrInvalidPosition):
+		return ErrInvalidPosition
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrInvalidTask):
+		return err
+	default:
+		return ErrDBInternal
+	}
+}
+
+func (s *task) AddChecklistItem(ctx context.Context, username string, taskID uuid.UUID, text string, position int) (*db.Task, error) {
+	now := time.Now()
+	t, err := s.updateTask(ctx, taskID, AuditTaskUpdate, func(t *db.Task) error {
+		if t.User != username {
+			return ErrNotFound
+		}
+		t.UpdatedAt = now
+		return t.AddChecklistItem(db.ChecklistItem{
+			ID:        uuid.New(),
+			Text:      text,
+			CreatedAt: now,
+		}, position)
+	})
+	return t, checklistError(err)
+}
+
+func (s *task) MoveChecklistItem(ctx context.Context, username string, taskID uuid.UUID, itemID uuid.UUID, position int) (*db.Task, error) {
+	t, err := s.updateTask(ctx, taskID, AuditTaskUpdate, func(t *db.Task) error {
+		if t.User != username {
+			return ErrNotFound
+		}
+		t.UpdatedAt = time.Now()
+		return t.MoveChecklistItem(itemID, position)
+	})
+	return t, checklistError(err)
+}
+
+func (s *task) SetChecklistItemDone(ctx context.Context, username string, taskID uuid.UUID, itemID uuid.UUID, done bool) (*db.Task, error) {
+	t, err := s.updateTask(ctx, taskID, AuditTaskUpdate, func(t *db.Task) error {
+		if t.User != username {
+			return ErrNotFound
+		}
+		t.UpdatedAt = time.Now()
+		return t.SetChecklistItemDone(itemID, done)
+	})
+	return t, checklistError(err)
+}
+
+func (s *task) DeleteChecklistItem(ctx context.Context, username string, taskID uuid.UUID, itemID uuid.UUID) (*db.Task, error) {
+	t, err := s.updateTask(ctx, taskID, AuditTaskUpdate, func(t *db.Task) error {
+		if t.User != username {
+			return ErrNotFound
+		}
+		t.UpdatedAt = time.Now()
+		_, err := t.RemoveChecklistItem(itemID)
+		return err
+	})
+	return t, checklistError(err)
+}
+
+// ConvertChecklistItem turns a checklist item into a standalone task owned by
+// the same user and removes it from the checklist of its parent.
+func (s *task) ConvertChecklistItem(ctx context.Context, username string, taskID uuid.UUID, itemID uuid.UUID) (*db.Task, error) {
+	var t *db.Task
+	err := s.db.InTransaction(username, func(tx *db.TaskTx) error {
+		var before *db.TaskSummary
+		parent, newTask, err := tx.ConvertChecklistItem(taskID, itemID, func(parent *db.Task, item db.ChecklistItem) (*db.Task, error) {
+			before = parent.Summary()
+			now := time.Now()
+			newTask := &db.Task{
+				ID:        uuid.New(),
+				Title:     item.Text,
+				User:      parent.User,
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+			if err := Validate(newTask, ErrInvalidTask); err != nil {
+				return nil, err
+			}
+			return newTask, nil
+		})
+		if err != nil {
+			return err
+		}
+		t = newTask
+		if err := auditTx(ctx, tx, parent.User, AuditTaskUpdate, taskID.String(), before, parent.Summary()); err != nil {
+			return err
+		}
+		return auditCreatedTx(ctx, tx, t)
+	})
+	if err != nil {
+		return nil, checklistError(err)
+	}
+	return t, nil
+}
@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"tasks/db"
+	"tasks/lib"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+// csvPreviewLimit bounds the number of tasks returned by a dry run.
+const csvPreviewLimit = 50
+
+var (
+	ErrInvalidCSV = lib.ErrInvalidCSV
+	errCSVDryRun  = errors.New("dry run")
+)
+
+// CSVImportResult reports what a CSV import did, or would do for a dry run.
+// Errors lists the problems of the rows that were not imported, Preview the
+// first tasks a dry run would create or update.
+type CSVImportResult struct {
+	DryRun  bool              `json:"dryRun"`
+	Columns []string          `json:"columns"`
+	Ignored []string          `json:"ignored"`
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Failed  int               `json:"failed"`
+	Errors  []lib.CSVRowError `json:"errors"`
+	Preview []db.Task         `json:"preview,omitempty"`
+}
+
+// mergeCSVRow copies the columns read from a CSV row onto the task. An empty
+// status leaves the status unchanged.
+func mergeCSVRow(t *db.Task, from *db.Task, columns []string, now time.Time) {
+	completed := false
+	for _, column := range columns {
+		switch column {
+		case "title":
+			t.Title = from.Title
+		case "text":
+			t.Text = from.Text
+		case "project":
+			t.Project = from.Project
+		case "status":
+			if from.Status != "" {
+				t.SetStatus(from.Status, now)
+			}
+		case "priority":
+			t.Priority = from.Priority
+		case "tags":
+			t.Tags = from.Tags
+		case "due":
+			t.Due = from.Due
+		case "recurrence":
+			t.Recurrence = from.Recurrence
+		case "deferUntil":
+			t.DeferUntil = from.DeferUntil
+		case "someday":
+			t.Someday = from.Someday
+		case "createdAt":
+			if !from.CreatedAt.IsZero() {
+				t.CreatedAt = from.CreatedAt
+			}
+		case "completedAt":
+			completed = from.CompletedAt != nil
+		}
+	}
+	if completed && t.Status == db.StatusDone {
+		t.CompletedAt = from.CompletedAt
+	}
+	t.UpdatedAt = now
+}
+
+// validationProblems describes the rules of db.Task that a row breaks.
+func validationProblems(line int, err error) []lib.CSVRowError {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return []lib.CSVRowError{{Line: line, Reason: err.Error()}}
+	}
+	problems := make([]lib.CSVRowError, 0, len(errs))
+	for _, fe := range errs {
+		column := fe.Field()
+		if i := strings.IndexByte(column, '['); i >= 0 {
+			column = column[:i]
+		}
+		rule := fe.Tag()
+		if fe.Param() != "" {
+			rule += "=" + fe.Param()
+		}
+		problems = append(problems, lib.CSVRowError{
+			Line:   line,
+			Column: strings.ToLower(column[:1]) + column[1:],
+			Value:  fmt.Sprint(fe.Value()),
+			Reason: fmt.Sprintf("fails the %s rule", rule),
+		})
+	}
+	return problems
+}
+
+// setCSVFields converts the raw custom field values of a row to the types of
+// the definitions and stores them on the task. An empty value clears a field.
+func setCSVFields(t *db.Task, line int, raw map[string]string, defs map[string]*db.FieldDefinition) []lib.CSVRowError {
+	keys := make([]string, 0, len(raw))
+	for key := range raw {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var problems []lib.CSVRowError
+	values := make(map[string]interface{}, len(raw))
+	for _, key := range keys {
+		if raw[key] == "" {
+			values[key] = nil
+			continue
+		}
+		problem := lib.CSVRowError{Line: line, Column: lib.CSVFieldPrefix + key, Value: raw[key]}
+		def, ok := defs[key]
+		if !ok {
+			problem.Reason = fmt.Sprintf("not a field of project %q", t.Project)
+			problems = append(problems, problem)
+			continue
+		}
+		v, err := convertFieldValue(def, raw[key])
+		if err != nil {
+			problem.Reason = fmt.Sprintf("expects a %s value", def.Type)
+			problems = append(problems, problem)
+			continue
+		}
+		values[key] = v
+	}
+	if len(problems) == 0 {
+		if err := setFieldValues(t, defs, values); err != nil {
+			problems = append(problems, lib.CSVRowError{Line: line, Reason: err.Error()})
+		}
+	}
+	return problems
+}
+
+// ImportCSV creates tasks from the rows of a CSV stream, mapping its header
+// with lib.NewCSVReader. A row whose id is a task of the user updates the
+// columns present in the file; other rows create tasks. Every row is checked
+// against the validation rules of db.Task, and rows with problems are left
+// out and reported. With dryRun set nothing is stored.
+func (s *task) ImportCSV(ctx context.Context, username string, r io.Reader, mapping map[string]string, dryRun bool) (*CSVImportResult, error) {
+	reader, err := lib.NewCSVReader(r, mapping)
+	if err != nil {
+		return nil, err
+	}
+	var rows []*lib.CSVRow
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+
+	result := &CSVImportResult{DryRun: dryRun, Columns: reader.Columns, Ignored: reader.Ignored, Errors: []lib.CSVRowError{}}
+	validate := validator.New()
+	now := time.Now()
+	var created []*db.Task
+	var befores, afters []*db.TaskSummary
+	var updated []uuid.UUID
+
+	err = s.db.InTransaction(username, func(tx *db.TaskTx) error {
+		defs := map[string]map[string]*db.FieldDefinition{}
+		seen := map[uuid.UUID]int{}
+		for _, row := range rows {
+			problems := row.Problems
+			var existing *db.Task
+			if id := row.Task.ID; id != uuid.Nil {
+				if line, ok := seen[id]; ok {
+					problems = append(problems, lib.CSVRowError{Line: row.Line, Column: "id", Value: id.String(), Reason: fmt.Sprintf("same id as line %d", line)})
+				}
+				seen[id] = row.Line
+				t, err := tx.GetTask(id)
+				switch {
+				case errors.Is(err, db.ErrNoRows):
+				case err != nil:
+					return err
+				default:
+					existing = t
+				}
+			}
+			if len(problems) > 0 {
+				result.Failed++
+				result.Errors = append(result.Errors, problems...)
+				continue
+			}
+
+			t := &db.Task{ID: uuid.New(), User: username, Status: db.StatusTodo, CreatedAt: now}
+			if existing != nil {
+				t = existing
+			}
+			before := existing.Summary()
+			mergeCSVRow(t, &row.Task, reader.Columns, now)
+			if len(row.Fields) > 0 {
+				if _, ok := defs[t.Project]; !ok {
+					list, err := tx.GetFieldDefinitions(t.Project)
+					if err != nil {
+						return err
+					}
+					defs[t.Project] = fieldDefinitionsByKey(list)
+				}
+				problems = setCSVFields(t, row.Line, row.Fields, defs[t.Project])
+			}
+			if err := validate.Struct(t); err != nil {
+				problems = append(problems, validationProblems(row.Line, err)...)
+			}
+			if len(problems) > 0 {
+				result.Failed++
+				result.Errors = append(result.Errors, problems...)
+				continue
+			}
+
+			if existing != nil {
+				stored, err := tx.UpdateTask(t.ID, func(stored *db.Task) error {
+					*stored = *t
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				befores, afters = append(befores, before), append(afters, stored.Summary())
+				updated = append(updated, t.ID)
+				t = stored
+			} else {
+				if err := tx.CreateTask(t); err != nil {
+					return err
+				}
+				created = append(created, t)
+			}
+			if dryRun && len(result.Preview) < csvPreviewLimit {
+				result.Preview = append(result.Preview, *t)
+			}
+		}
+		if dryRun {
+			return errCSVDryRun
+		}
+		return auditImportTx(ctx, tx, username, created, updated, befores, afters)
+	})
+	if err != nil && !errors.Is(err, errCSVDryRun) {
+		return nil, ErrDBInternal
+	}
+
+	result.Created, result.Updated = len(created), len(updated)
+	return result, nil
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"tasks/lib"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Kind tells what went wrong in terms a client can act on. Handlers derive
+// the status of a response from it.
+type Kind string
+
+const (
+	KindInvalid            Kind = "invalid"
+	KindUnauthorized       Kind = "unauthorized"
+	KindNotFound           Kind = "not-found"
+	KindConflict           Kind = "conflict"
+	KindPreconditionFailed Kind = "precondition-failed"
+	KindUnsupported        Kind = "unsupported-media-type"
+	KindInternal           Kind = "internal"
+)
+
+// Kinds lists every kind of error, in order of status.
+var Kinds = []Kind{KindInvalid, KindUnauthorized, KindNotFound, KindConflict, KindPreconditionFailed, KindUnsupported, KindInternal}
+
+// Error is a sentinel error of the service. Errors are matched with
+// errors.Is, also once wrapped with details, and classified by KindOf. An
+// error refining a more general one matches it too.
+type Error struct {
+	kind   Kind
+	msg    string
+	parent *Error
+}
+
+func newError(kind Kind, msg string) *Error {
+	return &Error{kind: kind, msg: msg}
+}
+
+// refine returns an error of the same kind that also matches e.
+func (e *Error) refine(msg string) *Error {
+	return &Error{kind: e.kind, msg: msg, parent: e}
+}
+
+func (e *Error) Error() string {
+	return e.msg
+}
+
+func (e *Error) Kind() Kind {
+	return e.kind
+}
+
+func (e *Error) Unwrap() error {
+	if e.parent == nil {
+		return nil
+	}
+	return e.parent
+}
+
+var (
+	ErrMalformedRequest = newError(KindInvalid, "request body is malformed")
+	ErrInvalidRequest   = newError(KindInvalid, "request does not satisfy the validation rules")
+	ErrInvalidParameter = newError(KindInvalid, "request parameter is invalid")
+)
+
+// invalidInput are the errors the parsers of lib report malformed input
+// with.
+var invalidInput = []error{
+	lib.ErrInvalidCSV, lib.ErrInvalidFilter, lib.ErrInvalidICal, lib.ErrInvalidImport, lib.ErrInvalidMarkdown, lib.ErrInvalidTodoTxt,
+	lib.ErrInvalidGraphQL, lib.ErrGraphQLRequest, lib.ErrGraphQLTooDeep, lib.ErrGraphQLTooComplex,
+}
+
+// KindOf returns the kind of the error. Errors that are not errors of the
+// service or of malformed input are internal.
+func KindOf(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.kind
+	}
+	for _, invalid := range invalidInput {
+		if errors.Is(err, invalid) {
+			return KindInvalid
+		}
+	}
+	return KindInternal
+}
+
+// ValidationError lists the fields of a value that break its validation
+// rules.
+type ValidationError struct {
+	Err    *Error
+	Fields []lib.FieldError
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		fields = append(fields, f.Field+" "+f.Message)
+	}
+	return fmt.Sprintf("%v: %s", e.Err, strings.Join(fields, ", "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// structValidator names fields after their JSON names.
+var structValidator = func() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return f.Name
+		}
+		return name
+	})
+	return v
+}()
+
+// Validate checks v against its validate tags. Broken rules are reported as
+// a ValidationError of err.
+func Validate(v interface{}, err *Error) error {
+	verr := structValidator.Struct(v)
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(verr, &fieldErrs) {
+		if verr != nil {
+			return fmt.Errorf("%w: %v", err, verr)
+		}
+		return nil
+	}
+
+	fields := make([]lib.FieldError, 0, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		// The namespace starts with the name of the validated type.
+		_, field, _ := strings.Cut(fe.Namespace(), ".")
+		fields = append(fields, lib.FieldError{Field: field, Rule: fe.Tag(), Param: fe.Param(), Message: fieldMessage(fe)})
+	}
+	return &ValidationError{Err: err, Fields: fields}
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	unit := "characters"
+	switch fe.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = "items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64:
+		unit = ""
+	}
+	switch fe.Tag() {
+	case "required", "required_if":
+		return "is required"
+	case "min", "gte":
+		return strings.TrimSpace("must be at least " + fe.Param() + " " + unit)
+	case "max", "lte":
+		return strings.TrimSpace("must be at most " + fe.Param() + " " + unit)
+	case "len":
+		return strings.TrimSpace("must be exactly " + fe.Param() + " " + unit)
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case "email":
+		return "must be an email address"
+	case "alphanum":
+		return "must contain only letters and digits"
+	case "excludesall":
+		return fmt.Sprintf("must not contain any of %q", fe.Param())
+	}
+	return "must satisfy " + fe.Tag()
+}
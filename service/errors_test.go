@@ -0,0 +1,42 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"tasks/db"
+	"tasks/lib"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrors(t *testing.T) {
+	t.Run("kinds", func(t *testing.T) {
+		require.Equal(t, KindNotFound, KindOf(ErrNotFound))
+		require.Equal(t, KindNotFound, KindOf(fmt.Errorf("task 1: %w", ErrFieldNotFound)))
+		require.Equal(t, KindConflict, KindOf(ErrTimerRunning))
+		require.Equal(t, KindInvalid, KindOf(fmt.Errorf("%w: line 3", lib.ErrInvalidCSV)))
+		require.Equal(t, KindInternal, KindOf(ErrDBInternal))
+		require.Equal(t, KindInternal, KindOf(errors.New("disk is full")))
+	})
+
+	t.Run("refined errors match the general one", func(t *testing.T) {
+		require.ErrorIs(t, ErrUserNotFound, ErrNotFound)
+		require.Equal(t, KindNotFound, KindOf(ErrUserNotFound))
+		require.NotErrorIs(t, ErrNotFound, ErrUserNotFound)
+		require.ErrorIs(t, ErrUserAlreadyExists, ErrAlreadyExists)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		err := Validate(&db.Task{Title: "Pack bag"}, ErrInvalidTask)
+		var verr *ValidationError
+		require.ErrorAs(t, err, &verr)
+		require.ErrorIs(t, err, ErrInvalidTask)
+		require.Equal(t, KindInvalid, KindOf(err))
+		require.Equal(t, []lib.FieldError{{Field: "user", Rule: "required", Message: "is required"}}, verr.Fields)
+		require.EqualError(t, err, "task does not satisfy the validation rules: user is required")
+
+		require.NoError(t, Validate(&db.Task{Title: "Pack bag", User: "alice"}, ErrInvalidTask))
+	})
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"tasks/db"
+	"tasks/lib"
+)
+
+const (
+	AuditFeedCreate = "feed.create"
+	AuditFeedDelete = "feed.delete"
+)
+
+var ErrFeedNotFound = newError(KindNotFound, "requested feed is not found")
+
+// FeedQuery restricts a calendar feed to the tasks of any of Projects and
+// with any of Tags. Empty lists do not restrict the feed.
+type FeedQuery struct {
+	Projects []string
+	Tags     []string
+}
+
+func (q *FeedQuery) match(t *db.Task) bool {
+	if len(q.Projects) > 0 {
+		found := false
+		for _, project := range q.Projects {
+			found = found || t.Project == project
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(q.Tags) > 0 {
+		found := false
+		for _, tag := range q.Tags {
+			found = found || t.HasTag(tag)
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// CreateFeedToken generates the secret token of the calendar feed of the
+// user, replacing the previous one. The token is returned only here, as
+// just its hash is stored.
+func (s *task) CreateFeedToken(ctx context.Context, username string) (string, *db.FeedToken, error) {
+	secret, err := lib.NewSecretToken()
+	if err != nil {
+		return "", nil, ErrDBInternal
+	}
+	token := &db.FeedToken{User: username, Hash: lib.HashToken(secret), CreatedAt: time.Now()}
+	if err := s.db.PutFeedToken(token, auditEvent(ctx, username, AuditFeedCreate, username, nil, nil)); err != nil {
+		return "", nil, ErrDBInternal
+	}
+	return secret, token, nil
+}
+
+func feedError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, db.ErrFeedNotFound):
+		return ErrFeedNotFound
+	default:
+		return ErrDBInternal
+	}
+}
+
+func (s *task) GetFeedToken(ctx context.Context, username string) (*db.FeedToken, error) {
+	token, err := s.db.GetFeedToken(username)
+	if err != nil {
+		return nil, feedError(err)
+	}
+	return token, nil
+}
+
+func (s *task) DeleteFeedToken(ctx context.Context, username string) error {
+	return feedError(s.db.DeleteFeedToken(username, auditEvent(ctx, username, AuditFeedDelete, username, nil, nil)))
+}
+
+// GetFeedTasks returns the tasks with a due date of the user holding the
+// feed token that match the query, ordered by due date, and the time of the
+// last change of the tasks of the user, which also advances when a task is
+// deleted.
+func (s *task) GetFeedTasks(ctx context.Context, secret string, q FeedQuery) ([]db.Task, time.Time, error) {
+	token, err := s.db.GetFeedTokenByHash(lib.HashToken(secret))
+	if err != nil {
+		return nil, time.Time{}, feedError(err)
+	}
+	modified, err := s.db.GetTasksModified(token.User)
+	if err != nil {
+		return nil, time.Time{}, ErrDBInternal
+	}
+	all, err := s.db.GetAllTasksFromUser(ctx, token.User)
+	if err != nil {
+		return nil, time.Time{}, ErrDBInternal
+	}
+	tasks := []db.Task{}
+	for i := range all {
+		if all[i].UpdatedAt.After(modified) {
+			// Tasks written before the time was recorded.
+			modified = all[i].UpdatedAt
+		}
+		if all[i].Due != nil && q.match(&all[i]) {
+			tasks = append(tasks, all[i])
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if !tasks[i].Due.Equal(*tasks[j].Due) {
+			return tasks[i].Due.Before(*tasks[j].Due)
+		}
+		return tasks[i].ID.String() < tasks[j].ID.String()
+	})
+	return tasks, modified, nil
+}
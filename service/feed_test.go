@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"tasks/db"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeed(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	due := func(days int) *time.Time {
+		t := time.Date(2023, 4, days, 9, 0, 0, 0, time.UTC)
+		return &t
+	}
+	for _, task := range []db.Task{
+		{Title: "Pay rent", Project: "home", Due: due(3)},
+		{Title: "Book flights", Project: "trip", Tags: []string{"Travel"}, Due: due(1)},
+		{Title: "Water plants", Project: "home"},
+	} {
+		task.User = "alice"
+		_, err := s.CreateTask(ctx, &task)
+		require.NoError(t, err)
+	}
+
+	_, err := s.GetFeedToken(ctx, "alice")
+	require.ErrorIs(t, err, ErrFeedNotFound)
+	secret, token, err := s.CreateFeedToken(ctx, "alice")
+	require.NoError(t, err)
+	require.NotContains(t, token.Hash, secret)
+
+	titles := func(q FeedQuery) []string {
+		tasks, _, err := s.GetFeedTasks(ctx, secret, q)
+		require.NoError(t, err)
+		titles := []string{}
+		for _, task := range tasks {
+			titles = append(titles, task.Title)
+		}
+		return titles
+	}
+	require.Equal(t, []string{"Book flights", "Pay rent"}, titles(FeedQuery{}))
+	require.Equal(t, []string{"Pay rent"}, titles(FeedQuery{Projects: []string{"home"}}))
+	require.Equal(t, []string{"Book flights"}, titles(FeedQuery{Tags: []string{"travel"}}))
+	require.Empty(t, titles(FeedQuery{Projects: []string{"home"}, Tags: []string{"travel"}}))
+
+	renewed, _, err := s.CreateFeedToken(ctx, "alice")
+	require.NoError(t, err)
+	_, _, err = s.GetFeedTasks(ctx, secret, FeedQuery{})
+	require.ErrorIs(t, err, ErrFeedNotFound)
+	require.NoError(t, s.DeleteFeedToken(ctx, "alice"))
+	_, _, err = s.GetFeedTasks(ctx, renewed, FeedQuery{})
+	require.ErrorIs(t, err, ErrFeedNotFound)
+	require.ErrorIs(t, s.DeleteFeedToken(ctx, "alice"), ErrFeedNotFound)
+}
@@ -0,0 +1,302 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"tasks/db"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrFieldNotFound       = newError(KindNotFound, "requested custom field is not found")
+	ErrFieldExists         = newError(KindConflict, "custom field already exists")
+	ErrInvalidField        = newError(KindInvalid, "custom field definition is invalid")
+	ErrInvalidFieldValue   = newError(KindInvalid, "custom field value is invalid")
+	ErrFieldInUse          = newError(KindConflict, "custom field has values that would be lost")
+	fieldKeyRe             = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	fieldDateLayout        = "2006-01-02"
+	errFieldNotConvertible = errors.New("value cannot be converted")
+)
+
+func fieldError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, db.ErrFieldNotFound):
+		return ErrFieldNotFound
+	case errors.Is(err, db.ErrFieldExists):
+		return ErrFieldExists
+	case errors.Is(err, db.ErrNoRows):
+		return ErrNotFound
+	case errors.Is(err, db.ErrFieldValuesLost):
+		return ErrFieldInUse
+	case errors.Is(err, ErrInvalidFieldValue):
+		return err
+	default:
+		return ErrDBInternal
+	}
+}
+
+func checkFieldDefinition(def *db.FieldDefinition) error {
+	if !fieldKeyRe.MatchString(def.Key) {
+		return fmt.Errorf("%w: key must start with a letter and contain only a-z, 0-9 and _", ErrInvalidField)
+	}
+	if def.Type != db.FieldSelect && len(def.Options) > 0 {
+		return fmt.Errorf("%w: only select fields have options", ErrInvalidField)
+	}
+	seen := map[string]bool{}
+	for _, option := range def.Options {
+		if seen[strings.ToLower(option)] {
+			return fmt.Errorf("%w: duplicate option %q", ErrInvalidField, option)
+		}
+		seen[strings.ToLower(option)] = true
+	}
+	return nil
+}
+
+// normalizeFieldValue checks a decoded JSON value against the definition and
+// returns its stored form: a string for text, select and date (YYYY-MM-DD)
+// fields, a float64 for numbers and a bool for checkboxes.
+func normalizeFieldValue(def *db.FieldDefinition, v interface{}) (interface{}, error) {
+	invalid := func() error {
+		return fmt.Errorf("%w: %s expects a %s value", ErrInvalidFieldValue, def.Key, def.Type)
+	}
+	switch def.Type {
+	case db.FieldText:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case db.FieldNumber:
+		if n, ok := v.(float64); ok {
+			return n, nil
+		}
+	case db.FieldCheckbox:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case db.FieldDate:
+		s, ok := v.(string)
+		if !ok {
+			return nil, invalid()
+		}
+		if d, err := time.Parse(fieldDateLayout, s); err == nil {
+			return d.Format(fieldDateLayout), nil
+		}
+		if d, err := time.Parse(time.RFC3339, s); err == nil {
+			return d.Format(fieldDateLayout), nil
+		}
+	case db.FieldSelect:
+		s, ok := v.(string)
+		if !ok {
+			return nil, invalid()
+		}
+		for _, option := range def.Options {
+			if strings.EqualFold(option, s) {
+				return option, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %s must be one of %s", ErrInvalidFieldValue, def.Key, strings.Join(def.Options, ", "))
+	}
+	return nil, invalid()
+}
+
+// convertFieldValue converts a stored value to the type of a changed
+// definition. Every value converts to text, and text converts to the other
+// types when it parses as one.
+func convertFieldValue(def *db.FieldDefinition, v interface{}) (interface{}, error) {
+	if converted, err := normalizeFieldValue(def, v); err == nil {
+		return converted, nil
+	}
+	if def.Type == db.FieldText {
+		switch value := v.(type) {
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64), nil
+		default:
+			return fmt.Sprint(value), nil
+		}
+	}
+	if s, ok := v.(string); ok {
+		switch def.Type {
+		case db.FieldNumber:
+			if n, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+				return n, nil
+			}
+		case db.FieldCheckbox:
+			if b, err := strconv.ParseBool(strings.TrimSpace(s)); err == nil {
+				return b, nil
+			}
+		}
+	}
+	return nil, errFieldNotConvertible
+}
+
+func (s *task) fieldDefinitions(username string, project string) (map[string]*db.FieldDefinition, error) {
+	defs, err := s.db.GetFieldDefinitions(username, project)
+	if err != nil {
+		return nil, err
+	}
+	return fieldDefinitionsByKey(defs), nil
+}
+
+func fieldDefinitionsByKey(defs []db.FieldDefinition) map[string]*db.FieldDefinition {
+	byKey := make(map[string]*db.FieldDefinition, len(defs))
+	for i := range defs {
+		byKey[defs[i].Key] = &defs[i]
+	}
+	return byKey
+}
+
+// setFieldValues validates the values against the definitions and merges
+// them into the fields of the task. A nil value removes the field.
+func setFieldValues(t *db.Task, defs map[string]*db.FieldDefinition, values map[string]interface{}) error {
+	for key, v := range values {
+		if v == nil {
+			delete(t.Fields, key)
+			continue
+		}
+		def, ok := defs[key]
+		if !ok {
+			return fmt.Errorf("%w: %s is not a field of project %q", ErrInvalidFieldValue, key, t.Project)
+		}
+		value, err := normalizeFieldValue(def, v)
+		if err != nil {
+			return err
+		}
+		if t.Fields == nil {
+			t.Fields = map[string]interface{}{}
+		}
+		t.Fields[key] = value
+	}
+	if len(t.Fields) == 0 {
+		t.Fields = nil
+	}
+	return nil
+}
+
+func (s *task) CreateFieldDefinition(ctx context.Context, username string, project string, args *db.FieldDefinition) (*db.FieldDefinition, error) {
+	if err := checkFieldDefinition(args); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	def := &db.FieldDefinition{
+		User:      username,
+		Project:   project,
+		Key:       args.Key,
+		Name:      args.Name,
+		Type:      args.Type,
+		Options:   args.Options,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.db.CreateFieldDefinition(def); err != nil {
+		return nil, fieldError(err)
+	}
+	return def, nil
+}
+
+func (s *task) GetFieldDefinitions(ctx context.Context, username string, project string) ([]db.FieldDefinition, error) {
+	defs, err := s.db.GetFieldDefinitions(username, project)
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+	return defs, nil
+}
+
+// GetAllFieldDefinitions returns the field definitions of every project of
+// the user.
+func (s *task) GetAllFieldDefinitions(ctx context.Context, username string) ([]db.FieldDefinition, error) {
+	defs, err := s.db.GetAllFieldDefinitions(username)
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+	return defs, nil
+}
+
+// UpdateFieldDefinition changes the name, type or options of a field and
+// converts the stored values. Values that cannot be converted are removed,
+// which is refused with ErrFieldInUse unless force is set. The number of
+// removed values is returned.
+func (s *task) UpdateFieldDefinition(ctx context.Context, username string, project string, key string, args *db.FieldDefinition, force bool) (*db.FieldDefinition, int, error) {
+	args.Key = key
+	if err := checkFieldDefinition(args); err != nil {
+		return nil, 0, err
+	}
+	defs, err := s.fieldDefinitions(username, project)
+	if err != nil {
+		return nil, 0, ErrDBInternal
+	}
+	def, ok := defs[key]
+	if !ok {
+		return nil, 0, ErrFieldNotFound
+	}
+	def.Name = args.Name
+	def.Type = args.Type
+	def.Options = args.Options
+	def.UpdatedAt = time.Now()
+
+	lost, err := s.db.ReplaceFieldDefinition(username, project, key, def, force, s.migrateField(ctx, username, def.UpdatedAt, func(t *db.Task) {
+		converted, err := convertFieldValue(def, t.Fields[key])
+		if err != nil {
+			delete(t.Fields, key)
+		} else {
+			t.Fields[key] = converted
+		}
+	}))
+	if err != nil {
+		return nil, lost, fieldError(err)
+	}
+	return def, lost, nil
+}
+
+// DeleteFieldDefinition removes a field and its values from the tasks of the
+// project. If any task has a value, force must be set. The number of removed
+// values is returned.
+func (s *task) DeleteFieldDefinition(ctx context.Context, username string, project string, key string, force bool) (int, error) {
+	lost, err := s.db.ReplaceFieldDefinition(username, project, key, nil, force, s.migrateField(ctx, username, time.Now(), func(t *db.Task) {
+		delete(t.Fields, key)
+	}))
+	if err != nil {
+		return lost, fieldError(err)
+	}
+	return lost, nil
+}
+
+// migrateField returns the migration of a field definition change that
+// applies fn to the values of a task, marks the task updated at now and
+// audits it.
+func (s *task) migrateField(ctx context.Context, username string, now time.Time, fn func(t *db.Task)) func(tx *db.TaskTx, t *db.Task) error {
+	return func(tx *db.TaskTx, t *db.Task) error {
+		before := t.Summary()
+		fn(t)
+		if len(t.Fields) == 0 {
+			t.Fields = nil
+		}
+		t.UpdatedAt = now
+		return auditTx(ctx, tx, username, AuditTaskUpdate, t.ID.String(), before, t.Summary())
+	}
+}
+
+// SetTaskFields merges values into the custom fields of the task, validated
+// against the definitions of its project. A nil value clears a field.
+func (s *task) SetTaskFields(ctx context.Context, username string, taskID uuid.UUID, values map[string]interface{}) (*db.Task, error) {
+	current, err := s.db.GetTask(taskID.String())
+	if err != nil || current.User != username {
+		return nil, ErrNotFound
+	}
+	defs, err := s.fieldDefinitions(username, current.Project)
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+	t, err := s.updateTask(ctx, taskID, AuditTaskUpdate, func(t *db.Task) error {
+		t.UpdatedAt = time.Now()
+		return setFieldValues(t, defs, values)
+	})
+	return t, fieldError(err)
+}
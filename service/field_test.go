@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"tasks/db"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldValues(t *testing.T) {
+	size := &db.FieldDefinition{Key: "size", Type: db.FieldSelect, Options: []string{"S", "M", "L"}}
+	defs := map[string]*db.FieldDefinition{
+		"points": {Key: "points", Type: db.FieldNumber},
+		"due":    {Key: "due", Type: db.FieldDate},
+		"size":   size,
+	}
+
+	t.Run("normalize", func(t *testing.T) {
+		task := &db.Task{}
+		require.NoError(t, setFieldValues(task, defs, map[string]interface{}{
+			"points": 3.0, "due": "2023-04-05T10:00:00Z", "size": "m",
+		}))
+		require.Equal(t, map[string]interface{}{"points": 3.0, "due": "2023-04-05", "size": "M"}, task.Fields)
+
+		require.NoError(t, setFieldValues(task, defs, map[string]interface{}{"points": nil, "due": nil, "size": nil}))
+		require.Nil(t, task.Fields)
+
+		for _, values := range []map[string]interface{}{
+			{"points": "3"}, {"due": "tomorrow"}, {"size": "XL"}, {"other": "x"},
+		} {
+			require.ErrorIs(t, setFieldValues(task, defs, values), ErrInvalidFieldValue, values)
+		}
+	})
+
+	t.Run("convert", func(t *testing.T) {
+		text := &db.FieldDefinition{Type: db.FieldText}
+		number := &db.FieldDefinition{Type: db.FieldNumber}
+		checkbox := &db.FieldDefinition{Type: db.FieldCheckbox}
+
+		v, err := convertFieldValue(text, 2.5)
+		require.NoError(t, err)
+		require.Equal(t, "2.5", v)
+		v, err = convertFieldValue(number, " 8 ")
+		require.NoError(t, err)
+		require.Equal(t, 8.0, v)
+		v, err = convertFieldValue(checkbox, "true")
+		require.NoError(t, err)
+		require.Equal(t, true, v)
+		_, err = convertFieldValue(number, "many")
+		require.Error(t, err)
+		_, err = convertFieldValue(size, true)
+		require.Error(t, err)
+	})
+}
+
+func TestMigrateFieldValues(t *testing.T) {
+	s, d := newTestService(t)
+	ctx := context.Background()
+	_, err := s.CreateFieldDefinition(ctx, "alice", "home", &db.FieldDefinition{Key: "points", Name: "Points", Type: db.FieldText})
+	require.NoError(t, err)
+	id, err := s.CreateTask(ctx, &db.Task{Title: "Paint fence", User: "alice", Project: "home", Fields: map[string]interface{}{"points": "3"}})
+	require.NoError(t, err)
+	created, err := d.GetTask(id.String())
+	require.NoError(t, err)
+	start := time.Now()
+
+	_, lost, err := s.UpdateFieldDefinition(ctx, "alice", "home", "points", &db.FieldDefinition{Name: "Points", Type: db.FieldNumber}, false)
+	require.NoError(t, err)
+	require.Zero(t, lost)
+	lost, err = s.DeleteFieldDefinition(ctx, "alice", "home", "points", true)
+	require.NoError(t, err)
+	require.Equal(t, 1, lost)
+
+	task, err := d.GetTask(id.String())
+	require.NoError(t, err)
+	require.Nil(t, task.Fields)
+	require.True(t, task.UpdatedAt.After(created.UpdatedAt))
+	events, err := s.GetAuditEvents(ctx, "alice", start, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	for _, e := range events {
+		require.Equal(t, AuditTaskUpdate, e.Action)
+		require.Equal(t, id.String(), e.Target)
+		require.NotNil(t, e.Before)
+		require.NotNil(t, e.After)
+	}
+}
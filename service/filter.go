@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"tasks/db"
+	"tasks/lib"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrFilterNotFound = newError(KindNotFound, "requested filter is not found")
+	ErrInvalidFilter  = lib.ErrInvalidFilter
+)
+
+func filterError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, db.ErrFilterNotFound):
+		return ErrFilterNotFound
+	default:
+		return ErrDBInternal
+	}
+}
+
+// compileFilter parses the query and sort specification. Syntax errors wrap
+// ErrInvalidFilter and carry the position of the problem.
+func compileFilter(query string, sortSpec string) (*lib.Filter, lib.TaskOrder, error) {
+	filter, err := lib.ParseFilter(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	order, err := lib.ParseTaskOrder(sortSpec)
+	if err != nil {
+		return nil, nil, err
+	}
+	return filter, order, nil
+}
+
+func (s *task) CreateFilter(ctx context.Context, username string, args *db.SavedFilter) (*db.SavedFilter, error) {
+	if _, _, err := compileFilter(args.Query, args.Sort); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	filter := &db.SavedFilter{
+		ID:        uuid.New(),
+		User:      username,
+		Name:      args.Name,
+		Query:     args.Query,
+		Sort:      args.Sort,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.db.PutFilter(filter); err != nil {
+		return nil, ErrDBInternal
+	}
+	return filter, nil
+}
+
+func (s *task) GetFilters(ctx context.Context, username string) ([]db.SavedFilter, error) {
+	filters, err := s.db.GetFilters(username)
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+	sort.Slice(filters, func(i, j int) bool { return filters[i].Name < filters[j].Name })
+	return filters, nil
+}
+
+func (s *task) GetFilter(ctx context.Context, username string, id uuid.UUID) (*db.SavedFilter, error) {
+	filter, err := s.db.GetFilter(username, id)
+	return filter, filterError(err)
+}
+
+func (s *task) UpdateFilter(ctx context.Context, username string, id uuid.UUID, args *db.SavedFilter) (*db.SavedFilter, error) {
+	if _, _, err := compileFilter(args.Query, args.Sort); err != nil {
+		return nil, err
+	}
+	filter, err := s.db.GetFilter(username, id)
+	if err != nil {
+		return nil, filterError(err)
+	}
+	filter.Name = args.Name
+	filter.Query = args.Query
+	filter.Sort = args.Sort
+	filter.UpdatedAt = time.Now()
+	if err := s.db.PutFilter(filter); err != nil {
+		return nil, ErrDBInternal
+	}
+	return filter, nil
+}
+
+func (s *task) DeleteFilter(ctx context.Context, username string, id uuid.UUID) error {
+	return filterError(s.db.DeleteFilter(username, id))
+}
+
+// SearchTasks returns the tasks of the user matching the filter query,
+// sorted by sortSpec. Relative dates are resolved against now. The status,
+// project and due terms joined by the top-level AND are checked against the
+// per-user indexes first, so that only the remaining candidates are decoded.
+func (s *task) SearchTasks(ctx context.Context, username string, query string, sortSpec string, now time.Time) ([]db.Task, error) {
+	filter, order, err := compileFilter(query, sortSpec)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := s.db.GetTasksByKey(username, func(k *db.TaskKey) bool {
+		t := &db.Task{ID: k.ID, Status: k.Status, Project: k.Project, Due: k.Due}
+		return filter.MatchField("status", t, now) && filter.MatchField("project", t, now) &&
+			(!k.DueKnown || filter.MatchField("due", t, now))
+	})
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+	result := filter.Apply(tasks, now)
+	order.Sort(result)
+	return result, nil
+}
+
+// RunFilter searches with a saved filter. A non-empty sortSpec overrides the
+// sort order stored with the filter.
+func (s *task) RunFilter(ctx context.Context, username string, id uuid.UUID, sortSpec string, now time.Time) ([]db.Task, error) {
+	filter, err := s.db.GetFilter(username, id)
+	if err != nil {
+		return nil, filterError(err)
+	}
+	if sortSpec == "" {
+		sortSpec = filter.Sort
+	}
+	return s.SearchTasks(ctx, username, filter.Query, sortSpec, now)
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"tasks/db"
+	"tasks/lib"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidICal = lib.ErrInvalidICal
+
+// ICalImportResult reports what an iCalendar import did. Skipped lists the
+// components that are not tasks or are cancelled, Invalid the VTODOs that
+// could not be imported.
+type ICalImportResult struct {
+	Created int               `json:"created"`
+	Updated int               `json:"updated"`
+	Skipped []lib.ICalProblem `json:"skipped"`
+	Invalid []lib.ICalProblem `json:"invalid"`
+}
+
+// mergeICalTodo copies the properties carried by a VTODO onto the task.
+func mergeICalTodo(t *db.Task, from *db.Task, now time.Time) {
+	t.Title = from.Title
+	t.Text = from.Text
+	t.Due = from.Due
+	t.Priority = from.Priority
+	t.Recurrence = from.Recurrence
+	t.Tags = from.Tags
+	if from.Status == db.StatusDone {
+		if t.Status != db.StatusDone || from.CompletedAt != nil {
+			completed := now
+			if from.CompletedAt != nil {
+				completed = *from.CompletedAt
+			}
+			t.Status = db.StatusDone
+			t.CompletedAt = &completed
+		}
+	} else {
+		t.SetStatus(from.Status, now)
+	}
+	t.UpdatedAt = now
+}
+
+// ImportICal upserts the VTODO components of an iCalendar stream into the
+// tasks of the user. A component updates the task it was exported from or
+// last imported into, matched by UID, and creates a task otherwise. All
+// changes are committed together.
+func (s *task) ImportICal(ctx context.Context, username string, r io.Reader) (*ICalImportResult, error) {
+	parsed, err := lib.ParseICal(r)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := s.db.GetAllTasksFromUser(ctx, username)
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+	byUID := make(map[string]uuid.UUID, 2*len(tasks))
+	for i := range tasks {
+		byUID[tasks[i].ID.String()] = tasks[i].ID
+		byUID[lib.ICalUID(&tasks[i])] = tasks[i].ID
+	}
+
+	result := &ICalImportResult{Skipped: parsed.Skipped, Invalid: parsed.Invalid}
+	validate := validator.New()
+	now := time.Now()
+	seen := map[string]bool{}
+	var created []*db.Task
+	var updates []*lib.ICalTodo
+	for i := range parsed.Todos {
+		todo := &parsed.Todos[i]
+		invalid := func(reason string) {
+			result.Invalid = append(result.Invalid, lib.ICalProblem{Line: todo.Line, Component: "VTODO", UID: todo.UID, Reason: reason})
+		}
+		if seen[todo.UID] {
+			invalid("duplicate UID")
+			continue
+		}
+		seen[todo.UID] = true
+
+		t := &db.Task{ID: uuid.New(), User: username, CreatedAt: todo.Task.CreatedAt, ICalUID: todo.UID}
+		if id, ok := byUID[todo.UID]; ok {
+			t.ID = id
+		}
+		mergeICalTodo(t, &todo.Task, now)
+		if err := validate.Struct(t); err != nil {
+			invalid(fmt.Sprintf("%v: %v", ErrInvalidTask, err))
+			continue
+		}
+		if _, ok := byUID[todo.UID]; ok {
+			updates = append(updates, todo)
+			continue
+		}
+		if t.CreatedAt.IsZero() {
+			t.CreatedAt = now
+		}
+		created = append(created, t)
+	}
+
+	var befores, afters []*db.TaskSummary
+	var updated []uuid.UUID
+	err = s.db.InTransaction(username, func(tx *db.TaskTx) error {
+		for _, t := range created {
+			if err := tx.CreateTask(t); err != nil {
+				return err
+			}
+		}
+		for _, todo := range updates {
+			var before *db.TaskSummary
+			t, err := tx.UpdateTask(byUID[todo.UID], func(t *db.Task) error {
+				before = t.Summary()
+				mergeICalTodo(t, &todo.Task, now)
+				if t.ID.String() != todo.UID {
+					t.ICalUID = todo.UID
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			befores, afters = append(befores, before), append(afters, t.Summary())
+			updated = append(updated, t.ID)
+		}
+		return auditImportTx(ctx, tx, username, created, updated, befores, afters)
+	})
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+
+	result.Created, result.Updated = len(created), len(updated)
+	return result, nil
+}
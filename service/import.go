@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+
+	"tasks/db"
+	"tasks/lib"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidImport       = lib.ErrInvalidImport
+	ErrUnknownImportSource = newError(KindNotFound, "unknown import source")
+)
+
+// ImportResult summarises an import from another service. Unchanged counts
+// the tasks that were imported before and are the same at the source.
+// Skipped lists the items that are deleted or archived at the source,
+// Invalid those that do not satisfy the validation rules.
+type ImportResult struct {
+	Source    string              `json:"source"`
+	Projects  []string            `json:"projects"`
+	Created   int                 `json:"created"`
+	Updated   int                 `json:"updated"`
+	Unchanged int                 `json:"unchanged"`
+	Skipped   []lib.ImportProblem `json:"skipped"`
+	Invalid   []lib.ImportProblem `json:"invalid"`
+}
+
+// applyImportedTask overwrites the task with what the source holds.
+// Checklist items get IDs derived from their source IDs, so they keep them
+// across imports.
+func applyImportedTask(t *db.Task, from *lib.ImportedTask, parent *uuid.UUID, now time.Time) {
+	t.Title = from.Title
+	t.Text = from.Text()
+	t.Project = from.Project
+	t.Tags = nil
+	t.AddTags(from.Tags...)
+	t.Priority = from.Priority
+	t.Due = from.Due
+	t.Recurrence = from.Recurrence
+	t.ParentID = parent
+	t.SetStatus(from.Status, now)
+	if from.Status == db.StatusDone && from.CompletedAt != nil {
+		t.CompletedAt = from.CompletedAt
+	}
+
+	created := make(map[uuid.UUID]time.Time, len(t.Checklist))
+	for _, item := range t.Checklist {
+		created[item.ID] = item.CreatedAt
+	}
+	t.Checklist = nil
+	for _, item := range from.Checklist {
+		id := uuid.NewSHA1(t.ID, []byte(item.SourceID))
+		at, ok := created[id]
+		if !ok {
+			at = now
+		}
+		t.Checklist = append(t.Checklist, db.ChecklistItem{ID: id, Text: item.Text, Done: item.Done, CreatedAt: at})
+	}
+}
+
+// importDepth returns how many ancestors of the task are in the export, so
+// parents can be imported before their sub-tasks.
+func importDepth(tasks map[string]*lib.ImportedTask, t *lib.ImportedTask) int {
+	depth := 0
+	for parent, ok := tasks[t.Parent]; ok && depth <= len(tasks); parent, ok = tasks[parent.Parent] {
+		depth++
+	}
+	return depth
+}
+
+// ImportFrom imports the export file of another service, read by the parser
+// of the source in lib.ImportParsers. Every imported item is recorded with
+// the task it became, and importing it again overwrites that task with the
+// state at the source instead of creating another. All changes are
+// committed together.
+func (s *task) ImportFrom(ctx context.Context, username string, source string, r io.Reader) (*ImportResult, error) {
+	parse, ok := lib.ImportParsers[source]
+	if !ok {
+		return nil, ErrUnknownImportSource
+	}
+	now := time.Now()
+	data, err := parse(r, now)
+	if err != nil {
+		return nil, err
+	}
+
+	bySourceID := make(map[string]*lib.ImportedTask, len(data.Tasks))
+	for i := range data.Tasks {
+		bySourceID[data.Tasks[i].SourceID] = &data.Tasks[i]
+	}
+	depths := make(map[string]int, len(data.Tasks))
+	for i := range data.Tasks {
+		depths[data.Tasks[i].SourceID] = importDepth(bySourceID, &data.Tasks[i])
+	}
+	sort.SliceStable(data.Tasks, func(i, j int) bool {
+		return depths[data.Tasks[i].SourceID] < depths[data.Tasks[j].SourceID]
+	})
+
+	result := &ImportResult{Source: source, Projects: data.Projects, Skipped: data.Skipped, Invalid: []lib.ImportProblem{}}
+	validate := validator.New()
+	var created []*db.Task
+	var befores, afters []*db.TaskSummary
+	var updated []uuid.UUID
+
+	err = s.db.InTransaction(username, func(tx *db.TaskTx) error {
+		ids := map[string]uuid.UUID{}
+		for i := range data.Tasks {
+			from := &data.Tasks[i]
+			var existing *db.Task
+			if id := tx.ImportedTaskID(source, from.SourceID); id != uuid.Nil {
+				t, err := tx.GetTask(id)
+				switch {
+				case errors.Is(err, db.ErrNoRows):
+				case err != nil:
+					return err
+				default:
+					existing = t
+				}
+			}
+
+			t := &db.Task{ID: uuid.New(), User: username, Status: db.StatusTodo, CreatedAt: from.CreatedAt}
+			if existing != nil {
+				copied := *existing
+				t = &copied
+			} else if t.CreatedAt.IsZero() {
+				t.CreatedAt = now
+				if from.CompletedAt != nil {
+					t.CreatedAt = *from.CompletedAt
+				}
+			}
+			var parent *uuid.UUID
+			if id, ok := ids[from.Parent]; ok {
+				parent = &id
+			}
+			applyImportedTask(t, from, parent, now)
+			if err := validate.Struct(t); err != nil {
+				result.Invalid = append(result.Invalid, lib.ImportProblem{SourceID: from.SourceID, Title: from.Title, Reason: fmt.Sprintf("%v: %v", ErrInvalidTask, err)})
+				continue
+			}
+			ids[from.SourceID] = t.ID
+
+			switch {
+			case existing == nil:
+				t.UpdatedAt = now
+				if err := tx.CreateTask(t); err != nil {
+					return err
+				}
+				if err := tx.SetImportedTaskID(source, from.SourceID, t.ID); err != nil {
+					return err
+				}
+				created = append(created, t)
+			case reflect.DeepEqual(existing, t):
+				result.Unchanged++
+			default:
+				t.UpdatedAt = now
+				stored, err := tx.UpdateTask(t.ID, func(stored *db.Task) error {
+					*stored = *t
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				befores, afters = append(befores, existing.Summary()), append(afters, stored.Summary())
+				updated = append(updated, t.ID)
+			}
+		}
+		return auditImportTx(ctx, tx, username, created, updated, befores, afters)
+	})
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+
+	result.Created, result.Updated = len(created), len(updated)
+	return result, nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"tasks/lib"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportFrom(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	export := `{"name": "Launch", "lists": [{"id": "l1", "name": "To do"}], "cards": [
+		{"id": "c1", "name": "Write docs", "idList": "l1", "desc": "API first"},
+		{"id": "c2", "name": "Go", "idList": "l1"}
+	], "checklists": [{"idCard": "c1", "checkItems": [{"id": "i1", "name": "Outline", "state": "complete"}]}]}`
+
+	result, err := s.ImportFrom(ctx, "alice", lib.ImportTrello, strings.NewReader(export))
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Created)
+	require.Len(t, result.Invalid, 1)
+	require.Equal(t, "c2", result.Invalid[0].SourceID)
+
+	tasks, err := s.GetAllTasksFromUser(ctx, "alice")
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	first := tasks[0]
+	require.Equal(t, "Launch", first.Project)
+	require.Len(t, first.Checklist, 1)
+
+	result, err = s.ImportFrom(ctx, "alice", lib.ImportTrello, strings.NewReader(export))
+	require.NoError(t, err)
+	require.Equal(t, 0, result.Created)
+	require.Equal(t, 0, result.Updated)
+	require.Equal(t, 1, result.Unchanged)
+
+	changed := strings.Replace(export, `"state": "complete"`, `"state": "incomplete"`, 1)
+	result, err = s.ImportFrom(ctx, "alice", lib.ImportTrello, strings.NewReader(changed))
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Updated)
+
+	tasks, err = s.GetAllTasksFromUser(ctx, "alice")
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	require.Equal(t, first.ID, tasks[0].ID)
+	require.Equal(t, first.Checklist[0].ID, tasks[0].Checklist[0].ID)
+	require.False(t, tasks[0].Checklist[0].Done)
+
+	_, err = s.ImportFrom(ctx, "alice", "asana", strings.NewReader(export))
+	require.ErrorIs(t, err, ErrUnknownImportSource)
+	_, err = s.ImportFrom(ctx, "alice", lib.ImportTodoist, strings.NewReader(export))
+	require.ErrorIs(t, err, ErrInvalidImport)
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+
+	"tasks/db"
+	"tasks/lib"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidMarkdown = lib.ErrInvalidMarkdown
+
+// MarkdownImportResult reports what a Markdown import did. Unchanged counts
+// the files matching their task already. Invalid lists the files that could
+// not be imported.
+type MarkdownImportResult struct {
+	Created   int               `json:"created"`
+	Updated   int               `json:"updated"`
+	Unchanged int               `json:"unchanged"`
+	Invalid   []MarkdownProblem `json:"invalid"`
+}
+
+type MarkdownProblem struct {
+	File   string `json:"file"`
+	Reason string `json:"reason"`
+}
+
+// mergeMarkdownTask copies what a Markdown file carries onto the task.
+// Checklist items keep their ID and creation time when the file names an
+// item of the task. Values of custom fields that the project does not define
+// are dropped.
+func mergeMarkdownTask(t *db.Task, from *db.Task, parent *uuid.UUID, defs map[string]*db.FieldDefinition, now time.Time) {
+	t.Title = from.Title
+	t.Text = from.Text
+	t.Project = from.Project
+	t.Priority = from.Priority
+	t.Tags = nil
+	t.AddTags(from.Tags...)
+	t.ParentID = parent
+	t.Due = from.Due
+	t.Recurrence = from.Recurrence
+	t.DeferUntil = from.DeferUntil
+	t.Someday = from.Someday
+	if !from.CreatedAt.IsZero() {
+		t.CreatedAt = from.CreatedAt
+	}
+	status := from.Status
+	if status == "" {
+		status = db.StatusTodo
+	}
+	t.SetStatus(status, now)
+	if status == db.StatusDone && from.CompletedAt != nil {
+		t.CompletedAt = from.CompletedAt
+	}
+
+	t.Fields = nil
+	for key, v := range from.Fields {
+		def, ok := defs[key]
+		if !ok {
+			continue
+		}
+		if converted, err := convertFieldValue(def, v); err == nil {
+			if t.Fields == nil {
+				t.Fields = map[string]interface{}{}
+			}
+			t.Fields[key] = converted
+		}
+	}
+
+	created := make(map[uuid.UUID]time.Time, len(t.Checklist))
+	for _, item := range t.Checklist {
+		created[item.ID] = item.CreatedAt
+	}
+	t.Checklist = nil
+	for _, item := range from.Checklist {
+		at, ok := created[item.ID]
+		if !ok || item.ID == uuid.Nil {
+			item.ID, at = uuid.New(), now
+		}
+		t.Checklist = append(t.Checklist, db.ChecklistItem{ID: item.ID, Text: item.Text, Done: item.Done, CreatedAt: at})
+	}
+}
+
+// ImportMarkdown upserts Markdown tasks, read from a ZIP archive of files or
+// a single file, into the tasks of the user. A file with the id of a task of
+// the user updates it; other files create tasks. Parents are resolved among
+// the imported files first, so a folder exported from another account keeps
+// its sub-tasks. All changes are committed together.
+func (s *task) ImportMarkdown(ctx context.Context, username string, r io.Reader) (*MarkdownImportResult, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMarkdown, err)
+	}
+	files, err := lib.ReadMarkdownImport(data)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MarkdownImportResult{Invalid: []MarkdownProblem{}}
+	var valid []lib.MarkdownFile
+	for _, f := range files {
+		if f.Err != nil {
+			result.Invalid = append(result.Invalid, MarkdownProblem{File: f.Name, Reason: f.Err.Error()})
+			continue
+		}
+		valid = append(valid, f)
+	}
+	byID := map[uuid.UUID]*db.Task{}
+	for _, f := range valid {
+		if f.Task.ID != uuid.Nil {
+			byID[f.Task.ID] = f.Task
+		}
+	}
+	depth := func(t *db.Task) int {
+		d := 0
+		for t.ParentID != nil && d <= len(byID) {
+			parent, ok := byID[*t.ParentID]
+			if !ok {
+				break
+			}
+			t, d = parent, d+1
+		}
+		return d
+	}
+	sort.SliceStable(valid, func(i, j int) bool { return depth(valid[i].Task) < depth(valid[j].Task) })
+
+	validate := validator.New()
+	now := time.Now()
+	var created []*db.Task
+	var befores, afters []*db.TaskSummary
+	var updated []uuid.UUID
+
+	err = s.db.InTransaction(username, func(tx *db.TaskTx) error {
+		defs := map[string]map[string]*db.FieldDefinition{}
+		ids := map[uuid.UUID]uuid.UUID{}
+		seen := map[uuid.UUID]string{}
+		for _, f := range valid {
+			from := f.Task
+			invalid := func(reason string) {
+				result.Invalid = append(result.Invalid, MarkdownProblem{File: f.Name, Reason: reason})
+			}
+			t := &db.Task{ID: uuid.New(), User: username, Status: db.StatusTodo, CreatedAt: now}
+			var existing *db.Task
+			if from.ID != uuid.Nil {
+				if other, ok := seen[from.ID]; ok {
+					invalid(fmt.Sprintf("same id as %s", other))
+					continue
+				}
+				seen[from.ID] = f.Name
+				stored, err := tx.GetTask(from.ID)
+				switch {
+				case errors.Is(err, db.ErrNoRows):
+				case err != nil:
+					return err
+				default:
+					existing = stored
+					copied := *stored
+					t = &copied
+				}
+			}
+
+			var parent *uuid.UUID
+			if from.ParentID != nil {
+				if id, ok := ids[*from.ParentID]; ok {
+					parent = &id
+				} else if _, err := tx.GetTask(*from.ParentID); err == nil {
+					parent = from.ParentID
+				}
+			}
+			if _, ok := defs[from.Project]; !ok {
+				list, err := tx.GetFieldDefinitions(from.Project)
+				if err != nil {
+					return err
+				}
+				defs[from.Project] = fieldDefinitionsByKey(list)
+			}
+			mergeMarkdownTask(t, from, parent, defs[from.Project], now)
+			if err := validate.Struct(t); err != nil {
+				invalid(fmt.Sprintf("%v: %v", ErrInvalidTask, err))
+				continue
+			}
+			if from.ID != uuid.Nil {
+				ids[from.ID] = t.ID
+			}
+
+			switch {
+			case existing == nil:
+				t.UpdatedAt = now
+				if err := tx.CreateTask(t); err != nil {
+					return err
+				}
+				created = append(created, t)
+			case reflect.DeepEqual(existing, t):
+				result.Unchanged++
+			default:
+				t.UpdatedAt = now
+				stored, err := tx.UpdateTask(t.ID, func(stored *db.Task) error {
+					*stored = *t
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+				befores, afters = append(befores, existing.Summary()), append(afters, stored.Summary())
+				updated = append(updated, t.ID)
+			}
+		}
+		return auditImportTx(ctx, tx, username, created, updated, befores, afters)
+	})
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+
+	result.Created, result.Updated = len(created), len(updated)
+	return result, nil
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"tasks/db"
+	"tasks/lib"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportMarkdown(t *testing.T) {
+	s, d := newTestService(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	parent := &db.Task{
+		ID: uuid.New(), Title: "Pack bag", User: "alice", Project: "trip", Status: db.StatusTodo, Fields: map[string]interface{}{"km": 12.0},
+		Checklist: []db.ChecklistItem{{ID: uuid.New(), Text: "Socks", CreatedAt: now}}, CreatedAt: now, UpdatedAt: now,
+	}
+	child := &db.Task{ID: uuid.New(), Title: "Buy adapter", User: "alice", Project: "trip", Status: db.StatusTodo, ParentID: &parent.ID, CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, d.CreateTasks([]*db.Task{parent, child}))
+	for _, username := range []string{"alice", "bobby"} {
+		_, err := s.CreateFieldDefinition(ctx, username, "trip", &db.FieldDefinition{Key: "km", Name: "Distance", Type: db.FieldNumber})
+		require.NoError(t, err)
+	}
+
+	tasks, err := s.GetAllTasksFromUser(ctx, "alice")
+	require.NoError(t, err)
+	var archive bytes.Buffer
+	require.NoError(t, lib.WriteMarkdownArchive(&archive, tasks, now))
+
+	t.Run("unchanged", func(t *testing.T) {
+		result, err := s.ImportMarkdown(ctx, "alice", bytes.NewReader(archive.Bytes()))
+		require.NoError(t, err)
+		require.Equal(t, MarkdownImportResult{Unchanged: 2, Invalid: []MarkdownProblem{}}, *result)
+	})
+
+	t.Run("single file", func(t *testing.T) {
+		file, err := lib.TaskMarkdown(child)
+		require.NoError(t, err)
+		file = bytes.Replace(file, []byte("status: todo"), []byte("status: done"), 1)
+		result, err := s.ImportMarkdown(ctx, "alice", bytes.NewReader(file))
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Updated)
+
+		_, err = s.ImportMarkdown(ctx, "alice", bytes.NewReader([]byte("---\nstatus: [\n---\n")))
+		require.ErrorIs(t, err, ErrInvalidMarkdown)
+		result, err = s.ImportMarkdown(ctx, "alice", bytes.NewReader([]byte("# Go\n")))
+		require.NoError(t, err)
+		require.Len(t, result.Invalid, 1)
+	})
+
+	t.Run("other account", func(t *testing.T) {
+		result, err := s.ImportMarkdown(ctx, "bobby", bytes.NewReader(archive.Bytes()))
+		require.NoError(t, err)
+		require.Equal(t, 2, result.Created)
+
+		tasks, err := s.GetAllTasksFromUser(ctx, "bobby")
+		require.NoError(t, err)
+		require.Len(t, tasks, 2)
+		byTitle := map[string]db.Task{}
+		for _, task := range tasks {
+			require.NotEqual(t, parent.ID, task.ID)
+			require.NotEqual(t, child.ID, task.ID)
+			byTitle[task.Title] = task
+		}
+		require.Equal(t, byTitle["Pack bag"].ID, *byTitle["Buy adapter"].ParentID)
+		require.Equal(t, map[string]interface{}{"km": 12.0}, byTitle["Pack bag"].Fields)
+		require.Equal(t, "Socks", byTitle["Pack bag"].Checklist[0].Text)
+		require.Equal(t, now, byTitle["Pack bag"].CreatedAt)
+
+		alice, err := s.GetAllTasksFromUser(ctx, "alice")
+		require.NoError(t, err)
+		require.Len(t, alice, 2)
+	})
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"tasks/db"
+	"tasks/lib"
+
+	"github.com/google/uuid"
+	jsoniter "github.com/json-iterator/go"
+)
+
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
+var (
+	ErrUnsupportedPatch = newError(KindUnsupported, "patch media type is not supported")
+	ErrInvalidPatch     = newError(KindInvalid, "patch cannot be applied to the task")
+	ErrPatchTestFailed  = newError(KindConflict, "patch test operation failed")
+)
+
+// readOnlyTaskMembers are the members of a task a patch may test but not
+// change: they identify the task or are kept by the service.
+var readOnlyTaskMembers = []string{"id", "user", "rank", "parentId", "icalUid", "extensions", "createdAt", "updatedAt", "completedAt"}
+
+// PatchTask changes the task of the user with a JSON Merge Patch or a JSON
+// Patch, as told by the media type. The patch is applied to the JSON
+// representation of the task within one transaction, and the result must
+// satisfy the rules of a task: either the whole patch is stored or nothing
+// changes.
+func (s *task) PatchTask(ctx context.Context, username string, id uuid.UUID, mediaType string, patch []byte) (*db.Task, error) {
+	var apply func(doc []byte, patch []byte) ([]byte, error)
+	switch mediaType {
+	case lib.MergePatchContentType:
+		apply = lib.MergePatch
+	case lib.JSONPatchContentType:
+		apply = lib.JSONPatch
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedPatch, mediaType)
+	}
+
+	current, err := s.db.GetTask(id.String())
+	if err != nil || current.User != username {
+		return nil, ErrNotFound
+	}
+	// The project may change, so the definitions of all of them are loaded.
+	list, err := s.db.GetAllFieldDefinitions(username)
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+	defs := map[string]map[string]*db.FieldDefinition{}
+	for i := range list {
+		if defs[list[i].Project] == nil {
+			defs[list[i].Project] = map[string]*db.FieldDefinition{}
+		}
+		defs[list[i].Project][list[i].Key] = &list[i]
+	}
+
+	t, err := s.updateTask(ctx, id, AuditTaskUpdate, func(t *db.Task) error {
+		patched, err := patchTask(t, apply, patch, defs, time.Now())
+		if err != nil {
+			return err
+		}
+		*t = *patched
+		return nil
+	})
+	var serr *Error
+	switch {
+	case errors.Is(err, db.ErrNoRows):
+		return nil, ErrNotFound
+	case errors.As(err, &serr):
+		return nil, err
+	case err != nil:
+		return nil, ErrDBInternal
+	}
+	return t, nil
+}
+
+// patchTask returns the task t patched by apply. The status, tags and
+// custom fields of the result are normalized as when they are set by the
+// other operations.
+func patchTask(t *db.Task, apply func(doc []byte, patch []byte) ([]byte, error), patch []byte, defs map[string]map[string]*db.FieldDefinition, now time.Time) (*db.Task, error) {
+	doc, err := taskDocument(t)
+	if err != nil {
+		return nil, err
+	}
+	patchedDoc, err := apply(doc, patch)
+	switch {
+	case errors.Is(err, lib.ErrPatchTestFailed):
+		return nil, fmt.Errorf("%w: %v", ErrPatchTestFailed, err)
+	case err != nil:
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+
+	var before, after map[string]interface{}
+	if err := json.Unmarshal(doc, &before); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(patchedDoc, &after); err != nil || after == nil {
+		return nil, fmt.Errorf("%w: the patched task is not an object", ErrInvalidPatch)
+	}
+	for _, name := range readOnlyTaskMembers {
+		if !reflect.DeepEqual(before[name], after[name]) {
+			return nil, fmt.Errorf("%w: %s cannot be changed", ErrInvalidPatch, name)
+		}
+	}
+
+	patched := &db.Task{}
+	dec := json.NewDecoder(bytes.NewReader(patchedDoc))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(patched); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	if err := Validate(patched, ErrInvalidTask); err != nil {
+		return nil, err
+	}
+
+	status := patched.Status
+	patched.Status, patched.CompletedAt = t.Status, t.CompletedAt
+	patched.SetStatus(status, now)
+	tags := patched.Tags
+	patched.Tags = nil
+	patched.AddTags(tags...)
+	values := patched.Fields
+	patched.Fields = nil
+	if err := setFieldValues(patched, defs[patched.Project], values); err != nil {
+		return nil, err
+	}
+	patched.UpdatedAt = now
+	return patched, nil
+}
+
+// taskDocument returns the JSON representation of the task with every
+// member, also those left out while empty, so that a patch can test and
+// replace members that are not set.
+func taskDocument(t *db.Task) ([]byte, error) {
+	doc, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	var members map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	if err := dec.Decode(&members); err != nil {
+		return nil, err
+	}
+	rt := reflect.TypeOf(*t)
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if _, ok := members[name]; ok || name == "" || name == "-" {
+			continue
+		}
+		switch f.Type.Kind() {
+		case reflect.Slice:
+			members[name] = []interface{}{}
+		case reflect.Map:
+			members[name] = map[string]interface{}{}
+		case reflect.Ptr:
+			members[name] = nil
+		default:
+			members[name] = reflect.Zero(f.Type).Interface()
+		}
+	}
+	return json.Marshal(members)
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"tasks/db"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+const (
+	AuditTaskSnooze    = "task.snooze"
+	AuditTaskResurface = "task.resurface"
+
+	// DefaultResurfaceInterval is how often RunResurfacer looks for tasks
+	// whose deferral has ended when no interval is configured.
+	DefaultResurfaceInterval = time.Minute
+)
+
+var (
+	ErrInvalidSnooze = newError(KindInvalid, "snooze needs a time in the future or someday")
+	errNotResurfaced = errors.New("task deferral changed")
+)
+
+func snoozeError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, db.ErrNoRows):
+		return ErrNotFound
+	case errors.Is(err, ErrInvalidSnooze):
+		return ErrInvalidSnooze
+	default:
+		return ErrDBInternal
+	}
+}
+
+// SnoozeTask hides the task from the default listings until the given time,
+// or indefinitely when someday is set. Exactly one of the two must be given.
+func (s *task) SnoozeTask(ctx context.Context, username string, id uuid.UUID, until *time.Time, someday bool) (*db.Task, error) {
+	now := time.Now()
+	if someday == (until != nil) || until != nil && !until.After(now) {
+		return nil, ErrInvalidSnooze
+	}
+	t, err := s.updateTask(ctx, id, AuditTaskSnooze, func(t *db.Task) error {
+		if t.User != username {
+			return db.ErrNoRows
+		}
+		t.DeferUntil = until
+		t.Someday = someday
+		t.UpdatedAt = now
+		return nil
+	})
+	return t, snoozeError(err)
+}
+
+// UnsnoozeTask brings a deferred task back to the default listings.
+func (s *task) UnsnoozeTask(ctx context.Context, username string, id uuid.UUID) (*db.Task, error) {
+	t, err := s.updateTask(ctx, id, AuditTaskSnooze, func(t *db.Task) error {
+		if t.User != username {
+			return db.ErrNoRows
+		}
+		t.DeferUntil = nil
+		t.Someday = false
+		t.UpdatedAt = time.Now()
+		return nil
+	})
+	return t, snoozeError(err)
+}
+
+// GetDeferredTasks returns the snoozed tasks of the user, the ones that
+// resurface first first. Someday tasks are listed by GetSomedayTasks.
+func (s *task) GetDeferredTasks(ctx context.Context, username string, now time.Time) ([]db.Task, error) {
+	tasks, err := s.db.GetAllTasksFromUser(ctx, username)
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+	deferred := []db.Task{}
+	for _, t := range tasks {
+		if !t.Someday && t.Deferred(now) {
+			deferred = append(deferred, t)
+		}
+	}
+	sort.SliceStable(deferred, func(i, j int) bool {
+		return deferred[i].DeferUntil.Before(*deferred[j].DeferUntil)
+	})
+	return deferred, nil
+}
+
+// GetSomedayTasks returns the tasks of the user parked for someday in their
+// manual order.
+func (s *task) GetSomedayTasks(ctx context.Context, username string) ([]db.Task, error) {
+	tasks, err := s.db.GetAllTasksFromUser(ctx, username)
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+	someday := []db.Task{}
+	for _, t := range tasks {
+		if t.Someday {
+			someday = append(someday, t)
+		}
+	}
+	return someday, nil
+}
+
+// ResurfaceTasks clears the deferral of every task snoozed until now or
+// earlier and records a task.resurface event for each. It returns the
+// number of resurfaced tasks.
+func (s *task) ResurfaceTasks(ctx context.Context, now time.Time) (int, error) {
+	tasks, err := s.db.GetResurfacingTasks(now)
+	if err != nil {
+		return 0, ErrDBInternal
+	}
+	n := 0
+	for _, t := range tasks {
+		_, err := s.updateTask(ctx, t.ID, AuditTaskResurface, func(t *db.Task) error {
+			// The task may have been snoozed again since it was read.
+			if t.DeferUntil == nil || t.DeferUntil.After(now) {
+				return errNotResurfaced
+			}
+			t.DeferUntil = nil
+			return nil
+		})
+		switch {
+		case err == nil:
+			n++
+		case errors.Is(err, errNotResurfaced), errors.Is(err, db.ErrNoRows):
+		default:
+			return n, ErrDBInternal
+		}
+	}
+	return n, nil
+}
+
+// RunResurfacer calls ResurfaceTasks every interval until ctx is done.
+func (s *task) RunResurfacer(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultResurfaceInterval
+	}
+	l := zerolog.Ctx(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		n, err := s.ResurfaceTasks(ctx, time.Now())
+		if err != nil {
+			l.Error().Err(err).Msgf("could not resurface deferred tasks")
+		} else if n > 0 {
+			l.Info().Msgf("%d deferred tasks have resurfaced", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
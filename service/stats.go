@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"tasks/db"
+)
+
+// maxStatsPeriods bounds the number of buckets a single query may produce.
+const maxStatsPeriods = 1000
+
+type StatsQuery struct {
+	From     time.Time
+	To       time.Time
+	Period   string
+	Location *time.Location
+}
+
+type StatsPeriod struct {
+	Period    string `json:"period"`
+	Created   int64  `json:"created"`
+	Completed int64  `json:"completed"`
+}
+
+type GroupStats struct {
+	Name string `json:"name"`
+	db.StatusCounts
+}
+
+type Stats struct {
+	From                   time.Time     `json:"from"`
+	To                     time.Time     `json:"to"`
+	Periods                []StatsPeriod `json:"periods"`
+	Open                   int           `json:"open"`
+	Done                   int           `json:"done"`
+	Overdue                int           `json:"overdue"`
+	AverageLeadTimeSeconds int64         `json:"averageLeadTimeSeconds"`
+	CurrentStreakDays      int           `json:"currentStreakDays"`
+	LongestStreakDays      int           `json:"longestStreakDays"`
+	Tags                   []GroupStats  `json:"tags"`
+	Projects               []GroupStats  `json:"projects"`
+}
+
+// Stats summarises the activity of the user from the counters maintained on
+// every task write, so its cost does not grow with the number of tasks.
+func (s *task) Stats(ctx context.Context, username string, q StatsQuery) (*Stats, error) {
+	counters, err := s.db.GetStatsCounters(username)
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+	return buildStats(counters, q, time.Now())
+}
+
+// statsRange fills in the defaults of the query: periods in UTC, and the
+// last 30 days, 12 weeks or 12 months up to now.
+func statsRange(q StatsQuery, now time.Time) StatsQuery {
+	if q.Location == nil {
+		q.Location = time.UTC
+	}
+	if q.Period == "" {
+		q.Period = PeriodWeek
+	}
+	if q.To.IsZero() {
+		q.To = now
+	}
+	if q.From.IsZero() {
+		switch q.Period {
+		case PeriodDay:
+			q.From = q.To.AddDate(0, 0, -29)
+		case PeriodWeek:
+			q.From = q.To.AddDate(0, 0, -7*11)
+		default:
+			q.From = q.To.AddDate(0, -11, 0)
+		}
+		q.From = periodStart(q.From, q.Period, q.Location)
+	}
+	return q
+}
+
+func buildStats(c *db.StatsCounters, q StatsQuery, now time.Time) (*Stats, error) {
+	q = statsRange(q, now)
+	if !q.To.After(q.From) {
+		return nil, ErrInvalidTimeRange
+	}
+
+	stats := &Stats{
+		From:     q.From,
+		To:       q.To,
+		Periods:  []StatsPeriod{},
+		Open:     c.Totals.Open,
+		Done:     c.Totals.Done,
+		Tags:     groupStats(c.Tags),
+		Projects: groupStats(c.Projects),
+	}
+	if c.Totals.LeadTimeCount > 0 {
+		stats.AverageLeadTimeSeconds = c.Totals.LeadTimeSeconds / c.Totals.LeadTimeCount
+	}
+	for _, due := range c.Due {
+		if due.Before(now) {
+			stats.Overdue++
+		}
+	}
+
+	index := map[string]int{}
+	for start := periodStart(q.From, q.Period, q.Location); start.Before(q.To); start = nextPeriod(start, q.Period) {
+		if len(stats.Periods) == maxStatsPeriods {
+			return nil, ErrInvalidTimeRange
+		}
+		key := start.Format("2006-01-02")
+		index[key] = len(stats.Periods)
+		stats.Periods = append(stats.Periods, StatsPeriod{Period: key})
+	}
+	count := func(hours map[time.Time]int64, add func(p *StatsPeriod, n int64)) {
+		for hour, n := range hours {
+			if hour.Before(q.From) || !hour.Before(q.To) {
+				continue
+			}
+			if i, ok := index[periodStart(hour, q.Period, q.Location).Format("2006-01-02")]; ok {
+				add(&stats.Periods[i], n)
+			}
+		}
+	}
+	count(c.Created, func(p *StatsPeriod, n int64) { p.Created += n })
+	count(c.Completed, func(p *StatsPeriod, n int64) { p.Completed += n })
+
+	stats.CurrentStreakDays, stats.LongestStreakDays = completionStreaks(c.Completed, q.Location, now)
+	return stats, nil
+}
+
+func groupStats(groups map[string]db.StatusCounts) []GroupStats {
+	result := make([]GroupStats, 0, len(groups))
+	for name, counts := range groups {
+		result = append(result, GroupStats{Name: name, StatusCounts: counts})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// completionStreaks returns the number of consecutive days with at least one
+// completion ending today, or yesterday if nothing was completed today yet,
+// and the longest such run.
+func completionStreaks(completed map[time.Time]int64, loc *time.Location, now time.Time) (current int, longest int) {
+	days := map[time.Time]bool{}
+	for hour := range completed {
+		days[periodStart(hour, PeriodDay, loc)] = true
+	}
+
+	sorted := make([]time.Time, 0, len(days))
+	for day := range days {
+		sorted = append(sorted, day)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+	run := 0
+	for i, day := range sorted {
+		if i > 0 && sorted[i-1].AddDate(0, 0, 1).Equal(day) {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest = run
+		}
+	}
+
+	day := periodStart(now, PeriodDay, loc)
+	if !days[day] {
+		day = day.AddDate(0, 0, -1)
+	}
+	for days[day] {
+		current++
+		day = day.AddDate(0, 0, -1)
+	}
+	return current, longest
+}
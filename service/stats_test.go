@@ -0,0 +1,63 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"tasks/db"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildStats(t *testing.T) {
+	// Wednesday, 5 April 2023, 15:00 UTC.
+	now := time.Date(2023, 4, 5, 15, 0, 0, 0, time.UTC)
+	hour := func(d, h int) time.Time { return time.Date(2023, 4, d, h, 0, 0, 0, time.UTC) }
+
+	counters := &db.StatsCounters{
+		Totals:    db.StatsTotals{StatusCounts: db.StatusCounts{Open: 3, Done: 4}, LeadTimeSeconds: 4 * 3600, LeadTimeCount: 4},
+		Created:   map[time.Time]int64{hour(1, 10): 2, hour(3, 9): 3, hour(5, 8): 1},
+		Completed: map[time.Time]int64{hour(1, 12): 1, hour(3, 23): 1, hour(4, 10): 1, hour(5, 9): 1},
+		Due:       []time.Time{hour(4, 12), hour(6, 12)},
+		Tags:      map[string]db.StatusCounts{"work": {Open: 1, Done: 2}, "home": {Open: 2}},
+		Projects:  map[string]db.StatusCounts{},
+	}
+
+	t.Run("by day", func(t *testing.T) {
+		stats, err := buildStats(counters, StatsQuery{From: hour(2, 0), To: hour(6, 0), Period: PeriodDay}, now)
+		require.NoError(t, err)
+		require.Equal(t, []StatsPeriod{
+			{Period: "2023-04-02"},
+			{Period: "2023-04-03", Created: 3, Completed: 1},
+			{Period: "2023-04-04", Completed: 1},
+			{Period: "2023-04-05", Created: 1, Completed: 1},
+		}, stats.Periods)
+		require.Equal(t, 1, stats.Overdue)
+		require.Equal(t, int64(3600), stats.AverageLeadTimeSeconds)
+		require.Equal(t, 3, stats.CurrentStreakDays)
+		require.Equal(t, 3, stats.LongestStreakDays)
+		require.Equal(t, []GroupStats{
+			{Name: "home", StatusCounts: db.StatusCounts{Open: 2}},
+			{Name: "work", StatusCounts: db.StatusCounts{Open: 1, Done: 2}},
+		}, stats.Tags)
+	})
+	t.Run("by week in a time zone", func(t *testing.T) {
+		// 23:00 UTC on Monday 3 April is already Tuesday in Moscow.
+		loc := time.FixedZone("MSK", 3*3600)
+		stats, err := buildStats(counters, StatsQuery{Period: PeriodWeek, Location: loc}, now)
+		require.NoError(t, err)
+		require.Len(t, stats.Periods, 12)
+		last := stats.Periods[len(stats.Periods)-2:]
+		require.Equal(t, []StatsPeriod{
+			{Period: "2023-03-27", Created: 2, Completed: 1},
+			{Period: "2023-04-03", Created: 4, Completed: 3},
+		}, last)
+		require.Equal(t, 2, stats.CurrentStreakDays)
+	})
+	t.Run("rejects empty and huge ranges", func(t *testing.T) {
+		_, err := buildStats(counters, StatsQuery{From: hour(5, 0), To: hour(5, 0)}, now)
+		require.ErrorIs(t, err, ErrInvalidTimeRange)
+		_, err = buildStats(counters, StatsQuery{From: hour(5, 0).AddDate(-10, 0, 0), To: hour(5, 0), Period: PeriodDay}, now)
+		require.ErrorIs(t, err, ErrInvalidTimeRange)
+	})
+}
@@ -2,21 +2,24 @@ package service
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"tasks/db"
 	sqldb "tasks/db"
+	"tasks/lib"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 var (
-	ErrAlreadyExists     = errors.New("note already exists")
-	ErrDBInternal        = errors.New("internal DB error during operation")
-	ErrNotFound          = errors.New("requested note is not found")
-	ErrUserAlreadyExists = errors.New("note already exists")
-	ErrUserNotFound      = errors.New("requested note is not found")
+	ErrAlreadyExists     = newError(KindConflict, "note already exists")
+	ErrDBInternal        = newError(KindInternal, "internal DB error during operation")
+	ErrNotFound          = newError(KindNotFound, "requested note is not found")
+	ErrUserAlreadyExists = ErrAlreadyExists.refine("user already exists")
+	ErrUserNotFound      = ErrNotFound.refine("requested user is not found")
+	ErrItemNotFound      = newError(KindNotFound, "requested checklist item is not found")
+	ErrInvalidPosition   = newError(KindInvalid, "requested position is out of range")
+	ErrInvalidTask       = newError(KindInvalid, "task does not satisfy the validation rules")
 )
 
 type task struct {
@@ -27,21 +30,45 @@ func NewTask(db *sqldb.DB) *task {
 	return &task{db}
 }
 
-func (s *task) CreateTask(ctx context.Context, title string, username string, text string) (uuid.UUID, error) {
-	retID, err := s.db.CreateTask(&db.Task{
-		ID:        uuid.New(),
-		Title:     title,
-		User:      username,
-		Text:      text,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	})
+func (s *task) CreateTask(ctx context.Context, args *db.Task) (uuid.UUID, error) {
+	now := time.Now()
+	t := &db.Task{
+		ID:         uuid.New(),
+		Title:      args.Title,
+		User:       args.User,
+		Text:       args.Text,
+		Project:    args.Project,
+		Status:     db.StatusTodo,
+		Priority:   args.Priority,
+		Due:        args.Due,
+		Recurrence: args.Recurrence,
+		DeferUntil: args.DeferUntil,
+		Someday:    args.Someday,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	t.AddTags(args.Tags...)
+	if args.Status != "" {
+		t.SetStatus(args.Status, now)
+	}
+	if len(args.Fields) > 0 {
+		defs, err := s.fieldDefinitions(args.User, args.Project)
+		if err != nil {
+			return uuid.Nil, ErrDBInternal
+		}
+		if err := setFieldValues(t, defs, args.Fields); err != nil {
+			return uuid.Nil, err
+		}
+	}
+	err := s.createTasks(ctx, t.User, t)
 
 	switch {
+	case errors.Is(err, db.ErrTaskAlreadyExists):
+		return uuid.Nil, ErrAlreadyExists
 	case err != nil:
-		return uuid.Nil, err
+		return uuid.Nil, ErrDBInternal
 	default:
-		return retID, nil
+		return t.ID, nil
 	}
 }
 
@@ -54,33 +81,133 @@ func (s *task) GetAllTasksFromUser(ctx context.Context, username string) ([]db.T
 	return notes, nil
 }
 
-func (s *task) DeleteTask(ctx context.Context, reqID uuid.UUID) (uuid.UUID, error) {
-	id, err := s.db.DeleteTask(ctx, reqID)
+// GetTasks returns the tasks of the user with the ids, by id. Ids of missing
+// tasks or of tasks of other users are left out.
+func (s *task) GetTasks(ctx context.Context, username string, ids []uuid.UUID) (map[uuid.UUID]*db.Task, error) {
+	tasks, err := s.db.GetTasks(ids)
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+	byID := make(map[uuid.UUID]*db.Task, len(tasks))
+	for i := range tasks {
+		if tasks[i].User == username {
+			byID[tasks[i].ID] = &tasks[i]
+		}
+	}
+	return byID, nil
+}
+
+// DeleteTask deletes the task of the user. Tasks of other users are not
+// found.
+func (s *task) DeleteTask(ctx context.Context, username string, reqID uuid.UUID) (uuid.UUID, error) {
+	err := s.db.InTransaction(username, func(tx *db.TaskTx) error {
+		before, err := tx.GetTask(reqID)
+		if err != nil {
+			return err
+		}
+		if err := tx.DeleteTask(reqID); err != nil {
+			return err
+		}
+		return auditTx(ctx, tx, username, AuditTaskDelete, reqID.String(), before.Summary(), nil)
+	})
 
 	switch {
-	case errors.Is(err, sql.ErrNoRows):
+	case errors.Is(err, db.ErrNoRows):
 		return uuid.Nil, ErrNotFound
 	case err != nil:
 		return uuid.Nil, ErrDBInternal
 	default:
-		return id, nil
+		return reqID, nil
 	}
 }
 
-func (s *task) UpdateTask(ctx context.Context, reqID uuid.UUID, title string, text string, isTextValid bool) (uuid.UUID, error) {
-	id, err := s.db.UpdateTask(&db.Task{
-		ID:        reqID,
-		Title:     title,
-		Text:      text,
-		UpdatedAt: time.Now(),
+// UpdateTask sets the title, and the text if valid, of the task of the user.
+// Tasks of other users are not found.
+func (s *task) UpdateTask(ctx context.Context, username string, reqID uuid.UUID, title string, text string, isTextValid bool) (uuid.UUID, error) {
+	updated, err := s.updateTask(ctx, reqID, AuditTaskUpdate, func(t *db.Task) error {
+		if t.User != username {
+			return db.ErrNoRows
+		}
+		t.Title = title
+		if isTextValid {
+			t.Text = text
+		}
+		t.UpdatedAt = time.Now()
+		return nil
 	})
 
 	switch {
-	case errors.Is(err, sql.ErrNoRows):
+	case errors.Is(err, db.ErrNoRows):
 		return uuid.Nil, ErrNotFound
 	case err != nil:
 		return uuid.Nil, ErrDBInternal
 	default:
-		return id, nil
+		return updated.ID, nil
+	}
+}
+
+// MoveTask reorders a task between its new neighbours and optionally moves it
+// into another status or project column.
+func (s *task) MoveTask(ctx context.Context, username string, id uuid.UUID, after uuid.UUID, before uuid.UUID, status *string, project *string) (*db.Task, error) {
+	now := time.Now()
+	var t *db.Task
+	err := s.db.InTransaction(username, func(tx *db.TaskTx) error {
+		var summary *db.TaskSummary
+		var err error
+		t, err = tx.MoveTask(id, after, before, func(t *db.Task) error {
+			summary = t.Summary()
+			if status != nil {
+				t.SetStatus(*status, now)
+			}
+			if project != nil {
+				t.Project = *project
+			}
+			t.UpdatedAt = now
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return auditTx(ctx, tx, username, AuditTaskMove, t.ID.String(), summary, t.Summary())
+	})
+
+	switch {
+	case errors.Is(err, db.ErrNoRows):
+		return nil, ErrNotFound
+	case errors.Is(err, db.ErrInvalidRank):
+		return nil, ErrInvalidPosition
+	case err != nil:
+		return nil, ErrDBInternal
+	default:
+		return t, nil
+	}
+}
+
+// QuickAddTask parses a one-line description into a task. Unless dryRun is
+// set the task is created for the user.
+func (s *task) QuickAddTask(ctx context.Context, username string, text string, now time.Time, dryRun bool) (*db.Task, *lib.QuickAdd, error) {
+	parsed := lib.ParseQuickAdd(text, now)
+	t := &db.Task{
+		ID:         uuid.New(),
+		Title:      parsed.Title,
+		User:       username,
+		Status:     db.StatusTodo,
+		Priority:   parsed.Priority,
+		Due:        parsed.Due,
+		Recurrence: parsed.Recurrence,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	t.AddTags(parsed.Tags...)
+
+	if err := Validate(t, ErrInvalidTask); err != nil {
+		return nil, &parsed, err
+	}
+	if dryRun {
+		return t, &parsed, nil
+	}
+	if err := s.createTasks(ctx, username, t); err != nil {
+		return nil, &parsed, ErrDBInternal
 	}
+	return t, &parsed, nil
 }
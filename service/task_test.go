@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"tasks/db"
+	"tasks/lib"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestService returns the service over an empty database, with the users
+// registered with the password secret123. The database is closed when the
+// test ends.
+func newTestService(t *testing.T, usernames ...string) (*task, *db.DB) {
+	l := zerolog.Nop()
+	d, err := db.NewSQL(filepath.Join(t.TempDir(), "tasks.db"), &l)
+	require.NoError(t, err)
+	t.Cleanup(d.Close)
+	s := NewTask(d)
+	password, err := lib.Hash("secret123")
+	require.NoError(t, err)
+	for _, name := range usernames {
+		_, err = s.RegisterUser(context.Background(), &db.User{Username: name, Password: password, Email: name + "@example.com"})
+		require.NoError(t, err)
+	}
+	return s, d
+}
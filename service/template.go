@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"tasks/db"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTemplateNotFound = newError(KindNotFound, "requested template is not found")
+	ErrTemplateVariable = newError(KindInvalid, "template variables are missing")
+	ErrInvalidOffset    = newError(KindInvalid, "due offset is invalid")
+
+	placeholderRe = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+	offsetRe      = regexp.MustCompile(`(\d+)([wdhm])`)
+)
+
+func templateError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, db.ErrTemplateNotFound):
+		return ErrTemplateNotFound
+	default:
+		return ErrDBInternal
+	}
+}
+
+func (s *task) CreateTemplate(ctx context.Context, username string, args *db.Template) (*db.Template, error) {
+	if err := checkTemplateOffsets(args.Tasks); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &db.Template{
+		ID:        uuid.New(),
+		User:      username,
+		Name:      args.Name,
+		Tasks:     args.Tasks,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.db.PutTemplate(template); err != nil {
+		return nil, ErrDBInternal
+	}
+	return template, nil
+}
+
+func (s *task) GetTemplates(ctx context.Context, username string) ([]db.Template, error) {
+	templates, err := s.db.GetTemplates(username)
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+func (s *task) GetTemplate(ctx context.Context, username string, id uuid.UUID) (*db.Template, error) {
+	template, err := s.db.GetTemplate(username, id)
+	return template, templateError(err)
+}
+
+func (s *task) UpdateTemplate(ctx context.Context, username string, id uuid.UUID, args *db.Template) (*db.Template, error) {
+	if err := checkTemplateOffsets(args.Tasks); err != nil {
+		return nil, err
+	}
+	template, err := s.db.GetTemplate(username, id)
+	if err != nil {
+		return nil, templateError(err)
+	}
+	template.Name = args.Name
+	template.Tasks = args.Tasks
+	template.UpdatedAt = time.Now()
+	if err := s.db.PutTemplate(template); err != nil {
+		return nil, ErrDBInternal
+	}
+	return template, nil
+}
+
+func (s *task) DeleteTemplate(ctx context.Context, username string, id uuid.UUID) error {
+	return templateError(s.db.DeleteTemplate(username, id))
+}
+
+// InstantiateTemplate creates the tasks described by the template for the
+// user in one transaction. Placeholders are filled from vars, and {{date}}
+// defaults to the date of base. Due offsets are relative to base.
+func (s *task) InstantiateTemplate(ctx context.Context, username string, id uuid.UUID, vars map[string]string, base time.Time) ([]*db.Task, error) {
+	template, err := s.db.GetTemplate(username, id)
+	if err != nil {
+		return nil, templateError(err)
+	}
+
+	values := map[string]string{"date": base.Format("2006-01-02")}
+	for k, v := range vars {
+		values[k] = v
+	}
+	if missing := missingVariables(template, values); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateVariable, strings.Join(missing, ", "))
+	}
+
+	tasks, err := buildTemplateTasks(template.Tasks, username, values, base, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range tasks {
+		if err := Validate(t, ErrInvalidTask); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.createTasks(ctx, username, tasks...); err != nil {
+		return nil, ErrDBInternal
+	}
+	return tasks, nil
+}
+
+func expandPlaceholders(s string, values map[string]string) string {
+	return placeholderRe.ReplaceAllStringFunc(s, func(m string) string {
+		return values[placeholderRe.FindStringSubmatch(m)[1]]
+	})
+}
+
+// missingVariables lists the placeholders used by the template that have no
+// value, sorted and without duplicates.
+func missingVariables(template *db.Template, values map[string]string) []string {
+	seen := map[string]bool{}
+	var walk func(tasks []db.TemplateTask)
+	check := func(s string) {
+		for _, m := range placeholderRe.FindAllStringSubmatch(s, -1) {
+			if _, ok := values[m[1]]; !ok {
+				seen[m[1]] = true
+			}
+		}
+	}
+	walk = func(tasks []db.TemplateTask) {
+		for _, t := range tasks {
+			check(t.Title)
+			check(t.Text)
+			check(t.Project)
+			for _, item := range t.Checklist {
+				check(item)
+			}
+			walk(t.Subtasks)
+		}
+	}
+	walk(template.Tasks)
+
+	missing := make([]string, 0, len(seen))
+	for name := range seen {
+		missing = append(missing, name)
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// parseOffset parses relative offsets such as "3d", "2w" or "1d12h".
+func parseOffset(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if offsetRe.ReplaceAllString(s, "") != "" {
+		return 0, ErrInvalidOffset
+	}
+	var d time.Duration
+	for _, m := range offsetRe.FindAllStringSubmatch(s, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return 0, ErrInvalidOffset
+		}
+		unit := map[string]time.Duration{
+			"w": 7 * 24 * time.Hour,
+			"d": 24 * time.Hour,
+			"h": time.Hour,
+			"m": time.Minute,
+		}[m[2]]
+		d += time.Duration(n) * unit
+	}
+	return d, nil
+}
+
+func checkTemplateOffsets(tasks []db.TemplateTask) error {
+	for _, t := range tasks {
+		if _, err := parseOffset(t.DueIn); err != nil {
+			return fmt.Errorf("%w: %q", ErrInvalidOffset, t.DueIn)
+		}
+		if err := checkTemplateOffsets(t.Subtasks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func buildTemplateTasks(templates []db.TemplateTask, username string, values map[string]string, base time.Time, parent *uuid.UUID) ([]*db.Task, error) {
+	tasks := []*db.Task{}
+	now := time.Now()
+	for _, tt := range templates {
+		t := &db.Task{
+			ID:        uuid.New(),
+			Title:     expandPlaceholders(tt.Title, values),
+			User:      username,
+			Text:      expandPlaceholders(tt.Text, values),
+			Project:   expandPlaceholders(tt.Project, values),
+			Status:    db.StatusTodo,
+			ParentID:  parent,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if tt.DueIn != "" {
+			offset, err := parseOffset(tt.DueIn)
+			if err != nil {
+				return nil, ErrInvalidOffset
+			}
+			due := base.Add(offset)
+			t.Due = &due
+		}
+		for _, item := range tt.Checklist {
+			t.Checklist = append(t.Checklist, db.ChecklistItem{
+				ID:        uuid.New(),
+				Text:      expandPlaceholders(item, values),
+				CreatedAt: now,
+			})
+		}
+		tasks = append(tasks, t)
+
+		subtasks, err := buildTemplateTasks(tt.Subtasks, username, values, base, &t.ID)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, subtasks...)
+	}
+	return tasks, nil
+}
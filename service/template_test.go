@@ -0,0 +1,53 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"tasks/db"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOffset(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"":      0,
+		"3d":    72 * time.Hour,
+		"2w":    14 * 24 * time.Hour,
+		"1d12h": 36 * time.Hour,
+		"90m":   90 * time.Minute,
+	} {
+		got, err := parseOffset(in)
+		require.NoError(t, err, in)
+		require.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"3", "d", "3x", "-1d", "1d 2h"} {
+		_, err := parseOffset(in)
+		require.ErrorIs(t, err, ErrInvalidOffset, in)
+	}
+}
+
+func TestBuildTemplateTasks(t *testing.T) {
+	base := time.Date(2023, 4, 5, 9, 0, 0, 0, time.UTC)
+	template := &db.Template{Tasks: []db.TemplateTask{{
+		Title:     "Onboard {{name}}",
+		DueIn:     "1w",
+		Checklist: []string{"Laptop for {{ name }}"},
+		Subtasks:  []db.TemplateTask{{Title: "Accounts on {{date}}", DueIn: "1d"}},
+	}}}
+	values := map[string]string{"date": "2023-04-05"}
+
+	require.Equal(t, []string{"name"}, missingVariables(template, values))
+	values["name"] = "Alice"
+	require.Empty(t, missingVariables(template, values))
+
+	tasks, err := buildTemplateTasks(template.Tasks, "bob", values, base, nil)
+	require.NoError(t, err)
+	require.Len(t, tasks, 2)
+	require.Equal(t, "Onboard Alice", tasks[0].Title)
+	require.Equal(t, "Laptop for Alice", tasks[0].Checklist[0].Text)
+	require.Equal(t, base.AddDate(0, 0, 7), *tasks[0].Due)
+	require.Equal(t, "Accounts on 2023-04-05", tasks[1].Title)
+	require.Equal(t, tasks[0].ID, *tasks[1].ParentID)
+	require.Equal(t, base.AddDate(0, 0, 1), *tasks[1].Due)
+}
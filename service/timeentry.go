@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"tasks/db"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTimerRunning     = newError(KindConflict, "a timer is already running")
+	ErrTimerNotRunning  = newError(KindConflict, "no timer is running")
+	ErrInvalidTimeRange = newError(KindInvalid, "time range is invalid")
+)
+
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+
+	GroupByTask    = "task"
+	GroupByProject = "project"
+)
+
+type TimesheetQuery struct {
+	From     time.Time
+	To       time.Time
+	Period   string
+	GroupBy  string
+	Location *time.Location
+}
+
+type TimesheetRow struct {
+	Period  string `json:"period"`
+	Group   string `json:"group"`
+	Title   string `json:"title"`
+	Seconds int64  `json:"seconds"`
+}
+
+func timeEntryError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, db.ErrNoRows), errors.Is(err, db.ErrTimeEntryNotFound):
+		return ErrNotFound
+	case errors.Is(err, db.ErrTimerRunning):
+		return ErrTimerRunning
+	case errors.Is(err, db.ErrTimerNotRunning):
+		return ErrTimerNotRunning
+	default:
+		return ErrDBInternal
+	}
+}
+
+func (s *task) StartTimer(ctx context.Context, username string, taskID uuid.UUID, note string) (*db.TimeEntry, error) {
+	now := time.Now()
+	entry := &db.TimeEntry{
+		ID:        uuid.New(),
+		TaskID:    taskID,
+		User:      username,
+		Start:     now,
+		Note:      note,
+		CreatedAt: now,
+	}
+	if err := s.db.StartTimer(entry); err != nil {
+		return nil, timeEntryError(err)
+	}
+	return entry, nil
+}
+
+func (s *task) StopTimer(ctx context.Context, username string) (*db.TimeEntry, error) {
+	entry, err := s.db.StopTimer(username, time.Now())
+	return entry, timeEntryError(err)
+}
+
+func (s *task) GetRunningTimer(ctx context.Context, username string) (*db.TimeEntry, error) {
+	entry, err := s.db.GetRunningTimer(username)
+	return entry, timeEntryError(err)
+}
+
+func (s *task) AddTimeEntry(ctx context.Context, username string, taskID uuid.UUID, start time.Time, end time.Time, note string) (*db.TimeEntry, error) {
+	if !end.After(start) || end.After(time.Now()) {
+		return nil, ErrInvalidTimeRange
+	}
+	entry := &db.TimeEntry{
+		ID:        uuid.New(),
+		TaskID:    taskID,
+		User:      username,
+		Start:     start,
+		End:       &end,
+		Note:      note,
+		CreatedAt: time.Now(),
+	}
+	if err := s.db.CreateTimeEntry(entry); err != nil {
+		return nil, timeEntryError(err)
+	}
+	return entry, nil
+}
+
+func (s *task) GetTimeEntries(ctx context.Context, username string, taskID uuid.UUID) ([]db.TimeEntry, error) {
+	entries, err := s.db.GetTimeEntries(username, taskID, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, timeEntryError(err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Start.Before(entries[j].Start) })
+	return entries, nil
+}
+
+// GetTimeEntriesByTask returns the time entries of the tasks of the user in
+// chronological order, by task, reading the entries once.
+func (s *task) GetTimeEntriesByTask(ctx context.Context, username string, taskIDs []uuid.UUID) (map[uuid.UUID][]db.TimeEntry, error) {
+	entries, err := s.db.GetTimeEntries(username, uuid.Nil, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, timeEntryError(err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Start.Before(entries[j].Start) })
+	byTask := make(map[uuid.UUID][]db.TimeEntry, len(taskIDs))
+	for _, id := range taskIDs {
+		byTask[id] = []db.TimeEntry{}
+	}
+	for _, entry := range entries {
+		if list, ok := byTask[entry.TaskID]; ok {
+			byTask[entry.TaskID] = append(list, entry)
+		}
+	}
+	return byTask, nil
+}
+
+func (s *task) DeleteTimeEntry(ctx context.Context, username string, id uuid.UUID) error {
+	return timeEntryError(s.db.DeleteTimeEntry(username, id))
+}
+
+// Timesheet aggregates the time entries of the user within the query range.
+func (s *task) Timesheet(ctx context.Context, username string, q TimesheetQuery) ([]TimesheetRow, error) {
+	if !q.From.IsZero() && !q.To.IsZero() && !q.To.After(q.From) {
+		return nil, ErrInvalidTimeRange
+	}
+	entries, err := s.db.GetTimeEntries(username, uuid.Nil, q.From, q.To)
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+	tasks, err := s.db.GetAllTasksFromUser(ctx, username)
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+	byID := make(map[uuid.UUID]*db.Task, len(tasks))
+	for i := range tasks {
+		byID[tasks[i].ID] = &tasks[i]
+	}
+	return buildTimesheet(entries, byID, q, time.Now()), nil
+}
+
+// periodStart truncates t to the start of its day, ISO week or month in loc.
+func periodStart(t time.Time, period string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	switch period {
+	case PeriodWeek:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	case PeriodMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	default:
+		return day
+	}
+}
+
+// nextPeriod returns the start of the period following the one starting at
+// start.
+func nextPeriod(start time.Time, period string) time.Time {
+	switch period {
+	case PeriodWeek:
+		return start.AddDate(0, 0, 7)
+	case PeriodMonth:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// buildTimesheet sums the entries per period and group. Entries are clipped
+// to the query range and attributed to the period in which they start.
+func buildTimesheet(entries []db.TimeEntry, tasks map[uuid.UUID]*db.Task, q TimesheetQuery, now time.Time) []TimesheetRow {
+	loc := q.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	type key struct {
+		period string
+		group  string
+	}
+	rows := map[key]*TimesheetRow{}
+
+	for _, e := range entries {
+		start, end := e.Start, now
+		if e.End != nil {
+			end = *e.End
+		}
+		if !q.From.IsZero() && start.Before(q.From) {
+			start = q.From
+		}
+		if !q.To.IsZero() && end.After(q.To) {
+			end = q.To
+		}
+		if !end.After(start) {
+			continue
+		}
+
+		group, title := e.TaskID.String(), "(deleted task)"
+		t, ok := tasks[e.TaskID]
+		if ok {
+			title = t.Title
+		}
+		if q.GroupBy == GroupByProject {
+			group, title = "", "(no project)"
+			if ok && t.Project != "" {
+				group, title = t.Project, t.Project
+			}
+		}
+
+		k := key{periodStart(start, q.Period, loc).Format("2006-01-02"), group}
+		row, ok := rows[k]
+		if !ok {
+			row = &TimesheetRow{Period: k.period, Group: group, Title: title}
+			rows[k] = row
+		}
+		row.Seconds += int64(end.Sub(start) / time.Second)
+	}
+
+	result := make([]TimesheetRow, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, *row)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Period != result[j].Period {
+			return result[i].Period < result[j].Period
+		}
+		return result[i].Title < result[j].Title
+	})
+	return result
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"tasks/db"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildTimesheet(t *testing.T) {
+	day := time.Date(2023, 4, 5, 0, 0, 0, 0, time.UTC) // a Wednesday
+	a := &db.Task{ID: uuid.New(), Title: "Alpha", Project: "acme"}
+	b := &db.Task{ID: uuid.New(), Title: "Beta", Project: "acme"}
+	tasks := map[uuid.UUID]*db.Task{a.ID: a, b.ID: b}
+
+	entry := func(task *db.Task, start time.Time, d time.Duration) db.TimeEntry {
+		end := start.Add(d)
+		return db.TimeEntry{TaskID: task.ID, Start: start, End: &end}
+	}
+	entries := []db.TimeEntry{
+		entry(a, day.Add(9*time.Hour), time.Hour),
+		entry(a, day.Add(13*time.Hour), 30*time.Minute),
+		entry(b, day.Add(24*time.Hour+9*time.Hour), 2*time.Hour),
+	}
+
+	t.Run("by day and task", func(t *testing.T) {
+		rows := buildTimesheet(entries, tasks, TimesheetQuery{Period: PeriodDay, GroupBy: GroupByTask}, day)
+		require.Equal(t, []TimesheetRow{
+			{Period: "2023-04-05", Group: a.ID.String(), Title: "Alpha", Seconds: 5400},
+			{Period: "2023-04-06", Group: b.ID.String(), Title: "Beta", Seconds: 7200},
+		}, rows)
+	})
+	t.Run("by week and project", func(t *testing.T) {
+		rows := buildTimesheet(entries, tasks, TimesheetQuery{Period: PeriodWeek, GroupBy: GroupByProject}, day)
+		require.Equal(t, []TimesheetRow{
+			{Period: "2023-04-03", Group: "acme", Title: "acme", Seconds: 12600},
+		}, rows)
+	})
+	t.Run("by month and project", func(t *testing.T) {
+		late := append(entries, entry(b, day.AddDate(0, 1, 0), time.Hour))
+		rows := buildTimesheet(late, tasks, TimesheetQuery{Period: PeriodMonth, GroupBy: GroupByProject}, day)
+		require.Equal(t, []TimesheetRow{
+			{Period: "2023-04-01", Group: "acme", Title: "acme", Seconds: 12600},
+			{Period: "2023-05-01", Group: "acme", Title: "acme", Seconds: 3600},
+		}, rows)
+	})
+	t.Run("clips to range", func(t *testing.T) {
+		q := TimesheetQuery{From: day.Add(9*time.Hour + 30*time.Minute), To: day.Add(24 * time.Hour), GroupBy: GroupByTask}
+		rows := buildTimesheet(entries, tasks, q, day)
+		require.Len(t, rows, 1)
+		require.Equal(t, int64(3600), rows[0].Seconds)
+	})
+}
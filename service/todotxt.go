@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"tasks/db"
+	"tasks/lib"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidTodoTxt = lib.ErrInvalidTodoTxt
+
+// TodoTxtImportResult reports what a todo.txt import did. Invalid lists the
+// lines that could not be imported.
+type TodoTxtImportResult struct {
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Invalid []TodoTxtProblem `json:"invalid"`
+}
+
+type TodoTxtProblem struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+func sameDate(a time.Time, b time.Time) bool {
+	return a.UTC().Format("2006-01-02") == b.UTC().Format("2006-01-02")
+}
+
+// mergeTodoTxtItem copies what a todo.txt line carries onto the task. The
+// line holds dates only, so creation and completion times are kept when the
+// date did not change. Pairs naming a custom field of the project set it;
+// the other pairs are preserved as extensions.
+func mergeTodoTxtItem(t *db.Task, item *lib.TodoTxtItem, defs map[string]*db.FieldDefinition, now time.Time) {
+	from := &item.Task
+	t.Title = from.Title
+	t.Project = from.Project
+	t.Priority = from.Priority
+	t.Tags = from.Tags
+	t.Due = from.Due
+	t.DeferUntil = from.DeferUntil
+	t.Recurrence = from.Recurrence
+	t.Someday = from.Someday
+	if !from.CreatedAt.IsZero() && !sameDate(t.CreatedAt, from.CreatedAt) {
+		t.CreatedAt = from.CreatedAt
+	}
+	t.SetStatus(from.Status, now)
+	if from.CompletedAt != nil && t.CompletedAt != nil && !sameDate(*t.CompletedAt, *from.CompletedAt) {
+		t.CompletedAt = from.CompletedAt
+	}
+
+	t.Fields, t.Extensions = nil, nil
+	for key, raw := range item.Values {
+		if def, ok := defs[key]; ok {
+			if v, err := convertFieldValue(def, lib.TodoTxtUnescape(raw)); err == nil {
+				if t.Fields == nil {
+					t.Fields = map[string]interface{}{}
+				}
+				t.Fields[key] = v
+				continue
+			}
+		}
+		if t.Extensions == nil {
+			t.Extensions = map[string]string{}
+		}
+		t.Extensions[key] = raw
+	}
+	t.UpdatedAt = now
+}
+
+// ImportTodoTxt upserts the lines of a todo.txt file into the tasks of the
+// user. A line with the uuid of a task of the user updates it, keeping its
+// text; other lines create tasks. All changes are committed together.
+func (s *task) ImportTodoTxt(ctx context.Context, username string, r io.Reader) (*TodoTxtImportResult, error) {
+	items, err := lib.ParseTodoTxt(r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &TodoTxtImportResult{Invalid: []TodoTxtProblem{}}
+	validate := validator.New()
+	now := time.Now()
+	var created []*db.Task
+	var befores, afters []*db.TaskSummary
+	var updated []uuid.UUID
+
+	err = s.db.InTransaction(username, func(tx *db.TaskTx) error {
+		defs := map[string]map[string]*db.FieldDefinition{}
+		seen := map[uuid.UUID]int{}
+		for i := range items {
+			item := &items[i]
+			invalid := func(reason string) {
+				result.Invalid = append(result.Invalid, TodoTxtProblem{Line: item.Line, Reason: reason})
+			}
+			t := &db.Task{ID: uuid.New(), User: username, Status: db.StatusTodo, CreatedAt: now}
+			var before *db.TaskSummary
+			if id := item.Task.ID; id != uuid.Nil {
+				if line, ok := seen[id]; ok {
+					invalid(fmt.Sprintf("same uuid as line %d", line))
+					continue
+				}
+				seen[id] = item.Line
+				existing, err := tx.GetTask(id)
+				switch {
+				case errors.Is(err, db.ErrNoRows):
+				case err != nil:
+					return err
+				default:
+					t, before = existing, existing.Summary()
+				}
+			}
+
+			project := item.Task.Project
+			if _, ok := defs[project]; !ok {
+				list, err := tx.GetFieldDefinitions(project)
+				if err != nil {
+					return err
+				}
+				defs[project] = fieldDefinitionsByKey(list)
+			}
+			mergeTodoTxtItem(t, item, defs[project], now)
+			if err := validate.Struct(t); err != nil {
+				invalid(fmt.Sprintf("%v: %v", ErrInvalidTask, err))
+				continue
+			}
+
+			if before == nil {
+				if err := tx.CreateTask(t); err != nil {
+					return err
+				}
+				created = append(created, t)
+				continue
+			}
+			stored, err := tx.UpdateTask(t.ID, func(stored *db.Task) error {
+				*stored = *t
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			befores, afters = append(befores, before), append(afters, stored.Summary())
+			updated = append(updated, t.ID)
+		}
+		return auditImportTx(ctx, tx, username, created, updated, befores, afters)
+	})
+	if err != nil {
+		return nil, ErrDBInternal
+	}
+
+	result.Created, result.Updated = len(created), len(updated)
+	return result, nil
+}
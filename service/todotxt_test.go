@@ -0,0 +1,54 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"tasks/db"
+	"tasks/lib"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeTodoTxtItem(t *testing.T) {
+	created := time.Date(2023, 4, 1, 9, 30, 0, 0, time.UTC)
+	completed := time.Date(2023, 4, 3, 18, 0, 0, 0, time.UTC)
+	now := time.Date(2023, 4, 5, 15, 0, 0, 0, time.UTC)
+	defs := map[string]*db.FieldDefinition{
+		"points": {Key: "points", Type: db.FieldNumber},
+		"room":   {Key: "room", Type: db.FieldText},
+	}
+	task := db.Task{
+		Title: "Call mum", Text: "Ask about Sunday", Project: "family", Status: db.StatusDone, Tags: []string{"phone"},
+		CreatedAt: created, CompletedAt: &completed, Fields: map[string]interface{}{"points": 1.0, "room": "hall"},
+	}
+
+	t.Run("same dates", func(t *testing.T) {
+		got := task
+		item := lib.ParseTodoTxtLine("x 2023-04-03 2023-04-01 Call mum soon +family @phone points:2.5 points2:x room:living%20room h:10:30")
+		mergeTodoTxtItem(&got, &item, defs, now)
+		require.Equal(t, "Call mum soon", got.Title)
+		require.Equal(t, "Ask about Sunday", got.Text)
+		require.Equal(t, created, got.CreatedAt)
+		require.Equal(t, &completed, got.CompletedAt)
+		require.Equal(t, map[string]interface{}{"points": 2.5, "room": "living room"}, got.Fields)
+		require.Equal(t, map[string]string{"points2": "x", "h": "10:30"}, got.Extensions)
+		require.Equal(t, now, got.UpdatedAt)
+	})
+
+	t.Run("changed dates", func(t *testing.T) {
+		got := task
+		item := lib.ParseTodoTxtLine("x 2023-04-04 2023-03-31 Call mum points:lots")
+		mergeTodoTxtItem(&got, &item, defs, now)
+		require.Equal(t, time.Date(2023, 3, 31, 0, 0, 0, 0, time.UTC), got.CreatedAt)
+		require.Equal(t, time.Date(2023, 4, 4, 0, 0, 0, 0, time.UTC), *got.CompletedAt)
+		require.Empty(t, got.Project)
+		require.Nil(t, got.Fields)
+		require.Equal(t, map[string]string{"points": "lots"}, got.Extensions)
+
+		item = lib.ParseTodoTxtLine("Call mum")
+		mergeTodoTxtItem(&got, &item, defs, now)
+		require.Equal(t, db.StatusTodo, got.Status)
+		require.Nil(t, got.CompletedAt)
+	})
+}
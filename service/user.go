@@ -2,14 +2,18 @@ package service
 
 import (
 	"context"
+	"errors"
 
 	"tasks/db"
 )
 
 func (s *task) RegisterUser(ctx context.Context, args *db.User) (string, error) {
 	err := s.db.CreateUser(args)
-	if err != nil {
-		return "", err
+	switch {
+	case errors.Is(err, db.ErrUserAlreadyExists):
+		return "", ErrUserAlreadyExists
+	case err != nil:
+		return "", ErrDBInternal
 	}
 	return args.Username, nil
 
@@ -17,8 +21,11 @@ func (s *task) RegisterUser(ctx context.Context, args *db.User) (string, error)
 
 func (s *task) GetUser(ctx context.Context, username string) (*db.User, error) {
 	user, err := s.db.GetUser(username)
-	if err != nil {
-		return nil, err
+	switch {
+	case errors.Is(err, db.ErrUserNotFound):
+		return nil, ErrUserNotFound
+	case err != nil:
+		return nil, ErrDBInternal
 	}
 	return user, nil
 }